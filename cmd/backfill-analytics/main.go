@@ -0,0 +1,300 @@
+// Lệnh chạy một lần: dựng lại listening_analytics, podcast_analytics và subject_analytics từ lịch sử nghe,
+// chia ngày theo múi giờ của trường (INSTITUTION_TZ). Dùng sau khi sửa lỗi chia ngày theo UTC.
+//
+//	go run ./cmd/backfill-analytics -from=2025-01-01 -to=2025-06-30 [-tz=Asia/Ho_Chi_Minh] [-dry-run] [-allow-lossy]
+//
+// Lượt phát trong ngày = cặp (user, podcast) có nghe trong ngày đó, lấy từ sự kiện phát
+// cùng thời điểm nghe đầu / cuối trong listening_histories. Ngày cũ hơn thời hạn lưu sự kiện
+// (LISTENING_EVENT_RETENTION_DAYS) chỉ còn hai mốc này và mất thời gian nghe, nên lệnh từ chối
+// ghi đè các ngày đó trừ khi có -allow-lossy.
+package main
+
+import (
+	"flag"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joho/godotenv"
+	"gorm.io/gorm"
+
+	"github.com/vnkhanh/e-podcast-backend/config"
+	"github.com/vnkhanh/e-podcast-backend/models"
+	"github.com/vnkhanh/e-podcast-backend/utils"
+)
+
+// dayTotals là số liệu của một dòng thống kê (một ngày, hoặc một ngày của podcast / môn học)
+type dayTotals struct {
+	date      time.Time
+	plays     int64
+	completed int64
+	duration  int64
+	sketch    *utils.HLLSketch
+}
+
+type dayKey struct {
+	day string
+	id  uuid.UUID
+}
+
+type rollup struct {
+	global   map[string]*dayTotals
+	podcasts map[dayKey]*dayTotals
+	subjects map[dayKey]*dayTotals
+}
+
+func newDayTotals(date time.Time) *dayTotals {
+	return &dayTotals{date: date, sketch: utils.NewHLLSketch()}
+}
+
+func (r *rollup) globalDay(date time.Time) *dayTotals {
+	key := date.Format("2006-01-02")
+	if r.global[key] == nil {
+		r.global[key] = newDayTotals(date)
+	}
+	return r.global[key]
+}
+
+func (r *rollup) podcastDay(date time.Time, podcastID uuid.UUID) *dayTotals {
+	key := dayKey{date.Format("2006-01-02"), podcastID}
+	if r.podcasts[key] == nil {
+		r.podcasts[key] = newDayTotals(date)
+	}
+	return r.podcasts[key]
+}
+
+// subjectDay trả nil với podcast chưa gắn môn học
+func (r *rollup) subjectDay(date time.Time, subjectID *uuid.UUID) *dayTotals {
+	if subjectID == nil || *subjectID == uuid.Nil {
+		return nil
+	}
+	key := dayKey{date.Format("2006-01-02"), *subjectID}
+	if r.subjects[key] == nil {
+		r.subjects[key] = newDayTotals(date)
+	}
+	return r.subjects[key]
+}
+
+type playDay struct {
+	UserID    uuid.UUID
+	PodcastID uuid.UUID
+	SubjectID *uuid.UUID
+	Day       time.Time
+}
+
+type dayCount struct {
+	PodcastID uuid.UUID
+	SubjectID *uuid.UUID
+	Day       time.Time
+	Total     int64
+}
+
+func collect(db *gorm.DB, tz string, from, to time.Time) (*rollup, error) {
+	r := &rollup{global: map[string]*dayTotals{}, podcasts: map[dayKey]*dayTotals{}, subjects: map[dayKey]*dayTotals{}}
+
+	// 1. Lượt phát + người nghe không trùng
+	rows, err := db.Raw(`
+		WITH play_days AS (
+			SELECT user_id, podcast_id, (occurred_at AT TIME ZONE $1)::date AS day FROM listening_events
+			UNION
+			SELECT user_id, podcast_id, (first_listened_at AT TIME ZONE $1)::date FROM listening_histories
+			UNION
+			SELECT user_id, podcast_id, (last_listened_at AT TIME ZONE $1)::date FROM listening_histories
+		)
+		SELECT pd.user_id, pd.podcast_id, ch.subject_id, pd.day
+		FROM play_days pd
+		JOIN podcasts p ON p.id = pd.podcast_id
+		LEFT JOIN chapters ch ON ch.id = p.chapter_id
+		WHERE pd.day BETWEEN $2 AND $3
+	`, tz, from, to).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var pd playDay
+		if err := db.ScanRows(rows, &pd); err != nil {
+			return nil, err
+		}
+		targets := []*dayTotals{r.globalDay(pd.Day), r.podcastDay(pd.Day, pd.PodcastID)}
+		if s := r.subjectDay(pd.Day, pd.SubjectID); s != nil {
+			targets = append(targets, s)
+		}
+		for _, t := range targets {
+			t.plays++
+			t.sketch.Add(pd.UserID[:])
+		}
+	}
+
+	// 2. Lượt nghe hết: mỗi (user, podcast) chỉ tính một lần, vào ngày hoàn thành
+	var completions []dayCount
+	if err := db.Raw(`
+		SELECT lh.podcast_id, ch.subject_id, (lh.completed_at AT TIME ZONE $1)::date AS day, COUNT(*) AS total
+		FROM listening_histories lh
+		JOIN podcasts p ON p.id = lh.podcast_id
+		LEFT JOIN chapters ch ON ch.id = p.chapter_id
+		WHERE lh.completed AND lh.completed_at IS NOT NULL
+			AND (lh.completed_at AT TIME ZONE $1)::date BETWEEN $2 AND $3
+		GROUP BY 1, 2, 3
+	`, tz, from, to).Scan(&completions).Error; err != nil {
+		return nil, err
+	}
+	for _, c := range completions {
+		r.globalDay(c.Day).completed += c.Total
+		r.podcastDay(c.Day, c.PodcastID).completed += c.Total
+	}
+
+	// 3. Thời gian nghe thực từ sự kiện phát
+	var durations []dayCount
+	if err := db.Raw(`
+		SELECT e.podcast_id, ch.subject_id, (e.occurred_at AT TIME ZONE $1)::date AS day, SUM(e.listened_seconds) AS total
+		FROM listening_events e
+		JOIN podcasts p ON p.id = e.podcast_id
+		LEFT JOIN chapters ch ON ch.id = p.chapter_id
+		WHERE (e.occurred_at AT TIME ZONE $1)::date BETWEEN $2 AND $3
+		GROUP BY 1, 2, 3
+	`, tz, from, to).Scan(&durations).Error; err != nil {
+		return nil, err
+	}
+	for _, d := range durations {
+		r.globalDay(d.Day).duration += d.Total
+		r.podcastDay(d.Day, d.PodcastID).duration += d.Total
+		if s := r.subjectDay(d.Day, d.SubjectID); s != nil {
+			s.duration += d.Total
+		}
+	}
+
+	return r, nil
+}
+
+// write xóa các dòng thống kê trong khoảng rồi ghi lại từ số liệu đã dựng
+func write(tx *gorm.DB, r *rollup, from, to time.Time) error {
+	for _, model := range []interface{}{&models.ListeningAnalytics{}, &models.PodcastAnalytics{}, &models.SubjectAnalytics{}} {
+		if err := tx.Where("date BETWEEN ? AND ?", from, to).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+
+	global := make([]models.ListeningAnalytics, 0, len(r.global))
+	for _, t := range r.global {
+		global = append(global, models.ListeningAnalytics{
+			Date:             t.date,
+			TotalListens:     t.plays,
+			UniqueUsers:      t.sketch.Estimate(),
+			CompletedListens: t.completed,
+			TotalDuration:    t.duration,
+			ListenerSketch:   t.sketch.Bytes(),
+		})
+	}
+	podcasts := make([]models.PodcastAnalytics, 0, len(r.podcasts))
+	for key, t := range r.podcasts {
+		podcasts = append(podcasts, models.PodcastAnalytics{
+			Date:            t.date,
+			PodcastID:       key.id,
+			TotalPlays:      t.plays,
+			UniqueListeners: t.sketch.Estimate(),
+			CompletedPlays:  t.completed,
+			TotalDuration:   t.duration,
+			ListenerSketch:  t.sketch.Bytes(),
+		})
+	}
+	subjects := make([]models.SubjectAnalytics, 0, len(r.subjects))
+	for key, t := range r.subjects {
+		subjects = append(subjects, models.SubjectAnalytics{
+			Date:            t.date,
+			SubjectID:       key.id,
+			TotalPlays:      t.plays,
+			UniqueListeners: t.sketch.Estimate(),
+			TotalDuration:   t.duration,
+			ListenerSketch:  t.sketch.Bytes(),
+		})
+	}
+
+	if len(global) > 0 {
+		if err := tx.CreateInBatches(global, 200).Error; err != nil {
+			return err
+		}
+	}
+	if len(podcasts) > 0 {
+		if err := tx.CreateInBatches(podcasts, 200).Error; err != nil {
+			return err
+		}
+	}
+	if len(subjects) > 0 {
+		if err := tx.CreateInBatches(subjects, 200).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func main() {
+	fromStr := flag.String("from", "", "Ngày bắt đầu (YYYY-MM-DD), bắt buộc")
+	toStr := flag.String("to", "", "Ngày kết thúc (YYYY-MM-DD), bắt buộc")
+	tzName := flag.String("tz", "", "Múi giờ chia ngày, mặc định INSTITUTION_TZ")
+	dryRun := flag.Bool("dry-run", false, "Chỉ tính và in số liệu, không ghi vào database")
+	allowLossy := flag.Bool("allow-lossy", false, "Cho phép ghi đè các ngày cũ hơn thời hạn lưu sự kiện nghe")
+	flag.Parse()
+
+	if *fromStr == "" || *toStr == "" {
+		flag.Usage()
+		log.Fatal("Cần chỉ rõ -from và -to")
+	}
+
+	if err := godotenv.Load(); err != nil {
+		log.Println("Không tìm thấy file .env, dùng biến môi trường hiện có")
+	}
+
+	if *tzName != "" {
+		loc, err := time.LoadLocation(*tzName)
+		if err != nil {
+			log.Fatalf("Múi giờ không hợp lệ: %v", err)
+		}
+		utils.SetInstitutionLocation(loc)
+	}
+	loc := utils.InstitutionLocation()
+
+	from, err := time.ParseInLocation("2006-01-02", *fromStr, loc)
+	if err != nil {
+		log.Fatalf("from không hợp lệ: %v", err)
+	}
+	to, err := time.ParseInLocation("2006-01-02", *toStr, loc)
+	if err != nil {
+		log.Fatalf("to không hợp lệ: %v", err)
+	}
+	if to.Before(from) {
+		log.Fatal("Khoảng thời gian không hợp lệ")
+	}
+
+	// Sự kiện nghe cũ đã bị dọn: dựng lại các ngày đó sẽ làm mất thời gian nghe và lượt phát đã có
+	retentionDays := utils.ListeningEventRetentionDays()
+	oldest := utils.AnalyticsDay(time.Now().AddDate(0, 0, -retentionDays))
+	if from.Before(oldest) && !*allowLossy && !*dryRun {
+		log.Fatalf("Ngày %s cũ hơn thời hạn lưu sự kiện nghe (%d ngày, từ %s), dùng -allow-lossy nếu chắc chắn muốn ghi đè",
+			from.Format("2006-01-02"), retentionDays, oldest.Format("2006-01-02"))
+	}
+
+	db, err := config.ConnectDatabase()
+	if err != nil {
+		log.Fatalf("Không thể kết nối database: %v", err)
+	}
+
+	log.Printf("Dựng lại thống kê từ %s đến %s theo múi giờ %s", from.Format("2006-01-02"), to.Format("2006-01-02"), loc)
+	r, err := collect(db, loc.String(), from, to)
+	if err != nil {
+		log.Fatalf("Không thể đọc lịch sử nghe: %v", err)
+	}
+	log.Printf("Số dòng: %d ngày, %d podcast-ngày, %d môn học-ngày", len(r.global), len(r.podcasts), len(r.subjects))
+
+	if *dryRun {
+		log.Println("dry-run: không ghi vào database")
+		return
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		return write(tx, r, from, to)
+	}); err != nil {
+		log.Fatalf("Không thể ghi thống kê: %v", err)
+	}
+	log.Println("Hoàn tất")
+}
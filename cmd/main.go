@@ -10,6 +10,7 @@ import (
 	"github.com/joho/godotenv"
 
 	"github.com/vnkhanh/e-podcast-backend/config"
+	"github.com/vnkhanh/e-podcast-backend/controllers"
 	"github.com/vnkhanh/e-podcast-backend/routes"
 	"github.com/vnkhanh/e-podcast-backend/utils"
 )
@@ -25,6 +26,8 @@ func main() {
 	r := gin.Default()
 	// Khởi động Cleanup Job
 	utils.StartCleanupJob()
+	// Tự nộp bài tập đã hết giờ
+	controllers.StartAssignmentSweeper(config.DB)
 
 	// Bật CORS
 	origin := os.Getenv("CORS_ORIGIN")
@@ -37,7 +40,7 @@ func main() {
 		AllowOrigins:     allowOrigins,
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"*"},  
-		ExposeHeaders:    []string{"Content-Length"},
+		ExposeHeaders:    []string{"Content-Length", "Content-Disposition", "X-Skipped-Questions", "Retry-After", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset"},
 		AllowCredentials: true,
 		AllowWebSockets:  true,
 		MaxAge:           12 * time.Hour,
@@ -69,6 +69,18 @@ func InitDB() {
 	sqlDB.SetConnMaxLifetime(time.Hour)
 	sqlDB.SetConnMaxIdleTime(10 * time.Minute)
 
+	// Token feed trước đây lưu dạng rõ: đổi sang hash SHA-256 (giống utils.HashToken) để link đã phát vẫn dùng được
+	if DB.Migrator().HasColumn("feed_tokens", "token") {
+		if err := DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(`UPDATE feed_tokens SET token = encode(sha256(convert_to(token, 'UTF8')), 'hex')`).Error; err != nil {
+				return err
+			}
+			return tx.Migrator().RenameColumn("feed_tokens", "token", "token_hash")
+		}); err != nil {
+			log.Fatal("Không thể chuyển token feed sang hash: ", err)
+		}
+	}
+
 	// AutoMigrate các models
 	err = DB.AutoMigrate(
 		&models.User{},
@@ -92,12 +104,42 @@ func InitDB() {
 		&models.ListeningAnalytics{},
 		&models.PodcastAnalytics{},
 		&models.SubjectAnalytics{},
+		&models.ListeningEvent{},
+		&models.ListenerCoverage{},
+		&models.PodcastRetention{},
 		&models.PasswordReset{},
 		&models.Assignment{},
 		&models.AssignmentQuestion{},
 		&models.AssignmentOption{},
 		&models.AssignmentSubmission{},
 		&models.AssignmentAnswer{},
+		&models.QuestionBank{},
+		&models.BankQuestion{},
+		&models.BankOption{},
+		&models.AssignmentRule{},
+		&models.SubmissionQuestion{},
+		&models.AssignmentAttemptEvent{},
+		&models.Class{},
+		&models.ClassEnrollment{},
+		&models.AssignmentClass{},
+		&models.GradeCategory{},
+		&models.GradeAdjustment{},
+		&models.AssignmentAccessCode{},
+		&models.AssignmentOverride{},
+		&models.UserSession{},
+		&models.EmailVerification{},
+		&models.TwoFactorRecoveryCode{},
+		&models.LoginChallenge{},
+		&models.SubjectMember{},
+		&models.UserIdentity{},
+		&models.SSOLoginState{},
+		&models.Playlist{},
+		&models.PlaylistItem{},
+		&models.PlaylistClass{},
+		&models.FeedToken{},
+		&models.PodcastShare{},
+		&models.CommentEdit{},
+		&models.CommentReaction{},
 	)
 	if err != nil {
 		log.Fatal("autoMigrate lỗi: ", err)
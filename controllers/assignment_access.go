@@ -0,0 +1,435 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/vnkhanh/e-podcast-backend/models"
+	"github.com/vnkhanh/e-podcast-backend/utils"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// ==================== MẬT KHẨU & MÃ DỰ THI ====================
+
+const (
+	accessCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789" // Bỏ 0/O, 1/I dễ nhầm
+	accessCodeLength   = 10
+	maxAccessCodeBatch = 500
+)
+
+var (
+	errAccessPasswordRequired = errors.New("Vui lòng nhập mật khẩu bài tập")
+	errAccessPasswordInvalid  = errors.New("Mật khẩu không đúng")
+	errAccessCodeRequired     = errors.New("Vui lòng nhập mã dự thi")
+	errAccessCodeInvalid      = errors.New("Mã dự thi không hợp lệ")
+	errAccessCodeUsed         = errors.New("Mã dự thi đã được sử dụng")
+	errAccessCodeNotYetValid  = errors.New("Mã dự thi chưa đến thời gian sử dụng")
+	errAccessCodeExpired      = errors.New("Mã dự thi đã hết hạn")
+	errAccessCodeWrongUser    = errors.New("Mã dự thi không dành cho tài khoản này")
+	errAccessLocked           = errors.New("Nhập sai quá nhiều lần, vui lòng thử lại sau ít phút")
+)
+
+// Nhập sai mật khẩu / mã dự thi 5 lần thì khóa 1 phút, mỗi lần sai tiếp theo gấp đôi
+var assignmentAccessLockout = utils.LockoutPolicy{
+	Threshold: 5,
+	BaseDelay: time.Minute,
+	MaxDelay:  30 * time.Minute,
+	Window:    time.Hour,
+}
+
+// assignmentAccessInput là thông tin sinh viên gửi khi bắt đầu làm bài
+type assignmentAccessInput struct {
+	Password   string `json:"password"`
+	AccessCode string `json:"access_code"`
+}
+
+func requiresAccessCheck(a models.Assignment) bool {
+	return a.HasPassword || a.RequireAccessCode
+}
+
+// hashAssignmentPassword băm mật khẩu bài tập bằng bcrypt
+func hashAssignmentPassword(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
+
+// checkAssignmentPassword so khớp mật khẩu. Mật khẩu cũ còn lưu dạng thường
+// sẽ được băm lại ngay khi khớp.
+func checkAssignmentPassword(db *gorm.DB, assignment *models.Assignment, password string) bool {
+	if assignment.Password == "" || password == "" {
+		return false
+	}
+	if strings.HasPrefix(assignment.Password, "$2") {
+		return bcrypt.CompareHashAndPassword([]byte(assignment.Password), []byte(password)) == nil
+	}
+
+	if assignment.Password != password {
+		return false
+	}
+	if hashed, err := hashAssignmentPassword(password); err == nil {
+		db.Model(&models.Assignment{}).Where("id = ?", assignment.ID).Update("password", hashed)
+		assignment.Password = hashed
+	}
+	return true
+}
+
+// normalizeAccessCode bỏ khoảng trắng, gạch nối và đưa về chữ hoa
+func normalizeAccessCode(code string) string {
+	code = strings.ToUpper(code)
+	return strings.NewReplacer(" ", "", "-", "").Replace(code)
+}
+
+func hashAccessCode(code string) string {
+	sum := sha256.Sum256([]byte(normalizeAccessCode(code)))
+	return hex.EncodeToString(sum[:])
+}
+
+// generateAccessCode sinh mã dạng XXXXX-XXXXX
+func generateAccessCode() (string, error) {
+	b := make([]byte, accessCodeLength)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(accessCodeAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		b[i] = accessCodeAlphabet[n.Int64()]
+	}
+	return string(b[:accessCodeLength/2]) + "-" + string(b[accessCodeLength/2:]), nil
+}
+
+// findUsableAccessCode kiểm tra mã dự thi mà không đánh dấu đã dùng
+func findUsableAccessCode(db *gorm.DB, assignmentID, userID uuid.UUID, code string, now time.Time) (*models.AssignmentAccessCode, error) {
+	if strings.TrimSpace(code) == "" {
+		return nil, errAccessCodeRequired
+	}
+
+	var accessCode models.AssignmentAccessCode
+	if err := db.Where("code_hash = ? AND assignment_id = ?", hashAccessCode(code), assignmentID).
+		First(&accessCode).Error; err != nil {
+		return nil, errAccessCodeInvalid
+	}
+	switch {
+	case accessCode.UsedAt != nil:
+		return nil, errAccessCodeUsed
+	case accessCode.UserID != nil && *accessCode.UserID != userID:
+		return nil, errAccessCodeWrongUser
+	case accessCode.ValidFrom != nil && now.Before(*accessCode.ValidFrom):
+		return nil, errAccessCodeNotYetValid
+	case accessCode.ExpiresAt != nil && now.After(*accessCode.ExpiresAt):
+		return nil, errAccessCodeExpired
+	}
+	return &accessCode, nil
+}
+
+// checkAssignmentAccess kiểm tra mật khẩu và mã dự thi (nếu bài tập yêu cầu)
+// Đoán sai nhiều lần sẽ bị khóa tạm thời theo từng sinh viên + bài tập.
+func checkAssignmentAccess(db *gorm.DB, assignment *models.Assignment, userID uuid.UUID, input assignmentAccessInput, now time.Time) (*models.AssignmentAccessCode, error) {
+	lockKey := "assignment-access:" + assignment.ID.String() + ":" + userID.String()
+	if utils.LockoutRemaining(lockKey) > 0 {
+		return nil, errAccessLocked
+	}
+
+	var code *models.AssignmentAccessCode
+	var err error
+	if assignment.HasPassword {
+		if input.Password == "" {
+			return nil, errAccessPasswordRequired
+		}
+		if !checkAssignmentPassword(db, assignment, input.Password) {
+			err = errAccessPasswordInvalid
+		}
+	}
+	if err == nil && assignment.RequireAccessCode {
+		code, err = findUsableAccessCode(db, assignment.ID, userID, input.AccessCode, now)
+	}
+
+	switch {
+	case errors.Is(err, errAccessPasswordInvalid), errors.Is(err, errAccessCodeInvalid):
+		utils.RegisterFailure(lockKey, assignmentAccessLockout)
+	case err == nil:
+		utils.ClearFailures(lockKey)
+	}
+	return code, err
+}
+
+// bindAccessToSubmission đánh dấu bài làm đã qua kiểm tra và dùng mã dự thi (nếu có).
+// Cập nhật có điều kiện để hai lượt làm không thể dùng chung một mã.
+func bindAccessToSubmission(tx *gorm.DB, submission *models.AssignmentSubmission, code *models.AssignmentAccessCode, now time.Time) error {
+	updates := map[string]interface{}{"access_verified": true}
+	if code != nil {
+		res := tx.Model(&models.AssignmentAccessCode{}).
+			Where("id = ? AND used_at IS NULL", code.ID).
+			Updates(map[string]interface{}{
+				"used_at":       now,
+				"used_by":       submission.UserID,
+				"submission_id": submission.ID,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errAccessCodeUsed
+		}
+		updates["access_code_id"] = code.ID
+		submission.AccessCodeID = &code.ID
+	}
+	submission.AccessVerified = true
+	return tx.Model(&models.AssignmentSubmission{}).Where("id = ?", submission.ID).Updates(updates).Error
+}
+
+// respondAccessError trả lỗi kèm cờ để client biết cần hỏi mật khẩu / mã dự thi
+func respondAccessError(c *gin.Context, assignment models.Assignment, err error) {
+	c.JSON(http.StatusForbidden, gin.H{
+		"error":                err.Error(),
+		"requires_password":    assignment.HasPassword,
+		"requires_access_code": assignment.RequireAccessCode,
+	})
+}
+
+func isAccessError(err error) bool {
+	for _, target := range []error{
+		errAccessPasswordRequired, errAccessPasswordInvalid,
+		errAccessCodeRequired, errAccessCodeInvalid, errAccessCodeUsed,
+		errAccessCodeNotYetValid, errAccessCodeExpired, errAccessCodeWrongUser,
+		errAccessLocked,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// ==================== QUẢN LÝ MÃ DỰ THI ====================
+
+// loadManagedAssignment lấy bài tập và kiểm tra quyền của giảng viên
+func loadManagedAssignment(c *gin.Context, db *gorm.DB) (*models.Assignment, bool) {
+	var assignment models.Assignment
+	if err := db.First(&assignment, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy bài tập"})
+		return nil, false
+	}
+	if !canManageAssignment(c, db, assignment, models.PermAssignmentManage) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Bạn không có quyền quản lý bài tập này"})
+		return nil, false
+	}
+	return &assignment, true
+}
+
+// Tạo hàng loạt mã dự thi. Có class_id thì mỗi sinh viên trong lớp một mã gắn với tài khoản.
+// Mã gốc chỉ trả về một lần trong response này (JSON hoặc CSV).
+// POST /admin/assignments/:id/access-codes?format=json|csv
+func GenerateAssignmentAccessCodes(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	assignment, ok := loadManagedAssignment(c, db)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Count     int        `json:"count"`
+		ClassID   string     `json:"class_id"`
+		ValidFrom *time.Time `json:"valid_from"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.ValidFrom != nil && req.ExpiresAt != nil && !req.ExpiresAt.After(*req.ValidFrom) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at phải sau valid_from"})
+		return
+	}
+
+	// Người nhận mã: sinh viên của lớp, hoặc count mã không gắn tài khoản
+	type recipient struct {
+		UserID *uuid.UUID
+		Label  string
+		Email  string
+	}
+	var recipients []recipient
+	if req.ClassID != "" {
+		class, ok := loadOwnedClass(c, db, req.ClassID)
+		if !ok {
+			return
+		}
+		var enrollments []models.ClassEnrollment
+		db.Preload("User").Where("class_id = ?", class.ID).Find(&enrollments)
+		for _, e := range enrollments {
+			userID := e.UserID
+			recipients = append(recipients, recipient{UserID: &userID, Label: e.User.FullName, Email: e.User.Email})
+		}
+		if len(recipients) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Lớp chưa có sinh viên"})
+			return
+		}
+	} else {
+		if req.Count <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cần count hoặc class_id"})
+			return
+		}
+		recipients = make([]recipient, req.Count)
+	}
+	if len(recipients) > maxAccessCodeBatch {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Tối đa %d mã mỗi lần", maxAccessCodeBatch)})
+		return
+	}
+
+	creatorUUID, _ := uuid.Parse(c.GetString("user_id"))
+	batchID := uuid.New()
+	plainCodes := make([]string, len(recipients))
+	codes := make([]models.AssignmentAccessCode, len(recipients))
+	for i, r := range recipients {
+		code, err := generateAccessCode()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể sinh mã dự thi"})
+			return
+		}
+		label := r.Label
+		if r.Email != "" {
+			label = fmt.Sprintf("%s <%s>", r.Label, r.Email)
+		}
+		plainCodes[i] = code
+		codes[i] = models.AssignmentAccessCode{
+			AssignmentID: assignment.ID,
+			BatchID:      batchID,
+			CodeHash:     hashAccessCode(code),
+			Hint:         code[len(code)-3:],
+			Label:        label,
+			UserID:       r.UserID,
+			ValidFrom:    req.ValidFrom,
+			ExpiresAt:    req.ExpiresAt,
+			CreatedBy:    creatorUUID,
+		}
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.CreateInBatches(&codes, 100).Error; err != nil {
+			return err
+		}
+		// Đã phát mã thì bật yêu cầu mã dự thi cho bài tập
+		return tx.Model(assignment).Update("require_access_code", true).Error
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lưu mã dự thi"})
+		return
+	}
+
+	if c.DefaultQuery("format", "json") == "csv" {
+		formatTime := func(t *time.Time) string {
+			if t == nil {
+				return ""
+			}
+			return t.Format("02/01/2006 15:04")
+		}
+
+		var buf bytes.Buffer
+		buf.WriteString("\uFEFF") // BOM để Excel đọc đúng tiếng Việt
+		w := csv.NewWriter(&buf)
+		w.Write([]string{"STT", "Mã dự thi", "Sinh viên", "Hiệu lực từ", "Hết hạn"})
+		for i, code := range codes {
+			w.Write([]string{fmt.Sprintf("%d", i+1), plainCodes[i], code.Label, formatTime(code.ValidFrom), formatTime(code.ExpiresAt)})
+		}
+		w.Flush()
+
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=Ma_du_thi_%s.csv", time.Now().Format("20060102_150405")))
+		c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+		return
+	}
+
+	result := make([]gin.H, len(codes))
+	for i, code := range codes {
+		result[i] = gin.H{
+			"id":         code.ID,
+			"code":       plainCodes[i],
+			"label":      code.Label,
+			"user_id":    code.UserID,
+			"valid_from": code.ValidFrom,
+			"expires_at": code.ExpiresAt,
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Đã tạo mã dự thi. Mã chỉ hiển thị một lần, hãy lưu lại ngay",
+		"batch_id": batchID,
+		"codes":    result,
+		"total":    len(result),
+	})
+}
+
+// Danh sách mã dự thi (không kèm mã gốc)
+// GET /admin/assignments/:id/access-codes?status=unused|used|expired
+func GetAssignmentAccessCodes(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	assignment, ok := loadManagedAssignment(c, db)
+	if !ok {
+		return
+	}
+
+	query := db.Where("assignment_id = ?", assignment.ID)
+	now := time.Now()
+	switch c.Query("status") {
+	case "unused":
+		query = query.Where("used_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", now)
+	case "used":
+		query = query.Where("used_at IS NOT NULL")
+	case "expired":
+		query = query.Where("used_at IS NULL AND expires_at <= ?", now)
+	}
+
+	var codes []models.AssignmentAccessCode
+	if err := query.Order("created_at DESC, label ASC").Find(&codes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy mã dự thi"})
+		return
+	}
+
+	var used int64
+	db.Model(&models.AssignmentAccessCode{}).Where("assignment_id = ? AND used_at IS NOT NULL", assignment.ID).Count(&used)
+
+	c.JSON(http.StatusOK, gin.H{
+		"require_access_code": assignment.RequireAccessCode,
+		"codes":               codes,
+		"total":               len(codes),
+		"used":                used,
+	})
+}
+
+// Thu hồi mã chưa dùng
+// DELETE /admin/assignments/:id/access-codes/:codeId
+func RevokeAssignmentAccessCode(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	assignment, ok := loadManagedAssignment(c, db)
+	if !ok {
+		return
+	}
+
+	res := db.Where("id = ? AND assignment_id = ? AND used_at IS NULL", c.Param("codeId"), assignment.ID).
+		Delete(&models.AssignmentAccessCode{})
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể thu hồi mã"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Không tìm thấy mã hoặc mã đã được sử dụng"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Đã thu hồi mã dự thi"})
+}
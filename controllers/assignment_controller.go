@@ -1,11 +1,11 @@
 package controllers
 
 import (
-	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"math/rand"
-	"mime/multipart"
 	"net/http"
 	"strconv"
 	"strings"
@@ -74,12 +74,30 @@ func CreateAssignmentFromGemini(c *gin.Context) {
 		req.NumQuestions = 10
 	}
 
+	passwordHash := ""
+	if req.HasPassword {
+		if req.Password == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Vui lòng nhập mật khẩu bài tập"})
+			return
+		}
+		hashed, err := hashAssignmentPassword(req.Password)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể mã hóa mật khẩu"})
+			return
+		}
+		passwordHash = hashed
+	}
+
 	podcastUUID, _ := uuid.Parse(req.PodcastID)
 	var podcast models.Podcast
 	if err := db.Preload("Document").First(&podcast, "id = ?", podcastUUID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy podcast"})
 		return
 	}
+	if !canManagePodcast(c, db, podcast, models.PermAssignmentManage) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Bạn không có quyền tạo bài tập cho podcast này"})
+		return
+	}
 
 	doc := podcast.Document
 	if doc.ExtractedText == "" {
@@ -99,7 +117,7 @@ func CreateAssignmentFromGemini(c *gin.Context) {
 		IsPublished: false,
 		CreatedBy:   userUUID,
 		HasPassword: req.HasPassword,
-		Password:    req.Password,
+		Password:    passwordHash,
 		AllowReview: req.AllowReview,
 	}
 
@@ -276,7 +294,34 @@ func CreateAssignmentFromFile(c *gin.Context) {
 	timeLimit, _ := strconv.Atoi(c.DefaultPostForm("time_limit", "0"))
 	passScore, _ := strconv.ParseFloat(c.DefaultPostForm("pass_score", "5.0"), 64)
 	hasPassword := c.DefaultPostForm("has_password", "false") == "true"
-	password := c.PostForm("password")
+	password := ""
+	if hasPassword {
+		if c.PostForm("password") == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Vui lòng nhập mật khẩu bài tập"})
+			return
+		}
+		hashed, err := hashAssignmentPassword(c.PostForm("password"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể mã hóa mật khẩu"})
+			return
+		}
+		password = hashed
+	}
+
+	podcastUUID, err := uuid.Parse(podcastID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "podcast_id không hợp lệ"})
+		return
+	}
+	var podcast models.Podcast
+	if err := db.First(&podcast, "id = ?", podcastUUID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy podcast"})
+		return
+	}
+	if !canManagePodcast(c, db, podcast, models.PermAssignmentManage) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Bạn không có quyền tạo bài tập cho podcast này"})
+		return
+	}
 
 	file, err := c.FormFile("file")
 	if err != nil {
@@ -284,14 +329,20 @@ func CreateAssignmentFromFile(c *gin.Context) {
 		return
 	}
 
-	// Parse file (Excel/CSV)
-	questions, err := parseQuestionFile(file)
+	// Parse file (CSV, Excel, Moodle XML, GIFT, QTI)
+	parsed, issues, err := parseQuestionUpload(file, c.PostForm("format"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "File không hợp lệ: " + err.Error()})
 		return
 	}
-
-	podcastUUID, _ := uuid.Parse(podcastID)
+	questions, rejected := buildImportedQuestions(parsed, issues)
+	if len(questions) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":    "Không có câu hỏi hợp lệ nào trong file",
+			"rejected": rejected,
+		})
+		return
+	}
 
 	// Tạo assignment
 	assignment := models.Assignment{
@@ -307,56 +358,25 @@ func CreateAssignmentFromFile(c *gin.Context) {
 		Password:    password,
 	}
 
-	if err := db.Create(&assignment).Error; err != nil {
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&assignment).Error; err != nil {
+			return err
+		}
+		return saveImportedQuestions(tx, assignment.ID, questions, 0)
+	}); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tạo bài tập"})
 		return
 	}
 
-	// Lưu câu hỏi
-	for i, q := range questions {
-		question := models.AssignmentQuestion{
-			AssignmentID: assignment.ID,
-			Question:     q.Question,
-			Explanation:  q.Explanation,
-			Points:       q.Points,
-			SortOrder:    i + 1,
-		}
-
-		if err := db.Create(&question).Error; err != nil {
-			continue
-		}
-
-		for j, opt := range q.Options {
-			option := models.AssignmentOption{
-				QuestionID: question.ID,
-				OptionText: opt.Text,
-				IsCorrect:  opt.IsCorrect,
-				SortOrder:  j + 1,
-			}
-			db.Create(&option)
-		}
-	}
-
 	c.JSON(http.StatusOK, gin.H{
 		"message":    "Tạo bài tập từ file thành công",
 		"assignment": assignment,
 		"total":      len(questions),
+		"accepted":   len(questions),
+		"rejected":   rejected,
 	})
 }
 
-// Helper: Parse file Excel/CSV
-type QuestionDTO struct {
-	Question    string
-	Explanation string
-	Points      float64
-	Options     []OptionDTO
-}
-
-type OptionDTO struct {
-	Text      string
-	IsCorrect bool
-}
-
 // Lấy danh sách assignment của giảng viên
 func GetTeacherAssignments(c *gin.Context) {
 	db := c.MustGet("db").(*gorm.DB)
@@ -376,11 +396,15 @@ func GetTeacherAssignments(c *gin.Context) {
 		Preload("Podcast").
 		Preload("Podcast.Chapter").
 		Preload("Podcast.Chapter.Subject").
-		Preload("Questions.Options")
+		Preload("Questions", "pooled = ?", false).
+		Preload("Questions.Options").
+		Preload("Rules")
 
-	// Quyền giảng viên hoặc admin: chỉ lấy bài tập do chính mình tạo
-	if role == string(models.RoleLecturer) || role == string(models.RoleAdmin) {
+	// Admin: chỉ lấy bài tập do chính mình tạo; giảng viên: thêm bài tập thuộc môn được cấp quyền
+	if role == string(models.RoleAdmin) {
 		query = query.Where("assignments.created_by = ?", userUUID)
+	} else if role == string(models.RoleLecturer) {
+		query = query.Scopes(scopeManagedAssignments(db, userIDStr, models.PermAssignmentGrade))
 	}
 
 	// JOIN TABLES ĐỂ FILTER
@@ -469,10 +493,9 @@ func GetAssignmentDetailTeacher(c *gin.Context) {
 		query = query.Preload("Creator")
 	}
 
-	// Giảng viên chỉ được xem bài tập của mình
+	// Giảng viên chỉ được xem bài tập của mình hoặc thuộc môn được cấp quyền
 	if role == string(models.RoleLecturer) {
-		userUUID, _ := uuid.Parse(userIDStr)
-		query = query.Where("assignments.created_by = ?", userUUID)
+		query = query.Scopes(scopeManagedAssignments(db, userIDStr, models.PermAssignmentGrade))
 	}
 
 	var assignment models.Assignment
@@ -494,10 +517,7 @@ func GetAssignmentDetailTeacher(c *gin.Context) {
 func UpdateAssignment(c *gin.Context) {
 	db := c.MustGet("db").(*gorm.DB)
 	assignmentID := c.Param("id")
-	userIDStr := c.GetString("user_id")
-	role := c.GetString("role")
 
-	userUUID, _ := uuid.Parse(userIDStr)
 	assUUID, _ := uuid.Parse(assignmentID)
 
 	var assignment models.Assignment
@@ -507,7 +527,7 @@ func UpdateAssignment(c *gin.Context) {
 	}
 
 	// Kiểm tra quyền
-	if role != string(models.RoleAdmin) && assignment.CreatedBy != userUUID {
+	if !canManageAssignment(c, db, assignment, models.PermAssignmentManage) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Bạn không có quyền chỉnh sửa bài tập này"})
 		return
 	}
@@ -523,6 +543,19 @@ func UpdateAssignment(c *gin.Context) {
 		HasPassword bool       `json:"has_password"`
 		Password    string     `json:"password"`
 		AllowReview bool       `json:"allow_review"` // Cho phép sinh viên xem đáp án
+
+		ShuffleQuestions bool `json:"shuffle_questions"`
+		ShuffleOptions   bool `json:"shuffle_options"`
+
+		AllowLate         bool       `json:"allow_late"`
+		LatePenaltyPerDay float64    `json:"late_penalty_per_day"`
+		LatePenaltyMax    *float64   `json:"late_penalty_max"`
+		LateUntil         *time.Time `json:"late_until"`
+
+		RequireAccessCode *bool `json:"require_access_code"`
+
+		CategoryID  *string `json:"category_id"`  // "" để bỏ phân nhóm
+		ScorePolicy string  `json:"score_policy"` // best | last | average
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -545,8 +578,78 @@ func UpdateAssignment(c *gin.Context) {
 	assignment.IsPublished = req.IsPublished
 	assignment.HasPassword = req.HasPassword
 	assignment.AllowReview = req.AllowReview
+	assignment.ShuffleQuestions = req.ShuffleQuestions
+	assignment.ShuffleOptions = req.ShuffleOptions
 
-	assignment.Password = req.Password
+	if req.LatePenaltyPerDay < 0 || req.LatePenaltyPerDay > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "late_penalty_per_day phải từ 0 đến 100"})
+		return
+	}
+	if req.LatePenaltyMax != nil && (*req.LatePenaltyMax < 0 || *req.LatePenaltyMax > 100) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "late_penalty_max phải từ 0 đến 100"})
+		return
+	}
+	if req.LateUntil != nil && req.DueDate != nil && req.LateUntil.Before(*req.DueDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "late_until phải sau hạn nộp"})
+		return
+	}
+	assignment.AllowLate = req.AllowLate
+	assignment.LatePenaltyPerDay = req.LatePenaltyPerDay
+	if req.LatePenaltyMax != nil {
+		assignment.LatePenaltyMax = *req.LatePenaltyMax
+	}
+	assignment.LateUntil = req.LateUntil
+
+	if req.ScorePolicy != "" {
+		switch req.ScorePolicy {
+		case models.ScorePolicyBest, models.ScorePolicyLast, models.ScorePolicyAverage:
+			assignment.ScorePolicy = req.ScorePolicy
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "score_policy phải là best, last hoặc average"})
+			return
+		}
+	}
+	if req.CategoryID != nil {
+		if *req.CategoryID == "" {
+			assignment.CategoryID = nil
+		} else {
+			categoryUUID, err := uuid.Parse(*req.CategoryID)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "category_id không hợp lệ"})
+				return
+			}
+			var category models.GradeCategory
+			if err := db.First(&category, "id = ?", categoryUUID).Error; err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Không tìm thấy nhóm điểm"})
+				return
+			}
+			// Nhóm điểm phải thuộc môn học của podcast gắn với bài tập
+			if subjectID, ok := podcastSubjectID(db, assignment.PodcastID); !ok || category.SubjectID != subjectID {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Nhóm điểm không thuộc môn học của bài tập"})
+				return
+			}
+			assignment.CategoryID = &category.ID
+		}
+	}
+
+	// Để trống password thì giữ mật khẩu cũ
+	switch {
+	case !req.HasPassword:
+		assignment.Password = ""
+	case req.Password != "":
+		hashed, err := hashAssignmentPassword(req.Password)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể mã hóa mật khẩu"})
+			return
+		}
+		assignment.Password = hashed
+	case assignment.Password == "":
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Vui lòng nhập mật khẩu bài tập"})
+		return
+	}
+	if req.RequireAccessCode != nil {
+		assignment.RequireAccessCode = *req.RequireAccessCode
+	}
 
 	if err := db.Save(&assignment).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể cập nhật bài tập"})
@@ -559,14 +662,14 @@ func UpdateAssignment(c *gin.Context) {
 	})
 }
 
-// Thêm endpoint mới để verify password trước khi làm bài:
+// Kiểm tra trước mật khẩu / mã dự thi để hiển thị lỗi sớm.
+// Chỉ mang tính thông báo: quyền làm bài được xác nhận lại trong StartAssignment và mã chỉ bị dùng ở đó.
 func VerifyAssignmentPassword(c *gin.Context) {
 	db := c.MustGet("db").(*gorm.DB)
 	assignmentID := c.Param("id")
+	userUUID, _ := uuid.Parse(c.GetString("user_id"))
 
-	var req struct {
-		Password string `json:"password" binding:"required"`
-	}
+	var req assignmentAccessInput
 
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -581,22 +684,24 @@ func VerifyAssignmentPassword(c *gin.Context) {
 		return
 	}
 
-	if !assignment.HasPassword {
+	if !requiresAccessCheck(assignment) {
 		c.JSON(http.StatusOK, gin.H{"valid": true})
 		return
 	}
 
-	if assignment.Password != req.Password {
+	if _, err := checkAssignmentAccess(db, &assignment, userUUID, req, time.Now()); err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "Mật khẩu không đúng",
-			"valid": false,
+			"error":                err.Error(),
+			"valid":                false,
+			"requires_password":    assignment.HasPassword,
+			"requires_access_code": assignment.RequireAccessCode,
 		})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"valid":   true,
-		"message": "Mật khẩu chính xác",
+		"message": "Thông tin truy cập hợp lệ",
 	})
 }
 
@@ -604,10 +709,7 @@ func VerifyAssignmentPassword(c *gin.Context) {
 func DeleteAssignment(c *gin.Context) {
 	db := c.MustGet("db").(*gorm.DB)
 	assignmentID := c.Param("id")
-	userIDStr := c.GetString("user_id")
-	role := c.GetString("role")
 
-	userUUID, _ := uuid.Parse(userIDStr)
 	assUUID, _ := uuid.Parse(assignmentID)
 
 	var assignment models.Assignment
@@ -616,7 +718,7 @@ func DeleteAssignment(c *gin.Context) {
 		return
 	}
 
-	if role != string(models.RoleAdmin) && assignment.CreatedBy != userUUID {
+	if !canManageAssignment(c, db, assignment, models.PermAssignmentManage) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Bạn không có quyền xóa bài tập này"})
 		return
 	}
@@ -633,10 +735,7 @@ func DeleteAssignment(c *gin.Context) {
 func TogglePublishAssignment(c *gin.Context) {
 	db := c.MustGet("db").(*gorm.DB)
 	assignmentID := c.Param("id")
-	userIDStr := c.GetString("user_id")
-	role := c.GetString("role")
 
-	userUUID, _ := uuid.Parse(userIDStr)
 	assUUID, _ := uuid.Parse(assignmentID)
 
 	var assignment models.Assignment
@@ -645,7 +744,7 @@ func TogglePublishAssignment(c *gin.Context) {
 		return
 	}
 
-	if role != string(models.RoleAdmin) && assignment.CreatedBy != userUUID {
+	if !canManageAssignment(c, db, assignment, models.PermAssignmentManage) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Bạn không có quyền thay đổi trạng thái bài tập"})
 		return
 	}
@@ -684,9 +783,10 @@ func GetTeacherSubjects(c *gin.Context) {
 		return
 	}
 
+	// Môn của mình và môn được cấp quyền
 	var subjects []models.Subject
 	if err := db.Preload("Chapters").
-		Where("created_by = ?", userUUID).
+		Where("id IN (?)", memberSubjectIDs(db, userUUID.String())).
 		Find(&subjects).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy môn học"})
 		return
@@ -735,9 +835,19 @@ func GetAssignmentSubmissions(c *gin.Context) {
 		return
 	}
 
+	var assignment models.Assignment
+	if err := db.First(&assignment, "id = ?", assUUID).Error; err != nil {
+		c.JSON(404, gin.H{"error": "Không tìm thấy bài tập"})
+		return
+	}
+	if !canManageAssignment(c, db, assignment, models.PermAssignmentGrade) {
+		c.JSON(403, gin.H{"error": "Bạn không có quyền xem bài nộp của bài tập này"})
+		return
+	}
+
 	// Query params
 	search := c.Query("search")
-	status := c.Query("status") // passed | failed
+	status := c.Query("status") // passed | failed | pending | late | flagged | override
 	pageStr := c.Query("page")
 	limitStr := c.Query("limit")
 
@@ -763,12 +873,26 @@ func GetAssignmentSubmissions(c *gin.Context) {
 			Where("users.full_name ILIKE ?", like)
 	}
 
-	// Lọc pass/fail
+	// Lọc pass/fail/chờ chấm
 	if status == "passed" {
 		query = query.Where("is_passed = ?", true)
 	}
 	if status == "failed" {
-		query = query.Where("is_passed = ?", false)
+		query = query.Where("is_passed = ? AND grading_status = ?", false, models.GradingStatusGraded)
+	}
+	if status == "pending" {
+		query = query.Where("grading_status = ? AND submitted_at IS NOT NULL", models.GradingStatusPending)
+	}
+	// Bài nộp muộn / có dấu hiệu bất thường (rời tab, tự nộp khi hết giờ)
+	if status == "late" {
+		query = query.Where("is_late = ?", true)
+	}
+	if status == "flagged" {
+		query = query.Where("(integrity_flags > 0 OR auto_submitted = ?)", true)
+	}
+	// Sinh viên được cấp ngoại lệ (thêm giờ / gia hạn / thêm lượt)
+	if status == "override" {
+		query = query.Where("EXISTS (SELECT 1 FROM assignment_overrides ao WHERE ao.assignment_id = assignment_submissions.assignment_id AND ao.user_id = assignment_submissions.user_id)")
 	}
 
 	// Đếm tổng sau khi filter
@@ -792,8 +916,24 @@ func GetAssignmentSubmissions(c *gin.Context) {
 		return
 	}
 
+	// Ngoại lệ của các sinh viên trong trang, theo user_id
+	overrides := map[string]models.AssignmentOverride{}
+	if len(submissions) > 0 {
+		userIDs := make([]uuid.UUID, 0, len(submissions))
+		for _, s := range submissions {
+			userIDs = append(userIDs, s.UserID)
+		}
+		var rows []models.AssignmentOverride
+		db.Preload("Granter").Where("assignment_id = ? AND user_id IN ?", assUUID, userIDs).Find(&rows)
+		for _, o := range rows {
+			o.Granter.Password = ""
+			overrides[o.UserID.String()] = o
+		}
+	}
+
 	c.JSON(200, gin.H{
 		"submissions": submissions,
+		"overrides":   overrides,
 		"total":       total,
 		"page":        page,
 		"limit":       limit,
@@ -825,10 +965,21 @@ func GetAssignmentSubmissionDetailTeacher(c *gin.Context) {
 		c.JSON(404, gin.H{"error": "Bài nộp không tìm thấy"})
 		return
 	}
+	if !canManageAssignment(c, db, submission.Assignment, models.PermAssignmentGrade) {
+		c.JSON(403, gin.H{"error": "Bạn không có quyền xem bài nộp này"})
+		return
+	}
+
+	questions, err := loadSubmissionPaper(db, submission)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Không thể lấy đề bài"})
+		return
+	}
 
 	// Trả dữ liệu
 	c.JSON(200, gin.H{
 		"submission": submission,
+		"questions":  questions,
 	})
 }
 
@@ -849,8 +1000,7 @@ func GetAssignmentQuestionsForTeacher(c *gin.Context) {
 	query := db.Model(&models.Assignment{})
 
 	if role == string(models.RoleLecturer) {
-		userUUID, _ := uuid.Parse(userIDStr)
-		query = query.Where("assignments.created_by = ?", userUUID)
+		query = query.Scopes(scopeManagedAssignments(db, userIDStr, models.PermAssignmentGrade))
 	}
 
 	// Check assignment tồn tại & có quyền xem
@@ -870,7 +1020,7 @@ func GetAssignmentQuestionsForTeacher(c *gin.Context) {
 		Preload("Options", func(db *gorm.DB) *gorm.DB {
 			return db.Order("assignment_options.sort_order ASC")
 		}).
-		Where("assignment_id = ?", assignmentUUID).
+		Where("assignment_id = ? AND pooled = ?", assignmentUUID, false).
 		Order("sort_order ASC").
 		Find(&questions).Error; err != nil {
 
@@ -878,10 +1028,15 @@ func GetAssignmentQuestionsForTeacher(c *gin.Context) {
 		return
 	}
 
+	// Rule rút câu hỏi từ ngân hàng
+	var rules []models.AssignmentRule
+	db.Preload("Bank").Where("assignment_id = ?", assignmentUUID).Order("sort_order ASC").Find(&rules)
+
 	c.JSON(http.StatusOK, gin.H{
 		"assignment_id": assignmentUUID,
 		"title":         assignment.Title,
 		"questions":     questions,
+		"rules":         rules,
 	})
 }
 
@@ -901,8 +1056,7 @@ func CreateAssignmentQuestion(c *gin.Context) {
 	// Check quyền
 	query := db.Model(&models.Assignment{})
 	if role == string(models.RoleLecturer) {
-		userUUID, _ := uuid.Parse(userID)
-		query = query.Where("assignments.created_by = ?", userUUID)
+		query = query.Scopes(scopeManagedAssignments(db, userID, models.PermAssignmentManage))
 	}
 
 	var assignment models.Assignment
@@ -914,8 +1068,10 @@ func CreateAssignmentQuestion(c *gin.Context) {
 	// Parse body
 	var body struct {
 		Question    string                    `json:"question"`
+		Type        string                    `json:"type"`
 		Difficulty  string                    `json:"difficulty"`
 		Explanation string                    `json:"explanation"`
+		Rubric      string                    `json:"rubric"`
 		Points      float64                   `json:"points"`
 		SortOrder   int                       `json:"sort_order"`
 		Options     []models.AssignmentOption `json:"options"`
@@ -926,13 +1082,26 @@ func CreateAssignmentQuestion(c *gin.Context) {
 		return
 	}
 
+	// Kiểm tra loại câu hỏi và đáp án
+	qType, err := normalizeQuestionType(body.Type)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateQuestionOptions(qType, body.Options); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Tạo câu hỏi
 	q := models.AssignmentQuestion{
 		ID:           uuid.New(),
 		AssignmentID: assignmentUUID,
 		Question:     body.Question,
+		Type:         qType,
 		Difficulty:   body.Difficulty,
 		Explanation:  body.Explanation,
+		Rubric:       body.Rubric,
 		Points:       body.Points,
 		SortOrder:    body.SortOrder,
 	}
@@ -965,8 +1134,6 @@ func CreateAssignmentQuestion(c *gin.Context) {
 // Sửa câu hỏi
 func UpdateAssignmentQuestion(c *gin.Context) {
 	db := c.MustGet("db").(*gorm.DB)
-	role := c.GetString("role")
-	userID := c.GetString("user_id")
 
 	qID := c.Param("questionId")
 	qUUID, err := uuid.Parse(qID)
@@ -984,7 +1151,7 @@ func UpdateAssignmentQuestion(c *gin.Context) {
 	}
 
 	// Kiểm tra quyền
-	if role == string(models.RoleLecturer) && question.Assignment.CreatedBy.String() != userID {
+	if !canManageAssignment(c, db, question.Assignment, models.PermAssignmentManage) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Không có quyền chỉnh sửa câu hỏi này"})
 		return
 	}
@@ -992,8 +1159,10 @@ func UpdateAssignmentQuestion(c *gin.Context) {
 	// Parse body
 	var body struct {
 		Question    string                    `json:"question"`
+		Type        string                    `json:"type"`
 		Difficulty  string                    `json:"difficulty"`
 		Explanation string                    `json:"explanation"`
+		Rubric      string                    `json:"rubric"`
 		Points      float64                   `json:"points"`
 		SortOrder   int                       `json:"sort_order"`
 		Options     []models.AssignmentOption `json:"options"`
@@ -1003,10 +1172,23 @@ func UpdateAssignmentQuestion(c *gin.Context) {
 		return
 	}
 
+	// Kiểm tra loại câu hỏi và đáp án
+	qType, err := normalizeQuestionType(body.Type)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateQuestionOptions(qType, body.Options); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Update câu hỏi
 	question.Question = body.Question
+	question.Type = qType
 	question.Difficulty = body.Difficulty
 	question.Explanation = body.Explanation
+	question.Rubric = body.Rubric
 	question.Points = body.Points
 	question.SortOrder = body.SortOrder
 	if err := db.Save(&question).Error; err != nil {
@@ -1033,6 +1215,7 @@ func UpdateAssignmentQuestion(c *gin.Context) {
 				Where("id = ?", opt.ID).
 				Updates(map[string]interface{}{
 					"option_text": opt.OptionText,
+					"match_text":  opt.MatchText,
 					"is_correct":  opt.IsCorrect,
 					"sort_order":  opt.SortOrder,
 				})
@@ -1060,8 +1243,6 @@ func UpdateAssignmentQuestion(c *gin.Context) {
 // Xóa câu hỏi
 func DeleteAssignmentQuestion(c *gin.Context) {
 	db := c.MustGet("db").(*gorm.DB)
-	role := c.GetString("role")
-	userID := c.GetString("user_id")
 
 	qID := c.Param("questionId")
 	qUUID, err := uuid.Parse(qID)
@@ -1077,11 +1258,9 @@ func DeleteAssignmentQuestion(c *gin.Context) {
 		return
 	}
 
-	if role == string(models.RoleLecturer) {
-		if question.Assignment.CreatedBy.String() != userID {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Không có quyền xóa câu hỏi này"})
-			return
-		}
+	if !canManageAssignment(c, db, question.Assignment, models.PermAssignmentManage) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Không có quyền xóa câu hỏi này"})
+		return
 	}
 
 	// Xóa
@@ -1106,9 +1285,14 @@ func GetAssignmentsByPodcast(c *gin.Context) {
 	userUUID, _ := uuid.Parse(userIDStr)
 	podcastUUID, _ := uuid.Parse(podcastID)
 
+	query := db.Where("podcast_id = ? AND is_published = ?", podcastUUID, true)
+	// Sinh viên chỉ thấy bài tập chung hoặc bài được giao cho lớp mình
+	if c.GetString("role") == string(models.RoleUser) {
+		query = query.Scopes(visibleAssignmentsScope(userUUID))
+	}
+
 	var assignments []models.Assignment
-	if err := db.Where("podcast_id = ? AND is_published = ?", podcastUUID, true).
-		Preload("Questions").
+	if err := query.
 		Preload("Creator").
 		Order("created_at DESC").
 		Find(&assignments).Error; err != nil {
@@ -1119,12 +1303,16 @@ func GetAssignmentsByPodcast(c *gin.Context) {
 	// Lấy số lần đã làm của user
 	type AssignmentWithProgress struct {
 		models.Assignment
-		AttemptsUsed int     `json:"attempts_used"`
-		BestScore    float64 `json:"best_score"`
+		AttemptsUsed  int     `json:"attempts_used"`
+		BestScore     float64 `json:"best_score"`
+		QuestionCount int64   `json:"question_count"`
 	}
 
 	var result []AssignmentWithProgress
 	for _, ass := range assignments {
+		applyStudentSchedule(db, &ass, userUUID)
+		ass.Questions = []models.AssignmentQuestion{}
+
 		var attemptsUsed int64
 		var bestScore float64
 
@@ -1138,9 +1326,10 @@ func GetAssignmentsByPodcast(c *gin.Context) {
 			Scan(&bestScore)
 
 		result = append(result, AssignmentWithProgress{
-			Assignment:   ass,
-			AttemptsUsed: int(attemptsUsed),
-			BestScore:    bestScore,
+			Assignment:    ass,
+			AttemptsUsed:  int(attemptsUsed),
+			BestScore:     bestScore,
+			QuestionCount: countPaperQuestions(db, ass.ID),
 		})
 	}
 
@@ -1159,10 +1348,9 @@ func GetAssignmentDetailStudent(c *gin.Context) {
 	userUUID, _ := uuid.Parse(userIDStr)
 	assUUID, _ := uuid.Parse(assignmentID)
 
+	// Chỉ trả thông tin chung, câu hỏi chỉ có trong đề riêng phát khi bắt đầu làm bài
 	var assignment models.Assignment
-	if err := db.Preload("Questions.Options").
-		Preload("Creator"). // preload creator info
-		First(&assignment, "id = ?", assUUID).Error; err != nil {
+	if err := db.Preload("Creator").First(&assignment, "id = ?", assUUID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy bài tập"})
 		return
 	}
@@ -1171,12 +1359,19 @@ func GetAssignmentDetailStudent(c *gin.Context) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Bài tập chưa được công bố"})
 		return
 	}
+	if c.GetString("role") == string(models.RoleUser) && !canAccessAssignment(db, assignment.ID, userUUID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Bài tập không được giao cho lớp của bạn"})
+		return
+	}
+	applyStudentSchedule(db, &assignment, userUUID)
 
 	// Ẩn password khi trả về cho client
 	if assignment.HasPassword {
 		assignment.Password = ""
 	}
 
+	assignment.Questions = []models.AssignmentQuestion{}
+
 	// Lấy số lần đã làm của user
 	var attemptsUsed int64
 	db.Model(&models.AssignmentSubmission{}).
@@ -1210,51 +1405,10 @@ func GetAssignmentDetailStudent(c *gin.Context) {
 		"average_score":     avgScore,
 		"is_expired":        isExpired,
 		"allow_review":      assignment.AllowReview,
+		"question_count":    countPaperQuestions(db, assignment.ID),
 	})
 }
 
-func parseQuestionFile(file *multipart.FileHeader) ([]QuestionDTO, error) {
-	f, err := file.Open()
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-
-	reader := csv.NewReader(f)
-	records, err := reader.ReadAll()
-	if err != nil {
-		return nil, err
-	}
-
-	var questions []QuestionDTO
-
-	// Skip header
-	for i := 1; i < len(records); i++ {
-		row := records[i]
-		if len(row) < 8 {
-			continue
-		}
-
-		points, _ := strconv.ParseFloat(row[6], 64)
-
-		question := QuestionDTO{
-			Question:    row[0],
-			Explanation: row[7],
-			Points:      points,
-			Options: []OptionDTO{
-				{Text: row[1], IsCorrect: strings.ToUpper(row[5]) == "A"},
-				{Text: row[2], IsCorrect: strings.ToUpper(row[5]) == "B"},
-				{Text: row[3], IsCorrect: strings.ToUpper(row[5]) == "C"},
-				{Text: row[4], IsCorrect: strings.ToUpper(row[5]) == "D"},
-			},
-		}
-
-		questions = append(questions, question)
-	}
-
-	return questions, nil
-}
-
 // Nộp bài assignment
 func SubmitAssignment(c *gin.Context) {
 	db := c.MustGet("db").(*gorm.DB)
@@ -1264,12 +1418,9 @@ func SubmitAssignment(c *gin.Context) {
 	userUUID, _ := uuid.Parse(userIDStr)
 	assUUID, _ := uuid.Parse(assignmentID)
 
+	// time_spent của client không còn được dùng, server tự tính từ StartedAt
 	var req struct {
-		Answers []struct {
-			QuestionID uuid.UUID  `json:"question_id"`
-			SelectedID *uuid.UUID `json:"selected_id"`
-		} `json:"answers"`
-		TimeSpent int `json:"time_spent"`
+		Answers []AssignmentAnswerInput `json:"answers"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -1279,7 +1430,7 @@ func SubmitAssignment(c *gin.Context) {
 
 	// Tìm submission đang làm dở
 	var submission models.AssignmentSubmission
-	err := db.Preload("Assignment.Questions.Options").
+	err := db.Preload("Assignment").
 		Where("assignment_id = ? AND user_id = ? AND submitted_at IS NULL", assUUID, userUUID).
 		First(&submission).Error
 	if err != nil {
@@ -1287,87 +1438,55 @@ func SubmitAssignment(c *gin.Context) {
 		return
 	}
 
-	assignment := submission.Assignment
-
-	var totalScore float64
-	var maxScore float64
-
-	for _, q := range assignment.Questions {
-		maxScore += q.Points
-
-		var userAnswer *uuid.UUID
-		for _, ans := range req.Answers {
-			if ans.QuestionID == q.ID {
-				userAnswer = ans.SelectedID
-				break
-			}
-		}
-
-		var correctID uuid.UUID
-		for _, opt := range q.Options {
-			if opt.IsCorrect {
-				correctID = opt.ID
-				break
-			}
-		}
-
-		isCorrect := false
-		pointsEarned := 0.0
-		selectedID := uuid.Nil
-
-		if userAnswer != nil {
-			selectedID = *userAnswer
-			if selectedID == correctID {
-				isCorrect = true
-				pointsEarned = q.Points
-				totalScore += q.Points
-			}
-		}
-
-		// Tìm hoặc tạo câu trả lời
-		var answer models.AssignmentAnswer
-		err := db.Where("submission_id = ? AND question_id = ?", submission.ID, q.ID).First(&answer).Error
-		if err != nil {
-			answer = models.AssignmentAnswer{
-				SubmissionID: submission.ID,
-				QuestionID:   q.ID,
-			}
-		}
-
-		answer.SelectedID = selectedID
-		answer.IsCorrect = isCorrect
-		answer.PointsEarned = pointsEarned
+	applyStudentSchedule(db, &submission.Assignment, userUUID)
 
-		if err != nil {
-			db.Create(&answer)
-		} else {
-			db.Save(&answer)
-		}
+	if requiresAccessCheck(submission.Assignment) && !submission.AccessVerified {
+		respondAccessError(c, submission.Assignment, errors.New("Bài làm chưa được xác nhận quyền truy cập, vui lòng bắt đầu lại"))
+		return
 	}
 
-	// Cập nhật submission
-	scorePercent := (totalScore / maxScore) * 10 // chuẩn thang 10
-	isPassed := scorePercent >= assignment.PassScore
+	// Thời điểm nộp do server quyết định. Quá hạn (kể cả ân hạn) thì bỏ qua câu trả lời gửi lên,
+	// chỉ chấm phần đã autosave trước khi hết giờ.
 	now := time.Now()
+	answers := req.Answers
+	submittedAt := now
+	autoSubmitted := false
+	if isSubmissionExpired(submission, now) {
+		answers = answersFromDraft(db, submission.ID)
+		submittedAt = *submissionDeadline(submission)
+		autoSubmitted = true
+	} else if deadline := submissionDeadline(submission); deadline != nil && deadline.Before(now) {
+		submittedAt = *deadline
+	}
 
-	submission.Score = scorePercent
-	submission.IsPassed = isPassed
-	submission.TimeSpent = req.TimeSpent
-	submission.SubmittedAt = &now
-
-	if err := db.Save(&submission).Error; err != nil {
+	totalScore, maxScore, err := gradeSubmission(db, &submission, answers, submittedAt, autoSubmitted)
+	if errors.Is(err, errSubmissionClosed) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Bài làm đã được nộp"})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lưu bài làm"})
 		return
 	}
 
+	message := "Nộp bài thành công"
+	if autoSubmitted {
+		message = "Đã hết giờ làm bài, hệ thống chỉ ghi nhận các câu trả lời đã lưu trước thời hạn"
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"message":       "Nộp bài thành công",
-		"submission_id": submission.ID,
-		"score":         scorePercent,
-		"max_score":     10.0,
-		"is_passed":     isPassed,
-		"total_points":  totalScore,
-		"max_points":    maxScore,
+		"message":        message,
+		"submission_id":  submission.ID,
+		"score":          submission.Score,
+		"max_score":      10.0,
+		"is_passed":      submission.IsPassed,
+		"total_points":   totalScore,
+		"max_points":     maxScore,
+		"grading_status": submission.GradingStatus,
+		"time_spent":     submission.TimeSpent,
+		"auto_submitted": submission.AutoSubmitted,
+		"is_late":        submission.IsLate,
+		"late_penalty":   submission.LatePenalty,
 	})
 }
 
@@ -1404,19 +1523,66 @@ func StartAssignment(c *gin.Context) {
 	userUUID, _ := uuid.Parse(userIDStr)
 	assUUID, _ := uuid.Parse(assignmentID)
 
+	// Mật khẩu / mã dự thi (body có thể rỗng nếu bài tập không yêu cầu)
+	var access assignmentAccessInput
+	if err := c.ShouldBindJSON(&access); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Kiểm tra submission chưa nộp - THÊM PRELOAD ANSWERS
 	var submission models.AssignmentSubmission
 	err := db.Preload("Answers"). // ← THÊM DÒNG NÀY
 					Where("assignment_id = ? AND user_id = ? AND submitted_at IS NULL", assUUID, userUUID).
 					First(&submission).Error
 
+	now := time.Now()
 	if err == nil {
-		// Có submission chưa nộp
-		c.JSON(http.StatusOK, gin.H{
-			"submission": submission,
-			"message":    "Tiếp tục làm bài",
-		})
-		return
+		submission.Assignment = models.Assignment{}
+		db.First(&submission.Assignment, "id = ?", submission.AssignmentID)
+		applyStudentSchedule(db, &submission.Assignment, userUUID)
+
+		if isSubmissionExpired(submission, now) {
+			// Bài làm dở đã hết giờ: tự nộp rồi xét tiếp lượt làm mới
+			if err := finalizeExpiredSubmission(db, &submission); err != nil && !errors.Is(err, errSubmissionClosed) {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tự nộp bài làm đã hết giờ"})
+				return
+			}
+		} else {
+			// Bài làm dở chưa qua kiểm tra (tạo trước khi bật mật khẩu / mã) thì phải xác nhận lại
+			if requiresAccessCheck(submission.Assignment) && !submission.AccessVerified {
+				code, err := checkAssignmentAccess(db, &submission.Assignment, userUUID, access, now)
+				if err == nil {
+					err = db.Transaction(func(tx *gorm.DB) error {
+						return bindAccessToSubmission(tx, &submission, code, now)
+					})
+				}
+				if isAccessError(err) {
+					respondAccessError(c, submission.Assignment, err)
+					return
+				}
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể xác nhận quyền làm bài"})
+					return
+				}
+			}
+
+			// Có submission chưa nộp
+			questions, err := loadSubmissionPaper(db, submission)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy đề bài"})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"submission":  submission,
+				"questions":   paperForStudent(questions),
+				"message":     "Tiếp tục làm bài",
+				"server_time": now,
+				"expires_at":  submissionDeadline(submission),
+			})
+			return
+		}
 	}
 
 	// Lấy assignment
@@ -1426,6 +1592,12 @@ func StartAssignment(c *gin.Context) {
 		return
 	}
 
+	if c.GetString("role") == string(models.RoleUser) && !canAccessAssignment(db, assignment.ID, userUUID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Bài tập không được giao cho lớp của bạn"})
+		return
+	}
+	applyStudentSchedule(db, &assignment, userUUID)
+
 	// Kiểm tra lượt đã làm
 	var completedAttempts int64
 	db.Model(&models.AssignmentSubmission{}).
@@ -1437,24 +1609,59 @@ func StartAssignment(c *gin.Context) {
 		return
 	}
 
-	// Tạo submission mới
+	// Kiểm tra hạn nộp
+	if err := checkAssignmentOpen(assignment, now); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Kiểm tra mật khẩu / mã dự thi, mã được đánh dấu đã dùng cùng transaction tạo bài làm
+	accessCode, err := checkAssignmentAccess(db, &assignment, userUUID, access, now)
+	if err != nil {
+		respondAccessError(c, assignment, err)
+		return
+	}
+
+	// Tạo submission mới, thời hạn tính từ thời điểm server bắt đầu
 	submission = models.AssignmentSubmission{
 		AssignmentID: assUUID,
 		UserID:       userUUID,
 		AttemptNum:   int(completedAttempts) + 1,
-		StartedAt:    time.Now(),
+		StartedAt:    now,
+		ExpiresAt:    attemptDeadline(assignment, now),
 		Score:        0,
 		MaxScore:     10.0,
 		IsPassed:     false,
 	}
-	if err := db.Create(&submission).Error; err != nil {
+	// Tạo bài làm + đề riêng trong cùng transaction
+	var questions []models.AssignmentQuestion
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&submission).Error; err != nil {
+			return err
+		}
+		if requiresAccessCheck(assignment) {
+			if err := bindAccessToSubmission(tx, &submission, accessCode, now); err != nil {
+				return err
+			}
+		}
+		var err error
+		questions, err = buildSubmissionPaper(tx, assignment, submission.ID)
+		return err
+	}); err != nil {
+		if isAccessError(err) {
+			respondAccessError(c, assignment, err)
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tạo bài làm"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"submission": submission,
-		"message":    "Bắt đầu làm bài",
+		"submission":  submission,
+		"questions":   paperForStudent(questions),
+		"message":     "Bắt đầu làm bài",
+		"server_time": now,
+		"expires_at":  submission.ExpiresAt,
 	})
 }
 
@@ -1468,10 +1675,7 @@ func SaveAssignmentProgress(c *gin.Context) {
 	subUUID, _ := uuid.Parse(submissionID)
 
 	var req struct {
-		Answers []struct {
-			QuestionID uuid.UUID  `json:"question_id"`
-			SelectedID *uuid.UUID `json:"selected_id"`
-		} `json:"answers"`
+		Answers []AssignmentAnswerInput `json:"answers"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -1480,25 +1684,51 @@ func SaveAssignmentProgress(c *gin.Context) {
 	}
 
 	var submission models.AssignmentSubmission
-	if err := db.Where("id = ? AND user_id = ? AND submitted_at IS NULL", subUUID, userUUID).First(&submission).Error; err != nil {
+	if err := db.Preload("Assignment").Where("id = ? AND user_id = ? AND submitted_at IS NULL", subUUID, userUUID).First(&submission).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy bài làm hoặc đã nộp rồi"})
 		return
 	}
 
+	if requiresAccessCheck(submission.Assignment) && !submission.AccessVerified {
+		respondAccessError(c, submission.Assignment, errors.New("Bài làm chưa được xác nhận quyền truy cập, vui lòng bắt đầu lại"))
+		return
+	}
+
+	// Hết giờ: không nhận thêm câu trả lời, tự nộp phần đã lưu
+	if isSubmissionExpired(submission, time.Now()) {
+		if err := finalizeExpiredSubmission(db, &submission); err != nil && !errors.Is(err, errSubmissionClosed) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tự nộp bài làm đã hết giờ"})
+			return
+		}
+		c.JSON(http.StatusGone, gin.H{
+			"error":         "Đã hết giờ làm bài, bài làm đã được nộp tự động",
+			"submission_id": submission.ID,
+		})
+		return
+	}
+
 	// Xóa câu trả lời cũ
 	db.Where("submission_id = ?", subUUID).Delete(&models.AssignmentAnswer{})
 
 	// Lưu câu trả lời mới
 	for _, ans := range req.Answers {
-		if ans.SelectedID == nil {
+		if ans.IsEmpty() {
 			continue
 		}
+		selectedID := uuid.Nil
+		if ans.SelectedID != nil {
+			selectedID = *ans.SelectedID
+		}
 		answer := models.AssignmentAnswer{
 			SubmissionID: subUUID,
 			QuestionID:   ans.QuestionID,
-			SelectedID:   *ans.SelectedID,
+			SelectedID:   selectedID,
+			SelectedIDs:  ans.SelectedIDs,
+			TextAnswer:   ans.TextAnswer,
+			Pairs:        ans.Pairs,
 			IsCorrect:    false,
 			PointsEarned: 0,
+			TimeSpentSec: max(ans.TimeSpentSec, 0),
 		}
 		db.Create(&answer)
 	}
@@ -1506,7 +1736,7 @@ func SaveAssignmentProgress(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Đã lưu tiến trình"})
 }
 
-// GetSubmissionDetail: lấy submission hiện tại
+// GetSubmissionDetail: xem lại một lần làm bài đã nộp kèm đề có đáp án
 func GetSubmissionDetail(c *gin.Context) {
 	db := c.MustGet("db").(*gorm.DB)
 	assignmentID := c.Param("id")
@@ -1517,10 +1747,11 @@ func GetSubmissionDetail(c *gin.Context) {
 	assUUID, _ := uuid.Parse(assignmentID)
 	subUUID, _ := uuid.Parse(submissionID)
 
+	// Chỉ xem lại bài đã nộp: bài đang làm dở lấy đề (đã ẩn đáp án) qua CheckDraftSubmission
 	var submission models.AssignmentSubmission
-	if err := db.Where("id = ? AND assignment_id = ? AND user_id = ?", subUUID, assUUID, userUUID).
-		Preload("Assignment.Questions.Options").
-		Preload("Answers.Question.Options").
+	if err := db.Where("id = ? AND assignment_id = ? AND user_id = ? AND submitted_at IS NOT NULL", subUUID, assUUID, userUUID).
+		Preload("Assignment").
+		Preload("Answers").
 		Preload("Answers.SelectedOption").
 		First(&submission).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy lần làm bài"})
@@ -1532,7 +1763,14 @@ func GetSubmissionDetail(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"submission": submission})
+	// Đề riêng của lần làm bài (đúng thứ tự sinh viên đã thấy)
+	questions, err := loadSubmissionPaper(db, submission)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy đề bài"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"submission": submission, "questions": questions})
 }
 
 // Kiểm tra xem user có submission draft không
@@ -1562,9 +1800,18 @@ func CheckDraftSubmission(c *gin.Context) {
 		return
 	}
 
+	questions, err := loadSubmissionPaper(db, submission)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy đề bài"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"has_draft":  true,
-		"submission": submission,
+		"has_draft":   true,
+		"submission":  submission,
+		"questions":   paperForStudent(questions),
+		"server_time": time.Now(),
+		"expires_at":  submission.ExpiresAt,
 	})
 }
 
@@ -1585,6 +1832,10 @@ func ExportAssignmentSubmissions(c *gin.Context) {
 		c.JSON(404, gin.H{"error": "Không tìm thấy bài tập"})
 		return
 	}
+	if !canManageAssignment(c, db, assignment, models.PermAssignmentGrade) {
+		c.JSON(403, gin.H{"error": "Bạn không có quyền xuất bài nộp của bài tập này"})
+		return
+	}
 
 	// Lấy tất cả submissions đã nộp
 	var submissions []models.AssignmentSubmission
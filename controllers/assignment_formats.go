@@ -0,0 +1,329 @@
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/vnkhanh/e-podcast-backend/models"
+	"github.com/vnkhanh/e-podcast-backend/services"
+	"gorm.io/gorm"
+)
+
+// ==================== IMPORT / EXPORT CÂU HỎI ====================
+
+// parseQuestionUpload đọc file câu hỏi theo định dạng (csv, xlsx, moodle, gift, qti).
+// format rỗng thì đoán theo đuôi file và nội dung.
+func parseQuestionUpload(file *multipart.FileHeader, format string) ([]services.QuizQuestion, []services.ImportIssue, error) {
+	f, err := file.Open()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	format = strings.ToLower(strings.TrimSpace(format))
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(file.Filename)) {
+		case ".csv":
+			format = "csv"
+		case ".xlsx":
+			format = "xlsx"
+		case ".gift", ".txt":
+			format = "gift"
+		case ".zip":
+			format = "qti"
+		case ".xml":
+			// Moodle có gốc <quiz>, QTI có gốc <assessmentItem>
+			if bytes.Contains(data, []byte("<assessmentItem")) {
+				format = "qti"
+			} else {
+				format = "moodle"
+			}
+		default:
+			return nil, nil, fmt.Errorf("không nhận diện được định dạng file %s", file.Filename)
+		}
+	}
+
+	switch format {
+	case "csv":
+		return services.ParseQuestionCSV(data)
+	case "xlsx", "excel":
+		return services.ParseQuestionXLSX(data)
+	case "moodle", "xml":
+		return services.ParseMoodleXML(data)
+	case "gift":
+		return services.ParseGIFT(data)
+	case "qti":
+		return services.ParseQTI(data)
+	}
+	return nil, nil, fmt.Errorf("định dạng %s không được hỗ trợ", format)
+}
+
+// buildImportedQuestions kiểm tra từng câu theo quy tắc của hệ thống,
+// câu không hợp lệ được đưa vào danh sách bị từ chối kèm lý do
+func buildImportedQuestions(parsed []services.QuizQuestion, issues []services.ImportIssue) ([]models.AssignmentQuestion, []services.ImportIssue) {
+	rejected := append([]services.ImportIssue{}, issues...)
+	var accepted []models.AssignmentQuestion
+
+	for _, q := range parsed {
+		reject := func(reason string) {
+			rejected = append(rejected, services.ImportIssue{Row: q.Row, Question: q.Question, Reason: reason})
+		}
+
+		if strings.TrimSpace(q.Question) == "" {
+			reject("Thiếu nội dung câu hỏi")
+			continue
+		}
+		qType, err := normalizeQuestionType(q.Type)
+		if err != nil {
+			reject(err.Error())
+			continue
+		}
+
+		options := make([]models.AssignmentOption, 0, len(q.Options))
+		for i, o := range q.Options {
+			options = append(options, models.AssignmentOption{
+				OptionText: o.Text,
+				MatchText:  o.MatchText,
+				IsCorrect:  o.IsCorrect,
+				SortOrder:  i + 1,
+			})
+		}
+		if err := validateQuestionOptions(qType, options); err != nil {
+			reject(err.Error())
+			continue
+		}
+
+		difficulty := q.Difficulty
+		if difficulty == "" {
+			difficulty = "medium"
+		}
+		points := q.Points
+		if points <= 0 {
+			points = 1
+		}
+
+		accepted = append(accepted, models.AssignmentQuestion{
+			Question:    q.Question,
+			Type:        qType,
+			Difficulty:  difficulty,
+			Explanation: q.Explanation,
+			Rubric:      q.Rubric,
+			Points:      points,
+			Options:     options,
+		})
+	}
+
+	return accepted, rejected
+}
+
+// saveImportedQuestions lưu câu hỏi vào bài tập, thứ tự nối tiếp sau startOrder
+func saveImportedQuestions(tx *gorm.DB, assignmentID uuid.UUID, questions []models.AssignmentQuestion, startOrder int) error {
+	for i := range questions {
+		q := &questions[i]
+		q.AssignmentID = assignmentID
+		q.SortOrder = startOrder + i + 1
+
+		options := q.Options
+		q.Options = nil
+		if err := tx.Create(q).Error; err != nil {
+			return err
+		}
+		for j := range options {
+			options[j].QuestionID = q.ID
+			if err := tx.Create(&options[j]).Error; err != nil {
+				return err
+			}
+		}
+		q.Options = options
+	}
+	return nil
+}
+
+// Import câu hỏi vào bài tập có sẵn
+// POST /admin/assignments/:id/questions/import (multipart: file, format?, dry_run?)
+func ImportAssignmentQuestions(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	assUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID không hợp lệ"})
+		return
+	}
+
+	var assignment models.Assignment
+	if err := db.First(&assignment, "id = ?", assUUID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy bài tập"})
+		return
+	}
+	if !canManageAssignment(c, db, assignment, models.PermAssignmentManage) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Không có quyền thêm câu hỏi vào bài tập này"})
+		return
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Không có file đính kèm"})
+		return
+	}
+
+	parsed, issues, err := parseQuestionUpload(file, c.PostForm("format"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File không hợp lệ: " + err.Error()})
+		return
+	}
+	accepted, rejected := buildImportedQuestions(parsed, issues)
+
+	// dry_run: chỉ trả về báo cáo kiểm tra, không lưu
+	if c.PostForm("dry_run") == "true" {
+		c.JSON(http.StatusOK, gin.H{
+			"dry_run":   true,
+			"accepted":  len(accepted),
+			"rejected":  rejected,
+			"questions": accepted,
+		})
+		return
+	}
+
+	if len(accepted) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":    "Không có câu hỏi hợp lệ nào trong file",
+			"rejected": rejected,
+		})
+		return
+	}
+
+	var maxOrder int
+	db.Model(&models.AssignmentQuestion{}).
+		Where("assignment_id = ? AND pooled = ?", assUUID, false).
+		Select("COALESCE(MAX(sort_order), 0)").Scan(&maxOrder)
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		return saveImportedQuestions(tx, assUUID, accepted, maxOrder)
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lưu câu hỏi"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  fmt.Sprintf("Đã import %d câu hỏi", len(accepted)),
+		"accepted": len(accepted),
+		"rejected": rejected,
+	})
+}
+
+// Xuất câu hỏi của bài tập
+// GET /admin/assignments/:id/questions/export?format=moodle|gift|qti[&report=true]
+func ExportAssignmentQuestions(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	assUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID không hợp lệ"})
+		return
+	}
+
+	var assignment models.Assignment
+	if err := db.Preload("Questions", func(db *gorm.DB) *gorm.DB {
+		return db.Where("pooled = ?", false).Order("sort_order ASC")
+	}).Preload("Questions.Options", func(db *gorm.DB) *gorm.DB {
+		return db.Order("sort_order ASC")
+	}).First(&assignment, "id = ?", assUUID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy bài tập"})
+		return
+	}
+	if !canManageAssignment(c, db, assignment, models.PermAssignmentGrade) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Bạn không có quyền xem bài tập này"})
+		return
+	}
+
+	questions := make([]services.QuizQuestion, 0, len(assignment.Questions))
+	for i, q := range assignment.Questions {
+		item := services.QuizQuestion{
+			Row:         i + 1,
+			Type:        q.Type,
+			Question:    q.Question,
+			Difficulty:  q.Difficulty,
+			Explanation: q.Explanation,
+			Rubric:      q.Rubric,
+			Points:      q.Points,
+		}
+		for _, o := range q.Options {
+			item.Options = append(item.Options, services.QuizOption{Text: o.OptionText, MatchText: o.MatchText, IsCorrect: o.IsCorrect})
+		}
+		questions = append(questions, item)
+	}
+
+	baseName := fmt.Sprintf("assignment_%s_%s", assUUID.String()[:8], time.Now().Format("20060102_150405"))
+
+	var (
+		content     []byte
+		skipped     []services.ImportIssue
+		contentType string
+		fileName    string
+	)
+	switch c.DefaultQuery("format", "moodle") {
+	case "moodle", "xml":
+		content, skipped = services.ExportMoodleXML(assignment.Title, questions)
+		contentType = "application/xml; charset=utf-8"
+		fileName = baseName + ".xml"
+	case "gift":
+		content, skipped = services.ExportGIFT(assignment.Title, questions)
+		contentType = "text/plain; charset=utf-8"
+		fileName = baseName + ".gift"
+	case "qti":
+		content, skipped, err = services.ExportQTI(questions)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tạo gói QTI"})
+			return
+		}
+		contentType = "application/zip"
+		fileName = baseName + "_qti.zip"
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format phải là moodle, gift hoặc qti"})
+		return
+	}
+
+	// report=true: chỉ trả danh sách câu bị bỏ qua kèm lý do, không tải file
+	if c.Query("report") == "true" {
+		if skipped == nil {
+			skipped = []services.ImportIssue{}
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"total":    len(questions),
+			"exported": len(questions) - len(skipped),
+			"skipped":  skipped,
+		})
+		return
+	}
+
+	// Số câu không biểu diễn được ở định dạng đích (header được expose qua CORS)
+	c.Header("X-Skipped-Questions", fmt.Sprintf("%d", len(skipped)))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", fileName))
+	c.Data(http.StatusOK, contentType, content)
+}
+
+// Tải file Excel mẫu để import câu hỏi
+// GET /admin/assignments/import-template
+func DownloadQuestionTemplate(c *gin.Context) {
+	content, err := services.BuildQuestionTemplateXLSX()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tạo file mẫu"})
+		return
+	}
+
+	c.Header("Content-Disposition", "attachment; filename=mau_cau_hoi.xlsx")
+	c.Data(http.StatusOK, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", content)
+}
@@ -0,0 +1,416 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/vnkhanh/e-podcast-backend/models"
+	"github.com/vnkhanh/e-podcast-backend/services"
+	"github.com/vnkhanh/e-podcast-backend/ws"
+	"gorm.io/gorm"
+)
+
+// ==================== CHẤM CÂU TỰ LUẬN ====================
+
+// suggestEssayGradesAsync gọi Gemini gợi ý điểm cho các câu tự luận đang chờ chấm của một bài nộp
+func suggestEssayGradesAsync(db *gorm.DB, submissionID uuid.UUID) {
+	var answers []models.AssignmentAnswer
+	if err := db.Preload("Question").
+		Where("submission_id = ? AND grading_status = ?", submissionID, models.GradingStatusPending).
+		Find(&answers).Error; err != nil {
+		log.Printf("Không thể lấy câu tự luận của bài nộp %s: %v\n", submissionID, err)
+		return
+	}
+
+	for _, ans := range answers {
+		if err := suggestEssayGrade(db, &ans); err != nil {
+			log.Printf("Gemini chấm câu %s lỗi: %v\n", ans.ID, err)
+		}
+	}
+}
+
+// suggestEssayGrade chấm một câu tự luận theo rubric, lưu điểm gợi ý + giải thích
+func suggestEssayGrade(db *gorm.DB, ans *models.AssignmentAnswer) error {
+	q := ans.Question
+	rubric := strings.TrimSpace(q.Rubric)
+	if rubric == "" {
+		rubric = "Chấm theo mức độ chính xác, đầy đủ và mạch lạc của câu trả lời so với câu hỏi."
+	}
+
+	prompt := fmt.Sprintf(`
+Bạn là trợ giảng chấm bài tự luận bằng tiếng Việt.
+Chấm câu trả lời của sinh viên theo rubric, thang điểm từ 0 đến %.2f.
+
+Câu hỏi:
+%s
+
+Rubric:
+%s
+
+Câu trả lời của sinh viên:
+%s
+
+Yêu cầu:
+- Chỉ dựa vào rubric và nội dung câu trả lời
+- "points" là số thực trong khoảng 0 đến %.2f
+- "justification" giải thích ngắn gọn (2-4 câu) vì sao cho điểm đó
+
+Trả về JSON:
+{
+    "points": 0.0,
+    "justification": "Giải thích"
+}
+`, q.Points, q.Question, rubric, ans.TextAnswer, q.Points)
+
+	rawResp, err := services.GeminiGenerateText(prompt)
+	if err != nil {
+		return err
+	}
+
+	clean := strings.TrimSpace(rawResp)
+	clean = strings.Trim(clean, "`")
+	clean = strings.TrimPrefix(clean, "json")
+	clean = strings.TrimSpace(clean)
+
+	var result struct {
+		Points        float64 `json:"points"`
+		Justification string  `json:"justification"`
+	}
+	if err := json.Unmarshal([]byte(clean), &result); err != nil {
+		return fmt.Errorf("parse JSON lỗi: %v", err)
+	}
+
+	points := roundPoints(math.Min(math.Max(result.Points, 0), q.Points))
+
+	// Chỉ cập nhật nếu giảng viên chưa chấm trong lúc chờ AI
+	return db.Model(&models.AssignmentAnswer{}).
+		Where("id = ? AND grading_status IN ?", ans.ID, []string{models.GradingStatusPending, models.GradingStatusAISuggested}).
+		Updates(map[string]interface{}{
+			"ai_suggested_points": points,
+			"ai_justification":    result.Justification,
+			"grading_status":      models.GradingStatusAISuggested,
+		}).Error
+}
+
+// recalculateSubmissionScore tính lại điểm bài nộp sau khi chấm tay, trả về true nếu đã chấm xong toàn bộ
+func recalculateSubmissionScore(db *gorm.DB, submissionID uuid.UUID) (*models.AssignmentSubmission, bool, error) {
+	var submission models.AssignmentSubmission
+	if err := db.Preload("Assignment").Preload("Answers").
+		First(&submission, "id = ?", submissionID).Error; err != nil {
+		return nil, false, err
+	}
+
+	questions, err := loadSubmissionPaper(db, submission)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var totalScore, maxScore float64
+	for _, q := range questions {
+		maxScore += q.Points
+	}
+
+	pending := false
+	for _, ans := range submission.Answers {
+		totalScore += ans.PointsEarned
+		if ans.GradingStatus == models.GradingStatusPending || ans.GradingStatus == models.GradingStatusAISuggested {
+			pending = true
+		}
+	}
+
+	scorePercent := 0.0
+	if maxScore > 0 {
+		scorePercent = (totalScore / maxScore) * 10 // chuẩn thang 10
+	}
+	scorePercent = applyLatePenalty(scorePercent, submission.LatePenalty)
+
+	submission.Score = scorePercent
+	if pending {
+		submission.GradingStatus = models.GradingStatusPending
+		submission.IsPassed = false
+	} else {
+		submission.GradingStatus = models.GradingStatusGraded
+		submission.IsPassed = scorePercent >= submission.Assignment.PassScore
+	}
+
+	if err := db.Model(&models.AssignmentSubmission{}).
+		Where("id = ?", submission.ID).
+		Updates(map[string]interface{}{
+			"score":          submission.Score,
+			"is_passed":      submission.IsPassed,
+			"grading_status": submission.GradingStatus,
+		}).Error; err != nil {
+		return nil, false, err
+	}
+
+	return &submission, !pending, nil
+}
+
+// Gửi thông báo cho sinh viên khi bài làm đã được chấm xong
+func notifyAssignmentGraded(db *gorm.DB, submission *models.AssignmentSubmission) {
+	title := "Bài tập đã được chấm"
+	message := fmt.Sprintf("Bài \"%s\" (lần %d) đã được chấm: %.2f điểm",
+		submission.Assignment.Title, submission.AttemptNum, submission.Score)
+	podcastID := submission.Assignment.PodcastID
+
+	notif := models.Notification{
+		UserID:    submission.UserID,
+		Title:     title,
+		Message:   message,
+		Type:      "assignment_graded",
+		PodcastID: &podcastID,
+	}
+	db.Create(&notif)
+
+	data := map[string]interface{}{
+		"type":          "assignment_graded",
+		"title":         title,
+		"message":       message,
+		"podcast_id":    podcastID.String(),
+		"assignment_id": submission.AssignmentID.String(),
+		"submission_id": submission.ID.String(),
+		"id":            notif.ID.String(),
+	}
+	jsonData, _ := json.Marshal(data)
+	ws.H.BroadcastToUser(submission.UserID.String(), websocket.TextMessage, jsonData)
+
+	var count int64
+	db.Model(&models.Notification{}).Where("user_id = ? AND is_read = false", submission.UserID).Count(&count)
+	ws.SendBadgeUpdate(submission.UserID.String(), count)
+}
+
+// Hàng đợi chấm bài tự luận (giảng viên)
+// GET /admin/assignments/grading-queue?assignment_id=&status=pending|ai_suggested
+func GetGradingQueue(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	role := c.GetString("role")
+	userIDStr := c.GetString("user_id")
+
+	page := 1
+	limit := 20
+	if p, err := strconv.Atoi(c.Query("page")); err == nil && p > 0 {
+		page = p
+	}
+	if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 {
+		limit = l
+	}
+	offset := (page - 1) * limit
+
+	query := db.Model(&models.AssignmentAnswer{}).
+		Joins("JOIN assignment_submissions ON assignment_submissions.id = assignment_answers.submission_id").
+		Joins("JOIN assignments ON assignments.id = assignment_submissions.assignment_id").
+		Where("assignment_submissions.submitted_at IS NOT NULL")
+
+	switch status := c.Query("status"); status {
+	case models.GradingStatusPending, models.GradingStatusAISuggested:
+		query = query.Where("assignment_answers.grading_status = ?", status)
+	case "":
+		query = query.Where("assignment_answers.grading_status IN ?",
+			[]string{models.GradingStatusPending, models.GradingStatusAISuggested})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status không hợp lệ"})
+		return
+	}
+
+	if assignmentID := c.Query("assignment_id"); assignmentID != "" {
+		assUUID, err := uuid.Parse(assignmentID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ID bài tập không hợp lệ"})
+			return
+		}
+		query = query.Where("assignments.id = ?", assUUID)
+	}
+
+	// Giảng viên chỉ thấy bài tập của mình hoặc thuộc môn được cấp quyền chấm
+	if role == string(models.RoleLecturer) {
+		query = query.Scopes(scopeManagedAssignments(db, userIDStr, models.PermAssignmentGrade))
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể đếm hàng đợi chấm bài"})
+		return
+	}
+
+	var answers []models.AssignmentAnswer
+	if err := query.
+		Preload("Question").
+		Preload("Submission.User").
+		Preload("Submission.Assignment").
+		Order("assignment_submissions.submitted_at ASC").
+		Limit(limit).
+		Offset(offset).
+		Find(&answers).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy hàng đợi chấm bài"})
+		return
+	}
+
+	type QueueItem struct {
+		models.AssignmentAnswer
+		StudentName     string     `json:"student_name"`
+		StudentEmail    string     `json:"student_email"`
+		AssignmentID    uuid.UUID  `json:"assignment_id"`
+		AssignmentTitle string     `json:"assignment_title"`
+		AttemptNum      int        `json:"attempt_num"`
+		SubmittedAt     *time.Time `json:"submitted_at"`
+	}
+
+	items := make([]QueueItem, 0, len(answers))
+	for _, ans := range answers {
+		items = append(items, QueueItem{
+			AssignmentAnswer: ans,
+			StudentName:      ans.Submission.User.FullName,
+			StudentEmail:     ans.Submission.User.Email,
+			AssignmentID:     ans.Submission.AssignmentID,
+			AssignmentTitle:  ans.Submission.Assignment.Title,
+			AttemptNum:       ans.Submission.AttemptNum,
+			SubmittedAt:      ans.Submission.SubmittedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items": items,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	})
+}
+
+// loadAnswerForGrading lấy câu trả lời + kiểm tra quyền chấm của giảng viên
+func loadAnswerForGrading(c *gin.Context, db *gorm.DB) (*models.AssignmentAnswer, bool) {
+	answerUUID, err := uuid.Parse(c.Param("answerId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID câu trả lời không hợp lệ"})
+		return nil, false
+	}
+
+	var answer models.AssignmentAnswer
+	if err := db.Preload("Question").Preload("Submission.Assignment").
+		First(&answer, "id = ?", answerUUID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy câu trả lời"})
+		return nil, false
+	}
+
+	if !canManageAssignment(c, db, answer.Submission.Assignment, models.PermAssignmentGrade) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Không có quyền chấm bài tập này"})
+		return nil, false
+	}
+
+	if !isManualQuestion(answer.Question.Type) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Chỉ chấm tay được câu tự luận / trả lời ngắn"})
+		return nil, false
+	}
+
+	return &answer, true
+}
+
+// Giảng viên chấm (hoặc xác nhận điểm AI) một câu tự luận
+// PUT /admin/assignments/answers/:answerId/grade
+func GradeAssignmentAnswer(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userUUID, _ := uuid.Parse(c.GetString("user_id"))
+
+	var req struct {
+		Points           *float64 `json:"points"`
+		AcceptSuggestion bool     `json:"accept_suggestion"` // Dùng điểm AI gợi ý
+		Feedback         string   `json:"feedback"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	answer, ok := loadAnswerForGrading(c, db)
+	if !ok {
+		return
+	}
+
+	var points float64
+	switch {
+	case req.Points != nil:
+		points = *req.Points
+	case req.AcceptSuggestion && answer.AISuggestedPoints != nil:
+		points = *answer.AISuggestedPoints
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cần nhập điểm hoặc chấp nhận điểm AI gợi ý"})
+		return
+	}
+
+	if points < 0 || points > answer.Question.Points {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Điểm phải nằm trong khoảng 0 - %.2f", answer.Question.Points),
+		})
+		return
+	}
+
+	now := time.Now()
+	if err := db.Model(&models.AssignmentAnswer{}).
+		Where("id = ?", answer.ID).
+		Updates(map[string]interface{}{
+			"points_earned":    roundPoints(points),
+			"is_correct":       points >= answer.Question.Points,
+			"teacher_feedback": req.Feedback,
+			"grading_status":   models.GradingStatusGraded,
+			"graded_by":        userUUID,
+			"graded_at":        now,
+		}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lưu điểm"})
+		return
+	}
+
+	submission, completed, err := recalculateSubmissionScore(db, answer.SubmissionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể cập nhật điểm bài nộp"})
+		return
+	}
+
+	if completed && answer.Submission.GradingStatus != models.GradingStatusGraded {
+		go notifyAssignmentGraded(db, submission)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Chấm điểm thành công",
+		"points_earned":  roundPoints(points),
+		"submission_id":  submission.ID,
+		"score":          submission.Score,
+		"is_passed":      submission.IsPassed,
+		"grading_status": submission.GradingStatus,
+	})
+}
+
+// Yêu cầu AI gợi ý lại điểm cho một câu tự luận
+// POST /admin/assignments/answers/:answerId/ai-suggest
+func SuggestAssignmentAnswerGrade(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	answer, ok := loadAnswerForGrading(c, db)
+	if !ok {
+		return
+	}
+
+	if answer.GradingStatus == models.GradingStatusGraded {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Câu trả lời đã được chấm"})
+		return
+	}
+
+	if err := suggestEssayGrade(db, answer); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "AI không thể gợi ý điểm", "details": err.Error()})
+		return
+	}
+
+	db.Preload("Question").First(answer, "id = ?", answer.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Đã cập nhật điểm gợi ý",
+		"answer":  answer,
+	})
+}
@@ -0,0 +1,191 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/vnkhanh/e-podcast-backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ==================== NGOẠI LỆ CHO TỪNG SINH VIÊN ====================
+
+// applyAssignmentOverride áp dụng ngoại lệ của sinh viên lên bản sao bài tập:
+// cộng thêm phút / lượt làm và thay hạn nộp. Hạn nộp muộn được lùi theo cùng khoảng gia hạn.
+func applyAssignmentOverride(db *gorm.DB, assignment *models.Assignment, userID uuid.UUID) *models.AssignmentOverride {
+	var override models.AssignmentOverride
+	if err := db.Where("assignment_id = ? AND user_id = ?", assignment.ID, userID).
+		First(&override).Error; err != nil {
+		return nil
+	}
+	assignment.Override = &override
+
+	if override.ExtraMinutes > 0 && assignment.TimeLimit > 0 {
+		assignment.TimeLimit += override.ExtraMinutes
+	}
+	if override.ExtraAttempts > 0 {
+		assignment.MaxAttempts += override.ExtraAttempts
+	}
+	if override.DueDate != nil {
+		if assignment.DueDate != nil && assignment.LateUntil != nil && override.DueDate.After(*assignment.DueDate) {
+			lateUntil := assignment.LateUntil.Add(override.DueDate.Sub(*assignment.DueDate))
+			assignment.LateUntil = &lateUntil
+		}
+		due := *override.DueDate
+		assignment.DueDate = &due
+	}
+	return &override
+}
+
+// applyStudentSchedule đưa bài tập về lịch riêng của sinh viên: hạn của lớp, sau đó ngoại lệ cá nhân.
+// Gọi lại trên bài tập đã áp ngoại lệ thì không cộng dồn.
+func applyStudentSchedule(db *gorm.DB, assignment *models.Assignment, userID uuid.UUID) *models.AssignmentOverride {
+	if assignment.Override != nil {
+		return assignment.Override
+	}
+	applyClassDueDate(db, assignment, userID)
+	return applyAssignmentOverride(db, assignment, userID)
+}
+
+// Danh sách ngoại lệ của bài tập
+// GET /admin/assignments/:id/overrides
+func GetAssignmentOverrides(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	assignment, ok := loadManagedAssignment(c, db)
+	if !ok {
+		return
+	}
+
+	var overrides []models.AssignmentOverride
+	if err := db.Preload("User").Preload("Granter").
+		Where("assignment_id = ?", assignment.ID).
+		Order("updated_at DESC").
+		Find(&overrides).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy danh sách ngoại lệ"})
+		return
+	}
+	for i := range overrides {
+		overrides[i].User.Password = ""
+		overrides[i].Granter.Password = ""
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"overrides": overrides,
+		"total":     len(overrides),
+	})
+}
+
+// Cấp hoặc cập nhật ngoại lệ cho một sinh viên (bắt buộc ghi lý do)
+// PUT /admin/assignments/:id/overrides/:userId  {extra_minutes, due_date, extra_attempts, reason}
+func UpsertAssignmentOverride(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	assignment, ok := loadManagedAssignment(c, db)
+	if !ok {
+		return
+	}
+
+	var student models.User
+	if err := db.First(&student, "id = ?", c.Param("userId")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy sinh viên"})
+		return
+	}
+	// Chỉ cấp ngoại lệ cho sinh viên được giao bài: học lớp được giao, hoặc học môn với bài không gắn lớp
+	if student.Role != models.RoleUser || !canAccessAssignment(db, assignment.ID, student.ID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Người dùng không phải sinh viên được giao bài tập này"})
+		return
+	}
+
+	var req struct {
+		ExtraMinutes  int        `json:"extra_minutes"`
+		DueDate       *time.Time `json:"due_date"`
+		ExtraAttempts int        `json:"extra_attempts"`
+		Reason        string     `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Vui lòng nhập lý do"})
+		return
+	}
+	if req.ExtraMinutes < 0 || req.ExtraAttempts < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Số phút / số lượt cộng thêm không được âm"})
+		return
+	}
+	if req.ExtraMinutes == 0 && req.ExtraAttempts == 0 && req.DueDate == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cần ít nhất một thay đổi: extra_minutes, due_date hoặc extra_attempts"})
+		return
+	}
+	if req.ExtraMinutes > 0 && assignment.TimeLimit == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Bài tập không giới hạn thời gian, không thể cộng thêm phút"})
+		return
+	}
+
+	granterUUID, _ := uuid.Parse(c.GetString("user_id"))
+	override := models.AssignmentOverride{
+		AssignmentID:  assignment.ID,
+		UserID:        student.ID,
+		ExtraMinutes:  req.ExtraMinutes,
+		DueDate:       req.DueDate,
+		ExtraAttempts: req.ExtraAttempts,
+		Reason:        req.Reason,
+		GrantedBy:     granterUUID,
+	}
+	if err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "assignment_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"extra_minutes", "due_date", "extra_attempts", "reason", "granted_by", "updated_at"}),
+	}).Create(&override).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lưu ngoại lệ"})
+		return
+	}
+
+	// Bài đang làm dở: lùi thời hạn theo lịch mới (chỉ kéo dài, không rút ngắn)
+	var draft models.AssignmentSubmission
+	if err := db.Where("assignment_id = ? AND user_id = ? AND submitted_at IS NULL", assignment.ID, student.ID).
+		First(&draft).Error; err == nil {
+		schedule := *assignment
+		applyStudentSchedule(db, &schedule, student.ID)
+		if deadline := attemptDeadline(schedule, draft.StartedAt); deadline == nil || (draft.ExpiresAt != nil && deadline.After(*draft.ExpiresAt)) {
+			db.Model(&draft).Update("expires_at", deadline)
+		}
+	}
+
+	db.Where("assignment_id = ? AND user_id = ?", assignment.ID, student.ID).First(&override)
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Đã lưu ngoại lệ cho sinh viên",
+		"override": override,
+	})
+}
+
+// Xóa ngoại lệ
+// DELETE /admin/assignments/:id/overrides/:userId
+func DeleteAssignmentOverride(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	assignment, ok := loadManagedAssignment(c, db)
+	if !ok {
+		return
+	}
+
+	res := db.Where("assignment_id = ? AND user_id = ?", assignment.ID, c.Param("userId")).
+		Delete(&models.AssignmentOverride{})
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể xóa ngoại lệ"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sinh viên không có ngoại lệ ở bài tập này"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Đã xóa ngoại lệ"})
+}
@@ -0,0 +1,370 @@
+package controllers
+
+import (
+	"math/rand"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/vnkhanh/e-podcast-backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ==================== ĐỀ RIÊNG CHO TỪNG LẦN LÀM BÀI ====================
+
+// buildSubmissionPaper tạo đề cho một lần làm bài: câu cố định + câu rút từ ngân hàng theo rule,
+// xáo thứ tự câu / đáp án nếu bài tập bật, rồi lưu lại để chấm và xem lại đúng đề đã làm
+func buildSubmissionPaper(tx *gorm.DB, assignment models.Assignment, submissionID uuid.UUID) ([]models.AssignmentQuestion, error) {
+	var questions []models.AssignmentQuestion
+	if err := tx.Preload("Options", func(db *gorm.DB) *gorm.DB {
+		return db.Order("assignment_options.sort_order ASC")
+	}).
+		Where("assignment_id = ? AND pooled = ?", assignment.ID, false).
+		Order("sort_order ASC").
+		Find(&questions).Error; err != nil {
+		return nil, err
+	}
+
+	var rules []models.AssignmentRule
+	if err := tx.Where("assignment_id = ?", assignment.ID).Order("sort_order ASC").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+
+	picked := []uuid.UUID{}
+	for _, rule := range rules {
+		bankQuestions, err := drawBankQuestions(tx, rule, picked)
+		if err != nil {
+			return nil, err
+		}
+		for _, bq := range bankQuestions {
+			picked = append(picked, bq.ID)
+
+			q, err := materializeBankQuestion(tx, assignment.ID, bq, rule.Points)
+			if err != nil {
+				return nil, err
+			}
+			questions = append(questions, *q)
+		}
+	}
+
+	if assignment.ShuffleQuestions {
+		rand.Shuffle(len(questions), func(i, j int) { questions[i], questions[j] = questions[j], questions[i] })
+	}
+
+	rows := make([]models.SubmissionQuestion, 0, len(questions))
+	for i := range questions {
+		q := &questions[i]
+
+		shuffle := q.Type == models.QuestionTypeOrdering
+		if assignment.ShuffleOptions {
+			switch q.Type {
+			case "", models.QuestionTypeSingleChoice, models.QuestionTypeMultipleChoice, models.QuestionTypeMatching:
+				shuffle = true
+			}
+		}
+		if shuffle {
+			rand.Shuffle(len(q.Options), func(a, b int) { q.Options[a], q.Options[b] = q.Options[b], q.Options[a] })
+		}
+
+		optionOrder := make(models.UUIDList, 0, len(q.Options))
+		for _, opt := range q.Options {
+			optionOrder = append(optionOrder, opt.ID)
+		}
+
+		if q.Type == models.QuestionTypeMatching {
+			q.MatchKeys = newMatchKeys(q.Options)
+		}
+
+		rows = append(rows, models.SubmissionQuestion{
+			SubmissionID: submissionID,
+			QuestionID:   q.ID,
+			SortOrder:    i + 1,
+			OptionOrder:  optionOrder,
+			MatchKeys:    q.MatchKeys,
+		})
+	}
+
+	if len(rows) > 0 {
+		if err := tx.Create(&rows).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return questions, nil
+}
+
+// drawBankQuestions rút ngẫu nhiên câu hỏi trong ngân hàng theo rule, bỏ qua các câu đã rút
+func drawBankQuestions(tx *gorm.DB, rule models.AssignmentRule, exclude []uuid.UUID) ([]models.BankQuestion, error) {
+	query := tx.Preload("Options", func(db *gorm.DB) *gorm.DB {
+		return db.Order("bank_options.sort_order ASC")
+	}).Where("bank_id = ?", rule.BankID)
+
+	if rule.ChapterID != nil {
+		query = query.Where("chapter_id = ?", *rule.ChapterID)
+	}
+	if rule.Difficulty != "" {
+		query = query.Where("difficulty = ?", rule.Difficulty)
+	}
+	if len(exclude) > 0 {
+		query = query.Where("id NOT IN ?", exclude)
+	}
+
+	var bankQuestions []models.BankQuestion
+	err := query.Order("RANDOM()").Limit(rule.Count).Find(&bankQuestions).Error
+	return bankQuestions, err
+}
+
+// materializeBankQuestion lấy (hoặc tạo) bản sao của câu ngân hàng trong bài tập để đáp án tham chiếu tới.
+// Bản sao được dùng lại cho tới khi câu gốc bị sửa, các bài đã làm vẫn giữ nguyên nội dung cũ.
+func materializeBankQuestion(tx *gorm.DB, assignmentID uuid.UUID, bq models.BankQuestion, rulePoints float64) (*models.AssignmentQuestion, error) {
+	points := bq.Points
+	if rulePoints > 0 {
+		points = rulePoints
+	}
+
+	var existing models.AssignmentQuestion
+	err := tx.Preload("Options", func(db *gorm.DB) *gorm.DB {
+		return db.Order("assignment_options.sort_order ASC")
+	}).
+		Where("assignment_id = ? AND bank_question_id = ? AND pooled = ? AND points = ? AND created_at >= ?",
+			assignmentID, bq.ID, true, points, bq.UpdatedAt).
+		Order("created_at DESC").
+		First(&existing).Error
+	if err == nil {
+		return &existing, nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	bankQuestionID := bq.ID
+	q := models.AssignmentQuestion{
+		AssignmentID:   assignmentID,
+		Question:       bq.Question,
+		Type:           bq.Type,
+		Difficulty:     bq.Difficulty,
+		Explanation:    bq.Explanation,
+		Rubric:         bq.Rubric,
+		Points:         points,
+		BankQuestionID: &bankQuestionID,
+		Pooled:         true,
+	}
+	if err := tx.Create(&q).Error; err != nil {
+		return nil, err
+	}
+
+	for _, bo := range bq.Options {
+		q.Options = append(q.Options, models.AssignmentOption{
+			QuestionID: q.ID,
+			OptionText: bo.OptionText,
+			MatchText:  bo.MatchText,
+			IsCorrect:  bo.IsCorrect,
+			SortOrder:  bo.SortOrder,
+		})
+	}
+	if len(q.Options) > 0 {
+		if err := tx.Create(&q.Options).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return &q, nil
+}
+
+// loadSubmissionPaper trả về đề của một lần làm bài theo đúng thứ tự câu / đáp án sinh viên đã thấy.
+// Bài làm cũ (trước khi có đề riêng) dùng danh sách câu cố định của bài tập; nếu có câu ghép nối thì
+// đề được lưu lại để cấp id lựa chọn riêng như đề mới.
+func loadSubmissionPaper(db *gorm.DB, submission models.AssignmentSubmission) ([]models.AssignmentQuestion, error) {
+	rows, err := submissionPaperRows(db, submission.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) == 0 {
+		var questions []models.AssignmentQuestion
+		if err := db.Preload("Options", func(db *gorm.DB) *gorm.DB {
+			return db.Order("assignment_options.sort_order ASC")
+		}).
+			Where("assignment_id = ? AND pooled = ?", submission.AssignmentID, false).
+			Order("sort_order ASC").
+			Find(&questions).Error; err != nil {
+			return nil, err
+		}
+		if !hasMatchingQuestion(questions) {
+			return questions, nil
+		}
+		if err := saveLegacyPaper(db, submission.ID, questions); err != nil {
+			return nil, err
+		}
+		if rows, err = submissionPaperRows(db, submission.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	questions := make([]models.AssignmentQuestion, 0, len(rows))
+	for _, row := range rows {
+		if row.Question.Type == models.QuestionTypeMatching && len(row.MatchKeys) == 0 {
+			if err := issueMatchKeys(db, &row); err != nil {
+				return nil, err
+			}
+		}
+
+		q := row.Question
+		position := map[uuid.UUID]int{}
+		for i, id := range row.OptionOrder {
+			position[id] = i
+		}
+		sort.SliceStable(q.Options, func(i, j int) bool {
+			pi, okI := position[q.Options[i].ID]
+			pj, okJ := position[q.Options[j].ID]
+			switch {
+			case okI && okJ:
+				return pi < pj
+			case okI != okJ:
+				// Đáp án thêm sau khi bắt đầu làm bài xếp cuối
+				return okI
+			default:
+				return q.Options[i].SortOrder < q.Options[j].SortOrder
+			}
+		})
+		q.MatchKeys = row.MatchKeys
+		questions = append(questions, q)
+	}
+
+	return questions, nil
+}
+
+func submissionPaperRows(db *gorm.DB, submissionID uuid.UUID) ([]models.SubmissionQuestion, error) {
+	var rows []models.SubmissionQuestion
+	err := db.Preload("Question.Options").
+		Where("submission_id = ?", submissionID).
+		Order("sort_order ASC").
+		Find(&rows).Error
+	return rows, err
+}
+
+func hasMatchingQuestion(questions []models.AssignmentQuestion) bool {
+	for _, q := range questions {
+		if q.Type == models.QuestionTypeMatching {
+			return true
+		}
+	}
+	return false
+}
+
+// saveLegacyPaper lưu đề cố định của bài làm cũ thành đề riêng (giữ nguyên thứ tự), bỏ qua nếu đã có
+func saveLegacyPaper(db *gorm.DB, submissionID uuid.UUID, questions []models.AssignmentQuestion) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var submission models.AssignmentSubmission
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id").
+			First(&submission, "id = ?", submissionID).Error; err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&models.SubmissionQuestion{}).Where("submission_id = ?", submissionID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+
+		rows := make([]models.SubmissionQuestion, 0, len(questions))
+		for i, q := range questions {
+			optionOrder := make(models.UUIDList, 0, len(q.Options))
+			for _, opt := range q.Options {
+				optionOrder = append(optionOrder, opt.ID)
+			}
+			rows = append(rows, models.SubmissionQuestion{
+				SubmissionID: submissionID,
+				QuestionID:   q.ID,
+				SortOrder:    i + 1,
+				OptionOrder:  optionOrder,
+			})
+		}
+		return tx.Create(&rows).Error
+	})
+}
+
+// issueMatchKeys cấp id lựa chọn riêng cho câu ghép nối của đề phát khi chưa có MatchKeys.
+// Các cặp đã lưu theo option_id được đổi sang id mới để bài làm dở / đã nộp vẫn chấm lại được.
+func issueMatchKeys(db *gorm.DB, row *models.SubmissionQuestion) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var current models.SubmissionQuestion
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "match_keys").
+			First(&current, "id = ?", row.ID).Error; err != nil {
+			return err
+		}
+		if len(current.MatchKeys) > 0 {
+			row.MatchKeys = current.MatchKeys
+			return nil
+		}
+
+		keys := models.StringMap(newMatchKeys(row.Question.Options))
+		if err := tx.Model(&current).Update("match_keys", keys).Error; err != nil {
+			return err
+		}
+
+		choiceOf := make(map[string]string, len(keys))
+		for choiceID, optionID := range keys {
+			choiceOf[optionID] = choiceID
+		}
+		var answers []models.AssignmentAnswer
+		if err := tx.Select("id", "pairs").
+			Where("submission_id = ? AND question_id = ?", row.SubmissionID, row.QuestionID).
+			Find(&answers).Error; err != nil {
+			return err
+		}
+		for _, ans := range answers {
+			if len(ans.Pairs) == 0 {
+				continue
+			}
+			pairs := make(models.StringMap, len(ans.Pairs))
+			for left, right := range ans.Pairs {
+				if choiceID, ok := choiceOf[right]; ok {
+					pairs[left] = choiceID
+				}
+			}
+			if err := tx.Model(&models.AssignmentAnswer{ID: ans.ID}).Update("pairs", pairs).Error; err != nil {
+				return err
+			}
+		}
+
+		row.MatchKeys = keys
+		return nil
+	})
+}
+
+// newMatchKeys sinh id ngẫu nhiên cho từng lựa chọn vế phải của câu ghép nối: id lựa chọn -> option_id
+func newMatchKeys(options []models.AssignmentOption) map[string]string {
+	keys := make(map[string]string, len(options))
+	for _, opt := range options {
+		keys[uuid.NewString()] = opt.ID.String()
+	}
+	return keys
+}
+
+// paperForStudent ẩn đáp án của đề trước khi trả cho sinh viên đang làm bài
+func paperForStudent(questions []models.AssignmentQuestion) []models.AssignmentQuestion {
+	for i := range questions {
+		prepareQuestionForStudent(&questions[i])
+	}
+	return questions
+}
+
+// countPaperQuestions ước tính số câu của mỗi đề: câu cố định + tổng số câu theo rule
+func countPaperQuestions(db *gorm.DB, assignmentID uuid.UUID) int64 {
+	var fixed int64
+	db.Model(&models.AssignmentQuestion{}).
+		Where("assignment_id = ? AND pooled = ?", assignmentID, false).
+		Count(&fixed)
+
+	var pooled int64
+	db.Model(&models.AssignmentRule{}).
+		Where("assignment_id = ?", assignmentID).
+		Select("COALESCE(SUM(count), 0)").
+		Scan(&pooled)
+
+	return fixed + pooled
+}
@@ -0,0 +1,313 @@
+package controllers
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/vnkhanh/e-podcast-backend/models"
+)
+
+// AssignmentAnswerInput là câu trả lời sinh viên gửi lên cho một câu hỏi (dùng cho nộp bài và autosave)
+type AssignmentAnswerInput struct {
+	QuestionID  uuid.UUID         `json:"question_id"`
+	SelectedID  *uuid.UUID        `json:"selected_id"`  // single_choice, true_false
+	SelectedIDs []uuid.UUID       `json:"selected_ids"` // multiple_choice; ordering (theo thứ tự sinh viên sắp xếp)
+	TextAnswer  string            `json:"text_answer"`  // fill_blank
+	Pairs       map[string]string `json:"pairs"`        // matching: option_id -> id lựa chọn vế phải
+
+	TimeSpentSec int `json:"time_spent_sec"` // Tổng thời gian sinh viên ở câu này (giây), dùng cho phân tích câu hỏi
+}
+
+// IsEmpty: sinh viên chưa trả lời câu này
+func (a AssignmentAnswerInput) IsEmpty() bool {
+	return (a.SelectedID == nil || *a.SelectedID == uuid.Nil) &&
+		len(a.SelectedIDs) == 0 &&
+		strings.TrimSpace(a.TextAnswer) == "" &&
+		len(a.Pairs) == 0
+}
+
+var validQuestionTypes = map[string]bool{
+	models.QuestionTypeSingleChoice:   true,
+	models.QuestionTypeMultipleChoice: true,
+	models.QuestionTypeTrueFalse:      true,
+	models.QuestionTypeFillBlank:      true,
+	models.QuestionTypeOrdering:       true,
+	models.QuestionTypeMatching:       true,
+	models.QuestionTypeShortAnswer:    true,
+	models.QuestionTypeEssay:          true,
+}
+
+// isManualQuestion: câu tự luận / trả lời ngắn cần giảng viên chấm
+func isManualQuestion(qType string) bool {
+	return qType == models.QuestionTypeShortAnswer || qType == models.QuestionTypeEssay
+}
+
+// normalizeQuestionType trả về loại câu hỏi hợp lệ, rỗng = single_choice
+func normalizeQuestionType(t string) (string, error) {
+	t = strings.ToLower(strings.TrimSpace(t))
+	if t == "" {
+		return models.QuestionTypeSingleChoice, nil
+	}
+	if !validQuestionTypes[t] {
+		return "", errors.New("Loại câu hỏi không hợp lệ")
+	}
+	return t, nil
+}
+
+// validateQuestionOptions kiểm tra danh sách đáp án có phù hợp với loại câu hỏi
+func validateQuestionOptions(qType string, options []models.AssignmentOption) error {
+	numCorrect := 0
+	for _, opt := range options {
+		if strings.TrimSpace(opt.OptionText) == "" {
+			return errors.New("Nội dung đáp án không được để trống")
+		}
+		if opt.IsCorrect {
+			numCorrect++
+		}
+	}
+
+	switch qType {
+	case models.QuestionTypeShortAnswer, models.QuestionTypeEssay:
+		if len(options) > 0 {
+			return errors.New("Câu tự luận không có đáp án lựa chọn, hãy dùng rubric")
+		}
+
+	case models.QuestionTypeSingleChoice:
+		if len(options) < 2 {
+			return errors.New("Câu hỏi một đáp án cần ít nhất 2 lựa chọn")
+		}
+		if numCorrect != 1 {
+			return errors.New("Câu hỏi một đáp án phải có đúng 1 đáp án đúng")
+		}
+
+	case models.QuestionTypeMultipleChoice:
+		if len(options) < 2 {
+			return errors.New("Câu hỏi nhiều đáp án cần ít nhất 2 lựa chọn")
+		}
+		if numCorrect < 1 {
+			return errors.New("Câu hỏi nhiều đáp án phải có ít nhất 1 đáp án đúng")
+		}
+
+	case models.QuestionTypeTrueFalse:
+		if len(options) != 2 {
+			return errors.New("Câu hỏi Đúng/Sai phải có đúng 2 lựa chọn")
+		}
+		if numCorrect != 1 {
+			return errors.New("Câu hỏi Đúng/Sai phải có đúng 1 đáp án đúng")
+		}
+
+	case models.QuestionTypeFillBlank:
+		if len(options) < 1 {
+			return errors.New("Câu điền khuyết cần ít nhất 1 đáp án được chấp nhận")
+		}
+
+	case models.QuestionTypeOrdering:
+		if len(options) < 2 {
+			return errors.New("Câu sắp xếp cần ít nhất 2 mục")
+		}
+		seen := map[int]bool{}
+		for _, opt := range options {
+			if seen[opt.SortOrder] {
+				return errors.New("Câu sắp xếp: sort_order của các mục không được trùng nhau")
+			}
+			seen[opt.SortOrder] = true
+		}
+
+	case models.QuestionTypeMatching:
+		if len(options) < 2 {
+			return errors.New("Câu ghép nối cần ít nhất 2 cặp")
+		}
+		for _, opt := range options {
+			if strings.TrimSpace(opt.MatchText) == "" {
+				return errors.New("Câu ghép nối: mỗi cặp phải có match_text")
+			}
+		}
+	}
+
+	return nil
+}
+
+// prepareQuestionForStudent ẩn mọi thông tin lộ đáp án (đáp án đúng, giải thích, rubric) của một câu
+// trong đề riêng trước khi trả cho sinh viên (thứ tự đáp án đã được xáo khi phát đề)
+func prepareQuestionForStudent(q *models.AssignmentQuestion) {
+	q.Explanation = ""
+	q.Rubric = "" // Rubric có thể chứa đáp án mẫu
+	for i := range q.Options {
+		q.Options[i].IsCorrect = false
+	}
+
+	switch q.Type {
+	case models.QuestionTypeFillBlank:
+		// Options chính là đáp án
+		q.Options = []models.AssignmentOption{}
+
+	case models.QuestionTypeOrdering:
+		for i := range q.Options {
+			q.Options[i].SortOrder = 0
+		}
+
+	case models.QuestionTypeMatching:
+		// Đề luôn có MatchKeys (loadSubmissionPaper cấp cho đề cũ); không bao giờ lộ option_id làm id lựa chọn
+		keys := q.MatchKeys
+		matchText := make(map[string]string, len(q.Options))
+		for i := range q.Options {
+			matchText[q.Options[i].ID.String()] = q.Options[i].MatchText
+			q.Options[i].MatchText = ""
+		}
+
+		choices := make([]models.MatchChoice, 0, len(keys))
+		for choiceID, optionID := range keys {
+			id, err := uuid.Parse(choiceID)
+			if err != nil {
+				continue
+			}
+			choices = append(choices, models.MatchChoice{ID: id, Text: matchText[optionID]})
+		}
+		// id lựa chọn là ngẫu nhiên nên sắp theo id vừa xáo trộn vừa giữ nguyên thứ tự giữa các lần tải đề
+		sort.Slice(choices, func(i, j int) bool { return choices[i].ID.String() < choices[j].ID.String() })
+		q.MatchChoices = choices
+		q.MatchKeys = nil
+	}
+}
+
+// scoreAnswer chấm một câu theo loại câu hỏi, trả về (đúng hoàn toàn, điểm đạt được)
+func scoreAnswer(q models.AssignmentQuestion, ans AssignmentAnswerInput) (bool, float64) {
+	if ans.IsEmpty() {
+		return false, 0
+	}
+
+	switch q.Type {
+	case models.QuestionTypeMultipleChoice:
+		return scoreMultipleChoice(q, ans.SelectedIDs)
+	case models.QuestionTypeFillBlank:
+		return scoreFillBlank(q, ans.TextAnswer)
+	case models.QuestionTypeOrdering:
+		return scoreOrdering(q, ans.SelectedIDs)
+	case models.QuestionTypeMatching:
+		return scoreMatching(q, ans.Pairs)
+	case models.QuestionTypeShortAnswer, models.QuestionTypeEssay:
+		// Chấm tay - điểm được cập nhật khi giảng viên chấm
+		return false, 0
+	default:
+		// single_choice, true_false: tất cả hoặc không
+		if ans.SelectedID == nil {
+			return false, 0
+		}
+		for _, opt := range q.Options {
+			if opt.IsCorrect && opt.ID == *ans.SelectedID {
+				return true, q.Points
+			}
+		}
+		return false, 0
+	}
+}
+
+// Nhiều đáp án: (số đúng đã chọn - số sai đã chọn) / tổng số đáp án đúng, không âm
+func scoreMultipleChoice(q models.AssignmentQuestion, selected []uuid.UUID) (bool, float64) {
+	correct := map[uuid.UUID]bool{}
+	for _, opt := range q.Options {
+		if opt.IsCorrect {
+			correct[opt.ID] = true
+		}
+	}
+	if len(correct) == 0 {
+		return false, 0
+	}
+
+	picked := map[uuid.UUID]bool{}
+	right, wrong := 0, 0
+	for _, id := range selected {
+		if picked[id] {
+			continue
+		}
+		picked[id] = true
+		if correct[id] {
+			right++
+		} else {
+			wrong++
+		}
+	}
+
+	ratio := math.Max(0, float64(right-wrong)/float64(len(correct)))
+	return right == len(correct) && wrong == 0, roundPoints(q.Points * ratio)
+}
+
+// Điền khuyết: so khớp không phân biệt hoa thường / khoảng trắng với các đáp án được chấp nhận
+func scoreFillBlank(q models.AssignmentQuestion, text string) (bool, float64) {
+	answer := normalizeBlankAnswer(text)
+	for _, opt := range q.Options {
+		if normalizeBlankAnswer(opt.OptionText) == answer {
+			return true, q.Points
+		}
+	}
+	return false, 0
+}
+
+func normalizeBlankAnswer(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+// Sắp xếp: điểm theo tỉ lệ vị trí đúng
+func scoreOrdering(q models.AssignmentQuestion, order []uuid.UUID) (bool, float64) {
+	expected := make([]models.AssignmentOption, len(q.Options))
+	copy(expected, q.Options)
+	sort.SliceStable(expected, func(i, j int) bool { return expected[i].SortOrder < expected[j].SortOrder })
+
+	if len(expected) == 0 {
+		return false, 0
+	}
+
+	matched := 0
+	for i, opt := range expected {
+		if i < len(order) && order[i] == opt.ID {
+			matched++
+		}
+	}
+
+	ratio := float64(matched) / float64(len(expected))
+	return matched == len(expected), roundPoints(q.Points * ratio)
+}
+
+// Ghép nối: điểm theo tỉ lệ cặp ghép đúng.
+// Lựa chọn vế phải được ánh xạ về option qua MatchKeys của đề
+func scoreMatching(q models.AssignmentQuestion, pairs map[string]string) (bool, float64) {
+	if len(q.Options) == 0 {
+		return false, 0
+	}
+
+	matched := 0
+	for _, opt := range q.Options {
+		chosen, ok := pairs[opt.ID.String()]
+		if !ok {
+			continue
+		}
+		if chosen, ok = q.MatchKeys[chosen]; !ok {
+			continue
+		}
+		chosenID, err := uuid.Parse(chosen)
+		if err != nil {
+			continue
+		}
+		if chosenID == opt.ID {
+			matched++
+			continue
+		}
+		// Chấp nhận lựa chọn vế phải trùng nội dung (trường hợp nhiều cặp cùng match_text)
+		for _, other := range q.Options {
+			if other.ID == chosenID && strings.TrimSpace(other.MatchText) == strings.TrimSpace(opt.MatchText) {
+				matched++
+				break
+			}
+		}
+	}
+
+	ratio := float64(matched) / float64(len(q.Options))
+	return matched == len(q.Options), roundPoints(q.Points * ratio)
+}
+
+func roundPoints(p float64) float64 {
+	return math.Round(p*100) / 100
+}
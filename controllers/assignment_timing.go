@@ -0,0 +1,475 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/vnkhanh/e-podcast-backend/models"
+	"gorm.io/gorm"
+)
+
+// ==================== THỜI GIAN LÀM BÀI & NỘP MUỘN ====================
+
+// Cho phép trễ một chút so với thời hạn để bù độ trễ mạng khi client nộp đúng lúc hết giờ
+const submissionGracePeriod = 30 * time.Second
+
+var errSubmissionClosed = errors.New("Bài làm đã được nộp")
+
+// checkAssignmentOpen kiểm tra còn được bắt đầu lần làm mới hay không (theo hạn nộp / chính sách nộp muộn)
+func checkAssignmentOpen(assignment models.Assignment, now time.Time) error {
+	if assignment.DueDate == nil || !now.After(*assignment.DueDate) {
+		return nil
+	}
+	if !assignment.AllowLate {
+		return errors.New("Bài tập đã quá hạn nộp")
+	}
+	if assignment.LateUntil != nil && now.After(*assignment.LateUntil) {
+		return errors.New("Đã hết thời gian nhận bài nộp muộn")
+	}
+	return nil
+}
+
+// attemptDeadline tính thời hạn của một lần làm bài: StartedAt + TimeLimit,
+// không vượt quá hạn nộp (hoặc hạn nộp muộn). nil = không giới hạn
+func attemptDeadline(assignment models.Assignment, startedAt time.Time) *time.Time {
+	var deadline *time.Time
+	if assignment.TimeLimit > 0 {
+		t := startedAt.Add(time.Duration(assignment.TimeLimit) * time.Minute)
+		deadline = &t
+	}
+
+	var cutoff *time.Time
+	if assignment.DueDate != nil {
+		if !assignment.AllowLate {
+			cutoff = assignment.DueDate
+		} else if assignment.LateUntil != nil {
+			cutoff = assignment.LateUntil
+		}
+	}
+	if cutoff != nil && (deadline == nil || cutoff.Before(*deadline)) {
+		t := *cutoff
+		deadline = &t
+	}
+	return deadline
+}
+
+// submissionDeadline lấy thời hạn đã lưu, bài làm cũ chưa có thì tính lại từ StartedAt
+func submissionDeadline(submission models.AssignmentSubmission) *time.Time {
+	if submission.ExpiresAt != nil {
+		return submission.ExpiresAt
+	}
+	return attemptDeadline(submission.Assignment, submission.StartedAt)
+}
+
+// isSubmissionExpired: đã quá thời hạn (cộng thời gian ân hạn)
+func isSubmissionExpired(submission models.AssignmentSubmission, now time.Time) bool {
+	deadline := submissionDeadline(submission)
+	return deadline != nil && now.After(deadline.Add(submissionGracePeriod))
+}
+
+// latePenaltyPercent: % điểm bị trừ, mỗi ngày trễ (làm tròn lên) trừ LatePenaltyPerDay
+func latePenaltyPercent(assignment models.Assignment, submittedAt time.Time) (bool, float64) {
+	if assignment.DueDate == nil || !submittedAt.After(*assignment.DueDate) {
+		return false, 0
+	}
+
+	days := math.Ceil(submittedAt.Sub(*assignment.DueDate).Hours() / 24)
+	penalty := days * assignment.LatePenaltyPerDay
+	maxPenalty := math.Min(math.Max(assignment.LatePenaltyMax, 0), 100)
+	return true, math.Min(penalty, maxPenalty)
+}
+
+// applyLatePenalty quy đổi điểm thang 10 sau khi trừ điểm nộp muộn
+func applyLatePenalty(score, penaltyPercent float64) float64 {
+	if penaltyPercent <= 0 {
+		return score
+	}
+	return roundPoints(score * (1 - penaltyPercent/100))
+}
+
+// answersFromDraft chuyển các câu trả lời đã autosave thành input để chấm
+func answersFromDraft(db *gorm.DB, submissionID uuid.UUID) []AssignmentAnswerInput {
+	var saved []models.AssignmentAnswer
+	db.Where("submission_id = ?", submissionID).Find(&saved)
+
+	inputs := make([]AssignmentAnswerInput, 0, len(saved))
+	for _, ans := range saved {
+		input := AssignmentAnswerInput{
+			QuestionID:  ans.QuestionID,
+			SelectedIDs: ans.SelectedIDs,
+			TextAnswer:  ans.TextAnswer,
+			Pairs:       ans.Pairs,
+
+			TimeSpentSec: ans.TimeSpentSec,
+		}
+		if ans.SelectedID != uuid.Nil {
+			selected := ans.SelectedID
+			input.SelectedID = &selected
+		}
+		inputs = append(inputs, input)
+	}
+	return inputs
+}
+
+// gradeSubmission chấm và chốt bài làm. submission phải preload Assignment.
+// Thời gian làm bài được tính từ StartedAt phía server, không tin time_spent của client.
+func gradeSubmission(db *gorm.DB, submission *models.AssignmentSubmission, answers []AssignmentAnswerInput, submittedAt time.Time, auto bool) (float64, float64, error) {
+	// Hạn nộp riêng của lớp / ngoại lệ của sinh viên (nếu có) quyết định nộp muộn và thời gian tối đa
+	applyStudentSchedule(db, &submission.Assignment, submission.UserID)
+	assignment := submission.Assignment
+
+	// Chấm theo đúng đề của lần làm bài này
+	questions, err := loadSubmissionPaper(db, *submission)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	isLate, penalty := latePenaltyPercent(assignment, submittedAt)
+	timeSpent := int(submittedAt.Sub(submission.StartedAt).Seconds())
+	if timeSpent < 0 {
+		timeSpent = 0
+	}
+	if assignment.TimeLimit > 0 && timeSpent > assignment.TimeLimit*60 {
+		timeSpent = assignment.TimeLimit * 60
+	}
+
+	var totalScore, maxScore float64
+	hasPendingGrading := false
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Chốt bài trước để sweeper và request nộp bài không chấm trùng
+		res := tx.Model(&models.AssignmentSubmission{}).
+			Where("id = ? AND submitted_at IS NULL", submission.ID).
+			Update("submitted_at", submittedAt)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errSubmissionClosed
+		}
+
+		for _, q := range questions {
+			maxScore += q.Points
+
+			userAnswer := AssignmentAnswerInput{QuestionID: q.ID}
+			for _, ans := range answers {
+				if ans.QuestionID == q.ID {
+					userAnswer = ans
+					break
+				}
+			}
+
+			// Chấm theo loại câu hỏi
+			isCorrect, pointsEarned := scoreAnswer(q, userAnswer)
+			totalScore += pointsEarned
+
+			// Câu tự luận có trả lời: chờ giảng viên chấm
+			gradingStatus := models.GradingStatusAuto
+			if isManualQuestion(q.Type) {
+				gradingStatus = models.GradingStatusGraded
+				if !userAnswer.IsEmpty() {
+					gradingStatus = models.GradingStatusPending
+					hasPendingGrading = true
+				}
+			}
+
+			selectedID := uuid.Nil
+			if userAnswer.SelectedID != nil {
+				selectedID = *userAnswer.SelectedID
+			}
+
+			// Tìm hoặc tạo câu trả lời
+			var answer models.AssignmentAnswer
+			findErr := tx.Where("submission_id = ? AND question_id = ?", submission.ID, q.ID).First(&answer).Error
+			if findErr != nil {
+				answer = models.AssignmentAnswer{
+					SubmissionID: submission.ID,
+					QuestionID:   q.ID,
+				}
+			}
+
+			answer.SelectedID = selectedID
+			answer.SelectedIDs = userAnswer.SelectedIDs
+			answer.TextAnswer = strings.TrimSpace(userAnswer.TextAnswer)
+			answer.Pairs = userAnswer.Pairs
+			answer.IsCorrect = isCorrect
+			answer.PointsEarned = pointsEarned
+			answer.GradingStatus = gradingStatus
+			if userAnswer.TimeSpentSec > answer.TimeSpentSec {
+				answer.TimeSpentSec = userAnswer.TimeSpentSec
+			}
+
+			var saveErr error
+			if findErr != nil {
+				saveErr = tx.Create(&answer).Error
+			} else {
+				saveErr = tx.Save(&answer).Error
+			}
+			if saveErr != nil {
+				return saveErr
+			}
+		}
+
+		scorePercent := 0.0
+		if maxScore > 0 {
+			scorePercent = (totalScore / maxScore) * 10 // chuẩn thang 10
+		}
+		scorePercent = applyLatePenalty(scorePercent, penalty)
+
+		gradingStatus := models.GradingStatusGraded
+		isPassed := scorePercent >= assignment.PassScore
+		if hasPendingGrading {
+			// Điểm tạm tính, chỉ xét đạt khi giảng viên chấm xong
+			gradingStatus = models.GradingStatusPending
+			isPassed = false
+		}
+
+		submission.Score = scorePercent
+		submission.IsPassed = isPassed
+		submission.TimeSpent = timeSpent
+		submission.SubmittedAt = &submittedAt
+		submission.GradingStatus = gradingStatus
+		submission.AutoSubmitted = auto
+		submission.IsLate = isLate
+		submission.LatePenalty = penalty
+
+		return tx.Model(&models.AssignmentSubmission{}).
+			Where("id = ?", submission.ID).
+			Updates(map[string]interface{}{
+				"score":          submission.Score,
+				"is_passed":      submission.IsPassed,
+				"time_spent":     submission.TimeSpent,
+				"grading_status": submission.GradingStatus,
+				"auto_submitted": submission.AutoSubmitted,
+				"is_late":        submission.IsLate,
+				"late_penalty":   submission.LatePenalty,
+			}).Error
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// Gợi ý điểm câu tự luận bằng AI (chạy nền)
+	if hasPendingGrading {
+		go suggestEssayGradesAsync(db, submission.ID)
+	}
+
+	return totalScore, maxScore, nil
+}
+
+// finalizeExpiredSubmission tự nộp bài đã hết giờ với các câu trả lời đã autosave
+func finalizeExpiredSubmission(db *gorm.DB, submission *models.AssignmentSubmission) error {
+	submittedAt := time.Now()
+	if deadline := submissionDeadline(*submission); deadline != nil && deadline.Before(submittedAt) {
+		submittedAt = *deadline
+	}
+
+	_, _, err := gradeSubmission(db, submission, answersFromDraft(db, submission.ID), submittedAt, true)
+	return err
+}
+
+// StartAssignmentSweeper chạy nền, định kỳ tự nộp các bài làm đã hết giờ
+func StartAssignmentSweeper(db *gorm.DB) {
+	ticker := time.NewTicker(time.Minute)
+
+	go func() {
+		defer ticker.Stop()
+		for range ticker.C {
+			sweepExpiredSubmissions(db)
+		}
+	}()
+
+	log.Println("Assignment sweeper đã được khởi động (chạy mỗi phút)")
+}
+
+func sweepExpiredSubmissions(db *gorm.DB) {
+	backfillDraftDeadlines(db)
+
+	var submissions []models.AssignmentSubmission
+	if err := db.Preload("Assignment").
+		Where("submitted_at IS NULL AND expires_at IS NOT NULL AND expires_at < ?", time.Now().Add(-submissionGracePeriod)).
+		Limit(200).
+		Find(&submissions).Error; err != nil {
+		log.Println("Sweeper: không thể lấy bài làm hết giờ:", err)
+		return
+	}
+
+	count := 0
+	for i := range submissions {
+		if err := finalizeExpiredSubmission(db, &submissions[i]); err != nil {
+			if !errors.Is(err, errSubmissionClosed) {
+				log.Printf("Sweeper: không thể tự nộp bài %s: %v", submissions[i].ID, err)
+			}
+			continue
+		}
+		count++
+	}
+	if count > 0 {
+		log.Printf("Sweeper: đã tự nộp %d bài làm hết giờ", count)
+	}
+}
+
+// backfillDraftDeadlines lưu thời hạn cho bài làm dở bắt đầu trước khi có expires_at,
+// chỉ xét bài tập có giới hạn thời gian hoặc hạn chót không nhận bài muộn (các bài khác không bao giờ hết giờ)
+func backfillDraftDeadlines(db *gorm.DB) {
+	var submissions []models.AssignmentSubmission
+	timed := db.Model(&models.Assignment{}).Select("id").
+		Where("time_limit > 0 OR (due_date IS NOT NULL AND (allow_late = ? OR late_until IS NOT NULL))", false)
+	if err := db.Preload("Assignment").
+		Where("submitted_at IS NULL AND expires_at IS NULL AND assignment_id IN (?)", timed).
+		Limit(200).
+		Find(&submissions).Error; err != nil {
+		log.Println("Sweeper: không thể lấy bài làm chưa có thời hạn:", err)
+		return
+	}
+
+	for _, submission := range submissions {
+		assignment := submission.Assignment
+		applyStudentSchedule(db, &assignment, submission.UserID)
+		deadline := attemptDeadline(assignment, submission.StartedAt)
+		if deadline == nil {
+			continue
+		}
+		if err := db.Model(&models.AssignmentSubmission{}).
+			Where("id = ? AND expires_at IS NULL", submission.ID).
+			Update("expires_at", *deadline).Error; err != nil {
+			log.Printf("Sweeper: không thể lưu thời hạn bài làm %s: %v", submission.ID, err)
+		}
+	}
+}
+
+// ==================== NHẬT KÝ LÀM BÀI ====================
+
+var validAttemptEvents = map[string]bool{
+	models.AttemptEventFocusLost:      true,
+	models.AttemptEventTabHidden:      true,
+	models.AttemptEventFullscreenExit: true,
+	models.AttemptEventCopy:           true,
+	models.AttemptEventPaste:          true,
+}
+
+// Client báo các sự kiện rời tab / mất focus trong lúc làm bài
+// POST /user/assignments/submissions/:submissionId/events
+func LogAttemptEvents(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userUUID, _ := uuid.Parse(c.GetString("user_id"))
+
+	subUUID, err := uuid.Parse(c.Param("submissionId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID bài làm không hợp lệ"})
+		return
+	}
+
+	var req struct {
+		Events []struct {
+			Type       string     `json:"type"`
+			DurationMs int        `json:"duration_ms"`
+			Detail     string     `json:"detail"`
+			OccurredAt *time.Time `json:"occurred_at"`
+		} `json:"events"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.Events) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Không có sự kiện nào"})
+		return
+	}
+	if len(req.Events) > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tối đa 100 sự kiện mỗi lần gửi"})
+		return
+	}
+
+	var submission models.AssignmentSubmission
+	if err := db.Where("id = ? AND user_id = ? AND submitted_at IS NULL", subUUID, userUUID).
+		First(&submission).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy bài làm hoặc đã nộp rồi"})
+		return
+	}
+
+	events := make([]models.AssignmentAttemptEvent, 0, len(req.Events))
+	for _, e := range req.Events {
+		if !validAttemptEvents[e.Type] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Loại sự kiện không hợp lệ: " + e.Type})
+			return
+		}
+		detail := e.Detail
+		if r := []rune(detail); len(r) > 500 {
+			detail = string(r[:500])
+		}
+		events = append(events, models.AssignmentAttemptEvent{
+			SubmissionID: subUUID,
+			UserID:       userUUID,
+			Type:         e.Type,
+			DurationMs:   e.DurationMs,
+			Detail:       detail,
+			ClientTime:   e.OccurredAt,
+		})
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&events).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.AssignmentSubmission{}).
+			Where("id = ?", subUUID).
+			UpdateColumn("integrity_flags", gorm.Expr("integrity_flags + ?", len(events))).Error
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lưu sự kiện"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Đã ghi nhận", "count": len(events)})
+}
+
+// Giảng viên xem nhật ký làm bài của một bài nộp
+// GET /admin/assignments/submissions/:id/events
+func GetSubmissionEvents(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	subUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID bài nộp không hợp lệ"})
+		return
+	}
+
+	var submission models.AssignmentSubmission
+	if err := db.Preload("Assignment").First(&submission, "id = ?", subUUID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bài nộp không tìm thấy"})
+		return
+	}
+	if !canManageAssignment(c, db, submission.Assignment, models.PermAssignmentGrade) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Bạn không có quyền xem bài nộp này"})
+		return
+	}
+
+	var events []models.AssignmentAttemptEvent
+	if err := db.Where("submission_id = ?", subUUID).Order("created_at ASC").Find(&events).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy nhật ký"})
+		return
+	}
+
+	// Tổng hợp theo loại và tổng thời gian rời khỏi bài
+	summary := map[string]int{}
+	awayMs := 0
+	for _, e := range events {
+		summary[e.Type]++
+		awayMs += e.DurationMs
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"events":         events,
+		"summary":        summary,
+		"total_away_sec": awayMs / 1000,
+		"auto_submitted": submission.AutoSubmitted,
+		"is_late":        submission.IsLate,
+		"time_spent":     submission.TimeSpent,
+	})
+}
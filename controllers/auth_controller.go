@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"cloud.google.com/go/auth/credentials/idtoken"
@@ -61,11 +62,12 @@ func Register(c *gin.Context) {
 	// Tạo user mới
 	newUser := models.User{
 		// ID sẽ tự sinh vì default:gen_random_uuid()
-		FullName: input.FullName,
-		Email:    input.Email,
-		Password: string(hashed),
-		Role:     models.RoleUser,
-		Status:   BoolPtr(true), // default true
+		FullName:      input.FullName,
+		Email:         input.Email,
+		Password:      string(hashed),
+		Role:          models.RoleUser,
+		Status:        BoolPtr(true), // default true
+		EmailVerified: BoolPtr(false),
 	}
 
 	if err := config.DB.Create(&newUser).Error; err != nil {
@@ -73,12 +75,16 @@ func Register(c *gin.Context) {
 		return
 	}
 
+	// Gửi link xác minh; lỗi gửi mail thì user vẫn có thể yêu cầu gửi lại
+	emailSent := sendVerificationEmail(newUser) == nil
+
 	// Ẩn mật khẩu khi trả về
 	newUser.Password = ""
 	c.JSON(http.StatusCreated,
 		gin.H{
-			"message": "Đăng ký thành công",
-			"user":    newUser,
+			"message":    "Đăng ký thành công, vui lòng kiểm tra email để xác minh tài khoản",
+			"email_sent": emailSent,
+			"user":       newUser,
 		})
 }
 
@@ -89,37 +95,57 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	// Sai nhiều lần thì khóa tạm thời theo email + IP (tăng dần), không khóa được tài khoản từ IP khác
+	lockKey := "login:" + strings.ToLower(strings.TrimSpace(input.Email)) + ":" + c.ClientIP()
+	if wait := utils.LockoutRemaining(lockKey); wait > 0 {
+		respondLoginLocked(c, wait)
+		return
+	}
+
 	var user models.User
 	if err := config.DB.Where("email = ?", input.Email).First(&user).Error; err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Email hoặc mật khẩu không đúng"})
+		failLogin(c, lockKey)
 		return
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Email hoặc mật khẩu không đúng"})
+		failLogin(c, lockKey)
 		return
 	}
+	utils.ClearFailures(lockKey)
 	if user.Status != nil && !*user.Status {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Tài khoản của bạn đã bị tạm khóa"})
 		return
 	}
 
-	// Sinh JWT (truyền ID dạng string và Role)
-	token, err := utils.GenerateToken(user.ID.String(), string(user.Role))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tạo token"})
+	// Email đăng ký chưa xác minh thì chưa cho đăng nhập
+	if user.EmailVerified != nil && !*user.EmailVerified {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":              "Email chưa được xác minh, vui lòng kiểm tra hộp thư",
+			"email_not_verified": true,
+		})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Đăng nhập thành công",
-		"token":   token,
-		"user": gin.H{
-			"id":        user.ID,
-			"email":     user.Email,
-			"full_name": user.FullName,
-			"role":      user.Role,
-		},
+	// Bước 2 (2FA) nếu có, sau đó tạo phiên đăng nhập
+	completeLogin(c, user, "password")
+}
+
+// failLogin ghi nhận lần đăng nhập sai, vượt ngưỡng thì trả 429 kèm thời gian chờ
+func failLogin(c *gin.Context, lockKey string) {
+	if wait := utils.RegisterFailure(lockKey, utils.LoginLockoutPolicy()); wait > 0 {
+		respondLoginLocked(c, wait)
+		return
+	}
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "Email hoặc mật khẩu không đúng"})
+}
+
+func respondLoginLocked(c *gin.Context, wait time.Duration) {
+	secs := utils.RetryAfterSeconds(wait)
+	c.Header("Retry-After", strconv.Itoa(secs))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       fmt.Sprintf("Đăng nhập sai quá nhiều lần, vui lòng thử lại sau %d giây", secs),
+		"retry_after": secs,
 	})
 }
 
@@ -168,22 +194,15 @@ func GoogleLogin(c *gin.Context) {
 		return
 	}
 
-	// Tạo JWT token: truyền ID dạng string
-	token, err := utils.GenerateToken(user.ID.String(), string(user.Role))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tạo token"})
-		return
+	// Google đã xác minh email -> đánh dấu luôn cho tài khoản đăng ký chưa xác minh
+	if user.EmailVerified != nil && !*user.EmailVerified {
+		if verified, _ := payload.Claims["email_verified"].(bool); verified {
+			config.DB.Model(&user).Update("email_verified", true)
+		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"token": token,
-		"user": gin.H{
-			"id":        user.ID,
-			"email":     user.Email,
-			"full_name": user.FullName,
-			"role":      user.Role,
-		},
-	})
+	// Bước 2 (2FA) nếu có, sau đó tạo phiên đăng nhập
+	completeLogin(c, user, "google")
 }
 
 // ========== QUÊN MẬT KHẨU ==========
@@ -294,7 +313,12 @@ func ResetPassword(c *gin.Context) {
 
 	// Cập nhật mật khẩu
 	hashed, _ := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
-	db.Model(&models.User{}).Where("id = ?", userID).Update("password", string(hashed))
+	// Nhận được link qua email nghĩa là email đã được xác minh
+	db.Model(&models.User{}).Where("id = ?", userID).
+		Updates(map[string]interface{}{"password": string(hashed), "email_verified": true})
+
+	// Đăng xuất mọi thiết bị đang dùng mật khẩu cũ
+	utils.RevokeUserSessions(db, pr.UserID, models.SessionRevokedPasswordChange, "")
 
 	// XÓA TOKEN SAU KHI DÙNG THÀNH CÔNG (thay vì update used = true)
 	db.Delete(&pr)
@@ -452,6 +476,9 @@ func ChangePassword(c *gin.Context) {
 		return
 	}
 
+	// Đăng xuất các thiết bị khác, giữ phiên hiện tại
+	utils.RevokeUserSessions(db, user.ID, models.SessionRevokedPasswordChange, c.GetString("session_id"))
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Đổi mật khẩu thành công",
 	})
@@ -626,6 +653,11 @@ func ToggleUserStatus(c *gin.Context) {
 		return
 	}
 
+	// Khóa tài khoản thì thu hồi ngay mọi phiên đăng nhập
+	if !*user.Status {
+		utils.RevokeUserSessions(config.DB, user.ID, models.SessionRevokedAccountLocked, "")
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Cập nhật trạng thái thành công",
 		"user":    user,
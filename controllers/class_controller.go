@@ -0,0 +1,840 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"encoding/csv"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/vnkhanh/e-podcast-backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ==================== LỚP HỌC PHẦN ====================
+
+const inviteCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// generateInviteCode sinh mã mời 8 ký tự, bỏ các ký tự dễ nhầm (0/O, 1/I)
+func generateInviteCode(db *gorm.DB) (string, error) {
+	for attempt := 0; attempt < 5; attempt++ {
+		b := make([]byte, 8)
+		for i := range b {
+			n, err := rand.Int(rand.Reader, big.NewInt(int64(len(inviteCodeAlphabet))))
+			if err != nil {
+				return "", err
+			}
+			b[i] = inviteCodeAlphabet[n.Int64()]
+		}
+		code := string(b)
+
+		var count int64
+		db.Model(&models.Class{}).Where("invite_code = ?", code).Count(&count)
+		if count == 0 {
+			return code, nil
+		}
+	}
+	return "", errors.New("không sinh được mã mời")
+}
+
+// loadOwnedClass lấy lớp và kiểm tra quyền (giảng viên chỉ quản lý lớp mình phụ trách)
+func loadOwnedClass(c *gin.Context, db *gorm.DB, classID string) (*models.Class, bool) {
+	classUUID, err := uuid.Parse(classID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID lớp không hợp lệ"})
+		return nil, false
+	}
+
+	var class models.Class
+	if err := db.Preload("Subject").First(&class, "id = ?", classUUID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy lớp"})
+		return nil, false
+	}
+
+	if c.GetString("role") == string(models.RoleLecturer) && class.LecturerID.String() != c.GetString("user_id") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Không có quyền với lớp này"})
+		return nil, false
+	}
+
+	return &class, true
+}
+
+// Danh sách lớp
+// GET /admin/classes?subject_id=&search=&archived=
+func GetClasses(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	query := db.Model(&models.Class{})
+	if c.GetString("role") == string(models.RoleLecturer) {
+		query = query.Where("lecturer_id = ?", c.GetString("user_id"))
+	}
+	if subjectID := c.Query("subject_id"); subjectID != "" {
+		query = query.Where("subject_id = ?", subjectID)
+	}
+	if search := c.Query("search"); search != "" {
+		query = query.Where("name ILIKE ?", "%"+search+"%")
+	}
+	query = query.Where("is_archived = ?", c.Query("archived") == "true")
+
+	var classes []models.Class
+	if err := query.Preload("Subject").Preload("Lecturer").
+		Order("created_at DESC").
+		Find(&classes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy danh sách lớp"})
+		return
+	}
+
+	type ClassWithCount struct {
+		models.Class
+		StudentCount int64 `json:"student_count"`
+	}
+
+	result := make([]ClassWithCount, 0, len(classes))
+	for _, cl := range classes {
+		cl.Lecturer.Password = ""
+		var count int64
+		db.Model(&models.ClassEnrollment{}).Where("class_id = ?", cl.ID).Count(&count)
+		result = append(result, ClassWithCount{Class: cl, StudentCount: count})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"classes": result,
+		"total":   len(result),
+	})
+}
+
+type classRequest struct {
+	SubjectID  string `json:"subject_id"`
+	LecturerID string `json:"lecturer_id"` // Chỉ admin được chỉ định giảng viên khác
+	Name       string `json:"name"`
+	Semester   string `json:"semester"`
+	AllowJoin  *bool  `json:"allow_join"`
+	IsArchived *bool  `json:"is_archived"`
+}
+
+// Tạo lớp
+// POST /admin/classes
+func CreateClass(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	role := c.GetString("role")
+
+	var req classRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tên lớp không được để trống"})
+		return
+	}
+
+	subjectUUID, err := uuid.Parse(req.SubjectID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "subject_id không hợp lệ"})
+		return
+	}
+	var subject models.Subject
+	if err := db.First(&subject, "id = ?", subjectUUID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy môn học"})
+		return
+	}
+
+	lecturerUUID, _ := uuid.Parse(c.GetString("user_id"))
+	if role == string(models.RoleAdmin) && req.LecturerID != "" {
+		id, err := uuid.Parse(req.LecturerID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "lecturer_id không hợp lệ"})
+			return
+		}
+		var lecturer models.User
+		if err := db.First(&lecturer, "id = ? AND role = ?", id, models.RoleLecturer).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy giảng viên"})
+			return
+		}
+		lecturerUUID = id
+	}
+
+	code, err := generateInviteCode(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	class := models.Class{
+		SubjectID:  subjectUUID,
+		LecturerID: lecturerUUID,
+		Name:       req.Name,
+		Semester:   strings.TrimSpace(req.Semester),
+		InviteCode: code,
+		AllowJoin:  true,
+	}
+	if err := db.Create(&class).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tạo lớp"})
+		return
+	}
+	class.Subject = subject
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Tạo lớp thành công",
+		"class":   class,
+	})
+}
+
+// Chi tiết lớp kèm danh sách sinh viên và bài tập đã giao
+// GET /admin/classes/:id
+func GetClassDetail(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	class, ok := loadOwnedClass(c, db, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var enrollments []models.ClassEnrollment
+	db.Preload("User").Where("class_id = ?", class.ID).Order("created_at ASC").Find(&enrollments)
+	for i := range enrollments {
+		enrollments[i].User.Password = ""
+	}
+
+	var targets []models.AssignmentClass
+	db.Preload("Assignment").Where("class_id = ?", class.ID).Order("created_at DESC").Find(&targets)
+
+	type ClassAssignment struct {
+		AssignmentID uuid.UUID  `json:"assignment_id"`
+		Title        string     `json:"title"`
+		IsPublished  bool       `json:"is_published"`
+		DueDate      *time.Time `json:"due_date,omitempty"`
+	}
+	assignments := make([]ClassAssignment, 0, len(targets))
+	for _, t := range targets {
+		due := t.DueDate
+		if due == nil {
+			due = t.Assignment.DueDate
+		}
+		assignments = append(assignments, ClassAssignment{
+			AssignmentID: t.AssignmentID,
+			Title:        t.Assignment.Title,
+			IsPublished:  t.Assignment.IsPublished,
+			DueDate:      due,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"class":       class,
+		"students":    enrollments,
+		"assignments": assignments,
+	})
+}
+
+// Cập nhật lớp
+// PUT /admin/classes/:id
+func UpdateClass(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	class, ok := loadOwnedClass(c, db, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var req classRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if name := strings.TrimSpace(req.Name); name != "" {
+		updates["name"] = name
+	}
+	if req.Semester != "" {
+		updates["semester"] = strings.TrimSpace(req.Semester)
+	}
+	if req.AllowJoin != nil {
+		updates["allow_join"] = *req.AllowJoin
+	}
+	if req.IsArchived != nil {
+		updates["is_archived"] = *req.IsArchived
+	}
+	if c.GetString("role") == string(models.RoleAdmin) && req.LecturerID != "" {
+		id, err := uuid.Parse(req.LecturerID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "lecturer_id không hợp lệ"})
+			return
+		}
+		updates["lecturer_id"] = id
+	}
+
+	if len(updates) > 0 {
+		if err := db.Model(class).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể cập nhật lớp"})
+			return
+		}
+	}
+
+	db.Preload("Subject").First(class, "id = ?", class.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Cập nhật lớp thành công",
+		"class":   class,
+	})
+}
+
+// Xóa lớp (bài tập đã giao cho lớp sẽ bị gỡ liên kết)
+// DELETE /admin/classes/:id
+func DeleteClass(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	class, ok := loadOwnedClass(c, db, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("class_id = ?", class.ID).Delete(&models.AssignmentClass{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("class_id = ?", class.ID).Delete(&models.ClassEnrollment{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(class).Error
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể xóa lớp"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Đã xóa lớp"})
+}
+
+// Đổi mã mời (mã cũ hết hiệu lực)
+// POST /admin/classes/:id/invite-code
+func RegenerateClassInviteCode(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	class, ok := loadOwnedClass(c, db, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	code, err := generateInviteCode(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := db.Model(class).Update("invite_code", code).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể đổi mã mời"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"invite_code": code})
+}
+
+// RosterResult là kết quả thêm một dòng trong danh sách sinh viên
+type RosterResult struct {
+	Row    int    `json:"row"`
+	Email  string `json:"email"`
+	Status string `json:"status"` // added | already_enrolled | not_found | invalid
+	Reason string `json:"reason,omitempty"`
+}
+
+// enrollByEmails thêm sinh viên vào lớp theo email, trả về kết quả từng dòng
+func enrollByEmails(db *gorm.DB, classID uuid.UUID, emails []string, via string) []RosterResult {
+	results := make([]RosterResult, 0, len(emails))
+	seen := map[string]bool{}
+
+	for i, raw := range emails {
+		email := strings.ToLower(strings.TrimSpace(raw))
+		result := RosterResult{Row: i + 1, Email: email}
+
+		switch {
+		case email == "" || !strings.Contains(email, "@"):
+			result.Status = "invalid"
+			result.Reason = "Email không hợp lệ"
+			results = append(results, result)
+			continue
+		case seen[email]:
+			result.Status = "invalid"
+			result.Reason = "Email bị trùng trong danh sách"
+			results = append(results, result)
+			continue
+		}
+		seen[email] = true
+
+		var user models.User
+		if err := db.Where("LOWER(email) = ?", email).First(&user).Error; err != nil {
+			result.Status = "not_found"
+			result.Reason = "Chưa có tài khoản với email này"
+			results = append(results, result)
+			continue
+		}
+		if user.Role != models.RoleUser {
+			result.Status = "invalid"
+			result.Reason = "Tài khoản không phải sinh viên"
+			results = append(results, result)
+			continue
+		}
+
+		enrollment := models.ClassEnrollment{ClassID: classID, UserID: user.ID, JoinedVia: via}
+		res := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&enrollment)
+		switch {
+		case res.Error != nil:
+			result.Status = "invalid"
+			result.Reason = "Không thể thêm vào lớp"
+		case res.RowsAffected == 0:
+			result.Status = "already_enrolled"
+		default:
+			result.Status = "added"
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+func summarizeRoster(results []RosterResult) gin.H {
+	summary := map[string]int{}
+	for _, r := range results {
+		summary[r.Status]++
+	}
+	return gin.H{
+		"results": results,
+		"summary": summary,
+	}
+}
+
+// Thêm sinh viên theo danh sách email
+// POST /admin/classes/:id/students  {emails: [...]}
+func AddClassStudents(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	class, ok := loadOwnedClass(c, db, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Emails []string `json:"emails"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.Emails) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Danh sách email không được để trống"})
+		return
+	}
+
+	c.JSON(http.StatusOK, summarizeRoster(enrollByEmails(db, class.ID, req.Emails, models.EnrollmentViaManual)))
+}
+
+// Import danh sách sinh viên từ CSV (cột email, có thể có dòng tiêu đề)
+// POST /admin/classes/:id/roster (multipart: file)
+func ImportClassRoster(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	class, ok := loadOwnedClass(c, db, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Không có file đính kèm"})
+		return
+	}
+	f, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Không đọc được file"})
+		return
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	emailCol := 0
+	var emails []string
+	for rowIndex := 0; ; rowIndex++ {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "File CSV không hợp lệ: " + err.Error()})
+			return
+		}
+
+		// Dòng tiêu đề: tìm cột email
+		if rowIndex == 0 {
+			isHeader := false
+			for i, col := range row {
+				name := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(col, "\uFEFF")))
+				if name == "email" {
+					emailCol = i
+					isHeader = true
+				}
+			}
+			if isHeader {
+				continue
+			}
+		}
+
+		if emailCol < len(row) {
+			emails = append(emails, row[emailCol])
+		} else {
+			emails = append(emails, "")
+		}
+	}
+
+	if len(emails) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File không có dòng dữ liệu"})
+		return
+	}
+
+	c.JSON(http.StatusOK, summarizeRoster(enrollByEmails(db, class.ID, emails, models.EnrollmentViaRoster)))
+}
+
+// Xóa sinh viên khỏi lớp
+// DELETE /admin/classes/:id/students/:userId
+func RemoveClassStudent(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	class, ok := loadOwnedClass(c, db, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	res := db.Where("class_id = ? AND user_id = ?", class.ID, c.Param("userId")).Delete(&models.ClassEnrollment{})
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể xóa sinh viên khỏi lớp"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sinh viên không thuộc lớp này"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Đã xóa sinh viên khỏi lớp"})
+}
+
+// ==================== GIAO BÀI TẬP CHO LỚP ====================
+
+// Danh sách lớp được giao bài tập
+// GET /admin/assignments/:id/classes
+func GetAssignmentClasses(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	var assignment models.Assignment
+	if err := db.First(&assignment, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy bài tập"})
+		return
+	}
+	if !canManageAssignment(c, db, assignment, models.PermAssignmentGrade) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Bạn không có quyền xem bài tập này"})
+		return
+	}
+
+	var targets []models.AssignmentClass
+	if err := db.Preload("Class.Subject").Where("assignment_id = ?", assignment.ID).Find(&targets).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy danh sách lớp"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"classes":  targets,
+		"due_date": assignment.DueDate,
+	})
+}
+
+// Giao bài tập cho các lớp (thay toàn bộ danh sách). Danh sách rỗng = mọi sinh viên đều thấy.
+// PUT /admin/assignments/:id/classes  {classes: [{class_id, due_date}]}
+func UpdateAssignmentClasses(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	role := c.GetString("role")
+	userID := c.GetString("user_id")
+
+	var assignment models.Assignment
+	if err := db.First(&assignment, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy bài tập"})
+		return
+	}
+	if !canManageAssignment(c, db, assignment, models.PermAssignmentManage) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Bạn không có quyền chỉnh sửa bài tập này"})
+		return
+	}
+
+	var req struct {
+		Classes []struct {
+			ClassID uuid.UUID  `json:"class_id"`
+			DueDate *time.Time `json:"due_date"`
+		} `json:"classes"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	targets := make([]models.AssignmentClass, 0, len(req.Classes))
+	seen := map[uuid.UUID]bool{}
+	for _, item := range req.Classes {
+		if seen[item.ClassID] {
+			continue
+		}
+		seen[item.ClassID] = true
+
+		var class models.Class
+		if err := db.First(&class, "id = ?", item.ClassID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Không tìm thấy lớp " + item.ClassID.String()})
+			return
+		}
+		if role == string(models.RoleLecturer) && class.LecturerID.String() != userID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Bạn không phụ trách lớp " + class.Name})
+			return
+		}
+		targets = append(targets, models.AssignmentClass{
+			AssignmentID: assignment.ID,
+			ClassID:      class.ID,
+			DueDate:      item.DueDate,
+		})
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("assignment_id = ?", assignment.ID).Delete(&models.AssignmentClass{}).Error; err != nil {
+			return err
+		}
+		if len(targets) == 0 {
+			return nil
+		}
+		return tx.Create(&targets).Error
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể giao bài tập cho lớp"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Đã cập nhật lớp được giao",
+		"classes": targets,
+	})
+}
+
+// ==================== PHÍA SINH VIÊN ====================
+
+// visibleAssignmentsScope lọc bài tập sinh viên được thấy:
+//   - bài được giao cho lớp (chưa lưu trữ) sinh viên đang học
+//   - bài không gắn lớp nào: sinh viên phải học một lớp (chưa lưu trữ) của môn chứa podcast;
+//     môn chưa mở lớp nào thì bài vẫn công khai như trước khi có lớp học
+func visibleAssignmentsScope(userID uuid.UUID) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(`((NOT EXISTS (SELECT 1 FROM assignment_classes ac WHERE ac.assignment_id = assignments.id)
+				AND (EXISTS (SELECT 1 FROM podcasts p
+						JOIN chapters ch ON ch.id = p.chapter_id
+						JOIN classes cl ON cl.subject_id = ch.subject_id
+						JOIN class_enrollments ce ON ce.class_id = cl.id
+						WHERE p.id = assignments.podcast_id AND ce.user_id = ? AND cl.is_archived = false)
+					OR NOT EXISTS (SELECT 1 FROM podcasts p
+						JOIN chapters ch ON ch.id = p.chapter_id
+						JOIN classes cl ON cl.subject_id = ch.subject_id
+						WHERE p.id = assignments.podcast_id AND cl.is_archived = false)))
+			OR EXISTS (SELECT 1 FROM assignment_classes ac
+				JOIN class_enrollments ce ON ce.class_id = ac.class_id
+				JOIN classes cl ON cl.id = ac.class_id
+				WHERE ac.assignment_id = assignments.id AND ce.user_id = ? AND cl.is_archived = false))`, userID, userID)
+	}
+}
+
+// canAccessAssignment kiểm tra sinh viên có thuộc lớp được giao bài tập
+func canAccessAssignment(db *gorm.DB, assignmentID, userID uuid.UUID) bool {
+	var count int64
+	db.Model(&models.Assignment{}).
+		Where("assignments.id = ?", assignmentID).
+		Scopes(visibleAssignmentsScope(userID)).
+		Count(&count)
+	return count > 0
+}
+
+// applyClassDueDate thay hạn nộp chung bằng hạn riêng của lớp (chưa lưu trữ) sinh viên đang học
+// (học nhiều lớp cùng được giao thì lấy hạn muộn nhất)
+func applyClassDueDate(db *gorm.DB, assignment *models.Assignment, userID uuid.UUID) {
+	var dueDates []time.Time
+	db.Model(&models.AssignmentClass{}).
+		Joins("JOIN class_enrollments ce ON ce.class_id = assignment_classes.class_id").
+		Joins("JOIN classes cl ON cl.id = assignment_classes.class_id").
+		Where("assignment_classes.assignment_id = ? AND ce.user_id = ? AND cl.is_archived = ? AND assignment_classes.due_date IS NOT NULL", assignment.ID, userID, false).
+		Pluck("assignment_classes.due_date", &dueDates)
+
+	if len(dueDates) == 0 {
+		return
+	}
+	latest := dueDates[0]
+	for _, due := range dueDates[1:] {
+		if due.After(latest) {
+			latest = due
+		}
+	}
+	assignment.DueDate = &latest
+}
+
+// Tham gia lớp bằng mã mời
+// POST /user/classes/join  {invite_code}
+func JoinClass(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userUUID, _ := uuid.Parse(c.GetString("user_id"))
+
+	var req struct {
+		InviteCode string `json:"invite_code"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.InviteCode) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Vui lòng nhập mã mời"})
+		return
+	}
+
+	var class models.Class
+	if err := db.Preload("Subject").
+		Where("invite_code = ? AND is_archived = ?", strings.ToUpper(strings.TrimSpace(req.InviteCode)), false).
+		First(&class).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Mã mời không hợp lệ"})
+		return
+	}
+	if !class.AllowJoin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Lớp đã khóa mã mời"})
+		return
+	}
+
+	enrollment := models.ClassEnrollment{ClassID: class.ID, UserID: userUUID, JoinedVia: models.EnrollmentViaInvite}
+	res := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&enrollment)
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tham gia lớp"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusOK, gin.H{"message": "Bạn đã ở trong lớp này", "class": class})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Tham gia lớp thành công", "class": class})
+}
+
+// Rời lớp
+// DELETE /user/classes/:id
+func LeaveClass(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	res := db.Where("class_id = ? AND user_id = ?", c.Param("id"), c.GetString("user_id")).Delete(&models.ClassEnrollment{})
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể rời lớp"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bạn không ở trong lớp này"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Đã rời lớp"})
+}
+
+// Các lớp sinh viên đang học
+// GET /user/classes
+func GetMyClasses(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	var classes []models.Class
+	if err := db.Preload("Subject").Preload("Lecturer").
+		Joins("JOIN class_enrollments ce ON ce.class_id = classes.id").
+		Where("ce.user_id = ? AND classes.is_archived = ?", c.GetString("user_id"), false).
+		Order("classes.created_at DESC").
+		Find(&classes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy danh sách lớp"})
+		return
+	}
+
+	for i := range classes {
+		classes[i].Lecturer.Password = ""
+		// Sinh viên không cần thấy mã mời
+		classes[i].InviteCode = ""
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"classes": classes,
+		"total":   len(classes),
+	})
+}
+
+// Bài tập được giao cho các lớp của sinh viên
+// GET /user/classes/assignments?class_id=
+func GetMyClassAssignments(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userUUID, _ := uuid.Parse(c.GetString("user_id"))
+
+	query := db.Model(&models.AssignmentClass{}).
+		Joins("JOIN class_enrollments ce ON ce.class_id = assignment_classes.class_id").
+		Joins("JOIN classes cl ON cl.id = assignment_classes.class_id").
+		Joins("JOIN assignments a ON a.id = assignment_classes.assignment_id").
+		Where("ce.user_id = ? AND cl.is_archived = ? AND a.is_published = ?", userUUID, false, true)
+	if classID := c.Query("class_id"); classID != "" {
+		query = query.Where("assignment_classes.class_id = ?", classID)
+	}
+
+	var targets []models.AssignmentClass
+	if err := query.Preload("Assignment.Podcast").Preload("Class").
+		Order("assignment_classes.created_at DESC").
+		Find(&targets).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy danh sách bài tập"})
+		return
+	}
+
+	type MyAssignment struct {
+		AssignmentID uuid.UUID  `json:"assignment_id"`
+		Title        string     `json:"title"`
+		PodcastID    uuid.UUID  `json:"podcast_id"`
+		PodcastTitle string     `json:"podcast_title"`
+		ClassID      uuid.UUID  `json:"class_id"`
+		ClassName    string     `json:"class_name"`
+		DueDate      *time.Time `json:"due_date,omitempty"`
+		TimeLimit    int        `json:"time_limit"`
+		MaxAttempts  int        `json:"max_attempts"`
+		AttemptsUsed int        `json:"attempts_used"`
+		BestScore    float64    `json:"best_score"`
+		IsExpired    bool       `json:"is_expired"`
+	}
+
+	now := time.Now()
+	result := make([]MyAssignment, 0, len(targets))
+	for _, t := range targets {
+		due := t.DueDate
+		if due == nil {
+			due = t.Assignment.DueDate
+		}
+
+		var attemptsUsed int64
+		var bestScore float64
+		db.Model(&models.AssignmentSubmission{}).
+			Where("assignment_id = ? AND user_id = ?", t.AssignmentID, userUUID).
+			Count(&attemptsUsed)
+		db.Model(&models.AssignmentSubmission{}).
+			Where("assignment_id = ? AND user_id = ?", t.AssignmentID, userUUID).
+			Select("COALESCE(MAX(score), 0)").
+			Scan(&bestScore)
+
+		result = append(result, MyAssignment{
+			AssignmentID: t.AssignmentID,
+			Title:        t.Assignment.Title,
+			PodcastID:    t.Assignment.PodcastID,
+			PodcastTitle: t.Assignment.Podcast.Title,
+			ClassID:      t.ClassID,
+			ClassName:    t.Class.Name,
+			DueDate:      due,
+			TimeLimit:    t.Assignment.TimeLimit,
+			MaxAttempts:  t.Assignment.MaxAttempts,
+			AttemptsUsed: int(attemptsUsed),
+			BestScore:    bestScore,
+			IsExpired:    due != nil && now.After(*due),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"assignments": result,
+		"total":       len(result),
+	})
+}
@@ -2,7 +2,11 @@ package controllers
 
 import (
 	"encoding/json"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -11,6 +15,7 @@ import (
 	"github.com/vnkhanh/e-podcast-backend/models"
 	"github.com/vnkhanh/e-podcast-backend/ws"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // Gửi thông báo realtime + lưu DB với thông tin navigation
@@ -47,11 +52,259 @@ func notifyComment(db *gorm.DB, userID uuid.UUID, title, message, notifType stri
 	ws.SendBadgeUpdate(userID.String(), count)
 }
 
+const (
+	defaultCommentPageSize = 20
+	maxCommentPageSize     = 50
+	maxCommentLength       = 2000
+)
+
+// CommentResponse là bình luận trả về cho client
+type CommentResponse struct {
+	ID           uuid.UUID         `json:"id"`
+	PodcastID    uuid.UUID         `json:"podcast_id"`
+	UserID       uuid.UUID         `json:"user_id"`
+	ParentID     *uuid.UUID        `json:"parent_id"`
+	UserName     string            `json:"user_name"`
+	UserRole     string            `json:"user_role"`
+	Content      string            `json:"content"`
+	PositionSec  *int              `json:"position_sec"`            // Mốc thời gian gắn với bình luận, nil nếu không gắn
+	PositionText string            `json:"position_text,omitempty"` // Mốc thời gian dạng HH:MM:SS
+	CreatedAt    string            `json:"created_at"`
+	EditedAt     *string           `json:"edited_at"`
+	Reactions    map[string]int64  `json:"reactions"`    // Số lượt theo loại phản hồi
+	MyReactions  []string          `json:"my_reactions"` // Phản hồi của user hiện tại
+	ReplyCount   int64             `json:"reply_count"`  // Tổng số trả lời ở mọi cấp
+	Replies      []CommentResponse `json:"replies,omitempty"`
+}
+
+// commentRow là một dòng bình luận kèm tên và vai trò người viết
+type commentRow struct {
+	ID          uuid.UUID
+	PodcastID   uuid.UUID
+	UserID      uuid.UUID
+	ParentID    *uuid.UUID
+	Content     string
+	PositionSec *int
+	CreatedAt   time.Time
+	EditedAt    *time.Time
+	UserName    string
+	UserRole    models.UserRole
+}
+
+const commentColumns = `comments.id, comments.podcast_id, comments.user_id, comments.parent_id, comments.content,
+	comments.position_sec, comments.created_at, comments.edited_at, users.full_name AS user_name, users.role AS user_role`
+
+func commentRoleLabel(role models.UserRole) string {
+	switch role {
+	case models.RoleAdmin:
+		return "Quản trị viên"
+	case models.RoleLecturer:
+		return "Giảng viên"
+	}
+	return ""
+}
+
+func (r commentRow) response() CommentResponse {
+	resp := CommentResponse{
+		ID:          r.ID,
+		PodcastID:   r.PodcastID,
+		UserID:      r.UserID,
+		ParentID:    r.ParentID,
+		UserName:    r.UserName,
+		UserRole:    commentRoleLabel(r.UserRole),
+		Content:     r.Content,
+		PositionSec: r.PositionSec,
+		CreatedAt:   r.CreatedAt.Format("02/01/2006 15:04"),
+		Reactions:   map[string]int64{},
+		MyReactions: []string{},
+	}
+	if r.PositionSec != nil {
+		resp.PositionText = FormatSecondsToHHMMSS(*r.PositionSec)
+	}
+	if r.EditedAt != nil {
+		edited := r.EditedAt.Format("02/01/2006 15:04")
+		resp.EditedAt = &edited
+	}
+	return resp
+}
+
+// loadCommentRow đọc một bình luận kèm người viết
+func loadCommentRow(db *gorm.DB, commentID uuid.UUID) (*commentRow, error) {
+	var row commentRow
+	if err := db.Model(&models.Comment{}).
+		Select(commentColumns).
+		Joins("JOIN users ON users.id = comments.user_id").
+		Where("comments.id = ?", commentID).
+		Scan(&row).Error; err != nil {
+		return nil, err
+	}
+	if row.ID == uuid.Nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &row, nil
+}
+
+// loadCommentDescendants lấy mọi trả lời (mọi cấp) của các bình luận trong một truy vấn đệ quy
+func loadCommentDescendants(db *gorm.DB, parentIDs []uuid.UUID) ([]commentRow, error) {
+	var rows []commentRow
+	if len(parentIDs) == 0 {
+		return rows, nil
+	}
+	err := db.Raw(`
+		WITH RECURSIVE tree AS (
+			SELECT id FROM comments WHERE parent_id IN ?
+			UNION ALL
+			SELECT c.id FROM comments c JOIN tree t ON c.parent_id = t.id
+		)
+		SELECT `+commentColumns+`
+		FROM tree
+		JOIN comments ON comments.id = tree.id
+		JOIN users ON users.id = comments.user_id
+		ORDER BY comments.created_at ASC
+	`, parentIDs).Scan(&rows).Error
+	return rows, err
+}
+
+// countCommentReplies đếm tổng số trả lời (mọi cấp) của từng bình luận
+func countCommentReplies(db *gorm.DB, parentIDs []uuid.UUID) (map[uuid.UUID]int64, error) {
+	counts := map[uuid.UUID]int64{}
+	if len(parentIDs) == 0 {
+		return counts, nil
+	}
+	var rows []struct {
+		RootID uuid.UUID
+		Total  int64
+	}
+	if err := db.Raw(`
+		WITH RECURSIVE tree AS (
+			SELECT id, parent_id AS root_id FROM comments WHERE parent_id IN ?
+			UNION ALL
+			SELECT c.id, t.root_id FROM comments c JOIN tree t ON c.parent_id = t.id
+		)
+		SELECT root_id, COUNT(*) AS total FROM tree GROUP BY root_id
+	`, parentIDs).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		counts[r.RootID] = r.Total
+	}
+	return counts, nil
+}
+
+// buildCommentReplies dựng cây trả lời của parentID từ danh sách phẳng, kèm tổng số trả lời mỗi nút
+func buildCommentReplies(children map[uuid.UUID][]commentRow, parentID uuid.UUID) ([]CommentResponse, int64) {
+	var replies []CommentResponse
+	var total int64
+	for _, row := range children[parentID] {
+		resp := row.response()
+		resp.Replies, resp.ReplyCount = buildCommentReplies(children, row.ID)
+		total += 1 + resp.ReplyCount
+		replies = append(replies, resp)
+	}
+	return replies, total
+}
+
+func groupCommentsByParent(rows []commentRow) map[uuid.UUID][]commentRow {
+	children := map[uuid.UUID][]commentRow{}
+	for _, row := range rows {
+		if row.ParentID != nil {
+			children[*row.ParentID] = append(children[*row.ParentID], row)
+		}
+	}
+	return children
+}
+
+// collectComments trả con trỏ tới mọi bình luận trong cây để điền phản hồi
+func collectComments(list []CommentResponse, out []*CommentResponse) []*CommentResponse {
+	for i := range list {
+		out = append(out, &list[i])
+		out = collectComments(list[i].Replies, out)
+	}
+	return out
+}
+
+// attachReactions điền số phản hồi theo loại và phản hồi của user hiện tại (2 truy vấn cho cả danh sách)
+func attachReactions(db *gorm.DB, userID string, list []CommentResponse) error {
+	comments := collectComments(list, nil)
+	if len(comments) == 0 {
+		return nil
+	}
+	byID := make(map[uuid.UUID]*CommentResponse, len(comments))
+	ids := make([]uuid.UUID, 0, len(comments))
+	for _, cmt := range comments {
+		byID[cmt.ID] = cmt
+		ids = append(ids, cmt.ID)
+	}
+
+	var counts []struct {
+		CommentID uuid.UUID
+		Reaction  string
+		Total     int64
+	}
+	if err := db.Model(&models.CommentReaction{}).
+		Select("comment_id, reaction, COUNT(*) AS total").
+		Where("comment_id IN ?", ids).
+		Group("comment_id, reaction").
+		Scan(&counts).Error; err != nil {
+		return err
+	}
+	for _, r := range counts {
+		byID[r.CommentID].Reactions[r.Reaction] = r.Total
+	}
+
+	if userID == "" {
+		return nil
+	}
+	var mine []models.CommentReaction
+	if err := db.Select("comment_id", "reaction").
+		Where("user_id = ? AND comment_id IN ?", userID, ids).
+		Order("created_at ASC").
+		Find(&mine).Error; err != nil {
+		return err
+	}
+	for _, r := range mine {
+		byID[r.CommentID].MyReactions = append(byID[r.CommentID].MyReactions, r.Reaction)
+	}
+	return nil
+}
+
+// reactionCounts đếm phản hồi theo loại của một bình luận
+func reactionCounts(db *gorm.DB, commentID uuid.UUID) map[string]int64 {
+	var rows []struct {
+		Reaction string
+		Total    int64
+	}
+	db.Model(&models.CommentReaction{}).
+		Select("reaction, COUNT(*) AS total").
+		Where("comment_id = ?", commentID).
+		Group("reaction").
+		Scan(&rows)
+	counts := map[string]int64{}
+	for _, r := range rows {
+		counts[r.Reaction] = r.Total
+	}
+	return counts
+}
+
+// validCommentPosition: mốc thời gian không âm và không vượt thời lượng podcast (nếu đã biết)
+func validCommentPosition(pos *int, podcast *models.Podcast) bool {
+	if pos == nil {
+		return true
+	}
+	return *pos >= 0 && (podcast.DurationSec <= 0 || *pos <= podcast.DurationSec)
+}
+
+func broadcastComment(podcastID uuid.UUID, data map[string]interface{}) {
+	jsonData, _ := json.Marshal(data)
+	ws.H.Broadcast(podcastID.String(), websocket.TextMessage, jsonData)
+}
+
 // Request tạo bình luận
 type CreateCommentRequest struct {
-	PodcastID string  `json:"podcast_id" binding:"required"`
-	Content   string  `json:"content" binding:"required"`
-	ParentID  *string `json:"parent_id,omitempty"`
+	PodcastID   string  `json:"podcast_id" binding:"required"`
+	Content     string  `json:"content" binding:"required"`
+	ParentID    *string `json:"parent_id,omitempty"`
+	PositionSec *int    `json:"position_sec,omitempty"` // Gắn bình luận vào mốc thời gian (giây)
 }
 
 // Tạo bình luận với notification có đủ thông tin
@@ -61,6 +314,11 @@ func CreateComment(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	req.Content = strings.TrimSpace(req.Content)
+	if req.Content == "" || len([]rune(req.Content)) > maxCommentLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nội dung bình luận phải từ 1 đến 2000 ký tự"})
+		return
+	}
 
 	userIDStr, ok := c.Get("user_id")
 	if !ok {
@@ -84,81 +342,76 @@ func CreateComment(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "PodcastID không hợp lệ"})
 		return
 	}
+	var podcast models.Podcast
+	if err := config.DB.Select("id", "created_by", "duration_sec").First(&podcast, "id = ?", podcastID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy podcast"})
+		return
+	}
+	if !validCommentPosition(req.PositionSec, &podcast) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Mốc thời gian không hợp lệ"})
+		return
+	}
 
-	var parentID *uuid.UUID
+	// Trả lời phải cùng podcast với bình luận gốc
+	var parent *models.Comment
 	if req.ParentID != nil {
-		if id, err := uuid.Parse(*req.ParentID); err == nil {
-			parentID = &id
+		parentID, err := uuid.Parse(*req.ParentID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "parent_id không hợp lệ"})
+			return
+		}
+		parent = &models.Comment{}
+		if err := config.DB.First(parent, "id = ? AND podcast_id = ?", parentID, podcastID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Không tìm thấy bình luận được trả lời"})
+			return
 		}
 	}
 
 	comment := models.Comment{
-		PodcastID: podcastID,
-		UserID:    userID,
-		Content:   req.Content,
-		ParentID:  parentID,
+		PodcastID:   podcastID,
+		UserID:      userID,
+		Content:     req.Content,
+		PositionSec: req.PositionSec,
+	}
+	if parent != nil {
+		comment.ParentID = &parent.ID
 	}
 	if err := config.DB.Create(&comment).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lưu bình luận"})
 		return
 	}
 
-	config.DB.Preload("User").First(&comment, "id = ?", comment.ID)
-
-	role := ""
-	switch comment.User.Role {
-	case "admin":
-		role = "Quản trị viên"
-	case "teacher":
-		role = "Giảng viên"
-	}
-
-	var parentVal interface{} = nil
-	if comment.ParentID != nil {
-		parentVal = comment.ParentID.String()
-	}
-
-	response := map[string]interface{}{
-		"id":         comment.ID.String(),
-		"podcast_id": comment.PodcastID.String(),
-		"user_id":    comment.UserID.String(),
-		"user_name":  comment.User.FullName,
-		"user_role":  role,
-		"content":    comment.Content,
-		"created_at": comment.CreatedAt.Format("02/01/2006 15:04"),
-		"parent_id":  parentVal,
-		"replies":    []interface{}{},
-	}
+	response := commentRow{
+		ID:          comment.ID,
+		PodcastID:   comment.PodcastID,
+		UserID:      comment.UserID,
+		ParentID:    comment.ParentID,
+		Content:     comment.Content,
+		PositionSec: comment.PositionSec,
+		CreatedAt:   comment.CreatedAt,
+		UserName:    user.FullName,
+		UserRole:    user.Role,
+	}.response()
 
 	// Gửi realtime tới tất cả client đang xem podcast
-	data := map[string]interface{}{
+	broadcastComment(podcastID, map[string]interface{}{
 		"type":       "new_comment",
 		"podcast_id": podcastID.String(),
 		"comment":    response,
-	}
-	wsData, _ := json.Marshal(data)
-	ws.H.Broadcast(podcastID.String(), websocket.TextMessage, wsData)
+	})
 
 	// Thông báo cho chủ podcast (có comment_id)
-	var podcast models.Podcast
-	if err := config.DB.First(&podcast, "id = ?", podcastID).Error; err == nil {
-		if podcast.CreatedBy != userID {
-			title := "Bình luận mới về podcast của bạn"
-			message := user.FullName + " đã bình luận: " + req.Content
-			notifyComment(config.DB, podcast.CreatedBy, title, message, "comment_notification", podcastID, &comment.ID)
-		}
+	if podcast.CreatedBy != userID {
+		title := "Bình luận mới về podcast của bạn"
+		message := user.FullName + " đã bình luận: " + req.Content
+		notifyComment(config.DB, podcast.CreatedBy, title, message, "comment_notification", podcastID, &comment.ID)
 	}
 
 	// Nếu là reply, thông báo cho người bị reply (có comment_id)
-	if parentID != nil {
-		var parent models.Comment
-		if err := config.DB.Preload("User").First(&parent, "id = ?", *parentID).Error; err == nil {
-			if parent.UserID != userID {
-				title := "Ai đó đã trả lời bình luận của bạn"
-				message := user.FullName + " đã trả lời: " + req.Content
-				notifyComment(config.DB, parent.UserID, title, message, "reply_notification", podcastID, &comment.ID)
-			}
-		}
+	if parent != nil && parent.UserID != userID {
+		title := "Ai đó đã trả lời bình luận của bạn"
+		message := user.FullName + " đã trả lời: " + req.Content
+		notifyComment(config.DB, parent.UserID, title, message, "reply_notification", podcastID, &comment.ID)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -167,84 +420,366 @@ func CreateComment(c *gin.Context) {
 	})
 }
 
-// Lấy tất cả bình luận (đệ quy nhiều cấp)
+// GET /api/comments/podcasts/:id?sort=oldest|newest|position|top&from_sec=&to_sec=
+// Mặc định trả mảng bình luận gốc kèm toàn bộ cây trả lời như trước (client cũ vẫn dùng được).
+// Thêm paginated=true (cùng page, limit) để nhận {data, pagination}: chỉ bình luận gốc kèm reply_count,
+// trả lời tải sau qua /comments/:id/replies, hoặc kèm luôn với replies=all
 func GetComments(c *gin.Context) {
-	podcastID := c.Param("id")
-	var rootComments []models.Comment
+	db := config.DB
+	podcastID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "PodcastID không hợp lệ"})
+		return
+	}
+	paginated := c.Query("paginated") == "true"
 
-	if err := config.DB.
-		Preload("User").
-		Where("podcast_id = ? AND parent_id IS NULL", podcastID).
-		Order("created_at ASC").
-		Find(&rootComments).Error; err != nil {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultCommentPageSize)))
+	if page < 1 {
+		page = 1
+	}
+	if limit <= 0 || limit > maxCommentPageSize {
+		limit = defaultCommentPageSize
+	}
+
+	// Lọc bình luận gắn trong một đoạn của podcast (vd để hiện trên thanh phát)
+	roots := func() *gorm.DB {
+		q := db.Model(&models.Comment{}).Where("comments.podcast_id = ? AND comments.parent_id IS NULL", podcastID)
+		if from, err := strconv.Atoi(c.Query("from_sec")); err == nil {
+			q = q.Where("comments.position_sec >= ?", from)
+		}
+		if to, err := strconv.Atoi(c.Query("to_sec")); err == nil {
+			q = q.Where("comments.position_sec <= ?", to)
+		}
+		return q
+	}
+
+	var total int64
+	if paginated {
+		if err := roots().Count(&total).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy bình luận"})
+			return
+		}
+	}
+
+	order := "comments.created_at ASC"
+	switch c.Query("sort") {
+	case "newest":
+		order = "comments.created_at DESC"
+	case "position":
+		order = "comments.position_sec ASC NULLS LAST, comments.created_at ASC"
+	case "top":
+		order = "(SELECT COUNT(*) FROM comment_reactions r WHERE r.comment_id = comments.id) DESC, comments.created_at ASC"
+	}
+
+	query := roots().
+		Select(commentColumns).
+		Joins("JOIN users ON users.id = comments.user_id").
+		Order(order)
+	if paginated {
+		query = query.Offset((page - 1) * limit).Limit(limit)
+	}
+	var rows []commentRow
+	if err := query.Scan(&rows).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy bình luận"})
 		return
 	}
 
-	var loadReplies func(comment *models.Comment)
-	loadReplies = func(comment *models.Comment) {
-		var replies []models.Comment
-		config.DB.
-			Preload("User").
-			Where("parent_id = ?", comment.ID).
-			Order("created_at ASC").
-			Find(&replies)
+	ids := make([]uuid.UUID, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.ID)
+	}
 
-		for i := range replies {
-			loadReplies(&replies[i])
+	var children map[uuid.UUID][]commentRow
+	var replyCounts map[uuid.UUID]int64
+	if !paginated || c.Query("replies") == "all" {
+		descendants, err := loadCommentDescendants(db, ids)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy bình luận"})
+			return
 		}
+		children = groupCommentsByParent(descendants)
+	} else if replyCounts, err = countCommentReplies(db, ids); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy bình luận"})
+		return
+	}
 
-		comment.Replies = replies
+	response := make([]CommentResponse, 0, len(rows))
+	for _, row := range rows {
+		resp := row.response()
+		if children != nil {
+			resp.Replies, resp.ReplyCount = buildCommentReplies(children, row.ID)
+		} else {
+			resp.ReplyCount = replyCounts[row.ID]
+		}
+		response = append(response, resp)
+	}
+	if err := attachReactions(db, c.GetString("user_id"), response); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy bình luận"})
+		return
 	}
 
-	for i := range rootComments {
-		loadReplies(&rootComments[i])
+	if !paginated {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"data": response,
+		"pagination": gin.H{
+			"page":        page,
+			"limit":       limit,
+			"total":       total,
+			"total_pages": int(math.Ceil(float64(total) / float64(limit))),
+		},
+	})
+}
+
+// GET /api/comments/:id/replies
+// Toàn bộ cây trả lời của một bình luận, tải trong một truy vấn
+func GetCommentReplies(c *gin.Context) {
+	db := config.DB
+	commentID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID bình luận không hợp lệ"})
+		return
+	}
+	var count int64
+	if err := db.Model(&models.Comment{}).Where("id = ?", commentID).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy trả lời"})
+		return
+	}
+	if count == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy bình luận"})
+		return
+	}
+
+	descendants, err := loadCommentDescendants(db, []uuid.UUID{commentID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy trả lời"})
+		return
+	}
+	replies, total := buildCommentReplies(groupCommentsByParent(descendants), commentID)
+	if replies == nil {
+		replies = []CommentResponse{}
+	}
+	if err := attachReactions(db, c.GetString("user_id"), replies); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy trả lời"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":        replies,
+		"reply_count": total,
+	})
+}
+
+// Request sửa bình luận
+type UpdateCommentRequest struct {
+	Content       string `json:"content" binding:"required"`
+	PositionSec   *int   `json:"position_sec,omitempty"`
+	ClearPosition bool   `json:"clear_position,omitempty"` // Bỏ gắn mốc thời gian
+}
+
+// PUT /api/comments/:id
+// Chỉ người viết được sửa; nội dung cũ được lưu vào lịch sử
+func UpdateComment(c *gin.Context) {
+	db := config.DB
+	var req UpdateCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Content = strings.TrimSpace(req.Content)
+	if req.Content == "" || len([]rune(req.Content)) > maxCommentLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nội dung bình luận phải từ 1 đến 2000 ký tự"})
+		return
 	}
 
-	type CommentResponse struct {
-		ID        uuid.UUID         `json:"id"`
-		PodcastID uuid.UUID         `json:"podcast_id"`
-		UserID    uuid.UUID         `json:"user_id"`
-		UserName  string            `json:"user_name"`
-		UserRole  string            `json:"user_role"`
-		Content   string            `json:"content"`
-		CreatedAt string            `json:"created_at"`
-		Replies   []CommentResponse `json:"replies,omitempty"`
+	var comment models.Comment
+	if err := db.First(&comment, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy bình luận"})
+		return
+	}
+	if comment.UserID.String() != c.GetString("user_id") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Bạn chỉ có thể sửa bình luận của mình"})
+		return
 	}
 
-	var format func(models.Comment) CommentResponse
-	format = func(cmt models.Comment) CommentResponse {
-		role := ""
-		switch cmt.User.Role {
-		case "admin":
-			role = "Quản trị viên"
-		case "teacher":
-			role = "Giảng viên"
+	position := comment.PositionSec
+	if req.ClearPosition {
+		position = nil
+	} else if req.PositionSec != nil {
+		var podcast models.Podcast
+		if err := db.Select("id", "duration_sec").First(&podcast, "id = ?", comment.PodcastID).Error; err != nil ||
+			!validCommentPosition(req.PositionSec, &podcast) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Mốc thời gian không hợp lệ"})
+			return
 		}
+		position = req.PositionSec
+	}
 
-		resp := CommentResponse{
-			ID:        cmt.ID,
-			PodcastID: cmt.PodcastID,
-			UserID:    cmt.UserID,
-			UserName:  cmt.User.FullName,
-			UserRole:  role,
-			Content:   cmt.Content,
-			CreatedAt: cmt.CreatedAt.Format("02/01/2006 15:04"),
+	samePosition := (position == nil && comment.PositionSec == nil) ||
+		(position != nil && comment.PositionSec != nil && *position == *comment.PositionSec)
+	if req.Content != comment.Content || !samePosition {
+		now := time.Now()
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&models.CommentEdit{
+				CommentID:   comment.ID,
+				Content:     comment.Content,
+				PositionSec: comment.PositionSec,
+			}).Error; err != nil {
+				return err
+			}
+			return tx.Model(&comment).Updates(map[string]interface{}{
+				"content":      req.Content,
+				"position_sec": position,
+				"edited_at":    now,
+			}).Error
+		}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể sửa bình luận"})
+			return
 		}
+	}
+
+	row, err := loadCommentRow(db, comment.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể sửa bình luận"})
+		return
+	}
+	replyCounts, err := countCommentReplies(db, []uuid.UUID{comment.ID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể sửa bình luận"})
+		return
+	}
+	response := row.response()
+	response.ReplyCount = replyCounts[comment.ID]
+	list := []CommentResponse{response}
+	if err := attachReactions(db, c.GetString("user_id"), list); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể sửa bình luận"})
+		return
+	}
+
+	broadcastComment(comment.PodcastID, map[string]interface{}{
+		"type":       "edit_comment",
+		"podcast_id": comment.PodcastID.String(),
+		"comment":    list[0],
+	})
 
-		for _, reply := range cmt.Replies {
-			resp.Replies = append(resp.Replies, format(reply))
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Đã sửa bình luận",
+		"data":    list[0],
+	})
+}
+
+// GET /api/comments/:id/history
+// Các phiên bản trước của bình luận, mới nhất trước.
+// Ai cũng thấy bình luận đã sửa (edited_at); nội dung cũ chỉ người viết, admin và người quản lý podcast được xem
+func GetCommentHistory(c *gin.Context) {
+	db := config.DB
+	var comment models.Comment
+	if err := db.First(&comment, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy bình luận"})
+		return
+	}
+
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Vui lòng đăng nhập để xem lịch sử sửa"})
+		return
+	}
+	if comment.UserID.String() != userID {
+		var podcast models.Podcast
+		if err := db.Select("id", "created_by").First(&podcast, "id = ?", comment.PodcastID).Error; err != nil ||
+			!canManagePodcast(c, db, podcast, models.PermPodcastEdit) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Bạn không có quyền xem lịch sử sửa của bình luận này"})
+			return
 		}
+	}
 
-		return resp
+	var edits []models.CommentEdit
+	if err := db.Where("comment_id = ?", comment.ID).Order("created_at DESC").Find(&edits).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy lịch sử sửa"})
+		return
 	}
 
-	var response []CommentResponse
-	for _, root := range rootComments {
-		response = append(response, format(root))
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"comment_id":   comment.ID,
+			"content":      comment.Content,
+			"position_sec": comment.PositionSec,
+			"edited_at":    comment.EditedAt,
+			"edits":        edits,
+		},
+	})
+}
+
+// setCommentReaction thêm / bỏ phản hồi của user hiện tại rồi phát số lượt mới tới người đang xem podcast
+func setCommentReaction(c *gin.Context, add bool) {
+	db := config.DB
+	reaction := c.Param("reaction")
+	if _, ok := models.CommentReactions[reaction]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Loại phản hồi không hợp lệ"})
+		return
+	}
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Không tìm thấy người dùng"})
+		return
 	}
 
-	c.JSON(http.StatusOK, response)
+	var comment models.Comment
+	if err := db.Select("id", "podcast_id").First(&comment, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy bình luận"})
+		return
+	}
+
+	if add {
+		err = db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.CommentReaction{
+			CommentID: comment.ID,
+			UserID:    userID,
+			Reaction:  reaction,
+		}).Error
+	} else {
+		err = db.Where("comment_id = ? AND user_id = ? AND reaction = ?", comment.ID, userID, reaction).
+			Delete(&models.CommentReaction{}).Error
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể cập nhật phản hồi"})
+		return
+	}
+
+	counts := reactionCounts(db, comment.ID)
+	broadcastComment(comment.PodcastID, map[string]interface{}{
+		"type":       "comment_reaction",
+		"podcast_id": comment.PodcastID.String(),
+		"comment_id": comment.ID.String(),
+		"reactions":  counts,
+	})
+
+	var mine []string
+	db.Model(&models.CommentReaction{}).
+		Where("comment_id = ? AND user_id = ?", comment.ID, userID).
+		Order("created_at ASC").
+		Pluck("reaction", &mine)
+	if mine == nil {
+		mine = []string{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"comment_id":   comment.ID,
+		"reactions":    counts,
+		"my_reactions": mine,
+	})
+}
+
+// PUT /api/comments/:id/reactions/:reaction
+func AddCommentReaction(c *gin.Context) {
+	setCommentReaction(c, true)
+}
+
+// DELETE /api/comments/:id/reactions/:reaction
+func RemoveCommentReaction(c *gin.Context) {
+	setCommentReaction(c, false)
 }
 
 // Xóa bình luận hoặc trả lời (xóa luôn toàn bộ reply con)
@@ -273,31 +808,24 @@ func DeleteComment(c *gin.Context) {
 		}
 	}
 
-	var deleteReplies func(parentID uuid.UUID)
-	deleteReplies = func(parentID uuid.UUID) {
-		var replies []models.Comment
-		if err := db.Where("parent_id = ?", parentID).Find(&replies).Error; err == nil {
-			for _, r := range replies {
-				deleteReplies(r.ID)
-				db.Delete(&r)
-			}
-		}
-	}
-
-	deleteReplies(comment.ID)
-
-	if err := db.Delete(&comment).Error; err != nil {
+	// Xóa cả cây trong một câu lệnh; phản hồi và lịch sử sửa xóa theo khóa ngoại
+	if err := db.Exec(`
+		WITH RECURSIVE tree AS (
+			SELECT id FROM comments WHERE id = ?
+			UNION ALL
+			SELECT c.id FROM comments c JOIN tree t ON c.parent_id = t.id
+		)
+		DELETE FROM comments WHERE id IN (SELECT id FROM tree)
+	`, comment.ID).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể xóa bình luận"})
 		return
 	}
 
-	data := map[string]interface{}{
+	broadcastComment(comment.PodcastID, map[string]interface{}{
 		"type":       "delete_comment",
 		"comment_id": comment.ID.String(),
 		"podcast_id": comment.PodcastID.String(),
-	}
-	jsonData, _ := json.Marshal(data)
-	ws.H.Broadcast(comment.PodcastID.String(), websocket.TextMessage, jsonData)
+	})
 
 	c.JSON(http.StatusOK, gin.H{"message": "Đã xóa bình luận và toàn bộ trả lời con"})
 }
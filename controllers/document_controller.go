@@ -297,6 +297,34 @@ func GetDocumentDetail(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy tài liệu"})
 		return
 	}
+	if !canAccessDocument(c, config.DB, document) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Bạn không có quyền xem tài liệu này"})
+		return
+	}
+	c.JSON(http.StatusOK, document)
+}
+
+// Chi tiết tài liệu phía người học: tài liệu nguồn của podcast đã công bố,
+// hoặc tài liệu người dùng đăng nhập có quyền xem (người tải lên / quản lý podcast)
+// GET /user/documents/:id
+func GetPublishedDocumentDetail(c *gin.Context) {
+	id := c.Param("id")
+	var document models.Document
+	if err := config.DB.First(&document, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy tài liệu"})
+		return
+	}
+
+	if c.GetString("user_id") == "" || !canAccessDocument(c, config.DB, document) {
+		var published int64
+		config.DB.Model(&models.Podcast{}).
+			Where("document_id = ? AND status = ?", document.ID, "published").
+			Count(&published)
+		if published == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy tài liệu"})
+			return
+		}
+	}
 	c.JSON(http.StatusOK, document)
 }
 
@@ -314,6 +342,11 @@ func DeleteDocument(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy tài liệu"})
 		return
 	}
+	// Chỉ người tải lên (hoặc admin) được xóa tài liệu
+	if c.GetString("role") != string(models.RoleAdmin) && document.UserID.String() != c.GetString("user_id") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Bạn không có quyền xóa tài liệu này"})
+		return
+	}
 
 	// Xóa khỏi DB
 	if err := config.DB.Delete(&document).Error; err != nil {
@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vnkhanh/e-podcast-backend/config"
+	"github.com/vnkhanh/e-podcast-backend/models"
+	"github.com/vnkhanh/e-podcast-backend/utils"
+)
+
+// ==================== XÁC MINH EMAIL ====================
+
+const emailVerificationTTL = 24 * time.Hour
+
+// sendVerificationEmail tạo token mới (xóa token cũ) và gửi link xác minh
+func sendVerificationEmail(user models.User) error {
+	db := config.DB
+
+	token, err := utils.GenerateRandomToken()
+	if err != nil {
+		return err
+	}
+
+	db.Where("user_id = ?", user.ID).Delete(&models.EmailVerification{})
+	expiresAt := time.Now().Add(emailVerificationTTL)
+	if err := db.Create(&models.EmailVerification{
+		UserID:    user.ID,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: expiresAt,
+	}).Error; err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf(`%s/auth/verify-email?token=%s`, os.Getenv("FE_BASE_URL"), token)
+	body := fmt.Sprintf(`
+	<h3>Xin chào %s,</h3>
+	<p>Cảm ơn bạn đã đăng ký. Vui lòng nhấp vào liên kết dưới đây để xác minh email:</p>
+	<p><a href="%s">Xác minh email</a></p>
+	<p>Liên kết sẽ hết hạn vào <b>%s</b></p>
+	<hr>
+	<p><i>Nếu bạn không đăng ký tài khoản, hãy bỏ qua email này.</i></p>
+	`, user.FullName, link, expiresAt.Format("02/01/2006 15:04"))
+
+	return utils.SendEmail(user.Email, "Xác minh email", body)
+}
+
+// Xác minh email bằng token trong link
+// POST /auth/verify-email  {token}
+func VerifyEmail(c *gin.Context) {
+	var req struct {
+		Token string `json:"token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Thiếu token"})
+		return
+	}
+
+	db := config.DB
+	var ev models.EmailVerification
+	if err := db.Where("token_hash = ?", utils.HashToken(req.Token)).First(&ev).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Liên kết không hợp lệ hoặc đã được sử dụng"})
+		return
+	}
+	if time.Now().After(ev.ExpiresAt) {
+		db.Delete(&ev)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Liên kết đã hết hạn, vui lòng yêu cầu gửi lại"})
+		return
+	}
+
+	if err := db.Model(&models.User{}).Where("id = ?", ev.UserID).Update("email_verified", true).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể xác minh email"})
+		return
+	}
+	db.Delete(&ev)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Xác minh email thành công, bạn có thể đăng nhập"})
+}
+
+// Gửi lại email xác minh
+// POST /auth/resend-verification  {email}
+func ResendVerificationEmail(c *gin.Context) {
+	var req struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Không lộ email tồn tại hay đã xác minh chưa
+	message := gin.H{"message": "Nếu email chưa được xác minh, link xác minh đã được gửi"}
+
+	var user models.User
+	if err := config.DB.Where("email = ?", req.Email).First(&user).Error; err != nil {
+		c.JSON(http.StatusOK, message)
+		return
+	}
+	if user.EmailVerified == nil || *user.EmailVerified {
+		c.JSON(http.StatusOK, message)
+		return
+	}
+
+	// Chống spam: mỗi phút chỉ gửi một lần
+	var last models.EmailVerification
+	if err := config.DB.Where("user_id = ?", user.ID).Order("created_at DESC").First(&last).Error; err == nil &&
+		time.Since(last.CreatedAt) < time.Minute {
+		c.JSON(http.StatusOK, message)
+		return
+	}
+
+	if err := sendVerificationEmail(user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể gửi email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, message)
+}
@@ -1,20 +1,30 @@
 package controllers
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
 	"math"
 	"net/http"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/vnkhanh/e-podcast-backend/models"
+	"github.com/vnkhanh/e-podcast-backend/services"
+	"github.com/vnkhanh/e-podcast-backend/utils"
 	"github.com/vnkhanh/e-podcast-backend/ws"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // Thêm podcast vào danh sách yêu thích với notification có đầy đủ thông tin
@@ -215,7 +225,7 @@ func GetFavorites(c *gin.Context) {
 
 	switch timeFilter {
 	case "today":
-		startDate = time.Now().Truncate(24 * time.Hour)
+		startDate = analyticsDay(time.Now())
 	case "week":
 		startDate = time.Now().AddDate(0, 0, -7)
 	case "month":
@@ -295,15 +305,461 @@ func GetFavorites(c *gin.Context) {
 	})
 }
 
+// ==================== CHIA SẺ MẠNG XÃ HỘI (OPEN GRAPH, ẢNH CHIA SẺ, UTM) ====================
+
+const maxShareImageCache = 256
+
+// sharePodcast là podcast đã xuất bản kèm tên môn học, dùng cho trang / ảnh chia sẻ
+type sharePodcast struct {
+	ID          uuid.UUID
+	Title       string
+	Description string
+	Summary     string
+	AudioURL    string
+	CoverImage  string
+	DurationSec int
+	SubjectName string
+	UpdatedAt   time.Time
+}
+
+// siteName là tên trang hiển thị trên thẻ chia sẻ và feed, đọc từ SITE_NAME
+func siteName() string {
+	if name := os.Getenv("SITE_NAME"); name != "" {
+		return name
+	}
+	return "E-Podcast"
+}
+
+// loadSharePodcast chỉ trả podcast đã xuất bản; UpdatedAt tính cả lần sửa môn học để làm mới ảnh chia sẻ
+func loadSharePodcast(db *gorm.DB, idStr string) (*sharePodcast, bool) {
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return nil, false
+	}
+	var p sharePodcast
+	if err := db.Model(&models.Podcast{}).
+		Select(`podcasts.id, podcasts.title, podcasts.description, podcasts.summary, podcasts.audio_url,
+			podcasts.cover_image, podcasts.duration_sec, COALESCE(subjects.name, '') AS subject_name,
+			GREATEST(podcasts.updated_at, COALESCE(subjects.updated_at, podcasts.updated_at)) AS updated_at`).
+		Joins("LEFT JOIN chapters ON chapters.id = podcasts.chapter_id").
+		Joins("LEFT JOIN subjects ON subjects.id = chapters.subject_id").
+		Where("podcasts.id = ? AND podcasts.status = ?", id, "published").
+		Scan(&p).Error; err != nil || p.ID == uuid.Nil {
+		return nil, false
+	}
+	return &p, true
+}
+
+// shareDescription là mô tả ngắn cho thẻ Open Graph
+func (p *sharePodcast) shareDescription() string {
+	text := strings.TrimSpace(p.Description)
+	if text == "" {
+		text = strings.TrimSpace(p.Summary)
+	}
+	if text == "" && p.SubjectName != "" {
+		text = "Podcast môn " + p.SubjectName
+	}
+	runes := []rune(strings.Join(strings.Fields(text), " "))
+	if len(runes) > 200 {
+		return string(runes[:199]) + "…"
+	}
+	return string(runes)
+}
+
+// normalizeShareChannel đưa kênh lạ về "other"
+func normalizeShareChannel(channel string) string {
+	channel = strings.ToLower(strings.TrimSpace(channel))
+	for _, ch := range models.ShareChannels {
+		if ch == channel {
+			return ch
+		}
+	}
+	return models.ShareChannelOther
+}
+
+func shareMedium(channel string) string {
+	switch channel {
+	case models.ShareChannelEmail:
+		return "email"
+	case models.ShareChannelCopy:
+		return "referral"
+	default:
+		return "social"
+	}
+}
+
+// shareLink là link trang chia sẻ có gắn UTM theo kênh
+func shareLink(base string, podcastID uuid.UUID, channel string) string {
+	q := url.Values{}
+	q.Set("utm_source", channel)
+	q.Set("utm_medium", shareMedium(channel))
+	q.Set("utm_campaign", "podcast_share")
+	return fmt.Sprintf("%s/share/podcasts/%s?%s", base, podcastID, q.Encode())
+}
+
+// shareIntentURL là link mở hộp thoại chia sẻ của từng mạng; Zalo và sao chép link do frontend xử lý
+func shareIntentURL(channel, link, title string) string {
+	switch channel {
+	case models.ShareChannelFacebook:
+		return "https://www.facebook.com/sharer/sharer.php?u=" + url.QueryEscape(link)
+	case models.ShareChannelMessenger:
+		return "fb-messenger://share/?link=" + url.QueryEscape(link)
+	case models.ShareChannelTwitter:
+		return "https://twitter.com/intent/tweet?url=" + url.QueryEscape(link) + "&text=" + url.QueryEscape(title)
+	case models.ShareChannelLinkedIn:
+		return "https://www.linkedin.com/sharing/share-offsite/?url=" + url.QueryEscape(link)
+	case models.ShareChannelEmail:
+		return "mailto:?subject=" + url.PathEscape(title) + "&body=" + url.PathEscape(link)
+	default:
+		return ""
+	}
+}
+
+// ShareChannelLink là link chia sẻ của một kênh
+type ShareChannelLink struct {
+	Channel   string `json:"channel"`
+	Link      string `json:"link"`
+	IntentURL string `json:"intent_url,omitempty"`
+	Shares    int64  `json:"shares"`
+}
+
+// shareCounts đếm lượt chia sẻ theo kênh của podcast
+func shareCounts(db *gorm.DB, podcastID uuid.UUID) map[string]int64 {
+	var rows []struct {
+		Channel string
+		Total   int64
+	}
+	db.Model(&models.PodcastShare{}).
+		Select("channel, COUNT(*) AS total").
+		Where("podcast_id = ? AND action = ?", podcastID, models.ShareActionShare).
+		Group("channel").
+		Scan(&rows)
+	counts := map[string]int64{}
+	for _, r := range rows {
+		counts[r.Channel] = r.Total
+	}
+	return counts
+}
+
+// GET /api/podcasts/:podcast_id/share-social
+// Link trang chia sẻ (có thẻ Open Graph) cho từng kênh kèm số lượt chia sẻ
 func SharePodcastSocialHandler(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		podcastID := c.Param("podcast_id")
+		p, ok := loadSharePodcast(db, c.Param("podcast_id"))
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy podcast"})
+			return
+		}
+
+		base := apiBaseURL(c)
+		counts := shareCounts(db, p.ID)
+		var total int64
+		channels := make([]ShareChannelLink, 0, len(models.ShareChannels))
+		for _, ch := range models.ShareChannels {
+			link := shareLink(base, p.ID, ch)
+			channels = append(channels, ShareChannelLink{
+				Channel:   ch,
+				Link:      link,
+				IntentURL: shareIntentURL(ch, link, p.Title),
+				Shares:    counts[ch],
+			})
+			total += counts[ch]
+		}
+		total += counts[models.ShareChannelOther]
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":     "Link chia sẻ sẵn sàng",
+			"link":        shareLink(base, p.ID, models.ShareChannelCopy),
+			"page_url":    fmt.Sprintf("%s/share/podcasts/%s", base, p.ID),
+			"image_url":   fmt.Sprintf("%s/share/podcasts/%s/image", base, p.ID),
+			"channels":    channels,
+			"share_count": total,
+		})
+	}
+}
+
+type recordShareRequest struct {
+	Channel string `json:"channel" binding:"required"`
+}
+
+// recordPodcastShare ghi lượt chia sẻ / mở link, mỗi user (khách thì theo IP + trình duyệt)
+// chỉ được tính một lần cho mỗi podcast, kênh và ngày; bấm lại nhiều lần không làm tăng số đếm
+func recordPodcastShare(c *gin.Context, db *gorm.DB, share *models.PodcastShare) error {
+	actor := "guest:" + c.ClientIP() + "|" + c.GetHeader("User-Agent")
+	if share.UserID != nil {
+		actor = "user:" + share.UserID.String()
+	}
+	key := utils.HashToken(strings.Join([]string{
+		share.PodcastID.String(), share.Channel, share.Action,
+		analyticsDay(time.Now()).Format("2006-01-02"), actor,
+	}, "|"))
+	share.DedupKey = &key
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(share).Error
+}
+
+// POST /api/podcasts/:podcast_id/shares
+// Ghi nhận một lượt chia sẻ, trả link UTM của kênh đó
+func RecordPodcastShareHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, ok := loadSharePodcast(db, c.Param("podcast_id"))
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy podcast"})
+			return
+		}
+		var req recordShareRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Thiếu kênh chia sẻ"})
+			return
+		}
 
-		url := os.Getenv("FE_BASE_URL")
-		link := url + "/podcast/" + podcastID
+		share := models.PodcastShare{
+			PodcastID: p.ID,
+			Channel:   normalizeShareChannel(req.Channel),
+			Action:    models.ShareActionShare,
+		}
+		if userID, err := uuid.Parse(c.GetString("user_id")); err == nil {
+			share.UserID = &userID
+		}
+		if err := recordPodcastShare(c, db, &share); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể ghi nhận lượt chia sẻ"})
+			return
+		}
+
+		link := shareLink(apiBaseURL(c), p.ID, share.Channel)
 		c.JSON(http.StatusOK, gin.H{
-			"message": "Link chia sẻ sẵn sàng",
-			"link":    link,
+			"message":    "Đã ghi nhận lượt chia sẻ",
+			"channel":    share.Channel,
+			"link":       link,
+			"intent_url": shareIntentURL(share.Channel, link, p.Title),
 		})
 	}
 }
+
+// Bot đọc thẻ Open Graph khi dán link, không tính là lượt mở
+var shareBotAgents = []string{
+	"bot", "crawler", "spider", "facebookexternalhit", "facebookcatalog", "slack", "discord",
+	"telegram", "whatsapp", "skype", "embedly", "preview", "vkshare",
+}
+
+func isShareBot(userAgent string) bool {
+	ua := strings.ToLower(userAgent)
+	for _, bot := range shareBotAgents {
+		if strings.Contains(ua, bot) {
+			return true
+		}
+	}
+	return false
+}
+
+var sharePageTemplate = template.Must(template.New("share").Parse(`<!DOCTYPE html>
+<html lang="vi">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}} | {{.SiteName}}</title>
+<meta name="description" content="{{.Description}}">
+<link rel="canonical" href="{{.PageURL}}">
+<meta property="og:type" content="website">
+<meta property="og:site_name" content="{{.SiteName}}">
+<meta property="og:locale" content="vi_VN">
+<meta property="og:url" content="{{.PageURL}}">
+<meta property="og:title" content="{{.Title}}">
+<meta property="og:description" content="{{.Description}}">
+<meta property="og:image" content="{{.ImageURL}}">
+<meta property="og:image:type" content="image/png">
+<meta property="og:image:width" content="{{.ImageWidth}}">
+<meta property="og:image:height" content="{{.ImageHeight}}">
+<meta property="og:image:alt" content="{{.Title}}">
+{{- if .AudioURL}}
+<meta property="og:audio" content="{{.AudioURL}}">
+{{- end}}
+<meta name="twitter:card" content="summary_large_image">
+<meta name="twitter:title" content="{{.Title}}">
+<meta name="twitter:description" content="{{.Description}}">
+<meta name="twitter:image" content="{{.ImageURL}}">
+<meta http-equiv="refresh" content="0; url={{.TargetURL}}">
+</head>
+<body>
+<p><a href="{{.TargetURL}}">Nghe “{{.Title}}” trên {{.SiteName}}</a></p>
+<script>window.location.replace({{.TargetURL}});</script>
+</body>
+</html>
+`))
+
+// GET /share/podcasts/:podcast_id
+// Trang HTML có thẻ Open Graph / Twitter Card cho bot mạng xã hội, người dùng được chuyển sang frontend (giữ UTM)
+func PodcastSharePageHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		feBase := os.Getenv("FE_BASE_URL")
+		p, ok := loadSharePodcast(db, c.Param("podcast_id"))
+		if !ok {
+			c.Redirect(http.StatusFound, feBase+"/")
+			return
+		}
+
+		utm := url.Values{}
+		for _, key := range []string{"utm_source", "utm_medium", "utm_campaign", "utm_content", "utm_term"} {
+			if v := c.Query(key); v != "" {
+				utm.Set(key, v)
+			}
+		}
+		target := feBase + "/podcast/" + p.ID.String()
+		if len(utm) > 0 {
+			target += "?" + utm.Encode()
+		}
+
+		if source := c.Query("utm_source"); source != "" && !isShareBot(c.GetHeader("User-Agent")) {
+			open := models.PodcastShare{PodcastID: p.ID, Channel: normalizeShareChannel(source), Action: models.ShareActionOpen}
+			if err := recordPodcastShare(c, db, &open); err != nil {
+				log.Printf("Không ghi được lượt mở link chia sẻ %s: %v\n", p.ID, err)
+			}
+		}
+
+		base := apiBaseURL(c)
+		var buf bytes.Buffer
+		if err := sharePageTemplate.Execute(&buf, gin.H{
+			"SiteName":    siteName(),
+			"Title":       p.Title,
+			"Description": p.shareDescription(),
+			"PageURL":     fmt.Sprintf("%s/share/podcasts/%s", base, p.ID),
+			"ImageURL":    fmt.Sprintf("%s/share/podcasts/%s/image?v=%d", base, p.ID, p.UpdatedAt.Unix()),
+			"ImageWidth":  services.ShareCardWidth,
+			"ImageHeight": services.ShareCardHeight,
+			"AudioURL":    p.AudioURL,
+			"TargetURL":   target,
+		}); err != nil {
+			c.Redirect(http.StatusFound, target)
+			return
+		}
+		c.Header("Cache-Control", "public, max-age=300")
+		c.Data(http.StatusOK, "text/html; charset=utf-8", buf.Bytes())
+	}
+}
+
+var (
+	shareImageCache = map[string][]byte{}
+	shareImageMu    sync.Mutex
+)
+
+// GET /share/podcasts/:podcast_id/image
+// Ảnh chia sẻ PNG 1200x630 vẽ từ tên podcast, môn học và ảnh bìa; cache trong bộ nhớ theo lần cập nhật
+func PodcastShareImageHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, ok := loadSharePodcast(db, c.Param("podcast_id"))
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy podcast"})
+			return
+		}
+
+		key := fmt.Sprintf("%s-%d", p.ID, p.UpdatedAt.Unix())
+		etag := `"` + key + `"`
+		c.Header("Cache-Control", "public, max-age=86400")
+		c.Header("ETag", etag)
+		if c.GetHeader("If-None-Match") == etag {
+			c.Status(http.StatusNotModified)
+			return
+		}
+
+		shareImageMu.Lock()
+		img, cached := shareImageCache[key]
+		shareImageMu.Unlock()
+
+		if !cached {
+			card := services.ShareCard{
+				Title:    p.Title,
+				Subtitle: p.SubjectName,
+				Footer:   siteName(),
+			}
+			if p.DurationSec > 0 {
+				card.Footer += " · " + FormatSecondsToHHMMSS(p.DurationSec)
+			}
+			if p.CoverImage != "" {
+				if cover, err := services.FetchImage(p.CoverImage); err == nil {
+					card.Cover = cover
+				} else {
+					log.Printf("Không tải được ảnh bìa podcast %s: %v\n", p.ID, err)
+				}
+			}
+
+			var err error
+			img, err = services.RenderShareCard(card)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tạo ảnh chia sẻ"})
+				return
+			}
+
+			shareImageMu.Lock()
+			if len(shareImageCache) >= maxShareImageCache {
+				shareImageCache = map[string][]byte{}
+			}
+			shareImageCache[key] = img
+			shareImageMu.Unlock()
+		}
+
+		c.Data(http.StatusOK, "image/png", img)
+	}
+}
+
+// ShareChannelStat là số lượt chia sẻ / lượt mở link của một kênh
+type ShareChannelStat struct {
+	Channel string `json:"channel"`
+	Shares  int64  `json:"shares"`
+	Opens   int64  `json:"opens"`
+}
+
+// GET /api/admin/stats/podcasts/:podcast_id/shares?from=&to=
+func GetPodcastShareStats(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	podcastID, err := uuid.Parse(c.Param("podcast_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid podcast_id"})
+		return
+	}
+	var podcast models.Podcast
+	if err := db.First(&podcast, "id = ?", podcastID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy podcast"})
+		return
+	}
+	if !canManagePodcast(c, db, podcast, models.PermPodcastEdit) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Bạn không có quyền xem thống kê podcast này"})
+		return
+	}
+
+	from, to, _, ok := parseStatsRange(c, 30)
+	if !ok {
+		return
+	}
+
+	var rows []ShareChannelStat
+	db.Model(&models.PodcastShare{}).
+		Select(`channel,
+			COUNT(*) FILTER (WHERE action = ?) AS shares,
+			COUNT(*) FILTER (WHERE action = ?) AS opens`, models.ShareActionShare, models.ShareActionOpen).
+		Where("podcast_id = ? AND created_at >= ? AND created_at < ?", podcastID, from, to.AddDate(0, 0, 1)).
+		Group("channel").
+		Scan(&rows)
+
+	byChannel := map[string]ShareChannelStat{}
+	for _, r := range rows {
+		byChannel[r.Channel] = r
+	}
+	var totalShares, totalOpens int64
+	stats := make([]ShareChannelStat, 0, len(models.ShareChannels)+1)
+	channels := append(append([]string{}, models.ShareChannels...), models.ShareChannelOther)
+	for _, ch := range channels {
+		r := byChannel[ch]
+		r.Channel = ch
+		totalShares += r.Shares
+		totalOpens += r.Opens
+		stats = append(stats, r)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":         stats,
+		"total_shares": totalShares,
+		"total_opens":  totalOpens,
+		"from":         from.Format("2006-01-02"),
+		"to":           to.Format("2006-01-02"),
+	})
+}
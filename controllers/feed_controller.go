@@ -0,0 +1,682 @@
+package controllers
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/vnkhanh/e-podcast-backend/models"
+	"github.com/vnkhanh/e-podcast-backend/services"
+	"github.com/vnkhanh/e-podcast-backend/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ==================== FEED RSS / ITUNES / PODCASTING 2.0 ====================
+
+const (
+	itunesNamespace  = "http://www.itunes.com/dtds/podcast-1.0.dtd"
+	podcastNamespace = "https://podcastindex.org/namespace/1.0"
+	atomNamespace    = "http://www.w3.org/2005/Atom"
+
+	maxFeedEpisodes   = 300 // Feed danh mục chỉ giữ các tập mới nhất
+	maxSizeLookupsRun = 20  // Số podcast tối đa được HEAD để lấy kích thước file trong một lần dựng feed
+)
+
+// podcastGUIDNamespace là namespace UUIDv5 của podcast:guid (Podcasting 2.0)
+var podcastGUIDNamespace = uuid.MustParse("ead4c236-bf58-58c6-a2c6-a6b28d128cb6")
+
+type rssFeed struct {
+	XMLName   xml.Name   `xml:"rss"`
+	Version   string     `xml:"version,attr"`
+	ITunesNS  string     `xml:"xmlns:itunes,attr"`
+	PodcastNS string     `xml:"xmlns:podcast,attr"`
+	AtomNS    string     `xml:"xmlns:atom,attr"`
+	Channel   rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string            `xml:"title"`
+	Link          string            `xml:"link"`
+	Description   string            `xml:"description"`
+	Language      string            `xml:"language"`
+	LastBuildDate string            `xml:"lastBuildDate,omitempty"`
+	AtomLink      rssAtomLink       `xml:"atom:link"`
+	Image         *rssImage         `xml:"image,omitempty"`
+	Author        string            `xml:"itunes:author"`
+	Summary       string            `xml:"itunes:summary,omitempty"`
+	Type          string            `xml:"itunes:type"`
+	Explicit      string            `xml:"itunes:explicit"`
+	Block         string            `xml:"itunes:block,omitempty"`
+	ITunesImage   *rssITunesImage   `xml:"itunes:image,omitempty"`
+	Category      rssITunesCategory `xml:"itunes:category"`
+	Owner         *rssITunesOwner   `xml:"itunes:owner,omitempty"`
+	GUID          string            `xml:"podcast:guid"`
+	Locked        *rssLocked        `xml:"podcast:locked,omitempty"`
+	Items         []rssItem         `xml:"item"`
+}
+
+type rssAtomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssImage struct {
+	URL   string `xml:"url"`
+	Title string `xml:"title"`
+	Link  string `xml:"link"`
+}
+
+type rssITunesImage struct {
+	Href string `xml:"href,attr"`
+}
+
+type rssITunesCategory struct {
+	Text string             `xml:"text,attr"`
+	Sub  *rssITunesCategory `xml:"itunes:category,omitempty"`
+}
+
+type rssITunesOwner struct {
+	Name  string `xml:"itunes:name"`
+	Email string `xml:"itunes:email"`
+}
+
+type rssLocked struct {
+	Owner string `xml:"owner,attr,omitempty"`
+	Value string `xml:",chardata"`
+}
+
+type rssItem struct {
+	Title       string          `xml:"title"`
+	Description string          `xml:"description"`
+	Link        string          `xml:"link"`
+	GUID        rssGUID         `xml:"guid"`
+	PubDate     string          `xml:"pubDate"`
+	Enclosure   rssEnclosure    `xml:"enclosure"`
+	Duration    int             `xml:"itunes:duration,omitempty"`
+	Summary     string          `xml:"itunes:summary,omitempty"`
+	ITunesImage *rssITunesImage `xml:"itunes:image,omitempty"`
+	EpisodeType string          `xml:"itunes:episodeType"`
+	Season      int             `xml:"itunes:season,omitempty"`
+	Episode     int             `xml:"itunes:episode,omitempty"`
+	Explicit    string          `xml:"itunes:explicit"`
+	Chapters    *rssChapters    `xml:"podcast:chapters,omitempty"`
+}
+
+type rssGUID struct {
+	IsPermaLink string `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+type rssChapters struct {
+	URL  string `xml:"url,attr"`
+	Type string `xml:"type,attr"`
+}
+
+// podcastChapters là file chapters JSON của Podcasting 2.0 (application/json+chapters)
+type podcastChapters struct {
+	Version  string                `json:"version"`
+	Chapters []podcastChapterEntry `json:"chapters"`
+}
+
+type podcastChapterEntry struct {
+	StartTime int    `json:"startTime"`
+	Title     string `json:"title"`
+}
+
+// feedEpisode là một podcast đã xuất bản kèm thông tin chương để dựng item
+type feedEpisode struct {
+	ID           uuid.UUID
+	Title        string
+	Description  string
+	Summary      string
+	AudioURL     string
+	AudioSize    int64
+	DurationSec  int
+	CoverImage   string
+	PublishedAt  *time.Time
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	ChapterOrder int
+	ChapterID    uuid.UUID
+	HasChapters  bool
+}
+
+// feedEpisodes là truy vấn gốc cho các tập trong feed (chỉ podcast đã xuất bản)
+func feedEpisodes(db *gorm.DB) *gorm.DB {
+	return db.Model(&models.Podcast{}).
+		Select(`podcasts.id, podcasts.title, podcasts.description, podcasts.summary, podcasts.audio_url,
+			podcasts.audio_size, podcasts.duration_sec, podcasts.cover_image, podcasts.published_at,
+			podcasts.created_at, podcasts.updated_at, chapters.sort_order AS chapter_order, chapters.id AS chapter_id,
+			jsonb_array_length(COALESCE(podcasts.chapter_markers, '[]'::jsonb)) > 0 AS has_chapters`).
+		Joins("JOIN chapters ON chapters.id = podcasts.chapter_id").
+		Where("podcasts.status = ?", "published")
+}
+
+// feedVisibleSubjects là subquery id các môn học đang hoạt động mà người xem feed được nghe:
+// khách chỉ thấy môn công khai; user có token thấy thêm môn mình dạy hoặc học trong lớp của môn
+func feedVisibleSubjects(db *gorm.DB, viewer *models.User) *gorm.DB {
+	q := db.Model(&models.Subject{}).Select("subjects.id").Where("subjects.status = ?", true)
+	if viewer == nil {
+		return q.Where("subjects.public_feed = ?", true)
+	}
+	if viewer.Role == models.RoleAdmin {
+		return q
+	}
+	userID := viewer.ID.String()
+	return q.Where("subjects.public_feed = ? OR subjects.id IN (?) OR subjects.id IN (?)", true,
+		taughtSubjectIDs(db, userID),
+		db.Model(&models.ClassEnrollment{}).Select("classes.subject_id").
+			Joins("JOIN classes ON classes.id = class_enrollments.class_id").
+			Where("class_enrollments.user_id = ?", userID))
+}
+
+// resolveFeedViewer đọc ?token= của link feed riêng (so theo hash); không có token là khách
+func resolveFeedViewer(c *gin.Context, db *gorm.DB) (*models.User, bool) {
+	token := c.Query("token")
+	if token == "" {
+		return nil, true
+	}
+	var ft models.FeedToken
+	if err := db.Preload("User").Where("token_hash = ?", utils.HashToken(token)).First(&ft).Error; err != nil ||
+		(ft.User.Status != nil && !*ft.User.Status) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Link feed không hợp lệ hoặc đã bị thu hồi"})
+		return nil, false
+	}
+	db.Model(&ft).UpdateColumn("last_used_at", time.Now())
+	return &ft.User, true
+}
+
+// apiBaseURL là gốc URL của API theo request hiện tại (hỗ trợ reverse proxy)
+func apiBaseURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil || strings.EqualFold(c.GetHeader("X-Forwarded-Proto"), "https") {
+		scheme = "https"
+	}
+	host := c.Request.Host
+	if fwd := c.GetHeader("X-Forwarded-Host"); fwd != "" {
+		host = fwd
+	}
+	return scheme + "://" + host
+}
+
+// feedURL là link feed của môn học / danh mục, kèm token nếu là link riêng
+func feedURL(base, kind, slug, token string) string {
+	u := fmt.Sprintf("%s/api/feeds/%s/%s", base, kind, url.PathEscape(slug))
+	if token != "" {
+		u += "?token=" + url.QueryEscape(token)
+	}
+	return u
+}
+
+// podcastGUID là podcast:guid = UUIDv5 của URL feed công khai (bỏ scheme và dấu / cuối)
+func podcastGUID(publicURL string) string {
+	trimmed := publicURL
+	if i := strings.Index(trimmed, "://"); i >= 0 {
+		trimmed = trimmed[i+3:]
+	}
+	return uuid.NewSHA1(podcastGUIDNamespace, []byte(strings.TrimRight(trimmed, "/"))).String()
+}
+
+// audioMimeType đoán kiểu enclosure theo đuôi file
+func audioMimeType(audioURL string) string {
+	ext := ""
+	if u, err := url.Parse(audioURL); err == nil {
+		ext = strings.ToLower(path.Ext(u.Path))
+	}
+	switch ext {
+	case ".m4a", ".mp4", ".aac":
+		return "audio/x-m4a"
+	case ".wav":
+		return "audio/wav"
+	case ".ogg", ".oga":
+		return "audio/ogg"
+	case ".opus":
+		return "audio/opus"
+	default:
+		return "audio/mpeg"
+	}
+}
+
+// fillAudioSizes lấy kích thước file cho podcast cũ chưa có (HEAD song song, giới hạn mỗi lần) và lưu lại
+func fillAudioSizes(db *gorm.DB, episodes []feedEpisode) {
+	var wg sync.WaitGroup
+	lookups := 0
+	for i := range episodes {
+		if episodes[i].AudioSize > 0 || episodes[i].AudioURL == "" || lookups >= maxSizeLookupsRun {
+			continue
+		}
+		lookups++
+		wg.Add(1)
+		go func(ep *feedEpisode) {
+			defer wg.Done()
+			size, err := services.GetAudioSizeFromURL(ep.AudioURL)
+			if err != nil {
+				log.Printf("Không lấy được kích thước audio của podcast %s: %v", ep.ID, err)
+				return
+			}
+			ep.AudioSize = size
+			// UpdateColumn để không đổi updated_at (dùng làm Last-Modified của feed)
+			db.Model(&models.Podcast{}).Where("id = ?", ep.ID).UpdateColumn("audio_size", size)
+		}(&episodes[i])
+	}
+	wg.Wait()
+}
+
+// feedItems dựng item RSS; serial thì đánh số season = thứ tự chương, episode = thứ tự trong chương
+func feedItems(c *gin.Context, episodes []feedEpisode, serial bool, token string) []rssItem {
+	base := apiBaseURL(c)
+	feBase := os.Getenv("FE_BASE_URL")
+	items := make([]rssItem, 0, len(episodes))
+	episodeNo := map[uuid.UUID]int{}
+	for _, ep := range episodes {
+		description := ep.Description
+		if description == "" {
+			description = ep.Summary
+		}
+		if description == "" {
+			description = ep.Title
+		}
+		pubDate := ep.CreatedAt
+		if ep.PublishedAt != nil {
+			pubDate = *ep.PublishedAt
+		}
+
+		item := rssItem{
+			Title:       ep.Title,
+			Description: description,
+			Link:        feBase + "/podcast/" + ep.ID.String(),
+			GUID:        rssGUID{IsPermaLink: "false", Value: ep.ID.String()},
+			PubDate:     pubDate.UTC().Format(time.RFC1123Z),
+			Enclosure:   rssEnclosure{URL: ep.AudioURL, Length: ep.AudioSize, Type: audioMimeType(ep.AudioURL)},
+			Duration:    ep.DurationSec,
+			Summary:     ep.Summary,
+			EpisodeType: "full",
+			Explicit:    "false",
+		}
+		if ep.CoverImage != "" {
+			item.ITunesImage = &rssITunesImage{Href: ep.CoverImage}
+		}
+		if serial {
+			episodeNo[ep.ChapterID]++
+			item.Episode = episodeNo[ep.ChapterID]
+			if ep.ChapterOrder > 0 {
+				item.Season = ep.ChapterOrder
+			}
+		}
+		if ep.HasChapters {
+			chaptersURL := fmt.Sprintf("%s/api/feeds/podcasts/%s/chapters", base, ep.ID)
+			if token != "" {
+				chaptersURL += "?token=" + url.QueryEscape(token)
+			}
+			item.Chapters = &rssChapters{URL: chaptersURL, Type: "application/json+chapters"}
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// newFeedChannel điền các thẻ chung của channel
+func newFeedChannel(c *gin.Context, title, link, description, publicURL string, episodes []feedEpisode) rssChannel {
+	author := os.Getenv("FEED_AUTHOR")
+	if author == "" {
+		author = siteName()
+	}
+	selfURL := apiBaseURL(c) + c.Request.URL.RequestURI()
+
+	ch := rssChannel{
+		Title:       title,
+		Link:        link,
+		Description: description,
+		Language:    "vi",
+		AtomLink:    rssAtomLink{Href: selfURL, Rel: "self", Type: "application/rss+xml"},
+		Author:      author,
+		Summary:     description,
+		Explicit:    "false",
+		Category:    rssITunesCategory{Text: "Education", Sub: &rssITunesCategory{Text: "Courses"}},
+		GUID:        podcastGUID(publicURL),
+	}
+	if email := os.Getenv("FEED_OWNER_EMAIL"); email != "" {
+		ch.Owner = &rssITunesOwner{Name: author, Email: email}
+	}
+
+	// Ảnh bìa: ảnh của tập đầu tiên có ảnh, không có thì dùng FEED_IMAGE_URL
+	cover := os.Getenv("FEED_IMAGE_URL")
+	for _, ep := range episodes {
+		if ep.CoverImage != "" {
+			cover = ep.CoverImage
+			break
+		}
+	}
+	if cover != "" {
+		ch.ITunesImage = &rssITunesImage{Href: cover}
+		ch.Image = &rssImage{URL: cover, Title: title, Link: link}
+	}
+	return ch
+}
+
+// feedLastModified là thời điểm cập nhật gần nhất của feed
+func feedLastModified(since time.Time, episodes []feedEpisode) time.Time {
+	last := since
+	for _, ep := range episodes {
+		if ep.UpdatedAt.After(last) {
+			last = ep.UpdatedAt
+		}
+	}
+	return last.UTC().Truncate(time.Second)
+}
+
+// respondFeed trả XML, hỗ trợ If-Modified-Since để ứng dụng podcast không phải tải lại
+func respondFeed(c *gin.Context, ch rssChannel, lastModified time.Time, private bool) {
+	if private {
+		c.Header("Cache-Control", "private, max-age=900")
+	} else {
+		c.Header("Cache-Control", "public, max-age=900")
+	}
+	c.Header("Last-Modified", lastModified.Format(http.TimeFormat))
+	if since, err := http.ParseTime(c.GetHeader("If-Modified-Since")); err == nil && !lastModified.After(since) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	ch.LastBuildDate = lastModified.Format(time.RFC1123Z)
+	feed := rssFeed{
+		Version:   "2.0",
+		ITunesNS:  itunesNamespace,
+		PodcastNS: podcastNamespace,
+		AtomNS:    atomNamespace,
+		Channel:   ch,
+	}
+	out, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tạo feed"})
+		return
+	}
+	c.Data(http.StatusOK, "application/rss+xml; charset=utf-8", append([]byte(xml.Header), out...))
+}
+
+// GET /api/feeds/subjects/:slug[?token=]
+// Feed của môn học, các tập xếp theo thứ tự chương (itunes:type serial)
+func GetSubjectFeed(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	viewer, ok := resolveFeedViewer(c, db)
+	if !ok {
+		return
+	}
+
+	var subject models.Subject
+	if err := db.Where("slug = ? AND id IN (?)", c.Param("slug"), feedVisibleSubjects(db, viewer)).
+		First(&subject).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy feed"})
+		return
+	}
+
+	var episodes []feedEpisode
+	if err := feedEpisodes(db).
+		Where("chapters.subject_id = ?", subject.ID).
+		Order("chapters.sort_order ASC, CAST(substring(podcasts.title from '[0-9]+') AS INTEGER) ASC, podcasts.title ASC").
+		Scan(&episodes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tải danh sách podcast"})
+		return
+	}
+	fillAudioSizes(db, episodes)
+
+	base := apiBaseURL(c)
+	token := c.Query("token")
+	description := fmt.Sprintf("Podcast môn %s", subject.Name)
+	if subject.CourseCode != "" {
+		description = fmt.Sprintf("Podcast môn %s (%s)", subject.Name, subject.CourseCode)
+	}
+	ch := newFeedChannel(c, subject.Name, os.Getenv("FE_BASE_URL")+"/subject/"+subject.Slug, description,
+		feedURL(base, "subjects", subject.Slug, ""), episodes)
+	ch.Type = "serial"
+	if !subject.PublicFeed {
+		// Môn không công khai: không cho đưa lên thư mục podcast hay nền tảng khác
+		ch.Block = "Yes"
+		ch.Locked = &rssLocked{Owner: os.Getenv("FEED_OWNER_EMAIL"), Value: "yes"}
+	}
+	ch.Items = feedItems(c, episodes, true, token)
+
+	respondFeed(c, ch, feedLastModified(subject.UpdatedAt, episodes), viewer != nil)
+}
+
+// GET /api/feeds/categories/:slug[?token=]
+// Feed của danh mục, các tập mới xuất bản trước (itunes:type episodic)
+func GetCategoryFeed(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	viewer, ok := resolveFeedViewer(c, db)
+	if !ok {
+		return
+	}
+
+	var category models.Category
+	if err := db.Where("slug = ? AND status = ?", c.Param("slug"), true).First(&category).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy feed"})
+		return
+	}
+
+	var episodes []feedEpisode
+	if err := feedEpisodes(db).
+		Joins("JOIN podcast_categories pc ON pc.podcast_id = podcasts.id").
+		Where("pc.category_id = ? AND chapters.subject_id IN (?)", category.ID, feedVisibleSubjects(db, viewer)).
+		Order("COALESCE(podcasts.published_at, podcasts.created_at) DESC").
+		Limit(maxFeedEpisodes).
+		Scan(&episodes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tải danh sách podcast"})
+		return
+	}
+	fillAudioSizes(db, episodes)
+
+	base := apiBaseURL(c)
+	ch := newFeedChannel(c, category.Name, os.Getenv("FE_BASE_URL")+"/category/"+category.Slug,
+		fmt.Sprintf("Podcast thuộc danh mục %s", category.Name), feedURL(base, "categories", category.Slug, ""), episodes)
+	ch.Type = "episodic"
+	if viewer != nil {
+		// Link riêng có thể chứa tập của môn không công khai
+		ch.Block = "Yes"
+	}
+	ch.Items = feedItems(c, episodes, false, c.Query("token"))
+
+	respondFeed(c, ch, feedLastModified(category.UpdatedAt, episodes), viewer != nil)
+}
+
+// GET /api/feeds/podcasts/:id/chapters[?token=]
+// Mốc chương của podcast cho podcast:chapters
+func GetPodcastChapters(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	viewer, ok := resolveFeedViewer(c, db)
+	if !ok {
+		return
+	}
+
+	podcastID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID podcast không hợp lệ"})
+		return
+	}
+
+	var podcast models.Podcast
+	if err := db.Select("podcasts.id", "podcasts.chapter_markers").
+		Joins("JOIN chapters ON chapters.id = podcasts.chapter_id").
+		Where("podcasts.id = ? AND podcasts.status = ? AND chapters.subject_id IN (?)",
+			podcastID, "published", feedVisibleSubjects(db, viewer)).
+		Take(&podcast).Error; err != nil || len(podcast.ChapterMarkers) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Podcast này chưa có mốc chương"})
+		return
+	}
+
+	out := podcastChapters{Version: "1.2.0", Chapters: make([]podcastChapterEntry, 0, len(podcast.ChapterMarkers))}
+	for _, m := range podcast.ChapterMarkers {
+		out.Chapters = append(out.Chapters, podcastChapterEntry{StartTime: m.StartSec, Title: m.Title})
+	}
+	body, err := json.Marshal(out)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tạo danh sách chương"})
+		return
+	}
+
+	if viewer != nil {
+		c.Header("Cache-Control", "private, max-age=3600")
+	} else {
+		c.Header("Cache-Control", "public, max-age=3600")
+	}
+	c.Data(http.StatusOK, "application/json+chapters; charset=utf-8", body)
+}
+
+// ==================== LINK FEED RIÊNG CỦA USER ====================
+
+// issueFeedToken tạo token feed mới cho user (thay token cũ nếu có) và trả token gốc; DB chỉ lưu hash
+func issueFeedToken(db *gorm.DB, userID uuid.UUID) (string, *models.FeedToken, error) {
+	token, err := utils.GenerateRandomToken()
+	if err != nil {
+		return "", nil, err
+	}
+	ft := models.FeedToken{UserID: userID, TokenHash: utils.HashToken(token)}
+	if err := db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"token_hash":   ft.TokenHash,
+			"last_used_at": nil,
+			"created_at":   gorm.Expr("NOW()"),
+		}),
+	}).Create(&ft).Error; err != nil {
+		return "", nil, err
+	}
+	return token, &ft, nil
+}
+
+// SubjectFeedLink là link feed của một môn học user nghe được
+type SubjectFeedLink struct {
+	ID         uuid.UUID `json:"id"`
+	Name       string    `json:"name"`
+	CourseCode string    `json:"course_code"`
+	Slug       string    `json:"slug"`
+	PublicFeed bool      `json:"public_feed"`
+	FeedURL    string    `json:"feed_url"` // Rỗng với môn không công khai khi chưa có token vừa tạo
+}
+
+// CategoryFeedLink là link feed của một danh mục
+type CategoryFeedLink struct {
+	ID      uuid.UUID `json:"id"`
+	Name    string    `json:"name"`
+	Slug    string    `json:"slug"`
+	FeedURL string    `json:"feed_url"`
+}
+
+// feedLinksResponse liệt kê link feed. Token gốc chỉ có ngay sau khi tạo (DB chỉ giữ hash), nên khi token rỗng
+// môn không công khai không có link và danh mục dùng link công khai; user tạo token mới để lấy link riêng
+func feedLinksResponse(c *gin.Context, db *gorm.DB, viewer *models.User, ft *models.FeedToken, token string) gin.H {
+	base := apiBaseURL(c)
+
+	var subjects []models.Subject
+	db.Where("id IN (?)", feedVisibleSubjects(db, viewer)).Order("name ASC").Find(&subjects)
+	subjectLinks := make([]SubjectFeedLink, 0, len(subjects))
+	for _, s := range subjects {
+		link := SubjectFeedLink{
+			ID:         s.ID,
+			Name:       s.Name,
+			CourseCode: s.CourseCode,
+			Slug:       s.Slug,
+			PublicFeed: s.PublicFeed,
+		}
+		switch {
+		case s.PublicFeed:
+			link.FeedURL = feedURL(base, "subjects", s.Slug, "")
+		case token != "":
+			link.FeedURL = feedURL(base, "subjects", s.Slug, token)
+		}
+		subjectLinks = append(subjectLinks, link)
+	}
+
+	var categories []models.Category
+	db.Where("status = ?", true).Order("name ASC").Find(&categories)
+	categoryLinks := make([]CategoryFeedLink, 0, len(categories))
+	for _, cat := range categories {
+		categoryLinks = append(categoryLinks, CategoryFeedLink{
+			ID:      cat.ID,
+			Name:    cat.Name,
+			Slug:    cat.Slug,
+			FeedURL: feedURL(base, "categories", cat.Slug, token),
+		})
+	}
+
+	data := gin.H{
+		"has_token":  ft != nil,
+		"subjects":   subjectLinks,
+		"categories": categoryLinks,
+	}
+	if ft != nil {
+		data["last_used_at"] = ft.LastUsedAt
+		data["token_created_at"] = ft.CreatedAt
+	}
+	if token != "" {
+		data["token"] = token
+	}
+	return data
+}
+
+// GET /api/user/account/feeds
+// Link feed công khai và trạng thái token; link riêng chỉ hiện một lần khi tạo token (POST /feeds/token)
+func GetMyFeeds(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	var user models.User
+	if err := db.First(&user, "id = ?", c.GetString("user_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy người dùng"})
+		return
+	}
+
+	var ft *models.FeedToken
+	var existing models.FeedToken
+	if err := db.Where("user_id = ?", user.ID).First(&existing).Error; err == nil {
+		ft = &existing
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy link feed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Lấy link feed thành công",
+		"data":    feedLinksResponse(c, db, &user, ft, ""),
+	})
+}
+
+// POST /api/user/account/feeds/token
+// Tạo token mới (link cũ ngừng hoạt động) và trả các link feed riêng; token chỉ hiện lần này
+func RegenerateFeedToken(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	var user models.User
+	if err := db.First(&user, "id = ?", c.GetString("user_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy người dùng"})
+		return
+	}
+	token, ft, err := issueFeedToken(db, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tạo link feed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Đã tạo link feed mới, hãy lưu lại vì link sẽ không hiện lại; link cũ không còn dùng được",
+		"data":    feedLinksResponse(c, db, &user, ft, token),
+	})
+}
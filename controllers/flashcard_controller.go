@@ -1,8 +1,11 @@
 package controllers
 
 import (
+	"bytes"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"regexp"
 	"strings"
@@ -12,6 +15,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/vnkhanh/e-podcast-backend/models"
 	"github.com/vnkhanh/e-podcast-backend/services"
+	"github.com/vnkhanh/e-podcast-backend/utils"
 	"gorm.io/gorm"
 )
 
@@ -119,9 +123,9 @@ func GenerateFlashcardsFromDocument(c *gin.Context) {
 		podcastID = uuid.Nil
 	}
 
-	// XÓA FLASHCARD CŨ TRƯỚC KHI TẠO MỚI
+	// XÓA FLASHCARD CŨ TRƯỚC KHI TẠO MỚI (giữ lại bộ thẻ giảng viên biên soạn)
 	if err := db.
-		Where("user_id = ? AND podcast_id = ?", userUUID, podcastID).
+		Where("user_id = ? AND podcast_id = ? AND is_curated = ?", userUUID, podcastID, false).
 		Delete(&models.Flashcard{}).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể xóa flashcards cũ"})
 		return
@@ -240,10 +244,11 @@ func GetFlashcardsByPodcast(c *gin.Context) {
 		return
 	}
 
+	// Thẻ của chính user + bộ thẻ giảng viên biên soạn cho podcast
 	var flashcards []models.Flashcard
 	if err := db.
-		Where("user_id = ? AND podcast_id = ?", userUUID, podcastUUID).
-		Order("created_at ASC").
+		Where("podcast_id = ? AND (user_id = ? OR is_curated = ?)", podcastUUID, userUUID, true).
+		Order("is_curated DESC, created_at ASC").
 		Find(&flashcards).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy flashcards"})
 		return
@@ -254,3 +259,373 @@ func GetFlashcardsByPodcast(c *gin.Context) {
 		"count": len(flashcards),
 	})
 }
+
+// ======== API: XUẤT FLASHCARDS (CSV / ANKI) ========
+// GET /api/user/podcasts/:id/flashcards/export?format=csv|apkg|anki
+func ExportFlashcards(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userIDStr := c.GetString("user_id")
+	format := strings.ToLower(c.DefaultQuery("format", "csv"))
+
+	userUUID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id không hợp lệ"})
+		return
+	}
+
+	podcastUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "podcast_id không hợp lệ"})
+		return
+	}
+
+	var podcast models.Podcast
+	if err := db.First(&podcast, "id = ?", podcastUUID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy podcast"})
+		return
+	}
+
+	var flashcards []models.Flashcard
+	if err := db.
+		Where("podcast_id = ? AND (user_id = ? OR is_curated = ?)", podcastUUID, userUUID, true).
+		Order("is_curated DESC, created_at ASC").
+		Find(&flashcards).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy flashcards"})
+		return
+	}
+
+	if len(flashcards) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Podcast chưa có flashcard nào"})
+		return
+	}
+
+	baseName := "Flashcards_" + podcast.Title
+
+	switch format {
+	case "apkg":
+		cards := make([]services.AnkiCard, 0, len(flashcards))
+		for _, fc := range flashcards {
+			cards = append(cards, services.AnkiCard{
+				Front:     fc.FrontText,
+				Back:      fc.BackText,
+				Source:    fc.SourceText,
+				Reference: fc.ReferenceText,
+			})
+		}
+
+		data, err := services.BuildAnkiPackage("E-Podcast::"+podcast.Title, cards)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tạo file Anki", "details": err.Error()})
+			return
+		}
+		c.Header("Content-Disposition", utils.AttachmentDisposition(baseName+".apkg"))
+		c.Data(http.StatusOK, "application/apkg", data)
+
+	case "anki":
+		// Định dạng "Notes in Plain Text" của Anki (tab-separated + header)
+		var buf bytes.Buffer
+		buf.WriteString("#separator:tab\n#html:false\n#columns:Front\tBack\tSource\tReference\n")
+		w := csv.NewWriter(&buf)
+		w.Comma = '\t'
+		for _, fc := range flashcards {
+			w.Write([]string{fc.FrontText, fc.BackText, fc.SourceText, fc.ReferenceText})
+		}
+		w.Flush()
+		c.Header("Content-Disposition", utils.AttachmentDisposition(baseName+".txt"))
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", buf.Bytes())
+
+	case "csv":
+		var buf bytes.Buffer
+		buf.WriteString("\uFEFF") // BOM để Excel đọc đúng tiếng Việt
+		w := csv.NewWriter(&buf)
+		w.Write([]string{"front", "back", "source", "reference"})
+		for _, fc := range flashcards {
+			w.Write([]string{fc.FrontText, fc.BackText, fc.SourceText, fc.ReferenceText})
+		}
+		w.Flush()
+		c.Header("Content-Disposition", utils.AttachmentDisposition(baseName+".csv"))
+		c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format chỉ hỗ trợ csv, apkg hoặc anki"})
+	}
+}
+
+// ======== API: NHẬP FLASHCARDS TỪ CSV / ANKI TEXT ========
+// POST /api/user/podcasts/:id/flashcards/import (bộ thẻ cá nhân)
+func ImportFlashcards(c *gin.Context) {
+	importFlashcards(c, false)
+}
+
+// POST /api/admin/podcasts/:id/flashcards/import (bộ thẻ giảng viên biên soạn)
+func ImportCuratedFlashcards(c *gin.Context) {
+	importFlashcards(c, true)
+}
+
+// Dòng bị từ chối khi import
+type FlashcardImportError struct {
+	Row    int    `json:"row"`
+	Reason string `json:"reason"`
+}
+
+func importFlashcards(c *gin.Context, curated bool) {
+	db := c.MustGet("db").(*gorm.DB)
+	userIDStr := c.GetString("user_id")
+
+	userUUID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id không hợp lệ"})
+		return
+	}
+
+	podcastUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "podcast_id không hợp lệ"})
+		return
+	}
+
+	var podcast models.Podcast
+	if err := db.First(&podcast, "id = ?", podcastUUID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy podcast"})
+		return
+	}
+	// Flashcard chính thức của podcast chỉ người quản lý podcast được import
+	if curated && !canManagePodcast(c, db, podcast, models.PermPodcastEdit) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Bạn không có quyền chỉnh sửa podcast này"})
+		return
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Không có file đính kèm"})
+		return
+	}
+	if file.Size > 5*1024*1024 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File vượt quá 5MB"})
+		return
+	}
+
+	format := strings.ToLower(c.PostForm("format"))
+	// .apkg là gói zip + SQLite, chưa hỗ trợ nhập: yêu cầu xuất lại từ Anki dạng văn bản
+	if format == "apkg" || strings.HasSuffix(strings.ToLower(file.Filename), ".apkg") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Chưa hỗ trợ nhập file .apkg, hãy xuất từ Anki dạng \"Notes in Plain Text\" (.txt) hoặc CSV"})
+		return
+	}
+	if format == "" {
+		if strings.HasSuffix(strings.ToLower(file.Filename), ".csv") {
+			format = "csv"
+		} else {
+			format = "anki"
+		}
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Không thể đọc file"})
+		return
+	}
+	defer f.Close()
+
+	content, err := io.ReadAll(f)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Không thể đọc file"})
+		return
+	}
+
+	if bytes.HasPrefix(content, []byte("PK\x03\x04")) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File nén (.apkg / .zip) không được hỗ trợ, hãy tải lên file CSV hoặc văn bản Anki"})
+		return
+	}
+
+	var cards []services.AnkiCard
+	var rejected []FlashcardImportError
+	switch format {
+	case "csv":
+		cards, rejected, err = parseFlashcardCSV(content)
+	case "anki":
+		cards, rejected, err = parseAnkiText(content)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format chỉ hỗ trợ csv hoặc anki"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File không hợp lệ: " + err.Error()})
+		return
+	}
+
+	if len(cards) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Không có flashcard hợp lệ nào trong file", "rejected": rejected})
+		return
+	}
+
+	tx := db.Begin()
+
+	// replace=true: làm mới bộ thẻ cũ cùng loại trước khi nhập
+	if c.PostForm("replace") == "true" {
+		if err := tx.Where("user_id = ? AND podcast_id = ? AND is_curated = ?", userUUID, podcastUUID, curated).
+			Delete(&models.Flashcard{}).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể xóa flashcards cũ"})
+			return
+		}
+	}
+
+	flashcards := make([]models.Flashcard, 0, len(cards))
+	for _, card := range cards {
+		flashcards = append(flashcards, models.Flashcard{
+			UserID:        userUUID,
+			PodcastID:     podcastUUID,
+			FrontText:     card.Front,
+			BackText:      card.Back,
+			SourceText:    card.Source,
+			ReferenceText: card.Reference,
+			IsCurated:     curated,
+		})
+	}
+
+	if err := tx.Create(&flashcards).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lưu flashcards"})
+		return
+	}
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    fmt.Sprintf("Nhập thành công %d flashcards", len(flashcards)),
+		"total":      len(flashcards),
+		"rejected":   rejected,
+		"flashcards": flashcards,
+	})
+}
+
+// parseFlashcardCSV đọc CSV: front,back[,source,reference] (header tùy chọn)
+func parseFlashcardCSV(content []byte) ([]services.AnkiCard, []FlashcardImportError, error) {
+	content = bytes.TrimPrefix(content, []byte("\uFEFF"))
+	reader := csv.NewReader(bytes.NewReader(content))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	columns := map[string]int{"front": 0, "back": 1, "source": 2, "reference": 3}
+	start := 0
+	if len(records) > 0 && len(records[0]) > 0 && strings.EqualFold(strings.TrimSpace(records[0][0]), "front") {
+		for i, name := range records[0] {
+			columns[strings.ToLower(strings.TrimSpace(name))] = i
+		}
+		start = 1
+	}
+
+	var cards []services.AnkiCard
+	var rejected []FlashcardImportError
+	for i := start; i < len(records); i++ {
+		card, reason := flashcardFromRow(records[i], columns)
+		if reason != "" {
+			rejected = append(rejected, FlashcardImportError{Row: i + 1, Reason: reason})
+			continue
+		}
+		cards = append(cards, card)
+	}
+	return cards, rejected, nil
+}
+
+// parseAnkiText đọc file "Notes in Plain Text" do Anki xuất (#separator, #html, #columns)
+func parseAnkiText(content []byte) ([]services.AnkiCard, []FlashcardImportError, error) {
+	content = bytes.TrimPrefix(content, []byte("\uFEFF"))
+	separator := '\t'
+	stripHTML := false
+	columns := map[string]int{"front": 0, "back": 1, "source": 2, "reference": 3}
+
+	lines := strings.Split(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n")
+	headerLines := 0
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "#") {
+			break
+		}
+		headerLines++
+
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, "#"), ":")
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "separator":
+			switch strings.ToLower(strings.TrimSpace(value)) {
+			case "tab":
+				separator = '\t'
+			case "comma":
+				separator = ','
+			case "semicolon":
+				separator = ';'
+			case "pipe":
+				separator = '|'
+			case "space":
+				separator = ' '
+			default:
+				if r := []rune(value); len(r) == 1 {
+					separator = r[0]
+				}
+			}
+		case "html":
+			stripHTML = strings.TrimSpace(value) == "true"
+		case "columns":
+			for i, name := range strings.Split(value, string(separator)) {
+				columns[strings.ToLower(strings.TrimSpace(name))] = i
+			}
+		}
+	}
+
+	reader := csv.NewReader(strings.NewReader(strings.Join(lines[headerLines:], "\n")))
+	reader.Comma = separator
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var cards []services.AnkiCard
+	var rejected []FlashcardImportError
+	for i, row := range records {
+		if stripHTML {
+			for j := range row {
+				row[j] = strings.TrimSpace(services.HTMLTagRe.ReplaceAllString(row[j], " "))
+			}
+		}
+		card, reason := flashcardFromRow(row, columns)
+		if reason != "" {
+			rejected = append(rejected, FlashcardImportError{Row: headerLines + i + 1, Reason: reason})
+			continue
+		}
+		cards = append(cards, card)
+	}
+	return cards, rejected, nil
+}
+
+func flashcardFromRow(row []string, columns map[string]int) (services.AnkiCard, string) {
+	get := func(name string) string {
+		idx, ok := columns[name]
+		if !ok || idx >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[idx])
+	}
+
+	card := services.AnkiCard{
+		Front:     get("front"),
+		Back:      get("back"),
+		Source:    get("source"),
+		Reference: get("reference"),
+	}
+	if card.Front == "" {
+		return card, "Thiếu mặt trước (front)"
+	}
+	if card.Back == "" {
+		return card, "Thiếu mặt sau (back)"
+	}
+	return card, ""
+}
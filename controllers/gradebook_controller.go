@@ -0,0 +1,978 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/vnkhanh/e-podcast-backend/models"
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
+)
+
+// ==================== SỔ ĐIỂM ====================
+
+// GradebookAssignment là một cột bài tập trong sổ điểm
+type GradebookAssignment struct {
+	ID           uuid.UUID  `json:"id"`
+	Title        string     `json:"title"`
+	PodcastTitle string     `json:"podcast_title"`
+	CategoryID   *uuid.UUID `json:"category_id,omitempty"`
+	ScorePolicy  string     `json:"score_policy"`
+	DueDate      *time.Time `json:"due_date,omitempty"`
+}
+
+// GradebookCell là điểm của một sinh viên ở một bài tập (thang 10)
+type GradebookCell struct {
+	Score           *float64 `json:"score"`                      // Điểm hiệu lực, nil = chưa có
+	SubmissionScore *float64 `json:"submission_score,omitempty"` // Điểm từ bài làm theo chính sách best/last/average
+	Attempts        int      `json:"attempts"`
+	Adjusted        bool     `json:"adjusted"`     // Đã được điều chỉnh thủ công
+	Pending         bool     `json:"pending"`      // Còn câu tự luận chưa chấm
+	Missing         bool     `json:"missing"`      // Quá hạn mà không nộp, tính 0 điểm
+	NotAssigned     bool     `json:"not_assigned"` // Bài không giao cho sinh viên này (theo lớp), không tính vào tổng kết
+}
+
+// GradebookRow là một dòng sinh viên
+type GradebookRow struct {
+	UserID         uuid.UUID                 `json:"user_id"`
+	FullName       string                    `json:"full_name"`
+	Email          string                    `json:"email"`
+	Cells          map[string]*GradebookCell `json:"cells"`           // Theo assignment_id
+	CategoryScores map[string]*float64       `json:"category_scores"` // Theo category_id, "uncategorized" cho bài chưa phân nhóm
+	Final          *float64                  `json:"final"`
+}
+
+// Gradebook là toàn bộ lưới điểm của một môn hoặc một lớp
+type Gradebook struct {
+	SubjectID   uuid.UUID              `json:"subject_id"`
+	ClassID     *uuid.UUID             `json:"class_id,omitempty"`
+	Categories  []models.GradeCategory `json:"categories"`
+	Assignments []GradebookAssignment  `json:"assignments"`
+	Rows        []GradebookRow         `json:"rows"`
+}
+
+const uncategorizedKey = "uncategorized"
+
+// canManageSubject: admin, người tạo môn / được cấp quyền chấm trên môn, hoặc giảng viên có lớp thuộc môn
+func canManageSubject(c *gin.Context, db *gorm.DB, subjectID uuid.UUID) bool {
+	if hasSubjectPermission(c, db, subjectID, models.PermAssignmentGrade) {
+		return true
+	}
+
+	var count int64
+	db.Model(&models.Class{}).Where("subject_id = ? AND lecturer_id = ?", subjectID, c.GetString("user_id")).Count(&count)
+	return count > 0
+}
+
+// resolveGradebookScope đọc subject_id / class_id và kiểm tra quyền.
+// Có class_id thì môn học lấy theo lớp.
+func resolveGradebookScope(c *gin.Context, db *gorm.DB, subjectIDStr, classIDStr string) (uuid.UUID, *uuid.UUID, bool) {
+	if classIDStr != "" {
+		class, ok := loadOwnedClass(c, db, classIDStr)
+		if !ok {
+			return uuid.Nil, nil, false
+		}
+		return class.SubjectID, &class.ID, true
+	}
+
+	subjectID, err := uuid.Parse(subjectIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cần subject_id hoặc class_id hợp lệ"})
+		return uuid.Nil, nil, false
+	}
+	if !canManageSubject(c, db, subjectID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Không có quyền xem sổ điểm môn học này"})
+		return uuid.Nil, nil, false
+	}
+	return subjectID, nil, true
+}
+
+// aggregateScore gộp điểm các lần làm theo chính sách của bài tập (submissions sắp theo thời gian nộp)
+func aggregateScore(policy string, submissions []models.AssignmentSubmission) float64 {
+	switch policy {
+	case models.ScorePolicyLast:
+		return submissions[len(submissions)-1].Score
+	case models.ScorePolicyAverage:
+		total := 0.0
+		for _, s := range submissions {
+			total += s.Score
+		}
+		return roundPoints(total / float64(len(submissions)))
+	default:
+		best := submissions[0].Score
+		for _, s := range submissions[1:] {
+			if s.Score > best {
+				best = s.Score
+			}
+		}
+		return best
+	}
+}
+
+// latestAdjustments lấy điều chỉnh mới nhất của từng cặp (bài tập, sinh viên)
+func latestAdjustments(db *gorm.DB, assignmentIDs []uuid.UUID) map[string]models.GradeAdjustment {
+	result := map[string]models.GradeAdjustment{}
+	if len(assignmentIDs) == 0 {
+		return result
+	}
+
+	var adjustments []models.GradeAdjustment
+	db.Raw(`SELECT DISTINCT ON (assignment_id, user_id) * FROM grade_adjustments
+		WHERE assignment_id IN ? ORDER BY assignment_id, user_id, created_at DESC`, assignmentIDs).
+		Scan(&adjustments)
+
+	for _, adj := range adjustments {
+		result[adj.AssignmentID.String()+":"+adj.UserID.String()] = adj
+	}
+	return result
+}
+
+// buildGradebook tổng hợp điểm tất cả bài tập đã công bố của môn (hoặc lớp)
+func buildGradebook(db *gorm.DB, subjectID uuid.UUID, classID *uuid.UUID) (*Gradebook, error) {
+	book := &Gradebook{SubjectID: subjectID, ClassID: classID}
+
+	// Bài tập thuộc môn: assignment -> podcast -> chapter -> subject
+	query := db.Model(&models.Assignment{}).
+		Joins("JOIN podcasts p ON p.id = assignments.podcast_id").
+		Joins("JOIN chapters ch ON ch.id = p.chapter_id").
+		Where("ch.subject_id = ? AND assignments.is_published = ?", subjectID, true)
+	if classID != nil {
+		query = query.Where(`(NOT EXISTS (SELECT 1 FROM assignment_classes ac WHERE ac.assignment_id = assignments.id)
+			OR EXISTS (SELECT 1 FROM assignment_classes ac WHERE ac.assignment_id = assignments.id AND ac.class_id = ?))`, *classID)
+	}
+
+	var assignments []models.Assignment
+	if err := query.Preload("Podcast").Order("assignments.created_at ASC").Find(&assignments).Error; err != nil {
+		return nil, err
+	}
+
+	assignmentIDs := make([]uuid.UUID, 0, len(assignments))
+	for _, a := range assignments {
+		assignmentIDs = append(assignmentIDs, a.ID)
+	}
+	schedule, err := loadGradebookSchedule(db, subjectID, classID, assignments)
+	if err != nil {
+		return nil, err
+	}
+
+	// Hạn nộp riêng của lớp
+	classDue := map[uuid.UUID]*time.Time{}
+	if classID != nil {
+		for _, targets := range schedule.targets {
+			for _, t := range targets {
+				if t.ClassID == *classID && t.DueDate != nil {
+					classDue[t.AssignmentID] = t.DueDate
+				}
+			}
+		}
+	}
+
+	for _, a := range assignments {
+		due := a.DueDate
+		if d, ok := classDue[a.ID]; ok {
+			due = d
+		}
+		book.Assignments = append(book.Assignments, GradebookAssignment{
+			ID:           a.ID,
+			Title:        a.Title,
+			PodcastTitle: a.Podcast.Title,
+			CategoryID:   a.CategoryID,
+			ScorePolicy:  a.ScorePolicy,
+			DueDate:      due,
+		})
+	}
+
+	// Nhóm điểm: của cả môn + riêng của lớp
+	catQuery := db.Where("subject_id = ?", subjectID)
+	if classID != nil {
+		catQuery = catQuery.Where("(class_id IS NULL OR class_id = ?)", *classID)
+	} else {
+		catQuery = catQuery.Where("class_id IS NULL")
+	}
+	if err := catQuery.Order("sort_order ASC, created_at ASC").Find(&book.Categories).Error; err != nil {
+		return nil, err
+	}
+
+	// Sinh viên: theo lớp, hoặc mọi sinh viên của các lớp thuộc môn và người đã nộp bài
+	var users []models.User
+	if classID != nil {
+		db.Joins("JOIN class_enrollments ce ON ce.user_id = users.id").
+			Where("ce.class_id = ?", *classID).
+			Order("users.full_name ASC").
+			Find(&users)
+	} else {
+		db.Where(`users.role = ? AND (users.id IN (SELECT ce.user_id FROM class_enrollments ce JOIN classes cl ON cl.id = ce.class_id WHERE cl.subject_id = ?)
+			OR users.id IN (SELECT user_id FROM assignment_submissions WHERE assignment_id IN ? AND submitted_at IS NOT NULL))`,
+			models.RoleUser, subjectID, append(assignmentIDs, uuid.Nil)).
+			Order("users.full_name ASC").
+			Find(&users)
+	}
+
+	// Bài làm đã nộp, nhóm theo (bài tập, sinh viên)
+	submissionsByKey := map[string][]models.AssignmentSubmission{}
+	if len(assignmentIDs) > 0 {
+		var submissions []models.AssignmentSubmission
+		if err := db.Where("assignment_id IN ? AND submitted_at IS NOT NULL", assignmentIDs).
+			Order("submitted_at ASC").
+			Find(&submissions).Error; err != nil {
+			return nil, err
+		}
+		for _, s := range submissions {
+			key := s.AssignmentID.String() + ":" + s.UserID.String()
+			submissionsByKey[key] = append(submissionsByKey[key], s)
+		}
+	}
+	adjustments := latestAdjustments(db, assignmentIDs)
+
+	// Hạn nộp riêng theo ngoại lệ của từng sinh viên
+	overrideDue := map[string]time.Time{}
+	if len(assignmentIDs) > 0 {
+		var overrides []models.AssignmentOverride
+		db.Where("assignment_id IN ? AND due_date IS NOT NULL", assignmentIDs).Find(&overrides)
+		for _, o := range overrides {
+			overrideDue[o.AssignmentID.String()+":"+o.UserID.String()] = *o.DueDate
+		}
+	}
+
+	now := time.Now()
+	for _, u := range users {
+		row := GradebookRow{
+			UserID:         u.ID,
+			FullName:       u.FullName,
+			Email:          u.Email,
+			Cells:          map[string]*GradebookCell{},
+			CategoryScores: map[string]*float64{},
+		}
+
+		for _, a := range book.Assignments {
+			key := a.ID.String() + ":" + u.ID.String()
+			cell := &GradebookCell{}
+
+			if subs := submissionsByKey[key]; len(subs) > 0 {
+				score := aggregateScore(a.ScorePolicy, subs)
+				cell.SubmissionScore = &score
+				cell.Score = &score
+				cell.Attempts = len(subs)
+				for _, s := range subs {
+					if s.GradingStatus == models.GradingStatusPending {
+						cell.Pending = true
+					}
+				}
+			}
+			if adj, ok := adjustments[key]; ok && adj.Score != nil {
+				score := *adj.Score
+				cell.Score = &score
+				cell.Adjusted = true
+			}
+			assigned, due := schedule.forStudent(a.ID, u.ID)
+			if !assigned && cell.Score == nil {
+				cell.NotAssigned = true
+				row.Cells[a.ID.String()] = cell
+				continue
+			}
+			if d, ok := overrideDue[key]; ok {
+				due = &d
+			}
+			if cell.Score == nil && due != nil && now.After(*due) {
+				zero := 0.0
+				cell.Score = &zero
+				cell.Missing = true
+			}
+
+			row.Cells[a.ID.String()] = cell
+		}
+
+		computeFinalScore(book, &row)
+		book.Rows = append(book.Rows, row)
+	}
+
+	return book, nil
+}
+
+// gradebookSchedule giữ thông tin giao bài theo lớp để tính, cho từng sinh viên, bài nào được giao
+// và hạn nộp nào áp dụng — cùng quy tắc với visibleAssignmentsScope / applyClassDueDate phía sinh viên
+type gradebookSchedule struct {
+	baseDue           map[uuid.UUID]*time.Time
+	targets           map[uuid.UUID][]models.AssignmentClass // Lớp được giao của từng bài tập (kể cả lớp đã lưu trữ)
+	enrolled          map[uuid.UUID]map[uuid.UUID]bool       // user -> lớp chưa lưu trữ đang học
+	subjectMembers    map[uuid.UUID]bool                     // Sinh viên học ít nhất một lớp chưa lưu trữ của môn
+	subjectHasClasses bool                                   // Môn có lớp chưa lưu trữ nào không
+}
+
+// Khi xem sổ điểm của một lớp, lớp đó luôn được tính là đang học (để xem lại lớp đã lưu trữ)
+func loadGradebookSchedule(db *gorm.DB, subjectID uuid.UUID, classID *uuid.UUID, assignments []models.Assignment) (*gradebookSchedule, error) {
+	sch := &gradebookSchedule{
+		baseDue:        map[uuid.UUID]*time.Time{},
+		targets:        map[uuid.UUID][]models.AssignmentClass{},
+		enrolled:       map[uuid.UUID]map[uuid.UUID]bool{},
+		subjectMembers: map[uuid.UUID]bool{},
+	}
+	if len(assignments) == 0 {
+		return sch, nil
+	}
+
+	assignmentIDs := make([]uuid.UUID, 0, len(assignments))
+	for _, a := range assignments {
+		sch.baseDue[a.ID] = a.DueDate
+		assignmentIDs = append(assignmentIDs, a.ID)
+	}
+
+	var targets []models.AssignmentClass
+	if err := db.Where("assignment_id IN ?", assignmentIDs).Find(&targets).Error; err != nil {
+		return nil, err
+	}
+	targetClassIDs := []uuid.UUID{uuid.Nil}
+	for _, t := range targets {
+		sch.targets[t.AssignmentID] = append(sch.targets[t.AssignmentID], t)
+		targetClassIDs = append(targetClassIDs, t.ClassID)
+	}
+
+	viewedClass := uuid.Nil
+	if classID != nil {
+		viewedClass = *classID
+	}
+
+	var enrollments []struct {
+		UserID    uuid.UUID
+		ClassID   uuid.UUID
+		SubjectID uuid.UUID
+	}
+	if err := db.Model(&models.ClassEnrollment{}).
+		Select("class_enrollments.user_id, class_enrollments.class_id, cl.subject_id").
+		Joins("JOIN classes cl ON cl.id = class_enrollments.class_id").
+		Where("(cl.is_archived = ? AND (cl.subject_id = ? OR cl.id IN ?)) OR cl.id = ?", false, subjectID, targetClassIDs, viewedClass).
+		Scan(&enrollments).Error; err != nil {
+		return nil, err
+	}
+	for _, e := range enrollments {
+		if sch.enrolled[e.UserID] == nil {
+			sch.enrolled[e.UserID] = map[uuid.UUID]bool{}
+		}
+		sch.enrolled[e.UserID][e.ClassID] = true
+		if e.SubjectID == subjectID {
+			sch.subjectMembers[e.UserID] = true
+		}
+	}
+
+	var activeClasses int64
+	if err := db.Model(&models.Class{}).
+		Where("subject_id = ? AND is_archived = ?", subjectID, false).
+		Count(&activeClasses).Error; err != nil {
+		return nil, err
+	}
+	sch.subjectHasClasses = activeClasses > 0
+
+	return sch, nil
+}
+
+// forStudent trả về bài có được giao cho sinh viên không và hạn nộp áp dụng (hạn muộn nhất trong các lớp được giao)
+func (sch *gradebookSchedule) forStudent(assignmentID, userID uuid.UUID) (bool, *time.Time) {
+	due := sch.baseDue[assignmentID]
+	targets := sch.targets[assignmentID]
+	if len(targets) == 0 {
+		return sch.subjectMembers[userID] || !sch.subjectHasClasses, due
+	}
+
+	assigned := false
+	var classDue *time.Time
+	for _, t := range targets {
+		if !sch.enrolled[userID][t.ClassID] {
+			continue
+		}
+		assigned = true
+		if t.DueDate != nil && (classDue == nil || t.DueDate.After(*classDue)) {
+			classDue = t.DueDate
+		}
+	}
+	if classDue != nil {
+		due = classDue
+	}
+	return assigned, due
+}
+
+// computeFinalScore tính điểm từng nhóm (trung bình các bài trong nhóm) và điểm tổng kết theo trọng số.
+// Nếu môn chưa có nhóm nào có trọng số thì tổng kết = trung bình mọi bài.
+// Bài chưa phân nhóm không tính vào tổng kết khi đã có nhóm có trọng số.
+func computeFinalScore(book *Gradebook, row *GradebookRow) {
+	sums := map[string]float64{}
+	counts := map[string]int{}
+	allSum, allCount := 0.0, 0
+
+	for _, a := range book.Assignments {
+		cell := row.Cells[a.ID.String()]
+		if cell == nil || cell.Score == nil {
+			continue
+		}
+		key := uncategorizedKey
+		if a.CategoryID != nil {
+			key = a.CategoryID.String()
+		}
+		sums[key] += *cell.Score
+		counts[key]++
+		allSum += *cell.Score
+		allCount++
+	}
+
+	for key, n := range counts {
+		avg := roundPoints(sums[key] / float64(n))
+		row.CategoryScores[key] = &avg
+	}
+
+	weighted, totalWeight := 0.0, 0.0
+	for _, cat := range book.Categories {
+		if cat.Weight <= 0 {
+			continue
+		}
+		if score := row.CategoryScores[cat.ID.String()]; score != nil {
+			weighted += *score * cat.Weight
+			totalWeight += cat.Weight
+		}
+	}
+
+	switch {
+	case totalWeight > 0:
+		final := roundPoints(weighted / totalWeight)
+		row.Final = &final
+	case allCount > 0 && !hasWeightedCategory(book.Categories):
+		final := roundPoints(allSum / float64(allCount))
+		row.Final = &final
+	}
+}
+
+func hasWeightedCategory(categories []models.GradeCategory) bool {
+	for _, cat := range categories {
+		if cat.Weight > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Xem sổ điểm
+// GET /admin/gradebook?subject_id=&class_id=
+func GetGradebook(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	subjectID, classID, ok := resolveGradebookScope(c, db, c.Query("subject_id"), c.Query("class_id"))
+	if !ok {
+		return
+	}
+
+	book, err := buildGradebook(db, subjectID, classID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tổng hợp sổ điểm"})
+		return
+	}
+
+	c.JSON(http.StatusOK, book)
+}
+
+// Xuất sổ điểm ra Excel hoặc CSV
+// GET /admin/gradebook/export?subject_id=&class_id=&format=xlsx|csv
+func ExportGradebook(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	subjectID, classID, ok := resolveGradebookScope(c, db, c.Query("subject_id"), c.Query("class_id"))
+	if !ok {
+		return
+	}
+
+	book, err := buildGradebook(db, subjectID, classID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tổng hợp sổ điểm"})
+		return
+	}
+
+	var subject models.Subject
+	db.First(&subject, "id = ?", subjectID)
+	title := subject.Name
+	if classID != nil {
+		var class models.Class
+		db.First(&class, "id = ?", *classID)
+		title += " - " + class.Name
+	}
+
+	header, rows := gradebookTable(book)
+	fileBase := fmt.Sprintf("So_diem_%s_%s", strings.ReplaceAll(title, " ", "_"), time.Now().Format("20060102_150405"))
+
+	if c.DefaultQuery("format", "xlsx") == "csv" {
+		var buf bytes.Buffer
+		buf.WriteString("\uFEFF") // BOM để Excel đọc đúng tiếng Việt
+		w := csv.NewWriter(&buf)
+		w.Write(header)
+		w.WriteAll(rows)
+
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.csv", fileBase))
+		c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+		return
+	}
+
+	content, err := buildGradebookXLSX(title, book, header, rows)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tạo file Excel"})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.xlsx", fileBase))
+	c.Header("Content-Transfer-Encoding", "binary")
+	c.Data(http.StatusOK, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", content)
+}
+
+// gradebookTable chuyển sổ điểm thành bảng: STT, họ tên, email, từng bài, từng nhóm, tổng kết
+func gradebookTable(book *Gradebook) ([]string, [][]string) {
+	header := []string{"STT", "Họ và tên", "Email"}
+	for _, a := range book.Assignments {
+		header = append(header, a.Title)
+	}
+	for _, cat := range book.Categories {
+		header = append(header, fmt.Sprintf("%s (%.0f%%)", cat.Name, cat.Weight))
+	}
+	header = append(header, "Tổng kết")
+
+	formatScore := func(v *float64) string {
+		if v == nil {
+			return ""
+		}
+		return fmt.Sprintf("%.2f", *v)
+	}
+
+	rows := make([][]string, 0, len(book.Rows))
+	for i, r := range book.Rows {
+		line := []string{fmt.Sprintf("%d", i+1), r.FullName, r.Email}
+		for _, a := range book.Assignments {
+			cell := r.Cells[a.ID.String()]
+			value := formatScore(cell.Score)
+			switch {
+			case cell.NotAssigned:
+				value = "-"
+			case cell.Missing:
+				value = "0 (không nộp)"
+			case cell.Pending && value != "":
+				value += " (chờ chấm)"
+			}
+			line = append(line, value)
+		}
+		for _, cat := range book.Categories {
+			line = append(line, formatScore(r.CategoryScores[cat.ID.String()]))
+		}
+		line = append(line, formatScore(r.Final))
+		rows = append(rows, line)
+	}
+	return header, rows
+}
+
+func buildGradebookXLSX(title string, book *Gradebook, header []string, rows [][]string) ([]byte, error) {
+	f := excelize.NewFile()
+	sheetName := "Sổ điểm"
+	index, _ := f.NewSheet(sheetName)
+	f.SetActiveSheet(index)
+	f.DeleteSheet("Sheet1")
+
+	border := []excelize.Border{
+		{Type: "left", Color: "000000", Style: 1},
+		{Type: "top", Color: "000000", Style: 1},
+		{Type: "bottom", Color: "000000", Style: 1},
+		{Type: "right", Color: "000000", Style: 1},
+	}
+	headerStyle, _ := f.NewStyle(&excelize.Style{
+		Font:      &excelize.Font{Bold: true, Color: "FFFFFF"},
+		Fill:      excelize.Fill{Type: "pattern", Color: []string{"4472C4"}, Pattern: 1},
+		Alignment: &excelize.Alignment{Horizontal: "center", Vertical: "center", WrapText: true},
+		Border:    border,
+	})
+	dataStyle, _ := f.NewStyle(&excelize.Style{
+		Border:    border,
+		Alignment: &excelize.Alignment{Horizontal: "center", Vertical: "center"},
+	})
+	adjustedStyle, _ := f.NewStyle(&excelize.Style{
+		Border:    border,
+		Fill:      excelize.Fill{Type: "pattern", Color: []string{"FFF2CC"}, Pattern: 1},
+		Alignment: &excelize.Alignment{Horizontal: "center", Vertical: "center"},
+	})
+	missingStyle, _ := f.NewStyle(&excelize.Style{
+		Border:    border,
+		Font:      &excelize.Font{Color: "9C0006"},
+		Fill:      excelize.Fill{Type: "pattern", Color: []string{"FFC7CE"}, Pattern: 1},
+		Alignment: &excelize.Alignment{Horizontal: "center", Vertical: "center"},
+	})
+	finalStyle, _ := f.NewStyle(&excelize.Style{
+		Border:    border,
+		Font:      &excelize.Font{Bold: true},
+		Alignment: &excelize.Alignment{Horizontal: "center", Vertical: "center"},
+	})
+	titleStyle, _ := f.NewStyle(&excelize.Style{
+		Font:      &excelize.Font{Bold: true, Size: 16},
+		Alignment: &excelize.Alignment{Horizontal: "center", Vertical: "center"},
+	})
+
+	lastCol, _ := excelize.ColumnNumberToName(len(header))
+	f.MergeCell(sheetName, "A1", lastCol+"1")
+	f.SetCellValue(sheetName, "A1", "SỔ ĐIỂM: "+title)
+	f.SetCellStyle(sheetName, "A1", lastCol+"1", titleStyle)
+	f.SetRowHeight(sheetName, 1, 30)
+	f.SetCellValue(sheetName, "A2", fmt.Sprintf("Xuất lúc %s | Ô vàng: đã điều chỉnh thủ công | Ô đỏ: không nộp", time.Now().Format("02/01/2006 15:04")))
+
+	for i, h := range header {
+		cell, _ := excelize.CoordinatesToCellName(i+1, 4)
+		f.SetCellValue(sheetName, cell, h)
+		f.SetCellStyle(sheetName, cell, cell, headerStyle)
+	}
+	f.SetRowHeight(sheetName, 4, 45)
+
+	f.SetColWidth(sheetName, "A", "A", 6)
+	f.SetColWidth(sheetName, "B", "B", 25)
+	f.SetColWidth(sheetName, "C", "C", 30)
+	if len(header) > 3 {
+		firstScoreCol, _ := excelize.ColumnNumberToName(4)
+		f.SetColWidth(sheetName, firstScoreCol, lastCol, 16)
+	}
+
+	for r, line := range rows {
+		rowNum := r + 5
+		bookRow := book.Rows[r]
+		for col, value := range line {
+			cell, _ := excelize.CoordinatesToCellName(col+1, rowNum)
+			style := dataStyle
+
+			assignmentIdx := col - 3
+			switch {
+			case assignmentIdx >= 0 && assignmentIdx < len(book.Assignments):
+				gc := bookRow.Cells[book.Assignments[assignmentIdx].ID.String()]
+				if gc.Missing {
+					style = missingStyle
+				} else if gc.Adjusted {
+					style = adjustedStyle
+				}
+				// Ghi số để Excel tính toán được
+				if gc.Score != nil && !gc.Missing && !gc.Pending {
+					f.SetCellValue(sheetName, cell, *gc.Score)
+					f.SetCellStyle(sheetName, cell, cell, style)
+					continue
+				}
+			case col == len(line)-1:
+				style = finalStyle
+			}
+
+			f.SetCellValue(sheetName, cell, value)
+			f.SetCellStyle(sheetName, cell, cell, style)
+		}
+	}
+	f.SetPanes(sheetName, &excelize.Panes{Freeze: true, XSplit: 3, YSplit: 4, TopLeftCell: "D5", ActivePane: "bottomRight"})
+
+	buffer, err := f.WriteToBuffer()
+	if err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// ==================== NHÓM ĐIỂM ====================
+
+// Danh sách nhóm điểm
+// GET /admin/gradebook/categories?subject_id=&class_id=
+func GetGradeCategories(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	subjectID, classID, ok := resolveGradebookScope(c, db, c.Query("subject_id"), c.Query("class_id"))
+	if !ok {
+		return
+	}
+
+	query := db.Where("subject_id = ?", subjectID)
+	if classID != nil {
+		query = query.Where("(class_id IS NULL OR class_id = ?)", *classID)
+	} else {
+		query = query.Where("class_id IS NULL")
+	}
+
+	var categories []models.GradeCategory
+	if err := query.Order("sort_order ASC, created_at ASC").Find(&categories).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy nhóm điểm"})
+		return
+	}
+
+	totalWeight := 0.0
+	for _, cat := range categories {
+		totalWeight += cat.Weight
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"categories":   categories,
+		"total_weight": totalWeight,
+	})
+}
+
+type gradeCategoryRequest struct {
+	SubjectID string   `json:"subject_id"`
+	ClassID   string   `json:"class_id"`
+	Name      string   `json:"name"`
+	Weight    *float64 `json:"weight"`
+	SortOrder *int     `json:"sort_order"`
+}
+
+// Tạo nhóm điểm
+// POST /admin/gradebook/categories
+func CreateGradeCategory(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	var req gradeCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	subjectID, classID, ok := resolveGradebookScope(c, db, req.SubjectID, req.ClassID)
+	if !ok {
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tên nhóm điểm không được để trống"})
+		return
+	}
+	weight := 0.0
+	if req.Weight != nil {
+		weight = *req.Weight
+	}
+	if weight < 0 || weight > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Trọng số phải từ 0 đến 100"})
+		return
+	}
+
+	userUUID, _ := uuid.Parse(c.GetString("user_id"))
+	category := models.GradeCategory{
+		SubjectID: subjectID,
+		ClassID:   classID,
+		Name:      req.Name,
+		Weight:    weight,
+		CreatedBy: userUUID,
+	}
+	if req.SortOrder != nil {
+		category.SortOrder = *req.SortOrder
+	}
+
+	if err := db.Create(&category).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tạo nhóm điểm"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Tạo nhóm điểm thành công",
+		"category": category,
+	})
+}
+
+func loadOwnedGradeCategory(c *gin.Context, db *gorm.DB) (*models.GradeCategory, bool) {
+	var category models.GradeCategory
+	if err := db.First(&category, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy nhóm điểm"})
+		return nil, false
+	}
+
+	classID := ""
+	if category.ClassID != nil {
+		classID = category.ClassID.String()
+	}
+	if _, _, ok := resolveGradebookScope(c, db, category.SubjectID.String(), classID); !ok {
+		return nil, false
+	}
+	return &category, true
+}
+
+// Cập nhật nhóm điểm
+// PUT /admin/gradebook/categories/:id
+func UpdateGradeCategory(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	category, ok := loadOwnedGradeCategory(c, db)
+	if !ok {
+		return
+	}
+
+	var req gradeCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if name := strings.TrimSpace(req.Name); name != "" {
+		updates["name"] = name
+	}
+	if req.Weight != nil {
+		if *req.Weight < 0 || *req.Weight > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Trọng số phải từ 0 đến 100"})
+			return
+		}
+		updates["weight"] = *req.Weight
+	}
+	if req.SortOrder != nil {
+		updates["sort_order"] = *req.SortOrder
+	}
+
+	if len(updates) > 0 {
+		if err := db.Model(category).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể cập nhật nhóm điểm"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Cập nhật nhóm điểm thành công",
+		"category": category,
+	})
+}
+
+// Xóa nhóm điểm (bài tập thuộc nhóm trở thành chưa phân nhóm)
+// DELETE /admin/gradebook/categories/:id
+func DeleteGradeCategory(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	category, ok := loadOwnedGradeCategory(c, db)
+	if !ok {
+		return
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Assignment{}).Where("category_id = ?", category.ID).
+			Update("category_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(category).Error
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể xóa nhóm điểm"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Đã xóa nhóm điểm"})
+}
+
+// ==================== ĐIỀU CHỈNH ĐIỂM ====================
+
+// currentGradebookScore: điểm hiệu lực hiện tại của sinh viên ở một bài tập (trước khi điều chỉnh mới)
+func currentGradebookScore(db *gorm.DB, assignment models.Assignment, userID uuid.UUID) *float64 {
+	adjustments := latestAdjustments(db, []uuid.UUID{assignment.ID})
+	if adj, ok := adjustments[assignment.ID.String()+":"+userID.String()]; ok && adj.Score != nil {
+		return adj.Score
+	}
+
+	var submissions []models.AssignmentSubmission
+	db.Where("assignment_id = ? AND user_id = ? AND submitted_at IS NOT NULL", assignment.ID, userID).
+		Order("submitted_at ASC").
+		Find(&submissions)
+	if len(submissions) == 0 {
+		return nil
+	}
+	score := aggregateScore(assignment.ScorePolicy, submissions)
+	return &score
+}
+
+// Điều chỉnh điểm thủ công (bắt buộc ghi lý do). score = null để hủy điều chỉnh.
+// POST /admin/gradebook/adjustments  {assignment_id, user_id, score, reason}
+func CreateGradeAdjustment(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	var req struct {
+		AssignmentID uuid.UUID `json:"assignment_id"`
+		UserID       uuid.UUID `json:"user_id"`
+		Score        *float64  `json:"score"`
+		Reason       string    `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Vui lòng nhập lý do điều chỉnh"})
+		return
+	}
+	if req.Score != nil && (*req.Score < 0 || *req.Score > 10) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Điểm phải từ 0 đến 10"})
+		return
+	}
+
+	var assignment models.Assignment
+	if err := db.First(&assignment, "id = ?", req.AssignmentID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy bài tập"})
+		return
+	}
+	if !canManageAssignment(c, db, assignment, models.PermAssignmentGrade) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Bạn không có quyền điều chỉnh điểm bài tập này"})
+		return
+	}
+
+	var student models.User
+	if err := db.First(&student, "id = ?", req.UserID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy sinh viên"})
+		return
+	}
+
+	adjusterUUID, _ := uuid.Parse(c.GetString("user_id"))
+	adjustment := models.GradeAdjustment{
+		AssignmentID:  assignment.ID,
+		UserID:        student.ID,
+		Score:         req.Score,
+		PreviousScore: currentGradebookScore(db, assignment, student.ID),
+		Reason:        req.Reason,
+		AdjustedBy:    adjusterUUID,
+	}
+	if err := db.Create(&adjustment).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lưu điều chỉnh"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Đã điều chỉnh điểm",
+		"adjustment": adjustment,
+	})
+}
+
+// Lịch sử điều chỉnh điểm
+// GET /admin/gradebook/adjustments?assignment_id=&user_id=
+func GetGradeAdjustments(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	assignmentID, err := uuid.Parse(c.Query("assignment_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "assignment_id không hợp lệ"})
+		return
+	}
+
+	var assignment models.Assignment
+	if err := db.First(&assignment, "id = ?", assignmentID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy bài tập"})
+		return
+	}
+	if !canManageAssignment(c, db, assignment, models.PermAssignmentGrade) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Bạn không có quyền xem bài tập này"})
+		return
+	}
+
+	query := db.Preload("Adjuster").Where("assignment_id = ?", assignmentID)
+	if userID := c.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var adjustments []models.GradeAdjustment
+	if err := query.Order("created_at DESC").Find(&adjustments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy lịch sử điều chỉnh"})
+		return
+	}
+	for i := range adjustments {
+		adjustments[i].Adjuster.Password = ""
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"adjustments": adjustments,
+		"total":       len(adjustments),
+	})
+}
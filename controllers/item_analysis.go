@@ -0,0 +1,470 @@
+package controllers
+
+import (
+	"math"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/vnkhanh/e-podcast-backend/models"
+	"gorm.io/gorm"
+)
+
+// ==================== PHÂN TÍCH CÂU HỎI ====================
+// Độ khó p = tỉ lệ điểm trung bình đạt được của câu (1 = ai cũng đúng).
+// Độ phân biệt D = p(nhóm 27% điểm cao) - p(nhóm 27% điểm thấp).
+
+const (
+	itemGroupRatio     = 0.27 // Tỉ lệ nhóm cao / thấp theo Kelley
+	itemMinSample      = 10   // Dưới số bài này kết quả chỉ mang tính tham khảo
+	itemTooEasy        = 0.9
+	itemTooHard        = 0.2
+	itemLowDiscrim     = 0.2
+	itemDistractorPull = 0.25 // Phương án sai được >= 25% nhóm cao chọn
+)
+
+// Cờ cảnh báo cho từng câu
+const (
+	ItemFlagTooEasy           = "too_easy"
+	ItemFlagTooHard           = "too_hard"
+	ItemFlagLowDiscrimination = "low_discrimination"
+	ItemFlagNegative          = "negative_discrimination" // Nhóm thấp làm tốt hơn nhóm cao
+	ItemFlagPossibleMiskey    = "possible_miskey"         // Nhóm cao chọn một phương án sai nhiều hơn đáp án đúng
+	ItemFlagStrongDistractor  = "strong_distractor"       // Phương án sai thu hút nhóm cao hơn nhóm thấp
+)
+
+// ItemOptionStat thống kê một phương án trả lời
+type ItemOptionStat struct {
+	OptionID   uuid.UUID `json:"option_id"`
+	OptionText string    `json:"option_text"`
+	IsCorrect  bool      `json:"is_correct"`
+	Count      int       `json:"count"`
+	Percent    float64   `json:"percent"`
+	UpperCount int       `json:"upper_count"`
+	LowerCount int       `json:"lower_count"`
+}
+
+// ItemStat là kết quả phân tích một câu hỏi
+type ItemStat struct {
+	QuestionID     uuid.UUID        `json:"question_id"`
+	Question       string           `json:"question"`
+	Type           string           `json:"type"`
+	Difficulty     string           `json:"difficulty"` // Độ khó do giảng viên gán
+	Responses      int              `json:"responses"`
+	Blank          int              `json:"blank"`
+	DifficultyP    float64          `json:"difficulty_index"`
+	Discrimination *float64         `json:"discrimination_index"` // nil khi không đủ bài để chia nhóm
+	AvgTimeSec     *float64         `json:"avg_time_sec"`         // nil khi client chưa gửi thời gian
+	Options        []ItemOptionStat `json:"options,omitempty"`
+	Flags          []string         `json:"flags"`
+}
+
+// ItemAnalysis là báo cáo cho cả bài
+type ItemAnalysis struct {
+	Attempts   int            `json:"attempts"`
+	GroupSize  int            `json:"group_size"` // Số bài trong mỗi nhóm cao / thấp
+	MeanScore  float64        `json:"mean_score"`
+	LowSample  bool           `json:"low_sample"`
+	Items      []ItemStat     `json:"items"`
+	FlagCounts map[string]int `json:"flag_counts"`
+}
+
+// itemDef là câu hỏi cần phân tích
+type itemDef struct {
+	ID         uuid.UUID
+	Question   string
+	Type       string
+	Difficulty string
+	Options    []itemOptionDef
+}
+
+type itemOptionDef struct {
+	ID        uuid.UUID
+	Text      string
+	IsCorrect bool
+}
+
+// itemResponse là câu trả lời của một lần làm bài cho một câu
+type itemResponse struct {
+	AttemptID uuid.UUID
+	Ratio     float64 // Điểm đạt / điểm tối đa của câu, 0..1
+	Selected  []uuid.UUID
+	Blank     bool
+	TimeSec   int
+}
+
+// analyzeItems tính chỉ số cho mọi câu từ tổng điểm từng lần làm và câu trả lời theo câu
+func analyzeItems(totals map[uuid.UUID]float64, items []itemDef, responses map[uuid.UUID][]itemResponse) ItemAnalysis {
+	report := ItemAnalysis{
+		Attempts:   len(totals),
+		LowSample:  len(totals) < itemMinSample,
+		Items:      []ItemStat{},
+		FlagCounts: map[string]int{},
+	}
+
+	// Xếp hạng lần làm theo tổng điểm để chia nhóm cao / thấp
+	ranked := make([]uuid.UUID, 0, len(totals))
+	sum := 0.0
+	for id, score := range totals {
+		ranked = append(ranked, id)
+		sum += score
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if totals[ranked[i]] != totals[ranked[j]] {
+			return totals[ranked[i]] > totals[ranked[j]]
+		}
+		return ranked[i].String() < ranked[j].String()
+	})
+	if len(ranked) > 0 {
+		report.MeanScore = roundPoints(sum / float64(len(ranked)))
+	}
+
+	upper, lower := map[uuid.UUID]bool{}, map[uuid.UUID]bool{}
+	if len(ranked) >= 2 {
+		k := int(math.Round(float64(len(ranked)) * itemGroupRatio))
+		if k < 1 {
+			k = 1
+		}
+		if k > len(ranked)/2 {
+			k = len(ranked) / 2
+		}
+		report.GroupSize = k
+		for _, id := range ranked[:k] {
+			upper[id] = true
+		}
+		for _, id := range ranked[len(ranked)-k:] {
+			lower[id] = true
+		}
+	}
+
+	for _, item := range items {
+		stat := ItemStat{
+			QuestionID: item.ID,
+			Question:   item.Question,
+			Type:       item.Type,
+			Difficulty: item.Difficulty,
+			Flags:      []string{},
+		}
+
+		rs := responses[item.ID]
+		stat.Responses = len(rs)
+		if len(rs) == 0 {
+			report.Items = append(report.Items, stat)
+			continue
+		}
+
+		ratioSum, upperSum, lowerSum := 0.0, 0.0, 0.0
+		upperN, lowerN := 0, 0
+		timeSum, timeN := 0, 0
+		optionCounts := map[uuid.UUID]*ItemOptionStat{}
+		for _, o := range item.Options {
+			optionCounts[o.ID] = &ItemOptionStat{OptionID: o.ID, OptionText: o.Text, IsCorrect: o.IsCorrect}
+		}
+
+		for _, r := range rs {
+			ratioSum += r.Ratio
+			if r.Blank {
+				stat.Blank++
+			}
+			if r.TimeSec > 0 {
+				timeSum += r.TimeSec
+				timeN++
+			}
+			if upper[r.AttemptID] {
+				upperSum += r.Ratio
+				upperN++
+			}
+			if lower[r.AttemptID] {
+				lowerSum += r.Ratio
+				lowerN++
+			}
+			for _, sel := range r.Selected {
+				if o, ok := optionCounts[sel]; ok {
+					o.Count++
+					if upper[r.AttemptID] {
+						o.UpperCount++
+					}
+					if lower[r.AttemptID] {
+						o.LowerCount++
+					}
+				}
+			}
+		}
+
+		stat.DifficultyP = math.Round(ratioSum/float64(len(rs))*1000) / 1000
+		if upperN > 0 && lowerN > 0 {
+			d := math.Round((upperSum/float64(upperN)-lowerSum/float64(lowerN))*1000) / 1000
+			stat.Discrimination = &d
+		}
+		if timeN > 0 {
+			avg := math.Round(float64(timeSum)/float64(timeN)*10) / 10
+			stat.AvgTimeSec = &avg
+		}
+
+		// Phân tích phương án nhiễu (chỉ câu có lựa chọn)
+		bestCorrectUpper := -1
+		for _, o := range item.Options {
+			if o.IsCorrect && optionCounts[o.ID].UpperCount > bestCorrectUpper {
+				bestCorrectUpper = optionCounts[o.ID].UpperCount
+			}
+		}
+		miskey, strongDistractor := false, false
+		for _, o := range item.Options {
+			opt := optionCounts[o.ID]
+			opt.Percent = math.Round(float64(opt.Count)/float64(len(rs))*1000) / 10
+			stat.Options = append(stat.Options, *opt)
+
+			if o.IsCorrect || upperN == 0 {
+				continue
+			}
+			if opt.UpperCount > bestCorrectUpper {
+				miskey = true
+			}
+			if opt.UpperCount > opt.LowerCount && float64(opt.UpperCount) >= itemDistractorPull*float64(upperN) {
+				strongDistractor = true
+			}
+		}
+
+		if stat.DifficultyP > itemTooEasy {
+			stat.Flags = append(stat.Flags, ItemFlagTooEasy)
+		}
+		if stat.DifficultyP < itemTooHard {
+			stat.Flags = append(stat.Flags, ItemFlagTooHard)
+		}
+		if stat.Discrimination != nil {
+			if *stat.Discrimination < 0 {
+				stat.Flags = append(stat.Flags, ItemFlagNegative)
+			} else if *stat.Discrimination < itemLowDiscrim {
+				stat.Flags = append(stat.Flags, ItemFlagLowDiscrimination)
+			}
+		}
+		if miskey {
+			stat.Flags = append(stat.Flags, ItemFlagPossibleMiskey)
+		}
+		if strongDistractor {
+			stat.Flags = append(stat.Flags, ItemFlagStrongDistractor)
+		}
+		for _, f := range stat.Flags {
+			report.FlagCounts[f]++
+		}
+
+		report.Items = append(report.Items, stat)
+	}
+
+	return report
+}
+
+// isChoiceQuestion: câu có phương án để phân tích nhiễu
+func isChoiceQuestion(questionType string) bool {
+	switch questionType {
+	case models.QuestionTypeSingleChoice, models.QuestionTypeMultipleChoice, models.QuestionTypeTrueFalse:
+		return true
+	}
+	return false
+}
+
+// Phân tích câu hỏi của bài tập
+// GET /admin/assignments/:id/item-analysis?attempt=first|last|all
+func GetAssignmentItemAnalysis(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	var assignment models.Assignment
+	if err := db.First(&assignment, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy bài tập"})
+		return
+	}
+	if !canManageAssignment(c, db, assignment, models.PermAssignmentGrade) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Bạn không có quyền xem bài tập này"})
+		return
+	}
+
+	var submissions []models.AssignmentSubmission
+	if err := db.Where("assignment_id = ? AND submitted_at IS NOT NULL", assignment.ID).
+		Order("submitted_at ASC").
+		Find(&submissions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy bài làm"})
+		return
+	}
+
+	// Mặc định chỉ lấy lần làm đầu của mỗi sinh viên để không bị lệch do làm lại
+	mode := c.DefaultQuery("attempt", "first")
+	picked := map[uuid.UUID]models.AssignmentSubmission{}
+	totals := map[uuid.UUID]float64{}
+	for _, s := range submissions {
+		switch mode {
+		case "all":
+			totals[s.ID] = s.Score
+			continue
+		case "last":
+			picked[s.UserID] = s
+		default:
+			if _, ok := picked[s.UserID]; !ok {
+				picked[s.UserID] = s
+			}
+		}
+	}
+	for _, s := range picked {
+		totals[s.ID] = s.Score
+	}
+
+	// Gồm cả câu rút từ ngân hàng vì chúng có trong đề của từng sinh viên
+	var questions []models.AssignmentQuestion
+	if err := db.Preload("Options", func(db *gorm.DB) *gorm.DB {
+		return db.Order("sort_order ASC")
+	}).Where("assignment_id = ?", assignment.ID).
+		Order("pooled ASC, sort_order ASC").
+		Find(&questions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy câu hỏi"})
+		return
+	}
+
+	items := make([]itemDef, 0, len(questions))
+	questionPoints := map[uuid.UUID]float64{}
+	for _, q := range questions {
+		def := itemDef{ID: q.ID, Question: q.Question, Type: q.Type, Difficulty: q.Difficulty}
+		if isChoiceQuestion(q.Type) {
+			for _, o := range q.Options {
+				def.Options = append(def.Options, itemOptionDef{ID: o.ID, Text: o.OptionText, IsCorrect: o.IsCorrect})
+			}
+		}
+		items = append(items, def)
+		questionPoints[q.ID] = q.Points
+	}
+
+	responses := map[uuid.UUID][]itemResponse{}
+	if len(totals) > 0 {
+		submissionIDs := make([]uuid.UUID, 0, len(totals))
+		for id := range totals {
+			submissionIDs = append(submissionIDs, id)
+		}
+
+		var answers []models.AssignmentAnswer
+		if err := db.Where("submission_id IN ?", submissionIDs).Find(&answers).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy câu trả lời"})
+			return
+		}
+
+		for _, a := range answers {
+			points, ok := questionPoints[a.QuestionID]
+			if !ok {
+				continue
+			}
+			r := itemResponse{AttemptID: a.SubmissionID, TimeSec: a.TimeSpentSec}
+			if points > 0 {
+				r.Ratio = math.Min(a.PointsEarned/points, 1)
+			} else if a.IsCorrect {
+				r.Ratio = 1
+			}
+			if a.SelectedID != uuid.Nil {
+				r.Selected = append(r.Selected, a.SelectedID)
+			}
+			r.Selected = append(r.Selected, a.SelectedIDs...)
+			r.Blank = len(r.Selected) == 0 && a.TextAnswer == "" && len(a.Pairs) == 0
+			responses[a.QuestionID] = append(responses[a.QuestionID], r)
+		}
+	}
+
+	report := analyzeItems(totals, items, responses)
+	c.JSON(http.StatusOK, gin.H{
+		"assignment_id": assignment.ID,
+		"title":         assignment.Title,
+		"attempt_mode":  mode,
+		"analysis":      report,
+	})
+}
+
+// Phân tích câu hỏi của bộ quiz
+// GET /admin/quiz-sets/:id/item-analysis?attempt=first|last|all
+func GetQuizSetItemAnalysis(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	var quizSet models.QuizSet
+	if err := db.Preload("Podcast").First(&quizSet, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy quiz set"})
+		return
+	}
+	// Người tạo quiz, hoặc giảng viên có quyền xem kết quả trên môn học chứa podcast
+	if !canManageQuizSet(c, db, quizSet, models.PermAssignmentGrade) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Bạn không có quyền xem quiz này"})
+		return
+	}
+
+	var attempts []models.QuizAttempt
+	if err := db.Where("quiz_set_id = ?", quizSet.ID).
+		Order("taken_at ASC").
+		Find(&attempts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy lượt làm quiz"})
+		return
+	}
+
+	mode := c.DefaultQuery("attempt", "first")
+	picked := map[uuid.UUID]models.QuizAttempt{}
+	totals := map[uuid.UUID]float64{}
+	for _, a := range attempts {
+		switch mode {
+		case "all":
+			totals[a.ID] = a.Score
+			continue
+		case "last":
+			picked[a.UserID] = a
+		default:
+			if _, ok := picked[a.UserID]; !ok {
+				picked[a.UserID] = a
+			}
+		}
+	}
+	for _, a := range picked {
+		totals[a.ID] = a.Score
+	}
+
+	var questions []models.QuizQuestion
+	if err := db.Preload("Options").Where("quiz_set_id = ?", quizSet.ID).
+		Order("created_at ASC").
+		Find(&questions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy câu hỏi"})
+		return
+	}
+
+	items := make([]itemDef, 0, len(questions))
+	for _, q := range questions {
+		def := itemDef{ID: q.ID, Question: q.Question, Type: models.QuestionTypeSingleChoice, Difficulty: q.Difficulty}
+		for _, o := range q.Options {
+			def.Options = append(def.Options, itemOptionDef{ID: o.ID, Text: o.OptionText, IsCorrect: o.IsCorrect})
+		}
+		items = append(items, def)
+	}
+
+	responses := map[uuid.UUID][]itemResponse{}
+	if len(totals) > 0 {
+		attemptIDs := make([]uuid.UUID, 0, len(totals))
+		for id := range totals {
+			attemptIDs = append(attemptIDs, id)
+		}
+
+		var histories []models.QuizAttemptHistory
+		if err := db.Where("attempt_id IN ?", attemptIDs).Find(&histories).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy lịch sử làm quiz"})
+			return
+		}
+
+		for _, h := range histories {
+			r := itemResponse{AttemptID: h.AttemptID, TimeSec: h.TimeSpentSec, Blank: h.SelectedID == uuid.Nil}
+			if h.IsCorrect {
+				r.Ratio = 1
+			}
+			if !r.Blank {
+				r.Selected = []uuid.UUID{h.SelectedID}
+			}
+			responses[h.QuestionID] = append(responses[h.QuestionID], r)
+		}
+	}
+
+	report := analyzeItems(totals, items, responses)
+	c.JSON(http.StatusOK, gin.H{
+		"quiz_set_id":  quizSet.ID,
+		"title":        quizSet.Title,
+		"attempt_mode": mode,
+		"analysis":     report,
+	})
+}
@@ -0,0 +1,650 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/vnkhanh/e-podcast-backend/models"
+	"gorm.io/gorm"
+)
+
+// ==================== DASHBOARD GIẢNG VIÊN (THEO MÔN MÌNH DẠY) ====================
+
+// taughtSubjectIDs là subquery id các môn giảng viên dạy: sở hữu, được cấp vai trò, hoặc phụ trách lớp của môn
+func taughtSubjectIDs(db *gorm.DB, userID string) *gorm.DB {
+	return db.Model(&models.Subject{}).Select("subjects.id").
+		Where("subjects.id IN (?) OR subjects.id IN (?)", memberSubjectIDs(db, userID),
+			db.Model(&models.Class{}).Select("subject_id").Where("lecturer_id = ?", userID))
+}
+
+// canViewSubjectStats: admin xem mọi môn, giảng viên chỉ xem môn mình dạy
+func canViewSubjectStats(c *gin.Context, db *gorm.DB, subjectID uuid.UUID) bool {
+	if models.UserRole(c.GetString("role")) == models.RoleAdmin {
+		return true
+	}
+	var count int64
+	db.Model(&models.Subject{}).
+		Where("id = ? AND id IN (?)", subjectID, taughtSubjectIDs(db, c.GetString("user_id"))).
+		Count(&count)
+	return count > 0
+}
+
+// loadTaughtSubject đọc :subject_id, kiểm tra quyền xem thống kê và lớp lọc (?class_id=) nếu có
+func loadTaughtSubject(c *gin.Context, db *gorm.DB) (*models.Subject, *uuid.UUID, bool) {
+	subjectID, err := uuid.Parse(c.Param("subject_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subject_id"})
+		return nil, nil, false
+	}
+	var subject models.Subject
+	if err := db.First(&subject, "id = ?", subjectID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy môn học"})
+		return nil, nil, false
+	}
+	if !canViewSubjectStats(c, db, subjectID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Bạn không có quyền xem thống kê môn học này"})
+		return nil, nil, false
+	}
+
+	if c.Query("class_id") == "" {
+		return &subject, nil, true
+	}
+	classID, err := uuid.Parse(c.Query("class_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID lớp không hợp lệ"})
+		return nil, nil, false
+	}
+	var count int64
+	db.Model(&models.Class{}).Where("id = ? AND subject_id = ?", classID, subjectID).Count(&count)
+	if count == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Lớp không thuộc môn học này"})
+		return nil, nil, false
+	}
+	return &subject, &classID, true
+}
+
+// subjectStudentIDs là subquery id sinh viên ghi danh vào các lớp của môn (hoặc một lớp)
+func subjectStudentIDs(db *gorm.DB, subjectID uuid.UUID, classID *uuid.UUID) *gorm.DB {
+	q := db.Model(&models.ClassEnrollment{}).Select("class_enrollments.user_id").
+		Joins("JOIN classes ON classes.id = class_enrollments.class_id").
+		Where("classes.subject_id = ?", subjectID)
+	if classID != nil {
+		q = q.Where("classes.id = ?", *classID)
+	}
+	return q
+}
+
+// wantsCSV: mọi biểu đồ đều xuất được dữ liệu ra CSV với ?format=csv
+func wantsCSV(c *gin.Context) bool {
+	return c.Query("format") == "csv"
+}
+
+// respondCSV trả dữ liệu biểu đồ dưới dạng file CSV
+func respondCSV(c *gin.Context, fileBase string, header []string, rows [][]string) {
+	var buf bytes.Buffer
+	buf.WriteString("\uFEFF") // BOM để Excel đọc đúng tiếng Việt
+	w := csv.NewWriter(&buf)
+	w.Write(header)
+	w.WriteAll(rows)
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s_%s.csv", fileBase, time.Now().Format("20060102_150405")))
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+}
+
+func formatRate(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+func rateOf(part, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return roundPoints(float64(part) * 100 / float64(total))
+}
+
+// ===== DANH SÁCH MÔN ĐANG DẠY =====
+
+type TaughtSubject struct {
+	ID         uuid.UUID `json:"id"`
+	Name       string    `json:"name"`
+	CourseCode string    `json:"course_code"`
+	Classes    int64     `json:"classes"`
+	Students   int64     `json:"students"`
+	Chapters   int64     `json:"chapters"`
+	Podcasts   int64     `json:"podcasts"`
+}
+
+// Các môn giảng viên đang dạy (admin: mọi môn) kèm số lớp, sinh viên, chương, podcast
+// GET /admin/stats/subjects
+func GetTaughtSubjects(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	query := db.Table("subjects s").Select(`s.id, s.name, s.course_code,
+		(SELECT COUNT(*) FROM classes cl WHERE cl.subject_id = s.id AND NOT cl.is_archived) AS classes,
+		(SELECT COUNT(DISTINCT ce.user_id) FROM class_enrollments ce
+			JOIN classes cl ON cl.id = ce.class_id WHERE cl.subject_id = s.id) AS students,
+		(SELECT COUNT(*) FROM chapters ch WHERE ch.subject_id = s.id) AS chapters,
+		(SELECT COUNT(*) FROM podcasts p JOIN chapters ch ON ch.id = p.chapter_id
+			WHERE ch.subject_id = s.id AND p.status = 'published') AS podcasts`)
+	if models.UserRole(c.GetString("role")) != models.RoleAdmin {
+		query = query.Where("s.id IN (?)", taughtSubjectIDs(db, c.GetString("user_id")))
+	}
+
+	var out []TaughtSubject
+	if err := query.Order("s.name").Scan(&out).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy danh sách môn học"})
+		return
+	}
+
+	if wantsCSV(c) {
+		rows := make([][]string, 0, len(out))
+		for _, s := range out {
+			rows = append(rows, []string{s.Name, s.CourseCode,
+				strconv.FormatInt(s.Classes, 10), strconv.FormatInt(s.Students, 10),
+				strconv.FormatInt(s.Chapters, 10), strconv.FormatInt(s.Podcasts, 10)})
+		}
+		respondCSV(c, "Mon_hoc", []string{"Môn học", "Mã môn", "Số lớp", "Sinh viên", "Số chương", "Số podcast"}, rows)
+		return
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// ===== NGHE & HOÀN THÀNH THEO CHƯƠNG =====
+
+type ChapterStat struct {
+	ChapterID         uuid.UUID `json:"chapter_id"`
+	Title             string    `json:"title"`
+	SortOrder         int       `json:"sort_order"`
+	Podcasts          int64     `json:"podcasts"`
+	Plays             int64     `json:"plays"`              // Lượt phát trong khoảng from-to
+	ListenedSeconds   int64     `json:"listened_seconds"`   // Thời gian nghe trong khoảng from-to
+	Listeners         int64     `json:"listeners"`          // Người đã nghe ít nhất một podcast của chương
+	StudentsStarted   int64     `json:"students_started"`   // Sinh viên ghi danh đã nghe
+	StudentsCompleted int64     `json:"students_completed"` // Sinh viên ghi danh đã nghe hết mọi podcast của chương
+	NotStarted        int64     `json:"not_started"`
+	CompletionRate    float64   `json:"completion_rate"` // % sinh viên ghi danh đã hoàn thành chương
+}
+
+// Lượt nghe và tỉ lệ hoàn thành từng chương của môn
+// GET /admin/stats/subjects/:subject_id/chapters?from=&to=&class_id=&format=csv
+func GetSubjectChapterStats(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	subject, classID, ok := loadTaughtSubject(c, db)
+	if !ok {
+		return
+	}
+	from, to, _, ok := parseStatsRange(c, 120)
+	if !ok {
+		return
+	}
+
+	students := subjectStudentIDs(db, subject.ID, classID)
+	var enrolled int64
+	db.Raw("SELECT COUNT(DISTINCT user_id) FROM (?) AS st", students).Scan(&enrolled)
+
+	var out []ChapterStat
+	if err := db.Raw(`
+		SELECT ch.id AS chapter_id, ch.title, ch.sort_order,
+			(SELECT COUNT(*) FROM podcasts p WHERE p.chapter_id = ch.id AND p.status = 'published') AS podcasts,
+			(SELECT COALESCE(SUM(pa.total_plays), 0) FROM podcast_analytics pa
+				JOIN podcasts p ON p.id = pa.podcast_id
+				WHERE p.chapter_id = ch.id AND pa.date BETWEEN ? AND ?) AS plays,
+			(SELECT COALESCE(SUM(pa.total_duration), 0) FROM podcast_analytics pa
+				JOIN podcasts p ON p.id = pa.podcast_id
+				WHERE p.chapter_id = ch.id AND pa.date BETWEEN ? AND ?) AS listened_seconds,
+			(SELECT COUNT(DISTINCT lh.user_id) FROM listening_histories lh
+				JOIN podcasts p ON p.id = lh.podcast_id
+				WHERE p.chapter_id = ch.id) AS listeners,
+			(SELECT COUNT(DISTINCT lh.user_id) FROM listening_histories lh
+				JOIN podcasts p ON p.id = lh.podcast_id
+				WHERE p.chapter_id = ch.id AND lh.user_id IN (?)) AS students_started,
+			(SELECT COUNT(*) FROM (
+				SELECT lh.user_id FROM listening_histories lh
+				JOIN podcasts p ON p.id = lh.podcast_id
+				WHERE p.chapter_id = ch.id AND p.status = 'published' AND lh.completed AND lh.user_id IN (?)
+				GROUP BY lh.user_id
+				HAVING COUNT(*) = (SELECT COUNT(*) FROM podcasts p2 WHERE p2.chapter_id = ch.id AND p2.status = 'published')
+			) done) AS students_completed
+		FROM chapters ch
+		WHERE ch.subject_id = ?
+		ORDER BY ch.sort_order, ch.created_at
+	`, from, to, from, to, students, students, subject.ID).Scan(&out).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể thống kê theo chương"})
+		return
+	}
+
+	for i := range out {
+		out[i].NotStarted = enrolled - out[i].StudentsStarted
+		out[i].CompletionRate = rateOf(out[i].StudentsCompleted, enrolled)
+	}
+
+	if wantsCSV(c) {
+		rows := make([][]string, 0, len(out))
+		for _, s := range out {
+			rows = append(rows, []string{s.Title, strconv.FormatInt(s.Podcasts, 10),
+				strconv.FormatInt(s.Plays, 10), formatRate(float64(s.ListenedSeconds) / 3600),
+				strconv.FormatInt(s.Listeners, 10), strconv.FormatInt(s.StudentsStarted, 10),
+				strconv.FormatInt(s.StudentsCompleted, 10), strconv.FormatInt(s.NotStarted, 10),
+				formatRate(s.CompletionRate)})
+		}
+		respondCSV(c, "Thong_ke_chuong", []string{"Chương", "Số podcast", "Lượt phát", "Giờ nghe",
+			"Người nghe", "SV đã bắt đầu", "SV hoàn thành", "SV chưa bắt đầu", "Tỉ lệ hoàn thành (%)"}, rows)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"subject_id": subject.ID,
+		"class_id":   classID,
+		"from":       from.Format("2006-01-02"),
+		"to":         to.Format("2006-01-02"),
+		"enrolled":   enrolled,
+		"chapters":   out,
+	})
+}
+
+// ===== NGHE & HOÀN THÀNH THEO PODCAST =====
+
+type SubjectPodcastStat struct {
+	PodcastID       uuid.UUID `json:"podcast_id"`
+	Title           string    `json:"title"`
+	ChapterID       uuid.UUID `json:"chapter_id"`
+	ChapterTitle    string    `json:"chapter_title"`
+	DurationSec     int       `json:"duration_sec"`
+	Plays           int64     `json:"plays"`
+	ListenedSeconds int64     `json:"listened_seconds"`
+	Listeners       int64     `json:"listeners"`
+	Completed       int64     `json:"completed"`
+	CompletionRate  float64   `json:"completion_rate"` // % người nghe đã nghe hết
+	AvgProgress     float64   `json:"avg_progress"`    // Vị trí nghe cuối trung bình (% thời lượng)
+	StudentsStarted int64     `json:"students_started"`
+}
+
+// Lượt nghe và tỉ lệ hoàn thành từng podcast của môn
+// GET /admin/stats/subjects/:subject_id/podcasts?from=&to=&class_id=&chapter_id=&format=csv
+func GetSubjectPodcastStats(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	subject, classID, ok := loadTaughtSubject(c, db)
+	if !ok {
+		return
+	}
+	from, to, _, ok := parseStatsRange(c, 120)
+	if !ok {
+		return
+	}
+
+	chapterFilter := ""
+	args := []interface{}{subjectStudentIDs(db, subject.ID, classID), from, to, subject.ID}
+	if c.Query("chapter_id") != "" {
+		chapterID, err := uuid.Parse(c.Query("chapter_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chapter_id"})
+			return
+		}
+		chapterFilter = "AND ch.id = ?"
+		args = append(args, chapterID)
+	}
+
+	var out []SubjectPodcastStat
+	if err := db.Raw(`
+		SELECT p.id AS podcast_id, p.title, ch.id AS chapter_id, ch.title AS chapter_title, p.duration_sec,
+			COALESCE(pa.plays, 0) AS plays,
+			COALESCE(pa.duration, 0) AS listened_seconds,
+			COUNT(lh.id) AS listeners,
+			COUNT(lh.id) FILTER (WHERE lh.completed) AS completed,
+			COALESCE(AVG(LEAST(lh.last_position::float / NULLIF(p.duration_sec, 0), 1)) * 100, 0) AS avg_progress,
+			COUNT(lh.id) FILTER (WHERE lh.user_id IN (?)) AS students_started
+		FROM podcasts p
+		JOIN chapters ch ON ch.id = p.chapter_id
+		LEFT JOIN (
+			SELECT podcast_id, SUM(total_plays) AS plays, SUM(total_duration) AS duration
+			FROM podcast_analytics WHERE date BETWEEN ? AND ?
+			GROUP BY podcast_id
+		) pa ON pa.podcast_id = p.id
+		LEFT JOIN listening_histories lh ON lh.podcast_id = p.id
+		WHERE ch.subject_id = ? AND p.status = 'published' `+chapterFilter+`
+		GROUP BY p.id, p.title, ch.id, ch.title, ch.sort_order, p.duration_sec, p.created_at, pa.plays, pa.duration
+		ORDER BY ch.sort_order, p.created_at
+	`, args...).Scan(&out).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể thống kê theo podcast"})
+		return
+	}
+
+	for i := range out {
+		out[i].CompletionRate = rateOf(out[i].Completed, out[i].Listeners)
+		out[i].AvgProgress = roundPoints(out[i].AvgProgress)
+	}
+
+	if wantsCSV(c) {
+		rows := make([][]string, 0, len(out))
+		for _, s := range out {
+			rows = append(rows, []string{s.ChapterTitle, s.Title, strconv.Itoa(s.DurationSec),
+				strconv.FormatInt(s.Plays, 10), formatRate(float64(s.ListenedSeconds) / 3600),
+				strconv.FormatInt(s.Listeners, 10), strconv.FormatInt(s.Completed, 10),
+				formatRate(s.CompletionRate), formatRate(s.AvgProgress), strconv.FormatInt(s.StudentsStarted, 10)})
+		}
+		respondCSV(c, "Thong_ke_podcast", []string{"Chương", "Podcast", "Thời lượng (giây)", "Lượt phát", "Giờ nghe",
+			"Người nghe", "Nghe hết", "Tỉ lệ hoàn thành (%)", "Tiến độ TB (%)", "SV đã bắt đầu"}, rows)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"subject_id": subject.ID,
+		"class_id":   classID,
+		"from":       from.Format("2006-01-02"),
+		"to":         to.Format("2006-01-02"),
+		"podcasts":   out,
+	})
+}
+
+// ===== SINH VIÊN CHƯA BẮT ĐẦU CHƯƠNG =====
+
+type NotStartedStudent struct {
+	UserID   uuid.UUID `json:"user_id"`
+	FullName string    `json:"full_name"`
+	Email    string    `json:"email"`
+	Classes  string    `json:"classes"` // Tên các lớp của môn mà sinh viên ghi danh
+}
+
+// Sinh viên ghi danh chưa nghe podcast nào của chương
+// GET /admin/stats/subjects/:subject_id/chapters/:chapter_id/not-started?class_id=&format=csv
+func GetChapterNotStarted(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	subject, classID, ok := loadTaughtSubject(c, db)
+	if !ok {
+		return
+	}
+	chapterID, err := uuid.Parse(c.Param("chapter_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chapter_id"})
+		return
+	}
+	var chapter models.Chapter
+	if err := db.First(&chapter, "id = ? AND subject_id = ?", chapterID, subject.ID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy chương"})
+		return
+	}
+
+	query := db.Table("class_enrollments ce").
+		Select("u.id AS user_id, u.full_name, u.email, STRING_AGG(DISTINCT cl.name, ', ') AS classes").
+		Joins("JOIN classes cl ON cl.id = ce.class_id").
+		Joins("JOIN users u ON u.id = ce.user_id").
+		Where("cl.subject_id = ?", subject.ID).
+		Where(`NOT EXISTS (SELECT 1 FROM listening_histories lh
+			JOIN podcasts p ON p.id = lh.podcast_id
+			WHERE lh.user_id = ce.user_id AND p.chapter_id = ?)`, chapter.ID)
+	if classID != nil {
+		query = query.Where("cl.id = ?", *classID)
+	}
+
+	var out []NotStartedStudent
+	if err := query.Group("u.id, u.full_name, u.email").Order("u.full_name").Scan(&out).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy danh sách sinh viên"})
+		return
+	}
+
+	if wantsCSV(c) {
+		rows := make([][]string, 0, len(out))
+		for i, s := range out {
+			rows = append(rows, []string{strconv.Itoa(i + 1), s.FullName, s.Email, s.Classes})
+		}
+		respondCSV(c, "Chua_bat_dau", []string{"STT", "Họ tên", "Email", "Lớp"}, rows)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"chapter_id": chapter.ID,
+		"title":      chapter.Title,
+		"class_id":   classID,
+		"students":   out,
+		"total":      len(out),
+	})
+}
+
+// ===== PHÂN BỐ ĐIỂM =====
+
+type ScoreBucket struct {
+	Range string `json:"range"` // "0-1", ..., "9-10"
+	Count int64  `json:"count"`
+}
+
+// scoreDistribution chia điểm (thang 10) thành 10 khoảng bằng nhau; điểm 10 tính vào khoảng 9-10
+func scoreDistribution(scores []float64) []ScoreBucket {
+	buckets := make([]ScoreBucket, 10)
+	for i := range buckets {
+		buckets[i].Range = fmt.Sprintf("%d-%d", i, i+1)
+	}
+	for _, s := range scores {
+		idx := int(s)
+		if idx < 0 {
+			idx = 0
+		}
+		if idx > 9 {
+			idx = 9
+		}
+		buckets[idx].Count++
+	}
+	return buckets
+}
+
+// Phân bố điểm quiz và bài tập của sinh viên trong môn
+// GET /admin/stats/subjects/:subject_id/score-distribution?type=quiz|assignment&from=&to=&class_id=&format=csv
+func GetSubjectScoreDistribution(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	subject, classID, ok := loadTaughtSubject(c, db)
+	if !ok {
+		return
+	}
+	from, to, _, ok := parseStatsRange(c, 120)
+	if !ok {
+		return
+	}
+	to = to.AddDate(0, 0, 1) // Lấy trọn ngày "to"
+
+	students := subjectStudentIDs(db, subject.ID, classID)
+	kind := c.DefaultQuery("type", "quiz")
+
+	var scores []float64
+	var err error
+	switch kind {
+	case "quiz":
+		// Điểm quiz: lần làm tốt nhất của mỗi sinh viên trên từng bộ quiz
+		err = db.Raw(`
+			SELECT MAX(qa.score) FROM quiz_attempts qa
+			JOIN podcasts p ON p.id = qa.podcast_id
+			JOIN chapters ch ON ch.id = p.chapter_id
+			WHERE ch.subject_id = ? AND qa.user_id IN (?) AND qa.taken_at >= ? AND qa.taken_at < ?
+			GROUP BY qa.user_id, qa.quiz_set_id
+		`, subject.ID, students, from, to).Scan(&scores).Error
+	case "assignment":
+		// Điểm bài tập: lần nộp tốt nhất đã chấm xong của mỗi sinh viên trên từng bài tập
+		err = db.Raw(`
+			SELECT MAX(s.score) FROM assignment_submissions s
+			JOIN assignments a ON a.id = s.assignment_id
+			JOIN podcasts p ON p.id = a.podcast_id
+			JOIN chapters ch ON ch.id = p.chapter_id
+			WHERE ch.subject_id = ? AND s.user_id IN (?) AND s.submitted_at >= ? AND s.submitted_at < ?
+				AND s.grading_status <> ?
+			GROUP BY s.user_id, s.assignment_id
+		`, subject.ID, students, from, to, models.GradingStatusPending).Scan(&scores).Error
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type chỉ hỗ trợ quiz hoặc assignment"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể thống kê điểm"})
+		return
+	}
+
+	buckets := scoreDistribution(scores)
+	total, avg := int64(len(scores)), 0.0
+	for _, s := range scores {
+		avg += s
+	}
+	if total > 0 {
+		avg = roundPoints(avg / float64(total))
+	}
+
+	if wantsCSV(c) {
+		rows := make([][]string, 0, len(buckets))
+		for _, b := range buckets {
+			rows = append(rows, []string{b.Range, strconv.FormatInt(b.Count, 10), formatRate(rateOf(b.Count, total))})
+		}
+		respondCSV(c, "Phan_bo_diem_"+kind, []string{"Khoảng điểm", "Số lượng", "Tỉ lệ (%)"}, rows)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"subject_id": subject.ID,
+		"class_id":   classID,
+		"type":       kind,
+		"total":      total,
+		"average":    avg,
+		"buckets":    buckets,
+	})
+}
+
+// ===== XU HƯỚNG THEO TUẦN TRONG HỌC KỲ =====
+
+type TrendPoint struct {
+	Week               string  `json:"week"` // Thứ Hai đầu tuần (YYYY-MM-DD)
+	Plays              int64   `json:"plays"`
+	ListenedSeconds    int64   `json:"listened_seconds"`
+	UniqueListeners    int64   `json:"unique_listeners"`
+	Completions        int64   `json:"completions"`
+	QuizAttempts       int64   `json:"quiz_attempts"`
+	QuizAvgScore       float64 `json:"quiz_avg_score"`
+	Submissions        int64   `json:"submissions"`
+	SubmissionAvgScore float64 `json:"submission_avg_score"`
+}
+
+type weeklyAggregate struct {
+	Week  string
+	Count int64
+	Avg   float64
+}
+
+// Xu hướng nghe, hoàn thành và làm bài theo tuần trong học kỳ
+// GET /admin/stats/subjects/:subject_id/trends?from=&to=&class_id=&format=csv
+func GetSubjectTrends(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	subject, classID, ok := loadTaughtSubject(c, db)
+	if !ok {
+		return
+	}
+	from, to, loc, ok := parseStatsRange(c, 120)
+	if !ok {
+		return
+	}
+	from = weekStart(from)
+	end := to.AddDate(0, 0, 1)
+	students := subjectStudentIDs(db, subject.ID, classID)
+
+	points := map[string]*TrendPoint{}
+	var order []string
+	for w := from; !w.After(to); w = w.AddDate(0, 0, 7) {
+		key := w.Format("2006-01-02")
+		points[key] = &TrendPoint{Week: key}
+		order = append(order, key)
+	}
+	weekKey := func(t time.Time) string { return weekStart(t).Format("2006-01-02") }
+
+	// Lượt phát, thời gian nghe, người nghe không trùng: từ bảng tổng hợp theo môn (toàn bộ người nghe của môn)
+	var daily []models.SubjectAnalytics
+	db.Select("date", "total_plays", "total_duration").
+		Where("subject_id = ? AND date BETWEEN ? AND ?", subject.ID, from, to).
+		Find(&daily)
+	for _, d := range daily {
+		if p, ok := points[weekKey(d.Date)]; ok {
+			p.Plays += d.TotalPlays
+			p.ListenedSeconds += d.TotalDuration
+		}
+	}
+	rows := loadSketchRows(db, &models.SubjectAnalytics{}, from, to, "subject_id = ?", subject.ID)
+	for key, n := range mergeSketches(rows, weekKey) {
+		if p, ok := points[key]; ok {
+			p.UniqueListeners = n
+		}
+	}
+
+	// Hoàn thành podcast, quiz, bài tập: chỉ tính sinh viên ghi danh
+	var completions, quizzes, submissions []weeklyAggregate
+	db.Raw(`
+		SELECT TO_CHAR(DATE_TRUNC('week', lh.completed_at AT TIME ZONE ?), 'YYYY-MM-DD') AS week, COUNT(*) AS count
+		FROM listening_histories lh
+		JOIN podcasts p ON p.id = lh.podcast_id
+		JOIN chapters ch ON ch.id = p.chapter_id
+		WHERE ch.subject_id = ? AND lh.completed AND lh.user_id IN (?) AND lh.completed_at >= ? AND lh.completed_at < ?
+		GROUP BY 1
+	`, loc.String(), subject.ID, students, from, end).Scan(&completions)
+	db.Raw(`
+		SELECT TO_CHAR(DATE_TRUNC('week', qa.taken_at AT TIME ZONE ?), 'YYYY-MM-DD') AS week, COUNT(*) AS count, AVG(qa.score) AS avg
+		FROM quiz_attempts qa
+		JOIN podcasts p ON p.id = qa.podcast_id
+		JOIN chapters ch ON ch.id = p.chapter_id
+		WHERE ch.subject_id = ? AND qa.user_id IN (?) AND qa.taken_at >= ? AND qa.taken_at < ?
+		GROUP BY 1
+	`, loc.String(), subject.ID, students, from, end).Scan(&quizzes)
+	db.Raw(`
+		SELECT TO_CHAR(DATE_TRUNC('week', s.submitted_at AT TIME ZONE ?), 'YYYY-MM-DD') AS week, COUNT(*) AS count, AVG(s.score) AS avg
+		FROM assignment_submissions s
+		JOIN assignments a ON a.id = s.assignment_id
+		JOIN podcasts p ON p.id = a.podcast_id
+		JOIN chapters ch ON ch.id = p.chapter_id
+		WHERE ch.subject_id = ? AND s.user_id IN (?) AND s.submitted_at >= ? AND s.submitted_at < ?
+			AND s.grading_status <> ?
+		GROUP BY 1
+	`, loc.String(), subject.ID, students, from, end, models.GradingStatusPending).Scan(&submissions)
+
+	for _, r := range completions {
+		if p, ok := points[r.Week]; ok {
+			p.Completions = r.Count
+		}
+	}
+	for _, r := range quizzes {
+		if p, ok := points[r.Week]; ok {
+			p.QuizAttempts, p.QuizAvgScore = r.Count, roundPoints(r.Avg)
+		}
+	}
+	for _, r := range submissions {
+		if p, ok := points[r.Week]; ok {
+			p.Submissions, p.SubmissionAvgScore = r.Count, roundPoints(r.Avg)
+		}
+	}
+
+	out := make([]TrendPoint, 0, len(order))
+	for _, key := range order {
+		out = append(out, *points[key])
+	}
+
+	if wantsCSV(c) {
+		rows := make([][]string, 0, len(out))
+		for _, p := range out {
+			rows = append(rows, []string{p.Week, strconv.FormatInt(p.Plays, 10),
+				formatRate(float64(p.ListenedSeconds) / 3600), strconv.FormatInt(p.UniqueListeners, 10),
+				strconv.FormatInt(p.Completions, 10), strconv.FormatInt(p.QuizAttempts, 10), formatRate(p.QuizAvgScore),
+				strconv.FormatInt(p.Submissions, 10), formatRate(p.SubmissionAvgScore)})
+		}
+		respondCSV(c, "Xu_huong_tuan", []string{"Tuần", "Lượt phát", "Giờ nghe", "Người nghe", "Nghe hết podcast",
+			"Lượt làm quiz", "Điểm quiz TB", "Bài nộp", "Điểm bài tập TB"}, rows)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"subject_id": subject.ID,
+		"class_id":   classID,
+		"from":       from.Format("2006-01-02"),
+		"to":         to.Format("2006-01-02"),
+		"weeks":      out,
+	})
+}
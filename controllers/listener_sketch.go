@@ -0,0 +1,177 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/vnkhanh/e-podcast-backend/models"
+	"github.com/vnkhanh/e-podcast-backend/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ==================== ĐẾM NGƯỜI NGHE KHÔNG TRÙNG (HYPERLOGLOG) ====================
+
+type sketchRow struct {
+	ID             uuid.UUID
+	Date           time.Time
+	ListenerSketch []byte
+}
+
+// addToSketch thêm user vào sketch của một dòng thống kê (khóa dòng khi ghi) và cập nhật cột đếm
+func addToSketch(tx *gorm.DB, model interface{}, countColumn string, userID uuid.UUID, query string, args ...interface{}) error {
+	var row sketchRow
+	if err := tx.Model(model).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id", "listener_sketch").Where(query, args...).Take(&row).Error; err != nil {
+		return err
+	}
+	sketch, err := utils.ParseHLLSketch(row.ListenerSketch)
+	if err != nil {
+		sketch = utils.NewHLLSketch()
+	}
+	if !sketch.Add(userID[:]) {
+		return nil // Sketch không đổi: số đếm giữ nguyên
+	}
+	return tx.Model(model).Where("id = ?", row.ID).Updates(map[string]interface{}{
+		"listener_sketch": sketch.Bytes(),
+		countColumn:       sketch.Estimate(),
+	}).Error
+}
+
+// recordListener ghi nhận user đã nghe podcast trong ngày vào sketch toàn hệ thống, của podcast và của môn học
+func recordListener(tx *gorm.DB, day time.Time, userID, podcastID, subjectID uuid.UUID) error {
+	if err := tx.Exec(`
+		INSERT INTO listening_analytics (id, date, created_at, updated_at)
+		VALUES (gen_random_uuid(), $1, NOW(), NOW())
+		ON CONFLICT (date) DO NOTHING
+	`, day).Error; err != nil {
+		return err
+	}
+	if err := addToSketch(tx, &models.ListeningAnalytics{}, "unique_users", userID, "date = ?", day); err != nil {
+		return err
+	}
+
+	if err := tx.Exec(`
+		INSERT INTO podcast_analytics (id, date, podcast_id, created_at, updated_at)
+		VALUES (gen_random_uuid(), $1, $2, NOW(), NOW())
+		ON CONFLICT (date, podcast_id) DO NOTHING
+	`, day, podcastID).Error; err != nil {
+		return err
+	}
+	if err := addToSketch(tx, &models.PodcastAnalytics{}, "unique_listeners", userID, "date = ? AND podcast_id = ?", day, podcastID); err != nil {
+		return err
+	}
+
+	if subjectID == uuid.Nil {
+		return nil
+	}
+	if err := tx.Exec(`
+		INSERT INTO subject_analytics (id, date, subject_id, created_at, updated_at)
+		VALUES (gen_random_uuid(), $1, $2, NOW(), NOW())
+		ON CONFLICT (date, subject_id) DO NOTHING
+	`, day, subjectID).Error; err != nil {
+		return err
+	}
+	return addToSketch(tx, &models.SubjectAnalytics{}, "unique_listeners", userID, "date = ? AND subject_id = ?", day, subjectID)
+}
+
+// loadSketchRows lấy sketch theo ngày trong khoảng [from, to] của bảng thống kê
+func loadSketchRows(db *gorm.DB, model interface{}, from, to time.Time, query string, args ...interface{}) []sketchRow {
+	var rows []sketchRow
+	q := db.Model(model).Select("id", "date", "listener_sketch").
+		Where("date BETWEEN ? AND ?", from, to)
+	if query != "" {
+		q = q.Where(query, args...)
+	}
+	q.Order("date").Find(&rows)
+	return rows
+}
+
+// mergeSketches gộp sketch của nhiều dòng theo khóa nhóm (ngày / tuần / tháng), trả về số người nghe mỗi nhóm
+func mergeSketches(rows []sketchRow, groupKey func(time.Time) string) map[string]int64 {
+	merged := map[string]*utils.HLLSketch{}
+	for _, r := range rows {
+		sketch, err := utils.ParseHLLSketch(r.ListenerSketch)
+		if err != nil {
+			continue
+		}
+		key := groupKey(r.Date)
+		if acc, ok := merged[key]; ok {
+			acc.Merge(sketch)
+		} else {
+			merged[key] = sketch
+		}
+	}
+	out := make(map[string]int64, len(merged))
+	for key, sketch := range merged {
+		out[key] = sketch.Estimate()
+	}
+	return out
+}
+
+// uniqueListenersOf là số người nghe không trùng trong cả khoảng
+func uniqueListenersOf(rows []sketchRow) int64 {
+	return mergeSketches(rows, func(time.Time) string { return "" })[""]
+}
+
+// weekStart là thứ Hai của tuần chứa t
+func weekStart(t time.Time) time.Time {
+	offset := (int(t.Weekday()) + 6) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, t.Location())
+}
+
+// Số người nghe không trùng trong khoảng ngày bất kỳ (toàn hệ thống / theo podcast / theo môn học)
+// GET /admin/stats/unique-listeners?from=2025-01-01&to=2025-01-31&podcast_id=&subject_id=
+func GetUniqueListeners(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	from, to, _, ok := parseStatsRange(c, 30)
+	if !ok {
+		return
+	}
+
+	var rows []sketchRow
+	scope := gin.H{"type": "all"}
+	switch {
+	case c.Query("podcast_id") != "":
+		podcastID, err := uuid.Parse(c.Query("podcast_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid podcast_id"})
+			return
+		}
+		var podcast models.Podcast
+		if err := db.First(&podcast, "id = ?", podcastID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy podcast"})
+			return
+		}
+		if !canManagePodcast(c, db, podcast, models.PermPodcastEdit) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Bạn không có quyền xem thống kê podcast này"})
+			return
+		}
+		rows = loadSketchRows(db, &models.PodcastAnalytics{}, from, to, "podcast_id = ?", podcastID)
+		scope = gin.H{"type": "podcast", "podcast_id": podcastID}
+	case c.Query("subject_id") != "":
+		subjectID, err := uuid.Parse(c.Query("subject_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid subject_id"})
+			return
+		}
+		if !requireSubjectPermission(c, db, subjectID, models.PermSubjectEdit) {
+			return
+		}
+		rows = loadSketchRows(db, &models.SubjectAnalytics{}, from, to, "subject_id = ?", subjectID)
+		scope = gin.H{"type": "subject", "subject_id": subjectID}
+	default:
+		rows = loadSketchRows(db, &models.ListeningAnalytics{}, from, to, "")
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":             from.Format("2006-01-02"),
+		"to":               to.Format("2006-01-02"),
+		"scope":            scope,
+		"unique_listeners": uniqueListenersOf(rows),
+		"days":             len(rows),
+	})
+}
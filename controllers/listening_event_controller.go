@@ -0,0 +1,534 @@
+package controllers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/vnkhanh/e-podcast-backend/models"
+	"github.com/vnkhanh/e-podcast-backend/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ==================== SỰ KIỆN NGHE & RETENTION ====================
+
+const (
+	maxListeningEventBatch = 200
+	maxListeningEventGap   = 2 * time.Minute // Hai sự kiện cách nhau quá khoảng này thì không tính là nghe liên tục
+	maxListeningEventAge   = 7 * 24 * time.Hour
+	maxPodcastSeconds      = 6 * 3600 // Giới hạn vị trí khi podcast chưa có thời lượng
+)
+
+type listeningEventInput struct {
+	Seq          int       `json:"seq" binding:"min=0"`
+	Type         string    `json:"type" binding:"required"`
+	Position     float64   `json:"position" binding:"min=0"`
+	FromPosition *float64  `json:"from_position"`
+	PlaybackRate float64   `json:"playback_rate"`
+	OccurredAt   time.Time `json:"occurred_at" binding:"required"`
+}
+
+type ingestListeningEventsRequest struct {
+	PodcastID string                `json:"podcast_id" binding:"required"`
+	SessionID string                `json:"session_id" binding:"required,max=64"`
+	Events    []listeningEventInput `json:"events" binding:"required,min=1,dive"`
+}
+
+// playerState là trạng thái player sau sự kiện gần nhất của session
+type playerState struct {
+	valid    bool
+	seq      int
+	playing  bool
+	position int
+	rate     float64
+	at       time.Time
+}
+
+// listenSegment là đoạn [Start, End) giây của podcast đã được nghe
+type listenSegment struct {
+	Start, End int
+}
+
+func validListeningEventType(t string) bool {
+	switch t {
+	case models.ListeningEventPlay, models.ListeningEventPause, models.ListeningEventSeek,
+		models.ListeningEventRate, models.ListeningEventHeartbeat, models.ListeningEventEnded:
+		return true
+	}
+	return false
+}
+
+// analyticsDay là ngày thống kê của thời điểm t (theo múi giờ của trường, không phải UTC)
+func analyticsDay(t time.Time) time.Time {
+	return utils.AnalyticsDay(t)
+}
+
+func clampSecond(v float64, max int) int {
+	s := int(math.Floor(v))
+	if s < 0 {
+		return 0
+	}
+	if s > max {
+		return max
+	}
+	return s
+}
+
+// applyListeningEvent tính thời gian nghe thực và đoạn đã nghe giữa trạng thái trước và sự kiện e,
+// rồi cập nhật trạng thái. Chỉ tính khi player đang phát, thời gian hợp lý và nội dung không vượt quá tốc độ phát.
+func applyListeningEvent(state *playerState, e *models.ListeningEvent) *listenSegment {
+	var segment *listenSegment
+
+	end := e.Position
+	if e.Type == models.ListeningEventSeek && e.FromPosition != nil {
+		end = *e.FromPosition
+	}
+
+	if state.valid && state.playing {
+		wall := e.OccurredAt.Sub(state.at).Seconds()
+		if wall > 0 && wall <= maxListeningEventGap.Seconds() {
+			content := end - state.position
+			// Cho phép sai lệch nhỏ giữa đồng hồ client và vị trí player
+			if maxContent := int(math.Ceil(wall*state.rate*1.2)) + 2; content > maxContent {
+				content = maxContent
+			}
+			if content > 0 {
+				segment = &listenSegment{Start: state.position, End: state.position + content}
+				listened := math.Min(wall, float64(content)/state.rate)
+				e.ListenedSeconds = int(math.Round(listened))
+			}
+		}
+	}
+
+	switch e.Type {
+	case models.ListeningEventPlay, models.ListeningEventHeartbeat:
+		state.playing = true
+	case models.ListeningEventPause, models.ListeningEventEnded:
+		state.playing = false
+	case models.ListeningEventSeek, models.ListeningEventRate:
+		// Giữ nguyên trạng thái phát; session mới bắt đầu bằng seek coi như đang dừng
+		if !state.valid {
+			state.playing = false
+		}
+	}
+	if e.PlaybackRate > 0 {
+		state.rate = math.Max(e.PlaybackRate, 0.25)
+	}
+	e.PlaybackRate = state.rate
+	e.Playing = state.playing
+	state.position = e.Position
+	state.at = e.OccurredAt
+	state.seq = e.Seq
+	state.valid = true
+	return segment
+}
+
+// markCoverage bật các bit của đoạn đã nghe, trả về các giây lần đầu được nghe (gộp thành đoạn)
+func markCoverage(bitmap []byte, segments []listenSegment) ([]byte, []listenSegment) {
+	var fresh []listenSegment
+	for _, seg := range segments {
+		if need := (seg.End + 7) / 8; need > len(bitmap) {
+			bitmap = append(bitmap, make([]byte, need-len(bitmap))...)
+		}
+		for s := seg.Start; s < seg.End; s++ {
+			mask := byte(1) << (s % 8)
+			if bitmap[s/8]&mask != 0 {
+				continue
+			}
+			bitmap[s/8] |= mask
+			if n := len(fresh); n > 0 && fresh[n-1].End == s {
+				fresh[n-1].End = s + 1
+			} else {
+				fresh = append(fresh, listenSegment{Start: s, End: s + 1})
+			}
+		}
+	}
+	return bitmap, fresh
+}
+
+func countCoverage(bitmap []byte) int {
+	n := 0
+	for _, b := range bitmap {
+		for ; b != 0; b &= b - 1 {
+			n++
+		}
+	}
+	return n
+}
+
+// Nhận lô sự kiện phát từ player
+// POST /user/account/listening-events
+// {podcast_id, session_id, events: [{seq, type: play|pause|seek|rate|heartbeat|ended, position, from_position, playback_rate, occurred_at}]}
+func IngestListeningEvents(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req ingestListeningEventsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.Events) > maxListeningEventBatch {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tối đa " + strconv.Itoa(maxListeningEventBatch) + " sự kiện mỗi lần gửi"})
+		return
+	}
+	podcastID, err := uuid.Parse(req.PodcastID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "podcast_id không hợp lệ"})
+		return
+	}
+
+	// Admin / giảng viên nghe thử không tính vào thống kê (giống lượt nghe)
+	role := c.GetString("role")
+	if role == string(models.RoleAdmin) || role == string(models.RoleLecturer) {
+		c.JSON(http.StatusOK, gin.H{"message": "Admin/teacher không tính thống kê nghe", "accepted": 0})
+		return
+	}
+
+	var podcast models.Podcast
+	if err := db.Select("id", "chapter_id", "duration_sec").First(&podcast, "id = ?", podcastID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy podcast"})
+		return
+	}
+	maxSecond := podcast.DurationSec
+	if maxSecond <= 0 {
+		maxSecond = maxPodcastSeconds
+	}
+
+	// Chuẩn hóa và sắp xếp theo seq
+	now := time.Now()
+	events := make([]models.ListeningEvent, 0, len(req.Events))
+	for _, in := range req.Events {
+		if !validListeningEventType(in.Type) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Loại sự kiện không hợp lệ: " + in.Type})
+			return
+		}
+		occurredAt := in.OccurredAt
+		if occurredAt.After(now) {
+			occurredAt = now // Đồng hồ client chạy nhanh
+		}
+		if now.Sub(occurredAt) > maxListeningEventAge {
+			continue
+		}
+		e := models.ListeningEvent{
+			UserID:       userID,
+			PodcastID:    podcastID,
+			SessionID:    req.SessionID,
+			Seq:          in.Seq,
+			Type:         in.Type,
+			Position:     clampSecond(in.Position, maxSecond),
+			PlaybackRate: math.Min(math.Max(in.PlaybackRate, 0), 4),
+			OccurredAt:   occurredAt,
+		}
+		if in.FromPosition != nil {
+			from := clampSecond(*in.FromPosition, maxSecond)
+			e.FromPosition = &from
+		}
+		events = append(events, e)
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Seq < events[j].Seq })
+
+	var accepted, listenedTotal int
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Khóa theo session đến hết transaction: hai lô của cùng session gửi song song (client gửi lại)
+		// được xử lý lần lượt nên mỗi seq chỉ được tính thời gian nghe một lần
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext($1))", "listening_session:"+req.SessionID).Error; err != nil {
+			return err
+		}
+
+		// Trạng thái cuối của session; session phải thuộc đúng user và podcast
+		var last models.ListeningEvent
+		state := playerState{rate: 1}
+		if err := tx.Where("session_id = ?", req.SessionID).Order("seq DESC").First(&last).Error; err == nil {
+			if last.UserID != userID || last.PodcastID != podcastID {
+				return errSessionMismatch
+			}
+			state = playerState{
+				valid: true, seq: last.Seq, playing: last.Playing,
+				position: last.Position, rate: last.PlaybackRate, at: last.OccurredAt,
+			}
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		// Bỏ sự kiện đã nhận (client gửi lại lô)
+		seqs := make([]int, 0, len(events))
+		for _, e := range events {
+			seqs = append(seqs, e.Seq)
+		}
+		var existing []int
+		if len(seqs) > 0 {
+			if err := tx.Model(&models.ListeningEvent{}).Where("session_id = ? AND seq IN ?", req.SessionID, seqs).
+				Pluck("seq", &existing).Error; err != nil {
+				return err
+			}
+		}
+		seen := make(map[int]bool, len(existing))
+		for _, s := range existing {
+			seen[s] = true
+		}
+
+		fresh := make([]models.ListeningEvent, 0, len(events))
+		var segments []listenSegment
+		for i := range events {
+			e := events[i]
+			if seen[e.Seq] {
+				continue
+			}
+			seen[e.Seq] = true
+			// Sự kiện đến trễ (seq nhỏ hơn sự kiện đã xử lý) chỉ lưu lại, không tính thời gian nghe
+			if !state.valid || e.Seq > state.seq {
+				if seg := applyListeningEvent(&state, &e); seg != nil {
+					segments = append(segments, *seg)
+				}
+			} else {
+				e.Playing = false
+				if e.PlaybackRate <= 0 {
+					e.PlaybackRate = 1
+				}
+			}
+			fresh = append(fresh, e)
+		}
+		if len(fresh) == 0 {
+			return nil
+		}
+
+		// Thời gian nghe theo ngày
+		listenedByDay := map[time.Time]int{}
+		for _, e := range fresh {
+			listenedByDay[analyticsDay(e.OccurredAt)] += e.ListenedSeconds
+			listenedTotal += e.ListenedSeconds
+		}
+
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(&fresh, 100).Error; err != nil {
+			return err
+		}
+		accepted = len(fresh)
+
+		// Cộng dồn vào bảng thống kê theo ngày
+		var subjectID uuid.UUID
+		if podcast.ChapterID != uuid.Nil {
+			tx.Model(&models.Chapter{}).Select("subject_id").Where("id = ?", podcast.ChapterID).Scan(&subjectID)
+		}
+		for day, listened := range listenedByDay {
+			if err := recordListener(tx, day, userID, podcastID, subjectID); err != nil {
+				return err
+			}
+			if listened == 0 {
+				continue
+			}
+			if err := tx.Exec(`
+				UPDATE podcast_analytics SET total_duration = total_duration + $1, updated_at = NOW()
+				WHERE date = $2 AND podcast_id = $3
+			`, listened, day, podcastID).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec(`
+				UPDATE listening_analytics SET total_duration = total_duration + $1, updated_at = NOW()
+				WHERE date = $2
+			`, listened, day).Error; err != nil {
+				return err
+			}
+			if subjectID != uuid.Nil {
+				if err := tx.Exec(`
+					UPDATE subject_analytics SET total_duration = total_duration + $1, updated_at = NOW()
+					WHERE date = $2 AND subject_id = $3
+				`, listened, day, subjectID).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		if len(segments) == 0 {
+			return nil
+		}
+		return updateRetention(tx, userID, podcastID, segments)
+	})
+	if errors.Is(err, errSessionMismatch) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lưu sự kiện nghe"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"accepted":         accepted,
+		"skipped":          len(req.Events) - accepted, // Trùng seq hoặc quá cũ
+		"listened_seconds": listenedTotal,
+	})
+}
+
+var errSessionMismatch = errors.New("session_id đã được dùng cho podcast hoặc người dùng khác")
+
+// updateRetention ghi các giây user nghe lần đầu vào bitset và tăng số người nghe của những giây đó.
+// Dòng coverage được tạo trước (ON CONFLICT DO NOTHING) rồi khóa, để hai request song song của cùng user
+// không cùng thấy "chưa có" và cộng retention hai lần
+func updateRetention(tx *gorm.DB, userID, podcastID uuid.UUID, segments []listenSegment) error {
+	if err := tx.Exec(`
+		INSERT INTO listener_coverages (user_id, podcast_id, seconds, updated_at)
+		VALUES ($1, $2, 0, NOW())
+		ON CONFLICT (user_id, podcast_id) DO NOTHING
+	`, userID, podcastID).Error; err != nil {
+		return err
+	}
+
+	var coverage models.ListenerCoverage
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND podcast_id = ?", userID, podcastID).
+		Take(&coverage).Error; err != nil {
+		return err
+	}
+
+	bitmap, fresh := markCoverage(coverage.Bitmap, segments)
+	if len(fresh) == 0 {
+		return nil
+	}
+	if err := tx.Model(&models.ListenerCoverage{}).
+		Where("user_id = ? AND podcast_id = ?", userID, podcastID).
+		Updates(map[string]interface{}{
+			"bitmap":     bitmap,
+			"seconds":    countCoverage(bitmap),
+			"updated_at": time.Now(),
+		}).Error; err != nil {
+		return err
+	}
+
+	rows := make([]models.PodcastRetention, 0)
+	for _, seg := range fresh {
+		for s := seg.Start; s < seg.End; s++ {
+			rows = append(rows, models.PodcastRetention{PodcastID: podcastID, Second: s, Listeners: 1})
+		}
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "podcast_id"}, {Name: "second"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"listeners": gorm.Expr("podcast_retentions.listeners + EXCLUDED.listeners"),
+		}),
+	}).CreateInBatches(&rows, 500).Error
+}
+
+// Đường retention của podcast: tỉ lệ người nghe còn nghe ở từng thời điểm và các đoạn rời bỏ nhiều nhất
+// GET /admin/stats/podcasts/:podcast_id/retention?bucket=10
+func GetPodcastRetention(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	podcastID, err := uuid.Parse(c.Param("podcast_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid podcast_id"})
+		return
+	}
+	var podcast models.Podcast
+	if err := db.First(&podcast, "id = ?", podcastID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy podcast"})
+		return
+	}
+	if !canManagePodcast(c, db, podcast, models.PermPodcastEdit) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Bạn không có quyền xem thống kê podcast này"})
+		return
+	}
+
+	bucket, _ := strconv.Atoi(c.DefaultQuery("bucket", "10"))
+	if bucket < 1 {
+		bucket = 1
+	}
+	if bucket > 300 {
+		bucket = 300
+	}
+
+	var totalListeners int64
+	db.Model(&models.ListenerCoverage{}).Where("podcast_id = ?", podcastID).Count(&totalListeners)
+
+	var avgSeconds float64
+	db.Model(&models.ListenerCoverage{}).Where("podcast_id = ?", podcastID).
+		Select("COALESCE(AVG(seconds), 0)").Scan(&avgSeconds)
+
+	var rows []models.PodcastRetention
+	if err := db.Where("podcast_id = ?", podcastID).Order("second ASC").Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy dữ liệu retention"})
+		return
+	}
+
+	duration := podcast.DurationSec
+	if n := len(rows); n > 0 && rows[n-1].Second+1 > duration {
+		duration = rows[n-1].Second + 1
+	}
+	perSecond := make([]int64, duration)
+	for _, r := range rows {
+		perSecond[r.Second] = r.Listeners
+	}
+
+	type retentionPoint struct {
+		Second    int     `json:"second"`
+		Listeners float64 `json:"listeners"`
+		Retention float64 `json:"retention"` // % so với tổng số người nghe
+	}
+	points := make([]retentionPoint, 0, duration/bucket+1)
+	for start := 0; start < duration; start += bucket {
+		end := start + bucket
+		if end > duration {
+			end = duration
+		}
+		var sum int64
+		for s := start; s < end; s++ {
+			sum += perSecond[s]
+		}
+		avg := float64(sum) / float64(end-start)
+		retention := 0.0
+		if totalListeners > 0 {
+			retention = math.Round(avg/float64(totalListeners)*10000) / 100
+		}
+		points = append(points, retentionPoint{Second: start, Listeners: math.Round(avg*100) / 100, Retention: retention})
+	}
+
+	// Các đoạn mất nhiều người nghe nhất giữa hai bucket liên tiếp
+	type dropOff struct {
+		FromSecond   int     `json:"from_second"`
+		ToSecond     int     `json:"to_second"`
+		LostPercent  float64 `json:"lost_percent"`
+		LostListener float64 `json:"lost_listeners"`
+	}
+	drops := make([]dropOff, 0)
+	for i := 1; i < len(points); i++ {
+		if lost := points[i-1].Listeners - points[i].Listeners; lost > 0 {
+			drops = append(drops, dropOff{
+				FromSecond:   points[i-1].Second,
+				ToSecond:     points[i].Second,
+				LostPercent:  math.Round((points[i-1].Retention-points[i].Retention)*100) / 100,
+				LostListener: math.Round(lost*100) / 100,
+			})
+		}
+	}
+	sort.Slice(drops, func(i, j int) bool { return drops[i].LostListener > drops[j].LostListener })
+	if len(drops) > 5 {
+		drops = drops[:5]
+	}
+
+	avgPercent := 0.0
+	if duration > 0 {
+		avgPercent = math.Round(avgSeconds/float64(duration)*10000) / 100
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"podcast_id":              podcastID,
+		"duration_sec":            duration,
+		"bucket_sec":              bucket,
+		"total_listeners":         totalListeners,
+		"avg_listened_seconds":    math.Round(avgSeconds*100) / 100,
+		"avg_listened_percentage": avgPercent,
+		"points":                  points,
+		"drop_offs":               drops,
+	})
+}
@@ -64,7 +64,7 @@ func SavePodcastHistory(c *gin.Context) {
 	var history models.ListeningHistory
 	result := db.Where("user_id = ? AND podcast_id = ?", userID, podcastID).First(&history)
 	now := time.Now()
-	today := now.Truncate(24 * time.Hour)
+	today := analyticsDay(now)
 
 	isNewCompletedToday := false
 	shouldCountAsNewPlay := false
@@ -97,7 +97,7 @@ func SavePodcastHistory(c *gin.Context) {
 		// === TRƯỜNG HỢP 2: ĐÃ TỪNG NGHE TRƯỚC ĐÓ ===
 
 		// Kiểm tra xem hôm nay đã tính lượt nghe chưa
-		lastListenDate := history.LastListenedAt.Truncate(24 * time.Hour)
+		lastListenDate := analyticsDay(history.LastListenedAt)
 
 		// Nếu lần nghe cuối là ngày khác → đây là lượt nghe mới
 		if !lastListenDate.Equal(today) {
@@ -137,7 +137,7 @@ func SavePodcastHistory(c *gin.Context) {
 
 	// === CẬP NHẬT ANALYTICS (CHỈ KHI CẦN) ===
 	if shouldCountAsNewPlay || isNewCompletedToday {
-		updateAnalyticsAsync(db, podcastID, shouldCountAsNewPlay, isNewCompletedToday)
+		updateAnalyticsAsync(db, userID, podcastID, shouldCountAsNewPlay, isNewCompletedToday)
 	}
 
 	db.Preload("Podcast").First(&history, "id = ?", history.ID)
@@ -147,11 +147,11 @@ func SavePodcastHistory(c *gin.Context) {
 	})
 }
 
-// updateAnalyticsAsync cập nhật analytics khi có lượt nghe mới hoặc completed mới
-func updateAnalyticsAsync(db *gorm.DB, podcastID uuid.UUID, countAsNewPlay, countAsNewCompleted bool) {
+// updateAnalyticsAsync cập nhật analytics khi có lượt nghe mới hoặc completed mới.
+// Thời gian nghe do IngestListeningEvents cộng dồn từ sự kiện phát.
+func updateAnalyticsAsync(db *gorm.DB, userID, podcastID uuid.UUID, countAsNewPlay, countAsNewCompleted bool) {
+	today := analyticsDay(time.Now())
 	go func() {
-		today := time.Now().Truncate(24 * time.Hour)
-
 		playIncrement := 0
 		completedIncrement := 0
 
@@ -166,7 +166,7 @@ func updateAnalyticsAsync(db *gorm.DB, podcastID uuid.UUID, countAsNewPlay, coun
 		if playIncrement > 0 {
 			db.Exec(`
 				INSERT INTO listening_analytics (id, date, total_listens, unique_users, completed_listens, created_at, updated_at)
-				VALUES (gen_random_uuid(), $1, $2, 1, $3, NOW(), NOW())
+				VALUES (gen_random_uuid(), $1, $2, 0, $3, NOW(), NOW())
 				ON CONFLICT (date) DO UPDATE SET
 					total_listens = listening_analytics.total_listens + $2,
 					completed_listens = listening_analytics.completed_listens + $3,
@@ -187,7 +187,7 @@ func updateAnalyticsAsync(db *gorm.DB, podcastID uuid.UUID, countAsNewPlay, coun
 		if playIncrement > 0 || completedIncrement > 0 {
 			db.Exec(`
 				INSERT INTO podcast_analytics (id, date, podcast_id, total_plays, unique_listeners, completed_plays, total_duration, created_at, updated_at)
-				VALUES (gen_random_uuid(), $1, $2, $3, 1, $4, 0, NOW(), NOW())
+				VALUES (gen_random_uuid(), $1, $2, $3, 0, $4, 0, NOW(), NOW())
 				ON CONFLICT (date, podcast_id) DO UPDATE SET
 					total_plays = podcast_analytics.total_plays + $3,
 					completed_plays = podcast_analytics.completed_plays + $4,
@@ -196,12 +196,14 @@ func updateAnalyticsAsync(db *gorm.DB, podcastID uuid.UUID, countAsNewPlay, coun
 		}
 
 		// 3. Update subject analytics (nếu có)
+		var subjectID uuid.UUID
 		if playIncrement > 0 {
 			var podcast models.Podcast
 			if err := db.Preload("Chapter").First(&podcast, podcastID).Error; err == nil {
 				if podcast.ChapterID != uuid.Nil {
 					var chapter models.Chapter
 					if err := db.First(&chapter, podcast.ChapterID).Error; err == nil && chapter.SubjectID != uuid.Nil {
+						subjectID = chapter.SubjectID
 						db.Exec(`
 							INSERT INTO subject_analytics (id, date, subject_id, total_plays, created_at, updated_at)
 							VALUES (gen_random_uuid(), $1, $2, 1, NOW(), NOW())
@@ -212,6 +214,11 @@ func updateAnalyticsAsync(db *gorm.DB, podcastID uuid.UUID, countAsNewPlay, coun
 					}
 				}
 			}
+
+			// 4. Đếm người nghe không trùng (HyperLogLog)
+			db.Transaction(func(tx *gorm.DB) error {
+				return recordListener(tx, today, userID, podcastID, subjectID)
+			})
 		}
 	}()
 }
@@ -263,7 +270,7 @@ func GetListeningHistory(c *gin.Context) {
 
 	switch timeFilter {
 	case "today":
-		startDate = time.Now().Truncate(24 * time.Hour)
+		startDate = analyticsDay(time.Now())
 	case "week":
 		startDate = time.Now().AddDate(0, 0, -7)
 	case "month":
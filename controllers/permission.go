@@ -0,0 +1,304 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/vnkhanh/e-podcast-backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ==================== PHÂN QUYỀN THEO NỘI DUNG ====================
+
+// hasSubjectPermission: vai trò phải có quyền, sau đó admin được mọi môn,
+// giảng viên chỉ trên môn mình tạo hoặc được cấp vai trò có quyền đó
+func hasSubjectPermission(c *gin.Context, db *gorm.DB, subjectID uuid.UUID, perm models.Permission) bool {
+	role := models.UserRole(c.GetString("role"))
+	if !role.Can(perm) {
+		return false
+	}
+	if role == models.RoleAdmin {
+		return true
+	}
+	userID := c.GetString("user_id")
+
+	var subject models.Subject
+	if err := db.Select("id", "created_by").First(&subject, "id = ?", subjectID).Error; err != nil {
+		return false
+	}
+	if subject.CreatedBy != nil && subject.CreatedBy.String() == userID {
+		return true
+	}
+
+	var member models.SubjectMember
+	if err := db.Where("subject_id = ? AND user_id = ?", subjectID, userID).First(&member).Error; err != nil {
+		return false
+	}
+	return models.SubjectRoleCan(member.Role, perm)
+}
+
+// requireSubjectPermission như hasSubjectPermission nhưng tự trả 403
+func requireSubjectPermission(c *gin.Context, db *gorm.DB, subjectID uuid.UUID, perm models.Permission) bool {
+	if hasSubjectPermission(c, db, subjectID, perm) {
+		return true
+	}
+	c.JSON(http.StatusForbidden, gin.H{
+		"error":      "Bạn không có quyền thực hiện thao tác này trên môn học",
+		"permission": perm,
+	})
+	return false
+}
+
+// grantedSubjectIDs là subquery id các môn học user sở hữu hoặc được cấp vai trò có quyền perm
+func grantedSubjectIDs(db *gorm.DB, userID string, perm models.Permission) *gorm.DB {
+	return db.Model(&models.Subject{}).Select("subjects.id").
+		Where("subjects.created_by = ? OR subjects.id IN (?)", userID,
+			db.Model(&models.SubjectMember{}).Select("subject_id").
+				Where("user_id = ? AND role IN ?", userID, models.SubjectRolesWith(perm)))
+}
+
+// memberSubjectIDs là subquery id các môn học user sở hữu hoặc được cấp bất kỳ vai trò nào
+func memberSubjectIDs(db *gorm.DB, userID string) *gorm.DB {
+	return db.Model(&models.Subject{}).Select("subjects.id").
+		Where("subjects.created_by = ? OR subjects.id IN (?)", userID,
+			db.Model(&models.SubjectMember{}).Select("subject_id").Where("user_id = ?", userID))
+}
+
+// grantedPodcastIDs là subquery id các podcast thuộc những môn trên
+func grantedPodcastIDs(db *gorm.DB, userID string, perm models.Permission) *gorm.DB {
+	return db.Model(&models.Podcast{}).Select("podcasts.id").
+		Joins("JOIN chapters ON chapters.id = podcasts.chapter_id").
+		Where("chapters.subject_id IN (?)", grantedSubjectIDs(db, userID, perm))
+}
+
+// podcastSubjectID tìm môn học của podcast qua chương
+func podcastSubjectID(db *gorm.DB, podcastID uuid.UUID) (uuid.UUID, bool) {
+	var subjectID uuid.UUID
+	if err := db.Model(&models.Chapter{}).Select("chapters.subject_id").
+		Joins("JOIN podcasts ON podcasts.chapter_id = chapters.id").
+		Where("podcasts.id = ?", podcastID).
+		Scan(&subjectID).Error; err != nil || subjectID == uuid.Nil {
+		return uuid.Nil, false
+	}
+	return subjectID, true
+}
+
+// canManagePodcast: người tạo podcast, hoặc có quyền trên môn học chứa podcast
+func canManagePodcast(c *gin.Context, db *gorm.DB, podcast models.Podcast, perm models.Permission) bool {
+	role := models.UserRole(c.GetString("role"))
+	if !role.Can(perm) {
+		return false
+	}
+	if role == models.RoleAdmin || podcast.CreatedBy.String() == c.GetString("user_id") {
+		return true
+	}
+	subjectID, ok := podcastSubjectID(db, podcast.ID)
+	return ok && hasSubjectPermission(c, db, subjectID, perm)
+}
+
+// canManageAssignment: người tạo bài tập, hoặc có quyền trên môn học của podcast gắn với bài tập
+func canManageAssignment(c *gin.Context, db *gorm.DB, assignment models.Assignment, perm models.Permission) bool {
+	role := models.UserRole(c.GetString("role"))
+	if !role.Can(perm) {
+		return false
+	}
+	if role == models.RoleAdmin || assignment.CreatedBy.String() == c.GetString("user_id") {
+		return true
+	}
+	subjectID, ok := podcastSubjectID(db, assignment.PodcastID)
+	return ok && hasSubjectPermission(c, db, subjectID, perm)
+}
+
+// canManageQuizSet: người tạo bộ quiz, hoặc quản lý được podcast chứa bộ quiz với quyền perm
+func canManageQuizSet(c *gin.Context, db *gorm.DB, quizSet models.QuizSet, perm models.Permission) bool {
+	if quizSet.CreatedBy.String() == c.GetString("user_id") {
+		return true
+	}
+	podcast := quizSet.Podcast
+	if podcast.ID == uuid.Nil {
+		if err := db.Select("id", "created_by").First(&podcast, "id = ?", quizSet.PodcastID).Error; err != nil {
+			return false
+		}
+	}
+	return canManagePodcast(c, db, podcast, perm)
+}
+
+// scopeManagedAssignments giới hạn truy vấn bài tập trong phạm vi giảng viên: tự tạo hoặc thuộc môn được cấp quyền perm
+func scopeManagedAssignments(db *gorm.DB, userID string, perm models.Permission) func(*gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("(assignments.created_by = ? OR assignments.podcast_id IN (?))", userID, grantedPodcastIDs(db, userID, perm))
+	}
+}
+
+// canAccessDocument: admin, người tải lên, hoặc quản lý được podcast tạo từ tài liệu này
+func canAccessDocument(c *gin.Context, db *gorm.DB, document models.Document) bool {
+	role := models.UserRole(c.GetString("role"))
+	if role == models.RoleAdmin || document.UserID.String() == c.GetString("user_id") {
+		return true
+	}
+	var count int64
+	db.Model(&models.Podcast{}).
+		Where("document_id = ? AND id IN (?)", document.ID, grantedPodcastIDs(db, c.GetString("user_id"), models.PermPodcastEdit)).
+		Count(&count)
+	return count > 0
+}
+
+// Quyền của tôi (để FE ẩn / hiện chức năng)
+// GET /admin/me/permissions
+func GetMyPermissions(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	role := models.UserRole(c.GetString("role"))
+
+	var members []models.SubjectMember
+	db.Preload("Subject").Where("user_id = ?", c.GetString("user_id")).Find(&members)
+
+	subjects := make([]gin.H, 0, len(members))
+	for _, m := range members {
+		subjects = append(subjects, gin.H{
+			"subject_id":   m.SubjectID,
+			"subject_name": m.Subject.Name,
+			"role":         m.Role,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"role":             role,
+		"permissions":      role.Permissions(),
+		"granted_subjects": subjects,
+	})
+}
+
+// ===== ĐỒNG GIẢNG DẠY =====
+
+// Danh sách giảng viên được cấp quyền trên môn học
+// GET /admin/subjects/:id/members
+func GetSubjectMembers(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	subjectID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID không hợp lệ"})
+		return
+	}
+	var subject models.Subject
+	if err := db.Preload("User", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id, full_name, email")
+	}).First(&subject, "id = ?", subjectID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy môn học"})
+		return
+	}
+	if !requireSubjectPermission(c, db, subjectID, models.PermSubjectEdit) {
+		return
+	}
+
+	var members []models.SubjectMember
+	if err := db.Preload("User", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id, full_name, email")
+	}).Where("subject_id = ?", subjectID).Order("created_at ASC").Find(&members).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy danh sách giảng viên"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"owner":   subject.User,
+		"members": members,
+		"total":   len(members),
+	})
+}
+
+// Cấp hoặc đổi vai trò của giảng viên trên môn học
+// POST /admin/subjects/:id/members  {email, role: co_teacher | assistant}
+func UpsertSubjectMember(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	subjectID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID không hợp lệ"})
+		return
+	}
+	var subject models.Subject
+	if err := db.First(&subject, "id = ?", subjectID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy môn học"})
+		return
+	}
+	if !requireSubjectPermission(c, db, subjectID, models.PermSubjectGrant) {
+		return
+	}
+
+	var req struct {
+		Email string `json:"email" binding:"required,email"`
+		Role  string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !models.ValidSubjectRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Vai trò không hợp lệ (co_teacher | assistant)"})
+		return
+	}
+
+	var lecturer models.User
+	if err := db.Where("LOWER(email) = LOWER(?) AND role = ?", strings.TrimSpace(req.Email), models.RoleLecturer).
+		First(&lecturer).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy giảng viên"})
+		return
+	}
+	if subject.CreatedBy != nil && *subject.CreatedBy == lecturer.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Giảng viên này là chủ sở hữu môn học"})
+		return
+	}
+
+	granterUUID, _ := uuid.Parse(c.GetString("user_id"))
+	member := models.SubjectMember{
+		SubjectID: subjectID,
+		UserID:    lecturer.ID,
+		Role:      req.Role,
+		GrantedBy: granterUUID,
+	}
+	if err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "subject_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"role", "granted_by", "updated_at"}),
+	}).Create(&member).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể cấp quyền"})
+		return
+	}
+
+	member.User = lecturer
+	member.User.Password = ""
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Đã cấp quyền cho giảng viên",
+		"member":  member,
+	})
+}
+
+// Thu hồi quyền của giảng viên trên môn học
+// DELETE /admin/subjects/:id/members/:userId
+func DeleteSubjectMember(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	subjectID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID không hợp lệ"})
+		return
+	}
+	if !requireSubjectPermission(c, db, subjectID, models.PermSubjectGrant) {
+		return
+	}
+
+	res := db.Where("subject_id = ? AND user_id = ?", subjectID, c.Param("userId")).
+		Delete(&models.SubjectMember{})
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể thu hồi quyền"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Giảng viên không có quyền trên môn học này"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Đã thu hồi quyền"})
+}
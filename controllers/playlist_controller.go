@@ -0,0 +1,840 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/vnkhanh/e-podcast-backend/models"
+	"github.com/vnkhanh/e-podcast-backend/services"
+	"github.com/vnkhanh/e-podcast-backend/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ==================== DANH SÁCH PHÁT & HÀNG CHỜ ====================
+
+const maxPlaylistItems = 200
+
+var errPlaylistFull = errors.New("danh sách phát đã đầy")
+
+// PlaylistSummary là một dòng trong danh sách playlist (không kèm podcast)
+type PlaylistSummary struct {
+	ID          uuid.UUID  `json:"id"`
+	UserID      uuid.UUID  `json:"user_id"`
+	OwnerName   string     `json:"owner_name"`
+	Kind        string     `json:"kind"`
+	SubjectID   *uuid.UUID `json:"subject_id,omitempty"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Visibility  string     `json:"visibility"`
+	ItemCount   int64      `json:"item_count"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// playlistSummaries là truy vấn gốc cho danh sách playlist kèm số podcast và tên người tạo
+func playlistSummaries(db *gorm.DB) *gorm.DB {
+	return db.Model(&models.Playlist{}).
+		Select(`playlists.id, playlists.user_id, users.full_name AS owner_name, playlists.kind, playlists.subject_id,
+			playlists.title, playlists.description, playlists.visibility, playlists.updated_at,
+			(SELECT COUNT(*) FROM playlist_items pi WHERE pi.playlist_id = playlists.id) AS item_count`).
+		Joins("JOIN users ON users.id = playlists.user_id")
+}
+
+// playlistShareURL là link chia sẻ phía frontend
+func playlistShareURL(p *models.Playlist) string {
+	if p.ShareToken == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s/playlists/shared/%s", os.Getenv("FE_BASE_URL"), *p.ShareToken)
+}
+
+// canEditPlaylist: chủ sở hữu; playlist ôn thi thì người có quyền sửa môn học cũng được sửa
+func canEditPlaylist(c *gin.Context, db *gorm.DB, p *models.Playlist) bool {
+	if p.UserID.String() == c.GetString("user_id") {
+		return true
+	}
+	return p.Kind == models.PlaylistKindRevision && p.SubjectID != nil &&
+		hasSubjectPermission(c, db, *p.SubjectID, models.PermSubjectEdit)
+}
+
+// canViewPlaylist: người sửa được, playlist ôn thi của môn đang hoạt động (công khai như trang môn học),
+// hoặc playlist chia sẻ cho lớp mà user học / phụ trách. Chia sẻ bằng link đi qua GetSharedPlaylist.
+func canViewPlaylist(c *gin.Context, db *gorm.DB, p *models.Playlist) bool {
+	if canEditPlaylist(c, db, p) {
+		return true
+	}
+	if p.Kind == models.PlaylistKindRevision {
+		if p.SubjectID == nil {
+			return false
+		}
+		var count int64
+		db.Model(&models.Subject{}).Where("id = ? AND status = ?", *p.SubjectID, true).Count(&count)
+		return count > 0
+	}
+	userID := c.GetString("user_id")
+	if userID == "" {
+		return false
+	}
+	if p.Visibility != models.PlaylistVisibilityClass {
+		return false
+	}
+	var count int64
+	db.Model(&models.PlaylistClass{}).
+		Joins("JOIN classes ON classes.id = playlist_classes.class_id").
+		Where("playlist_classes.playlist_id = ?", p.ID).
+		Where(`classes.lecturer_id = ? OR EXISTS (
+			SELECT 1 FROM class_enrollments ce WHERE ce.class_id = classes.id AND ce.user_id = ?)`, userID, userID).
+		Count(&count)
+	return count > 0
+}
+
+// loadEditablePlaylist lấy playlist :id và kiểm tra quyền sửa (hàng chờ sửa qua API /queue)
+func loadEditablePlaylist(c *gin.Context, db *gorm.DB) (*models.Playlist, bool) {
+	var playlist models.Playlist
+	if err := db.First(&playlist, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy danh sách phát"})
+		return nil, false
+	}
+	if !canEditPlaylist(c, db, &playlist) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Bạn không có quyền chỉnh sửa danh sách phát này"})
+		return nil, false
+	}
+	return &playlist, true
+}
+
+// playlistPodcastIDs là id podcast của playlist theo thứ tự
+func playlistPodcastIDs(db *gorm.DB, playlistID uuid.UUID) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := db.Model(&models.PlaylistItem{}).Where("playlist_id = ?", playlistID).
+		Order("position, created_at").Pluck("podcast_id", &ids).Error
+	return ids, err
+}
+
+// playlistCards là thẻ podcast của playlist theo thứ tự (chỉ podcast đã xuất bản) kèm tiến độ nghe của user
+func playlistCards(db *gorm.DB, userID, playlistID uuid.UUID) ([]services.RecommendedPodcast, error) {
+	ids, err := playlistPodcastIDs(db, playlistID)
+	if err != nil {
+		return nil, err
+	}
+	return services.LoadPodcastCards(db, userID, ids)
+}
+
+// respondPlaylist trả playlist kèm thẻ podcast và tiến độ nghe của user
+func respondPlaylist(c *gin.Context, db *gorm.DB, status int, p *models.Playlist) {
+	userID, _ := uuid.Parse(c.GetString("user_id"))
+	editable := canEditPlaylist(c, db, p)
+
+	var classes []models.PlaylistClass
+	db.Preload("Class").Where("playlist_id = ?", p.ID).Find(&classes)
+
+	items, err := playlistCards(db, userID, p.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tải danh sách phát"})
+		return
+	}
+
+	shareURL := ""
+	if editable {
+		shareURL = playlistShareURL(p)
+	} else {
+		p.ShareToken = nil
+	}
+
+	c.JSON(status, gin.H{
+		"playlist":  p,
+		"classes":   classes,
+		"items":     items,
+		"editable":  editable,
+		"share_url": shareURL,
+	})
+}
+
+// checkPlaylistPodcast: podcast phải đã xuất bản; playlist ôn thi chỉ nhận podcast của môn đó
+func checkPlaylistPodcast(db *gorm.DB, p *models.Playlist, podcastID uuid.UUID) error {
+	var podcast models.Podcast
+	if err := db.Select("id", "status").First(&podcast, "id = ?", podcastID).Error; err != nil || podcast.Status != "published" {
+		return errors.New("Không tìm thấy podcast " + podcastID.String())
+	}
+	if p.Kind == models.PlaylistKindRevision && p.SubjectID != nil {
+		if subjectID, ok := podcastSubjectID(db, podcastID); !ok || subjectID != *p.SubjectID {
+			return errors.New("Podcast " + podcastID.String() + " không thuộc môn học của danh sách ôn thi")
+		}
+	}
+	return nil
+}
+
+// appendPlaylistItem thêm podcast vào cuối (hoặc đầu nếu atFront); podcast đã có thì chỉ chuyển lên đầu khi atFront
+func appendPlaylistItem(tx *gorm.DB, playlistID, podcastID uuid.UUID, atFront bool) error {
+	var existing models.PlaylistItem
+	found := tx.Where("playlist_id = ? AND podcast_id = ?", playlistID, podcastID).Take(&existing).Error == nil
+	if found && !atFront {
+		return nil
+	}
+	if !found {
+		var count int64
+		tx.Model(&models.PlaylistItem{}).Where("playlist_id = ?", playlistID).Count(&count)
+		if count >= maxPlaylistItems {
+			return errPlaylistFull
+		}
+	}
+
+	position := 0
+	if atFront {
+		if err := tx.Model(&models.PlaylistItem{}).Where("playlist_id = ?", playlistID).
+			UpdateColumn("position", gorm.Expr("position + 1")).Error; err != nil {
+			return err
+		}
+	} else {
+		tx.Model(&models.PlaylistItem{}).Where("playlist_id = ?", playlistID).
+			Select("COALESCE(MAX(position) + 1, 0)").Scan(&position)
+	}
+
+	if found {
+		return tx.Model(&existing).UpdateColumn("position", position).Error
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.PlaylistItem{
+		PlaylistID: playlistID,
+		PodcastID:  podcastID,
+		Position:   position,
+	}).Error
+}
+
+// reorderPlaylist đặt lại thứ tự; danh sách gửi lên phải gồm đúng các podcast đang có
+func reorderPlaylist(db *gorm.DB, playlistID uuid.UUID, podcastIDs []uuid.UUID) error {
+	current, err := playlistPodcastIDs(db, playlistID)
+	if err != nil {
+		return err
+	}
+	if len(current) != len(podcastIDs) {
+		return errors.New("Danh sách sắp xếp phải gồm đúng các podcast trong danh sách phát")
+	}
+	inPlaylist := make(map[uuid.UUID]bool, len(current))
+	for _, id := range current {
+		inPlaylist[id] = true
+	}
+	for _, id := range podcastIDs {
+		if !inPlaylist[id] {
+			return errors.New("Danh sách sắp xếp phải gồm đúng các podcast trong danh sách phát")
+		}
+		delete(inPlaylist, id) // Không cho trùng
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for i, id := range podcastIDs {
+			if err := tx.Model(&models.PlaylistItem{}).
+				Where("playlist_id = ? AND podcast_id = ?", playlistID, id).
+				UpdateColumn("position", i).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&models.Playlist{}).Where("id = ?", playlistID).UpdateColumn("updated_at", gorm.Expr("NOW()")).Error
+	})
+}
+
+// setPlaylistClasses thay danh sách lớp được chia sẻ: chủ sở hữu phải học hoặc phụ trách lớp (admin thì mọi lớp)
+func setPlaylistClasses(c *gin.Context, tx *gorm.DB, p *models.Playlist, classIDs []uuid.UUID) error {
+	userID := c.GetString("user_id")
+	isAdmin := c.GetString("role") == string(models.RoleAdmin)
+
+	targets := make([]models.PlaylistClass, 0, len(classIDs))
+	seen := map[uuid.UUID]bool{}
+	for _, classID := range classIDs {
+		if seen[classID] {
+			continue
+		}
+		seen[classID] = true
+
+		var class models.Class
+		if err := tx.First(&class, "id = ?", classID).Error; err != nil {
+			return errors.New("Không tìm thấy lớp " + classID.String())
+		}
+		if !isAdmin && class.LecturerID.String() != userID {
+			var count int64
+			tx.Model(&models.ClassEnrollment{}).Where("class_id = ? AND user_id = ?", class.ID, userID).Count(&count)
+			if count == 0 {
+				return errors.New("Bạn không thuộc lớp " + class.Name)
+			}
+		}
+		targets = append(targets, models.PlaylistClass{PlaylistID: p.ID, ClassID: class.ID})
+	}
+
+	if err := tx.Where("playlist_id = ?", p.ID).Delete(&models.PlaylistClass{}).Error; err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+	return tx.Create(&targets).Error
+}
+
+type playlistRequest struct {
+	Title       *string     `json:"title"`
+	Description *string     `json:"description"`
+	Visibility  *string     `json:"visibility"` // private | link | class
+	ClassIDs    []uuid.UUID `json:"class_ids"`  // Khi visibility = class
+	PodcastIDs  []uuid.UUID `json:"podcast_ids"`
+	SubjectID   string      `json:"subject_id"` // Chỉ với playlist ôn thi
+}
+
+func validPlaylistVisibility(v string) bool {
+	return v == models.PlaylistVisibilityPrivate || v == models.PlaylistVisibilityLink || v == models.PlaylistVisibilityClass
+}
+
+// applyPlaylistRequest ghi các trường của request vào playlist (tạo mới hoặc cập nhật)
+func applyPlaylistRequest(c *gin.Context, tx *gorm.DB, p *models.Playlist, req *playlistRequest) error {
+	if req.Title != nil {
+		p.Title = strings.TrimSpace(*req.Title)
+	}
+	if p.Title == "" {
+		return errors.New("Tên danh sách phát không được để trống")
+	}
+	if req.Description != nil {
+		p.Description = strings.TrimSpace(*req.Description)
+	}
+	if req.Visibility != nil {
+		if !validPlaylistVisibility(*req.Visibility) {
+			return errors.New("visibility chỉ nhận private, link hoặc class")
+		}
+		p.Visibility = *req.Visibility
+	}
+	if p.Visibility == models.PlaylistVisibilityLink && p.ShareToken == nil {
+		token, err := utils.GenerateRandomToken()
+		if err != nil {
+			return err
+		}
+		p.ShareToken = &token
+	}
+
+	if err := tx.Save(p).Error; err != nil {
+		return err
+	}
+
+	if req.ClassIDs != nil || p.Visibility != models.PlaylistVisibilityClass {
+		classIDs := req.ClassIDs
+		if p.Visibility != models.PlaylistVisibilityClass {
+			classIDs = nil // Đổi khỏi chế độ chia sẻ lớp thì bỏ luôn các lớp
+		}
+		if err := setPlaylistClasses(c, tx, p, classIDs); err != nil {
+			return err
+		}
+	}
+
+	for _, podcastID := range req.PodcastIDs {
+		if err := checkPlaylistPodcast(tx, p, podcastID); err != nil {
+			return err
+		}
+		if err := appendPlaylistItem(tx, p.ID, podcastID, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ===== PLAYLIST CÁ NHÂN =====
+
+// Danh sách phát của tôi và danh sách được chia sẻ cho lớp của tôi
+// GET /api/user/account/playlists
+func GetMyPlaylists(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userID := c.GetString("user_id")
+
+	var mine []PlaylistSummary
+	if err := playlistSummaries(db).
+		Where("playlists.user_id = ? AND playlists.kind = ?", userID, models.PlaylistKindPersonal).
+		Order("playlists.updated_at DESC").
+		Scan(&mine).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy danh sách phát"})
+		return
+	}
+
+	var shared []PlaylistSummary
+	playlistSummaries(db).
+		Where("playlists.user_id <> ? AND playlists.visibility = ?", userID, models.PlaylistVisibilityClass).
+		Where(`playlists.id IN (
+			SELECT pc.playlist_id FROM playlist_classes pc
+			JOIN classes cl ON cl.id = pc.class_id
+			WHERE NOT cl.is_archived AND (cl.lecturer_id = ? OR EXISTS (
+				SELECT 1 FROM class_enrollments ce WHERE ce.class_id = cl.id AND ce.user_id = ?)))`, userID, userID).
+		Order("playlists.updated_at DESC").
+		Scan(&shared)
+
+	c.JSON(http.StatusOK, gin.H{
+		"playlists": mine,
+		"shared":    shared,
+	})
+}
+
+// Tạo danh sách phát
+// POST /api/user/account/playlists  {title, description, visibility, class_ids, podcast_ids}
+func CreatePlaylist(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req playlistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	playlist := models.Playlist{
+		UserID:     userID,
+		Kind:       models.PlaylistKindPersonal,
+		Visibility: models.PlaylistVisibilityPrivate,
+	}
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		return applyPlaylistRequest(c, tx, &playlist, &req)
+	}); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	respondPlaylist(c, db, http.StatusCreated, &playlist)
+}
+
+// Xem danh sách phát (chủ sở hữu, lớp được chia sẻ, hoặc playlist ôn thi của môn)
+// GET /api/user/playlists/:id
+func GetPlaylist(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	var playlist models.Playlist
+	if err := db.First(&playlist, "id = ? AND kind <> ?", c.Param("id"), models.PlaylistKindQueue).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy danh sách phát"})
+		return
+	}
+	if !canViewPlaylist(c, db, &playlist) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Bạn không có quyền xem danh sách phát này"})
+		return
+	}
+
+	respondPlaylist(c, db, http.StatusOK, &playlist)
+}
+
+// Xem danh sách phát qua link chia sẻ
+// GET /api/user/playlists/shared/:token
+func GetSharedPlaylist(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	var playlist models.Playlist
+	if err := db.First(&playlist, "share_token = ? AND visibility = ?", c.Param("token"), models.PlaylistVisibilityLink).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Link chia sẻ không tồn tại hoặc đã bị thu hồi"})
+		return
+	}
+
+	respondPlaylist(c, db, http.StatusOK, &playlist)
+}
+
+// Cập nhật danh sách phát (tên, mô tả, chia sẻ); podcast_ids nếu có sẽ được thêm vào cuối
+// PUT /api/user/account/playlists/:id  (giảng viên: PUT /admin/playlists/:id)
+func UpdatePlaylist(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	playlist, ok := loadEditablePlaylist(c, db)
+	if !ok {
+		return
+	}
+	if playlist.Kind == models.PlaylistKindQueue {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Hàng chờ không đổi tên hay chia sẻ được"})
+		return
+	}
+
+	var req playlistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if playlist.Kind == models.PlaylistKindRevision {
+		req.Visibility, req.ClassIDs = nil, nil // Playlist ôn thi luôn hiển thị trên trang môn học
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		return applyPlaylistRequest(c, tx, playlist, &req)
+	}); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	respondPlaylist(c, db, http.StatusOK, playlist)
+}
+
+// Xóa danh sách phát
+// DELETE /api/user/account/playlists/:id  (giảng viên: DELETE /admin/playlists/:id)
+func DeletePlaylist(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	playlist, ok := loadEditablePlaylist(c, db)
+	if !ok {
+		return
+	}
+	if err := db.Delete(playlist).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể xóa danh sách phát"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Đã xóa danh sách phát"})
+}
+
+// Thêm podcast vào danh sách phát
+// POST /api/user/account/playlists/:id/items  {podcast_id, at_front}
+func AddPlaylistItem(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	playlist, ok := loadEditablePlaylist(c, db)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		PodcastID uuid.UUID `json:"podcast_id" binding:"required"`
+		AtFront   bool      `json:"at_front"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := checkPlaylistPodcast(db, playlist, req.PodcastID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := appendPlaylistItem(tx, playlist.ID, req.PodcastID, req.AtFront); err != nil {
+			return err
+		}
+		return tx.Model(playlist).UpdateColumn("updated_at", gorm.Expr("NOW()")).Error
+	}); err != nil {
+		if errors.Is(err, errPlaylistFull) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Danh sách phát tối đa %d podcast", maxPlaylistItems)})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể thêm podcast"})
+		return
+	}
+
+	respondPlaylist(c, db, http.StatusOK, playlist)
+}
+
+// Bỏ podcast khỏi danh sách phát
+// DELETE /api/user/account/playlists/:id/items/:podcast_id
+func RemovePlaylistItem(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	playlist, ok := loadEditablePlaylist(c, db)
+	if !ok {
+		return
+	}
+	if err := db.Where("playlist_id = ? AND podcast_id = ?", playlist.ID, c.Param("podcast_id")).
+		Delete(&models.PlaylistItem{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể bỏ podcast"})
+		return
+	}
+
+	respondPlaylist(c, db, http.StatusOK, playlist)
+}
+
+// Sắp xếp lại danh sách phát
+// PUT /api/user/account/playlists/:id/items/order  {podcast_ids: [...]}
+func ReorderPlaylistItems(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	playlist, ok := loadEditablePlaylist(c, db)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		PodcastIDs []uuid.UUID `json:"podcast_ids"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := reorderPlaylist(db, playlist.ID, req.PodcastIDs); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	respondPlaylist(c, db, http.StatusOK, playlist)
+}
+
+// Tạo lại link chia sẻ (link cũ hết hiệu lực)
+// POST /api/user/account/playlists/:id/share-link
+func RegeneratePlaylistShareLink(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	playlist, ok := loadEditablePlaylist(c, db)
+	if !ok {
+		return
+	}
+	if playlist.Kind != models.PlaylistKindPersonal {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Chỉ danh sách phát cá nhân mới chia sẻ bằng link"})
+		return
+	}
+
+	token, err := utils.GenerateRandomToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tạo link chia sẻ"})
+		return
+	}
+	playlist.ShareToken = &token
+	playlist.Visibility = models.PlaylistVisibilityLink
+	if err := db.Model(playlist).Updates(map[string]interface{}{
+		"share_token": token,
+		"visibility":  models.PlaylistVisibilityLink,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tạo link chia sẻ"})
+		return
+	}
+	db.Where("playlist_id = ?", playlist.ID).Delete(&models.PlaylistClass{})
+
+	c.JSON(http.StatusOK, gin.H{
+		"share_url":   playlistShareURL(playlist),
+		"share_token": token,
+	})
+}
+
+// ===== HÀNG CHỜ "PHÁT TIẾP THEO" =====
+
+// getOrCreateQueue lấy hàng chờ của user, tạo nếu chưa có
+func getOrCreateQueue(db *gorm.DB, userID uuid.UUID) (*models.Playlist, error) {
+	var queue models.Playlist
+	err := db.Where("user_id = ? AND kind = ?", userID, models.PlaylistKindQueue).Take(&queue).Error
+	if err == nil {
+		return &queue, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	queue = models.Playlist{
+		UserID:     userID,
+		Kind:       models.PlaylistKindQueue,
+		Title:      "Phát tiếp theo",
+		Visibility: models.PlaylistVisibilityPrivate,
+	}
+	if err := db.Create(&queue).Error; err != nil {
+		// Request song song vừa tạo xong (unique index idx_user_queue)
+		if err := db.Where("user_id = ? AND kind = ?", userID, models.PlaylistKindQueue).Take(&queue).Error; err != nil {
+			return nil, err
+		}
+	}
+	return &queue, nil
+}
+
+func respondQueue(c *gin.Context, db *gorm.DB, userID uuid.UUID, queue *models.Playlist) {
+	items, err := playlistCards(db, userID, queue.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tải hàng chờ"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"queue_id": queue.ID,
+		"items":    items,
+		"total":    len(items),
+	})
+}
+
+// loadMyQueue đọc user hiện tại và hàng chờ của họ
+func loadMyQueue(c *gin.Context, db *gorm.DB) (uuid.UUID, *models.Playlist, bool) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return uuid.Nil, nil, false
+	}
+	queue, err := getOrCreateQueue(db, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy hàng chờ"})
+		return uuid.Nil, nil, false
+	}
+	return userID, queue, true
+}
+
+// Hàng chờ phát tiếp theo
+// GET /api/user/account/queue
+func GetQueue(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	userID, queue, ok := loadMyQueue(c, db)
+	if !ok {
+		return
+	}
+	respondQueue(c, db, userID, queue)
+}
+
+// Thêm vào hàng chờ: mặc định cuối hàng, play_next = phát ngay sau bài hiện tại
+// POST /api/user/account/queue  {podcast_id, play_next}
+func AddToQueue(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	userID, queue, ok := loadMyQueue(c, db)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		PodcastID uuid.UUID `json:"podcast_id" binding:"required"`
+		PlayNext  bool      `json:"play_next"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := checkPlaylistPodcast(db, queue, req.PodcastID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		return appendPlaylistItem(tx, queue.ID, req.PodcastID, req.PlayNext)
+	}); err != nil {
+		if errors.Is(err, errPlaylistFull) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Hàng chờ tối đa %d podcast", maxPlaylistItems)})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể thêm vào hàng chờ"})
+		return
+	}
+
+	respondQueue(c, db, userID, queue)
+}
+
+// Bỏ podcast khỏi hàng chờ (player gọi khi bắt đầu phát bài đó)
+// DELETE /api/user/account/queue/:podcast_id
+func RemoveFromQueue(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	userID, queue, ok := loadMyQueue(c, db)
+	if !ok {
+		return
+	}
+	db.Where("playlist_id = ? AND podcast_id = ?", queue.ID, c.Param("podcast_id")).Delete(&models.PlaylistItem{})
+
+	respondQueue(c, db, userID, queue)
+}
+
+// Sắp xếp lại hàng chờ
+// PUT /api/user/account/queue/order  {podcast_ids: [...]}
+func ReorderQueue(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	userID, queue, ok := loadMyQueue(c, db)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		PodcastIDs []uuid.UUID `json:"podcast_ids"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := reorderPlaylist(db, queue.ID, req.PodcastIDs); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	respondQueue(c, db, userID, queue)
+}
+
+// Xóa hết hàng chờ
+// DELETE /api/user/account/queue
+func ClearQueue(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	userID, queue, ok := loadMyQueue(c, db)
+	if !ok {
+		return
+	}
+	db.Where("playlist_id = ?", queue.ID).Delete(&models.PlaylistItem{})
+
+	respondQueue(c, db, userID, queue)
+}
+
+// ===== PLAYLIST ÔN THI (GIẢNG VIÊN) =====
+
+// revisionPlaylists là danh sách ôn thi của một môn (hiển thị trên trang môn học)
+func revisionPlaylists(db *gorm.DB, subjectID uuid.UUID) []PlaylistSummary {
+	var out []PlaylistSummary
+	playlistSummaries(db).
+		Where("playlists.kind = ? AND playlists.subject_id = ?", models.PlaylistKindRevision, subjectID).
+		Order("playlists.created_at").
+		Scan(&out)
+	return out
+}
+
+// Danh sách ôn thi các môn tôi được sửa (admin: mọi môn)
+// GET /admin/playlists?subject_id=
+func GetRevisionPlaylists(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	query := playlistSummaries(db).Where("playlists.kind = ?", models.PlaylistKindRevision)
+	if models.UserRole(c.GetString("role")) != models.RoleAdmin {
+		query = query.Where("playlists.subject_id IN (?)", grantedSubjectIDs(db, c.GetString("user_id"), models.PermSubjectEdit))
+	}
+	if subjectID := c.Query("subject_id"); subjectID != "" {
+		query = query.Where("playlists.subject_id = ?", subjectID)
+	}
+
+	var out []PlaylistSummary
+	if err := query.Order("playlists.updated_at DESC").Scan(&out).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy danh sách ôn thi"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"playlists": out,
+		"total":     len(out),
+	})
+}
+
+// Tạo danh sách ôn thi cho môn học
+// POST /admin/playlists  {subject_id, title, description, podcast_ids}
+func CreateRevisionPlaylist(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	var req playlistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	subjectID, err := uuid.Parse(req.SubjectID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "subject_id không hợp lệ"})
+		return
+	}
+	if !requireSubjectPermission(c, db, subjectID, models.PermSubjectEdit) {
+		return
+	}
+
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	playlist := models.Playlist{
+		UserID:     userID,
+		Kind:       models.PlaylistKindRevision,
+		SubjectID:  &subjectID,
+		Visibility: models.PlaylistVisibilityPrivate,
+	}
+	req.Visibility, req.ClassIDs = nil, nil
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		return applyPlaylistRequest(c, tx, &playlist, &req)
+	}); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	respondPlaylist(c, db, http.StatusCreated, &playlist)
+}
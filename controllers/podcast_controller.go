@@ -1,10 +1,12 @@
 package controllers
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -57,6 +59,9 @@ func CreatePodcastWithUpload(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Không tìm thấy chương"})
 			return
 		}
+		if !requireSubjectPermission(c, db, chapter.SubjectID, models.PermPodcastCreate) {
+			return
+		}
 	} else if subjectIDStr != "" && chapterTitle != "" {
 		subjectUUID, err := uuid.Parse(subjectIDStr)
 		if err != nil {
@@ -70,6 +75,9 @@ func CreatePodcastWithUpload(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Môn học không tồn tại"})
 			return
 		}
+		if !requireSubjectPermission(c, db, subjectUUID, models.PermPodcastCreate) {
+			return
+		}
 
 		// Tìm chương trong môn học này
 		if err := db.Where("subject_id = ? AND LOWER(title) = LOWER(?)", subjectUUID, chapterTitle).
@@ -163,7 +171,7 @@ func CreatePodcastWithUpload(c *gin.Context) {
 	}
 	docUUID, _ := uuid.Parse(docIDStr)
 
-	durationFloat, err := services.GetMP3DurationFromURL(audioURL)
+	durationFloat, audioSize, err := services.GetMP3InfoFromURL(audioURL)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tính thời lượng", "details": err.Error()})
 		return
@@ -179,6 +187,7 @@ func CreatePodcastWithUpload(c *gin.Context) {
 		Description: description,
 		AudioURL:    audioURL,
 		DurationSec: totalSeconds,
+		AudioSize:   audioSize,
 		Summary:     summary,
 		CoverImage:  coverImage,
 		Status:      "draft",
@@ -296,8 +305,8 @@ func GetPodcasts(c *gin.Context) {
 	}
 
 	// --- Phân quyền ---
-	if role == string(models.RoleLecturer) { // giảng viên chỉ thấy podcast của mình
-		query = query.Where("created_by = ?", userUUID)
+	if role == string(models.RoleLecturer) { // giảng viên chỉ thấy podcast của mình hoặc thuộc môn được cấp quyền
+		query = query.Where("(podcasts.created_by = ? OR podcasts.id IN (?))", userUUID, grantedPodcastIDs(db, userIDStr, models.PermPodcastEdit))
 	} else if role == string(models.RoleAdmin) {
 		// admin: xem tất cả
 	}
@@ -484,6 +493,10 @@ func DeletePodcast(c *gin.Context) {
 		}
 		return
 	}
+	if !canManagePodcast(c, db, podcast, models.PermPodcastDelete) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Bạn không có quyền xóa podcast này"})
+		return
+	}
 
 	// Kiểm tra có podcast khác sử dụng cùng document không
 	var otherCount int64
@@ -581,6 +594,10 @@ func UpdatePodcast(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy podcast"})
 		return
 	}
+	if !canManagePodcast(c, db, podcast, models.PermPodcastEdit) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Bạn không có quyền chỉnh sửa podcast này"})
+		return
+	}
 
 	// === 1 Nhận các field text ===
 	title := c.PostForm("title")
@@ -588,6 +605,12 @@ func UpdatePodcast(c *gin.Context) {
 	status := c.PostForm("status")
 	summary := c.PostForm("summary")
 
+	// Công bố / lưu trữ cần quyền podcast.publish (trợ giảng chỉ soạn bản nháp)
+	if status != "" && status != podcast.Status && !canManagePodcast(c, db, podcast, models.PermPodcastPublish) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Bạn không có quyền thay đổi trạng thái công bố của podcast"})
+		return
+	}
+
 	// === 2 Xử lý Chapter (cho phép cập nhật hoặc tự tạo mới) ===
 	chapterIDStr := c.PostForm("chapter_id")
 	subjectIDStr := c.PostForm("subject_id")
@@ -604,6 +627,10 @@ func UpdatePodcast(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Không tìm thấy chương"})
 			return
 		}
+		// Chuyển sang chương của môn khác thì cũng phải có quyền trên môn đó
+		if !requireSubjectPermission(c, db, chapter.SubjectID, models.PermPodcastEdit) {
+			return
+		}
 		podcast.ChapterID = chapter.ID
 	} else if subjectIDStr != "" && chapterTitle != "" {
 		subjectUUID, err := uuid.Parse(subjectIDStr)
@@ -611,6 +638,9 @@ func UpdatePodcast(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "subject_id không hợp lệ"})
 			return
 		}
+		if !requireSubjectPermission(c, db, subjectUUID, models.PermPodcastEdit) {
+			return
+		}
 
 		// Tìm hoặc tạo chương mới
 		if err := db.Where("subject_id = ? AND LOWER(title) = LOWER(?)", subjectUUID, chapterTitle).
@@ -664,6 +694,15 @@ func UpdatePodcast(c *gin.Context) {
 	if summary != "" {
 		podcast.Summary = summary
 	}
+	// Mốc chương dạng JSON [{"start_sec": 0, "title": "..."}], gửi "[]" để xóa
+	if raw, ok := c.GetPostForm("chapter_markers"); ok {
+		markers, err := parseChapterMarkers(raw, podcast.DurationSec)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		podcast.ChapterMarkers = markers
+	}
 
 	podcast.UpdatedBy = &userUUID
 	podcast.UpdatedAt = time.Now()
@@ -721,6 +760,28 @@ func UpdatePodcast(c *gin.Context) {
 	})
 }
 
+// parseChapterMarkers đọc và kiểm tra mốc chương: tiêu đề không rỗng, mốc không trùng và nằm trong thời lượng
+func parseChapterMarkers(raw string, durationSec int) (models.ChapterMarkerList, error) {
+	var markers models.ChapterMarkerList
+	if err := json.Unmarshal([]byte(raw), &markers); err != nil {
+		return nil, errors.New("chapter_markers không hợp lệ")
+	}
+	sort.Slice(markers, func(i, j int) bool { return markers[i].StartSec < markers[j].StartSec })
+	for i := range markers {
+		markers[i].Title = strings.TrimSpace(markers[i].Title)
+		if markers[i].Title == "" {
+			return nil, errors.New("Mốc chương phải có tiêu đề")
+		}
+		if markers[i].StartSec < 0 || (durationSec > 0 && markers[i].StartSec >= durationSec) {
+			return nil, fmt.Errorf("Mốc chương %q nằm ngoài thời lượng podcast", markers[i].Title)
+		}
+		if i > 0 && markers[i].StartSec == markers[i-1].StartSec {
+			return nil, fmt.Errorf("Hai mốc chương cùng bắt đầu ở giây %d", markers[i].StartSec)
+		}
+	}
+	return markers, nil
+}
+
 /*============= USER =============*/
 // Lấy danh sách podcast theo slug category (chỉ podcast đã publish)
 func GetPodcastsByCategory(c *gin.Context) {
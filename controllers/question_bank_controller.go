@@ -0,0 +1,691 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/vnkhanh/e-podcast-backend/models"
+	"gorm.io/gorm"
+)
+
+// ==================== NGÂN HÀNG CÂU HỎI ====================
+
+// loadOwnedBank lấy ngân hàng câu hỏi và kiểm tra quyền: người tạo, hoặc có quyền soạn bài tập trên môn học của ngân hàng
+func loadOwnedBank(c *gin.Context, db *gorm.DB, bankID string) (*models.QuestionBank, bool) {
+	bankUUID, err := uuid.Parse(bankID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID ngân hàng không hợp lệ"})
+		return nil, false
+	}
+
+	var bank models.QuestionBank
+	if err := db.First(&bank, "id = ?", bankUUID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy ngân hàng câu hỏi"})
+		return nil, false
+	}
+
+	if bank.CreatedBy.String() != c.GetString("user_id") && !hasSubjectPermission(c, db, bank.SubjectID, models.PermAssignmentManage) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Không có quyền với ngân hàng câu hỏi này"})
+		return nil, false
+	}
+
+	return &bank, true
+}
+
+// Danh sách ngân hàng câu hỏi
+// GET /admin/question-banks?subject_id=&chapter_id=&search=
+func GetQuestionBanks(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	role := c.GetString("role")
+	userUUID, _ := uuid.Parse(c.GetString("user_id"))
+
+	query := db.Model(&models.QuestionBank{}).
+		Preload("Subject").
+		Preload("Chapter")
+
+	if role == string(models.RoleLecturer) {
+		query = query.Where("(created_by = ? OR subject_id IN (?))", userUUID,
+			grantedSubjectIDs(db, userUUID.String(), models.PermAssignmentManage))
+	}
+	if subjectID := c.Query("subject_id"); subjectID != "" {
+		query = query.Where("subject_id = ?", subjectID)
+	}
+	if chapterID := c.Query("chapter_id"); chapterID != "" {
+		query = query.Where("chapter_id = ?", chapterID)
+	}
+	if search := c.Query("search"); search != "" {
+		query = query.Where("title ILIKE ?", "%"+search+"%")
+	}
+
+	var banks []models.QuestionBank
+	if err := query.Order("created_at DESC").Find(&banks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy danh sách ngân hàng câu hỏi"})
+		return
+	}
+
+	// Số câu hỏi của từng ngân hàng
+	type bankCount struct {
+		BankID uuid.UUID
+		Total  int64
+	}
+	var counts []bankCount
+	db.Model(&models.BankQuestion{}).
+		Select("bank_id, COUNT(*) AS total").
+		Group("bank_id").
+		Scan(&counts)
+
+	countMap := map[uuid.UUID]int64{}
+	for _, ct := range counts {
+		countMap[ct.BankID] = ct.Total
+	}
+
+	type BankWithCount struct {
+		models.QuestionBank
+		QuestionCount int64 `json:"question_count"`
+	}
+
+	result := make([]BankWithCount, 0, len(banks))
+	for _, b := range banks {
+		result = append(result, BankWithCount{QuestionBank: b, QuestionCount: countMap[b.ID]})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"banks": result,
+		"total": len(result),
+	})
+}
+
+// Tạo ngân hàng câu hỏi
+// POST /admin/question-banks
+func CreateQuestionBank(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userUUID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id không hợp lệ"})
+		return
+	}
+
+	var req struct {
+		SubjectID   uuid.UUID  `json:"subject_id" binding:"required"`
+		ChapterID   *uuid.UUID `json:"chapter_id"`
+		Title       string     `json:"title" binding:"required"`
+		Description string     `json:"description"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := db.First(&models.Subject{}, "id = ?", req.SubjectID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy môn học"})
+		return
+	}
+	if !requireSubjectPermission(c, db, req.SubjectID, models.PermAssignmentManage) {
+		return
+	}
+	if req.ChapterID != nil {
+		if err := db.First(&models.Chapter{}, "id = ? AND subject_id = ?", *req.ChapterID, req.SubjectID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Chương không thuộc môn học"})
+			return
+		}
+	}
+
+	bank := models.QuestionBank{
+		SubjectID:   req.SubjectID,
+		ChapterID:   req.ChapterID,
+		Title:       strings.TrimSpace(req.Title),
+		Description: req.Description,
+		CreatedBy:   userUUID,
+	}
+	if err := db.Create(&bank).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tạo ngân hàng câu hỏi"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Tạo ngân hàng câu hỏi thành công",
+		"bank":    bank,
+	})
+}
+
+// Chi tiết ngân hàng + câu hỏi + thống kê theo chương / độ khó
+// GET /admin/question-banks/:id
+func GetQuestionBankDetail(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	bank, ok := loadOwnedBank(c, db, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	query := db.Preload("Options", func(db *gorm.DB) *gorm.DB {
+		return db.Order("bank_options.sort_order ASC")
+	}).Where("bank_id = ?", bank.ID)
+
+	if difficulty := c.Query("difficulty"); difficulty != "" {
+		query = query.Where("difficulty = ?", difficulty)
+	}
+	if chapterID := c.Query("chapter_id"); chapterID != "" {
+		query = query.Where("chapter_id = ?", chapterID)
+	}
+
+	var questions []models.BankQuestion
+	if err := query.Order("created_at ASC").Find(&questions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy câu hỏi"})
+		return
+	}
+
+	// Thống kê giúp giảng viên đặt rule
+	type BankStat struct {
+		ChapterID  *uuid.UUID `json:"chapter_id"`
+		Difficulty string     `json:"difficulty"`
+		Total      int64      `json:"total"`
+	}
+	var stats []BankStat
+	db.Model(&models.BankQuestion{}).
+		Select("chapter_id, difficulty, COUNT(*) AS total").
+		Where("bank_id = ?", bank.ID).
+		Group("chapter_id, difficulty").
+		Scan(&stats)
+
+	db.Preload("Subject").Preload("Chapter").First(bank, "id = ?", bank.ID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"bank":      bank,
+		"questions": questions,
+		"stats":     stats,
+	})
+}
+
+// Cập nhật ngân hàng
+// PUT /admin/question-banks/:id
+func UpdateQuestionBank(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	bank, ok := loadOwnedBank(c, db, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var req struct {
+		ChapterID   *uuid.UUID `json:"chapter_id"`
+		Title       string     `json:"title"`
+		Description string     `json:"description"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.ChapterID != nil {
+		if err := db.First(&models.Chapter{}, "id = ? AND subject_id = ?", *req.ChapterID, bank.SubjectID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Chương không thuộc môn học"})
+			return
+		}
+	}
+
+	if strings.TrimSpace(req.Title) != "" {
+		bank.Title = strings.TrimSpace(req.Title)
+	}
+	bank.Description = req.Description
+	bank.ChapterID = req.ChapterID
+
+	if err := db.Save(bank).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể cập nhật ngân hàng câu hỏi"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Cập nhật ngân hàng câu hỏi thành công",
+		"bank":    bank,
+	})
+}
+
+// Xóa ngân hàng (các câu đã rút vào bài làm vẫn được giữ lại)
+// DELETE /admin/question-banks/:id
+func DeleteQuestionBank(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	bank, ok := loadOwnedBank(c, db, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var ruleCount int64
+	db.Model(&models.AssignmentRule{}).Where("bank_id = ?", bank.ID).Count(&ruleCount)
+	if ruleCount > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Ngân hàng đang được dùng trong rule của bài tập, hãy xóa rule trước"})
+		return
+	}
+
+	if err := db.Delete(bank).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể xóa ngân hàng câu hỏi"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Xóa ngân hàng câu hỏi thành công"})
+}
+
+// Body tạo / sửa câu hỏi ngân hàng
+type bankQuestionRequest struct {
+	ChapterID   *uuid.UUID          `json:"chapter_id"`
+	Question    string              `json:"question"`
+	Type        string              `json:"type"`
+	Difficulty  string              `json:"difficulty"`
+	Explanation string              `json:"explanation"`
+	Rubric      string              `json:"rubric"`
+	Points      float64             `json:"points"`
+	Options     []models.BankOption `json:"options"`
+}
+
+// validate chuẩn hóa và kiểm tra câu hỏi theo cùng quy tắc với câu hỏi bài tập
+func (r *bankQuestionRequest) validate() (string, error) {
+	if strings.TrimSpace(r.Question) == "" {
+		return "", errors.New("Nội dung câu hỏi không được để trống")
+	}
+
+	qType, err := normalizeQuestionType(r.Type)
+	if err != nil {
+		return "", err
+	}
+
+	opts := make([]models.AssignmentOption, 0, len(r.Options))
+	for _, o := range r.Options {
+		opts = append(opts, models.AssignmentOption{
+			OptionText: o.OptionText,
+			MatchText:  o.MatchText,
+			IsCorrect:  o.IsCorrect,
+			SortOrder:  o.SortOrder,
+		})
+	}
+	if err := validateQuestionOptions(qType, opts); err != nil {
+		return "", err
+	}
+
+	if r.Points <= 0 {
+		r.Points = 1
+	}
+	if r.Difficulty == "" {
+		r.Difficulty = "medium"
+	}
+	return qType, nil
+}
+
+// Thêm câu hỏi vào ngân hàng
+// POST /admin/question-banks/:id/questions
+func CreateBankQuestion(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	bank, ok := loadOwnedBank(c, db, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var req bankQuestionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	qType, err := req.validate()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Mặc định câu hỏi thuộc chương của ngân hàng
+	chapterID := req.ChapterID
+	if chapterID == nil {
+		chapterID = bank.ChapterID
+	}
+
+	q := models.BankQuestion{
+		BankID:      bank.ID,
+		ChapterID:   chapterID,
+		Question:    req.Question,
+		Type:        qType,
+		Difficulty:  req.Difficulty,
+		Explanation: req.Explanation,
+		Rubric:      req.Rubric,
+		Points:      req.Points,
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&q).Error; err != nil {
+			return err
+		}
+		for i := range req.Options {
+			req.Options[i].ID = uuid.Nil
+			req.Options[i].QuestionID = q.ID
+		}
+		if len(req.Options) > 0 {
+			if err := tx.Create(&req.Options).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tạo câu hỏi"})
+		return
+	}
+	q.Options = req.Options
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Tạo câu hỏi thành công",
+		"question": q,
+	})
+}
+
+// Sửa câu hỏi ngân hàng (đáp án được thay mới toàn bộ)
+// PUT /admin/question-banks/questions/:questionId
+func UpdateBankQuestion(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	qUUID, err := uuid.Parse(c.Param("questionId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID không hợp lệ"})
+		return
+	}
+
+	var q models.BankQuestion
+	if err := db.First(&q, "id = ?", qUUID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy câu hỏi"})
+		return
+	}
+	if _, ok := loadOwnedBank(c, db, q.BankID.String()); !ok {
+		return
+	}
+
+	var req bankQuestionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	qType, err := req.validate()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.ChapterID != nil {
+		q.ChapterID = req.ChapterID
+	}
+	q.Question = req.Question
+	q.Type = qType
+	q.Difficulty = req.Difficulty
+	q.Explanation = req.Explanation
+	q.Rubric = req.Rubric
+	q.Points = req.Points
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&q).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("question_id = ?", q.ID).Delete(&models.BankOption{}).Error; err != nil {
+			return err
+		}
+		for i := range req.Options {
+			req.Options[i].ID = uuid.Nil
+			req.Options[i].QuestionID = q.ID
+		}
+		if len(req.Options) > 0 {
+			if err := tx.Create(&req.Options).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể cập nhật câu hỏi"})
+		return
+	}
+	q.Options = req.Options
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Cập nhật thành công",
+		"question": q,
+	})
+}
+
+// Xóa câu hỏi ngân hàng
+// DELETE /admin/question-banks/questions/:questionId
+func DeleteBankQuestion(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	qUUID, err := uuid.Parse(c.Param("questionId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID không hợp lệ"})
+		return
+	}
+
+	var q models.BankQuestion
+	if err := db.First(&q, "id = ?", qUUID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy câu hỏi"})
+		return
+	}
+	if _, ok := loadOwnedBank(c, db, q.BankID.String()); !ok {
+		return
+	}
+
+	if err := db.Delete(&q).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể xóa câu hỏi"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Xóa câu hỏi thành công"})
+}
+
+// Sao chép câu hỏi cố định của một bài tập vào ngân hàng
+// POST /admin/question-banks/:id/import-assignment/:assignmentId
+func ImportAssignmentQuestionsToBank(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	bank, ok := loadOwnedBank(c, db, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	assUUID, err := uuid.Parse(c.Param("assignmentId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID bài tập không hợp lệ"})
+		return
+	}
+
+	var assignment models.Assignment
+	if err := db.First(&assignment, "id = ?", assUUID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy bài tập"})
+		return
+	}
+	if !canManageAssignment(c, db, assignment, models.PermAssignmentGrade) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Không có quyền với bài tập này"})
+		return
+	}
+
+	var questions []models.AssignmentQuestion
+	if err := db.Preload("Options").
+		Where("assignment_id = ? AND pooled = ?", assUUID, false).
+		Order("sort_order ASC").
+		Find(&questions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy câu hỏi"})
+		return
+	}
+
+	imported := 0
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		for _, aq := range questions {
+			bq := models.BankQuestion{
+				BankID:      bank.ID,
+				ChapterID:   bank.ChapterID,
+				Question:    aq.Question,
+				Type:        aq.Type,
+				Difficulty:  aq.Difficulty,
+				Explanation: aq.Explanation,
+				Rubric:      aq.Rubric,
+				Points:      aq.Points,
+			}
+			if err := tx.Create(&bq).Error; err != nil {
+				return err
+			}
+			for _, ao := range aq.Options {
+				bo := models.BankOption{
+					QuestionID: bq.ID,
+					OptionText: ao.OptionText,
+					MatchText:  ao.MatchText,
+					IsCorrect:  ao.IsCorrect,
+					SortOrder:  ao.SortOrder,
+				}
+				if err := tx.Create(&bo).Error; err != nil {
+					return err
+				}
+			}
+			imported++
+		}
+		return nil
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể sao chép câu hỏi"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Sao chép câu hỏi thành công",
+		"imported": imported,
+	})
+}
+
+// ==================== RULE RÚT CÂU HỎI CHO BÀI TẬP ====================
+
+// Lấy danh sách rule của bài tập
+// GET /admin/assignments/:id/rules
+func GetAssignmentRules(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	assUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID không hợp lệ"})
+		return
+	}
+
+	var assignment models.Assignment
+	if err := db.First(&assignment, "id = ?", assUUID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy bài tập"})
+		return
+	}
+	if !canManageAssignment(c, db, assignment, models.PermAssignmentGrade) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Bạn không có quyền xem bài tập này"})
+		return
+	}
+
+	var rules []models.AssignmentRule
+	if err := db.Preload("Bank").Where("assignment_id = ?", assUUID).Order("sort_order ASC").Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy rule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"rules":             rules,
+		"shuffle_questions": assignment.ShuffleQuestions,
+		"shuffle_options":   assignment.ShuffleOptions,
+		"question_count":    countPaperQuestions(db, assUUID),
+	})
+}
+
+// Thay toàn bộ rule của bài tập
+// PUT /admin/assignments/:id/rules
+func UpdateAssignmentRules(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	assUUID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID không hợp lệ"})
+		return
+	}
+
+	var assignment models.Assignment
+	if err := db.First(&assignment, "id = ?", assUUID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy bài tập"})
+		return
+	}
+	if !canManageAssignment(c, db, assignment, models.PermAssignmentManage) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Bạn không có quyền chỉnh sửa bài tập này"})
+		return
+	}
+
+	var req struct {
+		Rules []struct {
+			BankID     uuid.UUID  `json:"bank_id"`
+			ChapterID  *uuid.UUID `json:"chapter_id"`
+			Difficulty string     `json:"difficulty"`
+			Count      int        `json:"count"`
+			Points     float64    `json:"points"`
+		} `json:"rules"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rules := make([]models.AssignmentRule, 0, len(req.Rules))
+	for i, r := range req.Rules {
+		if r.Count <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Số câu của mỗi rule phải lớn hơn 0", "rule": i + 1})
+			return
+		}
+		if r.Points < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Điểm mỗi câu không hợp lệ", "rule": i + 1})
+			return
+		}
+		if _, ok := loadOwnedBank(c, db, r.BankID.String()); !ok {
+			return
+		}
+
+		// Đủ câu hỏi thỏa điều kiện hay không
+		query := db.Model(&models.BankQuestion{}).Where("bank_id = ?", r.BankID)
+		if r.ChapterID != nil {
+			query = query.Where("chapter_id = ?", *r.ChapterID)
+		}
+		if r.Difficulty != "" {
+			query = query.Where("difficulty = ?", r.Difficulty)
+		}
+		var available int64
+		query.Count(&available)
+		if int(available) < r.Count {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":     "Ngân hàng không đủ câu hỏi thỏa điều kiện của rule",
+				"rule":      i + 1,
+				"available": available,
+				"required":  r.Count,
+			})
+			return
+		}
+
+		rules = append(rules, models.AssignmentRule{
+			AssignmentID: assUUID,
+			BankID:       r.BankID,
+			ChapterID:    r.ChapterID,
+			Difficulty:   r.Difficulty,
+			Count:        r.Count,
+			Points:       r.Points,
+			SortOrder:    i + 1,
+		})
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("assignment_id = ?", assUUID).Delete(&models.AssignmentRule{}).Error; err != nil {
+			return err
+		}
+		if len(rules) > 0 {
+			return tx.Create(&rules).Error
+		}
+		return nil
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lưu rule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Cập nhật rule thành công",
+		"rules":          rules,
+		"question_count": countPaperQuestions(db, assUUID),
+	})
+}
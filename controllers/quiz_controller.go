@@ -200,6 +200,7 @@ Chỉ trả về JSON hợp lệ, không thêm bất kỳ văn bản nào khác.
 type AnswerInput struct {
 	QuestionID       uuid.UUID  `json:"question_id"`
 	SelectedOptionID *uuid.UUID `json:"option_id"`
+	TimeSpentSec     int        `json:"time_spent_sec"`
 }
 
 // Nộp bài quiz
@@ -317,6 +318,8 @@ func SubmitQuizAttempt(c *gin.Context) {
 			SelectedID: selectedID, // nếu bỏ trống = uuid.Nil
 			IsCorrect:  isCorrect,
 			AnsweredAt: time.Now(),
+
+			TimeSpentSec: max(ans.TimeSpentSec, 0),
 		}
 		db.Create(&history)
 
@@ -627,13 +630,16 @@ func GetQuizAttemptDetail(c *gin.Context) {
 // Xóa 1 quiz set cụ thể của user
 func DeleteQuizSetByCurrentUser(c *gin.Context) {
 	db := config.DB
-	userID := c.MustGet("user_id").(string)
 	quizSetID := c.Param("quizset_id")
 
 	var quizSet models.QuizSet
-	// Chỉ tìm quizset do user này tạo
-	if err := db.Where("id = ? AND created_by = ?", quizSetID, userID).First(&quizSet).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Quiz set not found or not owned by this user"})
+	if err := db.Where("id = ?", quizSetID).First(&quizSet).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Quiz set not found"})
+		return
+	}
+	// Người tạo, hoặc giảng viên quản lý podcast chứa quiz
+	if !canManageQuizSet(c, db, quizSet, models.PermPodcastEdit) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Quiz set not owned by this user"})
 		return
 	}
 
@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/vnkhanh/e-podcast-backend/services"
+	"gorm.io/gorm"
+)
+
+// ==================== GỢI Ý CÁ NHÂN HÓA (TRANG CHỦ) ====================
+
+// trendingSince: kệ đang thịnh hành tính trên 30 ngày thống kê gần nhất
+func trendingSince() time.Time {
+	return analyticsDay(time.Now()).AddDate(0, 0, -30)
+}
+
+func shelfLimit(c *gin.Context) int {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 {
+		return 10
+	}
+	if limit > 30 {
+		return 30
+	}
+	return limit
+}
+
+// Trang chủ cá nhân hóa: nghe tiếp, bài tiếp theo của từng môn, ôn lại theo quiz, vì bạn đã nghe, đang thịnh hành.
+// Chưa đăng nhập chỉ có kệ đang thịnh hành.
+// GET /api/user/home?limit=10
+func GetHomeFeed(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	limit := shelfLimit(c)
+
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		trending, err := services.TrendingPodcasts(db, uuid.Nil, trendingSince(), limit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tải gợi ý"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"personalized": false,
+			"trending":     trending,
+		})
+		return
+	}
+
+	continueListening, err := services.ContinueListening(db, userID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tải gợi ý"})
+		return
+	}
+	nextInChapter, err := services.NextInChapter(db, userID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tải gợi ý"})
+		return
+	}
+	review, err := services.ReviewByQuiz(db, userID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tải gợi ý"})
+		return
+	}
+	becauseYouListened, err := services.BecauseYouListened(db, userID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tải gợi ý"})
+		return
+	}
+	trending, err := services.TrendingPodcasts(db, userID, trendingSince(), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tải gợi ý"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"personalized":         true,
+		"continue_listening":   continueListening,
+		"next_in_chapter":      nextInChapter,
+		"review":               review,
+		"because_you_listened": becauseYouListened,
+		"trending":             trending,
+	})
+}
+
+// Podcast tương tự (người nghe podcast này còn nghe gì)
+// GET /api/user/podcasts/:id/similar?limit=10
+func GetSimilarPodcasts(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	podcastID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID podcast không hợp lệ"})
+		return
+	}
+	userID, _ := uuid.Parse(c.GetString("user_id"))
+
+	cards, err := services.SimilarPodcasts(db, userID, podcastID, shelfLimit(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tải podcast tương tự"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"podcast_id": podcastID,
+		"podcasts":   cards,
+	})
+}
@@ -0,0 +1,237 @@
+package controllers
+
+import (
+	"context"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/crewjam/saml"
+	"github.com/crewjam/saml/samlsp"
+	"github.com/gin-gonic/gin"
+	"github.com/vnkhanh/e-podcast-backend/config"
+	"github.com/vnkhanh/e-podcast-backend/models"
+)
+
+// ===== SAML 2.0 =====
+
+var (
+	samlMu     sync.Mutex
+	samlCached *saml.ServiceProvider
+)
+
+func samlEnabled() bool {
+	return (os.Getenv("SAML_IDP_METADATA_URL") != "" || os.Getenv("SAML_IDP_METADATA_FILE") != "") &&
+		os.Getenv("SAML_SP_BASE_URL") != ""
+}
+
+// loadSAMLIDPMetadata đọc metadata của IdP từ URL hoặc file (file tiện cho IdP giả lập khi chạy local)
+func loadSAMLIDPMetadata(ctx context.Context) (*saml.EntityDescriptor, error) {
+	if path := os.Getenv("SAML_IDP_METADATA_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return samlsp.ParseMetadata(data)
+	}
+	metadataURL, err := url.Parse(os.Getenv("SAML_IDP_METADATA_URL"))
+	if err != nil {
+		return nil, err
+	}
+	return samlsp.FetchMetadata(ctx, http.DefaultClient, *metadataURL)
+}
+
+// getSAMLServiceProvider dựng service provider từ cặp khóa / chứng chỉ SP và metadata IdP, cache sau lần đầu
+func getSAMLServiceProvider(ctx context.Context) (*saml.ServiceProvider, error) {
+	if !samlEnabled() {
+		return nil, errors.New("Chưa cấu hình đăng nhập SAML")
+	}
+
+	samlMu.Lock()
+	defer samlMu.Unlock()
+	if samlCached != nil {
+		return samlCached, nil
+	}
+
+	keyPair, err := tls.LoadX509KeyPair(os.Getenv("SAML_SP_CERT_FILE"), os.Getenv("SAML_SP_KEY_FILE"))
+	if err != nil {
+		return nil, fmt.Errorf("không thể đọc khóa SAML của SP: %w", err)
+	}
+	cert, err := x509.ParseCertificate(keyPair.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("chứng chỉ SAML của SP không hợp lệ: %w", err)
+	}
+	signer, ok := keyPair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("khóa SAML của SP không hỗ trợ ký")
+	}
+
+	idpMetadata, err := loadSAMLIDPMetadata(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("không thể đọc metadata của IdP: %w", err)
+	}
+
+	baseURL := strings.TrimRight(os.Getenv("SAML_SP_BASE_URL"), "/")
+	metadataURL, _ := url.Parse(baseURL + "/api/auth/sso/saml/metadata")
+	acsURL, _ := url.Parse(baseURL + "/api/auth/sso/saml/acs")
+
+	samlCached = &saml.ServiceProvider{
+		EntityID:    os.Getenv("SAML_SP_ENTITY_ID"), // Để trống thì dùng URL metadata
+		Key:         signer,
+		Certificate: cert,
+		MetadataURL: *metadataURL,
+		AcsURL:      *acsURL,
+		IDPMetadata: idpMetadata,
+	}
+	return samlCached, nil
+}
+
+// samlAttribute lấy mọi giá trị của attribute theo Name hoặc FriendlyName
+func samlAttribute(assertion *saml.Assertion, name string) []string {
+	if name == "" {
+		return nil
+	}
+	var values []string
+	for _, stmt := range assertion.AttributeStatements {
+		for _, attr := range stmt.Attributes {
+			if attr.Name != name && attr.FriendlyName != name {
+				continue
+			}
+			for _, v := range attr.Values {
+				if v.Value != "" {
+					values = append(values, strings.TrimSpace(v.Value))
+				}
+			}
+		}
+	}
+	return values
+}
+
+func envOrDefault(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// Metadata của SP để đăng ký với IdP của trường
+// GET /auth/sso/saml/metadata
+func SAMLMetadata(c *gin.Context) {
+	sp, err := getSAMLServiceProvider(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	buf, err := xml.MarshalIndent(sp.Metadata(), "", "  ")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tạo metadata"})
+		return
+	}
+	c.Data(http.StatusOK, "application/samlmetadata+xml", buf)
+}
+
+// Chuyển hướng sang IdP với AuthnRequest (HTTP-Redirect binding)
+// GET /auth/sso/saml/login?redirect=/duong-dan-fe
+func SAMLLogin(c *gin.Context) {
+	sp, err := getSAMLServiceProvider(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+
+	authnRequest, err := sp.MakeAuthenticationRequest(
+		sp.GetSSOBindingLocation(saml.HTTPRedirectBinding), saml.HTTPRedirectBinding, saml.HTTPPostBinding)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tạo yêu cầu đăng nhập SAML"})
+		return
+	}
+
+	// RelayState là state một lần dùng, gắn với ID của AuthnRequest để chống replay / IdP-initiated
+	relayState, err := createSSOState(c, config.DB, models.SSOLoginState{
+		Provider:     models.SSOProviderSAML,
+		RequestID:    authnRequest.ID,
+		RedirectPath: safeRedirectPath(c.DefaultQuery("redirect", "/")),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tạo phiên đăng nhập SSO"})
+		return
+	}
+
+	redirectURL, err := authnRequest.Redirect(relayState, sp)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tạo yêu cầu đăng nhập SAML"})
+		return
+	}
+	c.Redirect(http.StatusFound, redirectURL.String())
+}
+
+// IdP POST SAMLResponse về đây
+// POST /auth/sso/saml/acs
+func SAMLACS(c *gin.Context) {
+	method := "saml"
+
+	state, err := consumeSSOState(c, config.DB, c.PostForm("RelayState"), models.SSOProviderSAML)
+	if err != nil {
+		redirectSSOResult(c, nil, method, "/", err)
+		return
+	}
+
+	sp, err := getSAMLServiceProvider(c.Request.Context())
+	if err != nil {
+		redirectSSOResult(c, nil, method, state.RedirectPath, err)
+		return
+	}
+
+	profile, err := parseSAMLProfile(c.Request, sp, state)
+	if err != nil {
+		redirectSSOResult(c, nil, method, state.RedirectPath, err)
+		return
+	}
+
+	mapping, defaultRole := ssoRoleMapping("SAML")
+	user, err := provisionSSOUser(config.DB, profile, mapSSORole(profile.Groups, mapping, defaultRole))
+	redirectSSOResult(c, user, method, state.RedirectPath, err)
+}
+
+// parseSAMLProfile kiểm tra chữ ký, audience, thời hạn và InResponseTo khớp AuthnRequest đã gửi
+// rồi đọc thông tin người dùng từ assertion
+func parseSAMLProfile(r *http.Request, sp *saml.ServiceProvider, state *models.SSOLoginState) (ssoProfile, error) {
+	assertion, err := sp.ParseResponse(r, []string{state.RequestID})
+	if err != nil {
+		var invalid *saml.InvalidResponseError
+		if errors.As(err, &invalid) {
+			err = fmt.Errorf("SAMLResponse không hợp lệ: %v", invalid.PrivateErr)
+		}
+		return ssoProfile{}, err
+	}
+	if assertion.Subject == nil || assertion.Subject.NameID == nil {
+		return ssoProfile{}, errors.New("IdP không trả về NameID")
+	}
+
+	nameID := strings.TrimSpace(assertion.Subject.NameID.Value)
+	email := ""
+	if values := samlAttribute(assertion, envOrDefault("SAML_EMAIL_ATTRIBUTE", "email")); len(values) > 0 {
+		email = values[0]
+	} else if assertion.Subject.NameID.Format == string(saml.EmailAddressNameIDFormat) {
+		email = nameID
+	}
+	fullName := strings.Join(samlAttribute(assertion, envOrDefault("SAML_NAME_ATTRIBUTE", "displayName")), " ")
+
+	return ssoProfile{
+		Provider: models.SSOProviderSAML,
+		Subject:  nameID,
+		Email:    email,
+		// Assertion đã được IdP của trường ký nên email được coi là đã xác minh
+		EmailVerified: true,
+		FullName:      fullName,
+		Groups:        samlAttribute(assertion, envOrDefault("SAML_ROLE_ATTRIBUTE", "eduPersonAffiliation")),
+	}, nil
+}
@@ -0,0 +1,155 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/vnkhanh/e-podcast-backend/config"
+	"github.com/vnkhanh/e-podcast-backend/models"
+	"github.com/vnkhanh/e-podcast-backend/utils"
+)
+
+// ==================== PHIÊN ĐĂNG NHẬP ====================
+
+// sessionDevice lấy thông tin thiết bị từ request đăng nhập
+func sessionDevice(c *gin.Context, method string) utils.SessionDevice {
+	return utils.SessionDevice{
+		UserAgent:   c.Request.UserAgent(),
+		IPAddress:   c.ClientIP(),
+		LoginMethod: method,
+	}
+}
+
+// Làm mới access token bằng refresh token (refresh token được xoay vòng mỗi lần dùng)
+// POST /auth/refresh  {refresh_token}
+func RefreshToken(c *gin.Context) {
+	var req struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Thiếu refresh_token"})
+		return
+	}
+
+	tokens, user, err := utils.RotateSession(config.DB, req.RefreshToken, c.ClientIP())
+	if err != nil {
+		if user != nil && user.Status != nil && !*user.Status {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Tài khoản của bạn đã bị tạm khóa"})
+			return
+		}
+		switch {
+		case errors.Is(err, utils.ErrSessionNotFound), errors.Is(err, utils.ErrSessionRevoked),
+			errors.Is(err, utils.ErrSessionExpired), errors.Is(err, utils.ErrTokenReused):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể làm mới token"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
+		"session_id":    tokens.SessionID,
+		"user": gin.H{
+			"id":        user.ID,
+			"email":     user.Email,
+			"full_name": user.FullName,
+			"role":      user.Role,
+		},
+	})
+}
+
+// Đăng xuất phiên hiện tại
+// POST /auth/logout
+func Logout(c *gin.Context) {
+	sessionID, err := uuid.Parse(c.GetString("session_id"))
+	if err != nil {
+		// Token cũ không gắn phiên: không có gì để thu hồi phía server
+		c.JSON(http.StatusOK, gin.H{"message": "Đăng xuất thành công"})
+		return
+	}
+
+	if err := utils.RevokeSession(config.DB, sessionID, models.SessionRevokedLogout); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể đăng xuất"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Đăng xuất thành công"})
+}
+
+// Danh sách phiên đăng nhập đang hoạt động của tôi
+// GET /user/account/sessions
+func GetMySessions(c *gin.Context) {
+	userID := c.GetString("user_id")
+	currentID := c.GetString("session_id")
+
+	var sessions []models.UserSession
+	if err := config.DB.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at DESC").
+		Find(&sessions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lấy danh sách phiên đăng nhập"})
+		return
+	}
+
+	result := make([]gin.H, 0, len(sessions))
+	for _, s := range sessions {
+		result = append(result, gin.H{
+			"id":           s.ID,
+			"device_name":  s.DeviceName,
+			"user_agent":   s.UserAgent,
+			"ip_address":   s.IPAddress,
+			"login_method": s.LoginMethod,
+			"created_at":   s.CreatedAt,
+			"last_used_at": s.LastUsedAt,
+			"expires_at":   s.ExpiresAt,
+			"is_current":   s.ID.String() == currentID,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sessions": result,
+		"total":    len(result),
+	})
+}
+
+// Đăng xuất từ xa một phiên
+// DELETE /user/account/sessions/:id
+func RevokeMySession(c *gin.Context) {
+	sessionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID phiên không hợp lệ"})
+		return
+	}
+
+	var session models.UserSession
+	if err := config.DB.Where("id = ? AND user_id = ?", sessionID, c.GetString("user_id")).
+		First(&session).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy phiên đăng nhập"})
+		return
+	}
+
+	if err := utils.RevokeSession(config.DB, session.ID, models.SessionRevokedByUser); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể thu hồi phiên đăng nhập"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Đã đăng xuất thiết bị"})
+}
+
+// Đăng xuất mọi thiết bị khác
+// POST /user/account/sessions/revoke-others
+func RevokeOtherSessions(c *gin.Context) {
+	userID, _ := uuid.Parse(c.GetString("user_id"))
+
+	if err := utils.RevokeUserSessions(config.DB, userID, models.SessionRevokedByUser, c.GetString("session_id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể thu hồi phiên đăng nhập"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Đã đăng xuất khỏi các thiết bị khác"})
+}
@@ -0,0 +1,494 @@
+package controllers
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/gin-gonic/gin"
+	"github.com/vnkhanh/e-podcast-backend/config"
+	"github.com/vnkhanh/e-podcast-backend/models"
+	"github.com/vnkhanh/e-podcast-backend/utils"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+)
+
+// ==================== ĐĂNG NHẬP MỘT LẦN (SSO) ====================
+
+const ssoStateTTL = 10 * time.Minute
+
+// ssoProfile là thông tin người dùng IdP trả về, đã chuẩn hóa giữa OIDC và SAML
+type ssoProfile struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	FullName      string
+	Groups        []string // Giá trị của claim / attribute dùng để map vai trò
+}
+
+// ssoRoleMapping đọc cấu hình dạng "staff=teacher,faculty=teacher,it-admin=admin" cho provider (OIDC / SAML)
+func ssoRoleMapping(prefix string) (map[string]models.UserRole, models.UserRole) {
+	mapping := map[string]models.UserRole{}
+	for _, pair := range strings.Split(os.Getenv(prefix+"_ROLE_MAP"), ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		role := models.UserRole(strings.TrimSpace(parts[1]))
+		if role != models.RoleAdmin && role != models.RoleLecturer && role != models.RoleUser {
+			continue
+		}
+		mapping[strings.ToLower(strings.TrimSpace(parts[0]))] = role
+	}
+
+	defaultRole := models.UserRole(os.Getenv(prefix + "_DEFAULT_ROLE"))
+	if defaultRole != models.RoleLecturer && defaultRole != models.RoleAdmin {
+		defaultRole = models.RoleUser
+	}
+	return mapping, defaultRole
+}
+
+// mapSSORole chọn vai trò cao nhất trong các nhóm khớp bảng map
+func mapSSORole(groups []string, mapping map[string]models.UserRole, defaultRole models.UserRole) models.UserRole {
+	rank := map[models.UserRole]int{models.RoleUser: 1, models.RoleLecturer: 2, models.RoleAdmin: 3}
+	role := defaultRole
+	for _, g := range groups {
+		if mapped, ok := mapping[strings.ToLower(strings.TrimSpace(g))]; ok && rank[mapped] > rank[role] {
+			role = mapped
+		}
+	}
+	return role
+}
+
+// provisionSSOUser tìm user theo định danh đã liên kết, sau đó theo email (chỉ khi IdP xác nhận email),
+// không có thì tạo mới (just-in-time) với vai trò map từ IdP
+func provisionSSOUser(db *gorm.DB, profile ssoProfile, role models.UserRole) (*models.User, error) {
+	if profile.Subject == "" {
+		return nil, errors.New("IdP không trả về định danh người dùng")
+	}
+	email := strings.ToLower(strings.TrimSpace(profile.Email))
+	syncRoles := os.Getenv("SSO_SYNC_ROLES") == "true"
+	now := time.Now()
+
+	var user models.User
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var identity models.UserIdentity
+		if err := tx.Where("provider = ? AND subject = ?", profile.Provider, profile.Subject).
+			First(&identity).Error; err == nil {
+			if err := tx.First(&user, "id = ?", identity.UserID).Error; err != nil {
+				return err
+			}
+			if syncRoles && user.Role != role {
+				if err := tx.Model(&user).Update("role", role).Error; err != nil {
+					return err
+				}
+			}
+			return tx.Model(&identity).Updates(map[string]interface{}{"email": email, "last_login_at": now}).Error
+		}
+
+		if email == "" {
+			return errors.New("IdP không trả về email")
+		}
+
+		if err := tx.Where("LOWER(email) = ?", email).First(&user).Error; err == nil {
+			// Liên kết tài khoản sẵn có: chỉ khi IdP khẳng định email thuộc về người này
+			if !profile.EmailVerified {
+				return errors.New("Email chưa được IdP xác minh, không thể liên kết với tài khoản có sẵn")
+			}
+			updates := map[string]interface{}{"email_verified": true}
+			if syncRoles && user.Role != role {
+				updates["role"] = role
+			}
+			if err := tx.Model(&user).Updates(updates).Error; err != nil {
+				return err
+			}
+		} else if errors.Is(err, gorm.ErrRecordNotFound) {
+			fullName := strings.TrimSpace(profile.FullName)
+			if fullName == "" {
+				fullName = strings.Split(email, "@")[0]
+			}
+			user = models.User{
+				FullName:      fullName,
+				Email:         email,
+				Role:          role,
+				Status:        BoolPtr(true),
+				EmailVerified: BoolPtr(true),
+				// Password để trống vì đăng nhập qua SSO
+			}
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+		} else {
+			return err
+		}
+
+		return tx.Create(&models.UserIdentity{
+			UserID:      user.ID,
+			Provider:    profile.Provider,
+			Subject:     profile.Subject,
+			Email:       email,
+			LastLoginAt: &now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	db.First(&user, "id = ?", user.ID)
+	return &user, nil
+}
+
+// safeRedirectPath chỉ chấp nhận đường dẫn tương đối của FE để tránh open redirect
+func safeRedirectPath(path string) string {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.Contains(path, "\\") {
+		return "/"
+	}
+	return path
+}
+
+// Cookie gắn state với trình duyệt đã bắt đầu đăng nhập, chống login CSRF (kẻ tấn công gửi link
+// callback chứa state của chính hắn cho nạn nhân). SAML ACS là POST cross-site từ IdP
+// nên cookie phải là SameSite=None; Secure
+const (
+	ssoBindingCookie = "sso_binding"
+	ssoBindingPath   = "/api/auth/sso"
+)
+
+func setSSOBindingCookie(c *gin.Context, value string, maxAge int) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     ssoBindingCookie,
+		Value:    value,
+		Path:     ssoBindingPath,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteNoneMode,
+	})
+}
+
+// createSSOState lưu state mới, đặt cookie gắn trình duyệt và trả về giá trị gốc để gửi sang IdP
+func createSSOState(c *gin.Context, db *gorm.DB, state models.SSOLoginState) (string, error) {
+	token, err := utils.GenerateRandomToken()
+	if err != nil {
+		return "", err
+	}
+	binding, err := utils.GenerateRandomToken()
+	if err != nil {
+		return "", err
+	}
+	state.StateHash = utils.HashToken(token)
+	state.BrowserHash = utils.HashToken(binding)
+	state.ExpiresAt = time.Now().Add(ssoStateTTL)
+	if err := db.Create(&state).Error; err != nil {
+		return "", err
+	}
+	setSSOBindingCookie(c, binding, int(ssoStateTTL.Seconds()))
+	return token, nil
+}
+
+// checkSSOBinding so cookie của trình duyệt với hash đã lưu cùng state
+func checkSSOBinding(c *gin.Context, state *models.SSOLoginState) error {
+	binding, err := c.Cookie(ssoBindingCookie)
+	if err != nil || binding == "" || state.BrowserHash == "" ||
+		subtle.ConstantTimeCompare([]byte(utils.HashToken(binding)), []byte(state.BrowserHash)) != 1 {
+		return errors.New("Phiên đăng nhập SSO không được bắt đầu từ trình duyệt này")
+	}
+	return nil
+}
+
+// consumeSSOState lấy và xóa state (mỗi state chỉ dùng một lần), state phải thuộc trình duyệt đang gọi lại
+func consumeSSOState(c *gin.Context, db *gorm.DB, token string, provider string) (*models.SSOLoginState, error) {
+	var state models.SSOLoginState
+	if err := db.Where("state_hash = ? AND provider = ?", utils.HashToken(token), provider).
+		First(&state).Error; err != nil {
+		return nil, errors.New("Phiên đăng nhập SSO không hợp lệ")
+	}
+	db.Delete(&state)
+	setSSOBindingCookie(c, "", -1)
+	if time.Now().After(state.ExpiresAt) {
+		return nil, errors.New("Phiên đăng nhập SSO đã hết hạn")
+	}
+	if err := checkSSOBinding(c, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// redirectSSOResult đưa trình duyệt về FE kèm vé đổi phiên (hoặc lỗi)
+func redirectSSOResult(c *gin.Context, user *models.User, method string, redirectPath string, ssoErr error) {
+	target, _ := url.Parse(os.Getenv("FE_BASE_URL") + "/auth/sso/callback")
+	q := target.Query()
+	q.Set("redirect", safeRedirectPath(redirectPath))
+
+	if ssoErr == nil && user.Status != nil && !*user.Status {
+		ssoErr = errors.New("Tài khoản của bạn đã bị tạm khóa")
+	}
+	if ssoErr != nil {
+		log.Printf("Đăng nhập SSO (%s) thất bại: %v", method, ssoErr)
+		q.Set("error", ssoErr.Error())
+		target.RawQuery = q.Encode()
+		c.Redirect(http.StatusFound, target.String())
+		return
+	}
+
+	ticket, err := utils.GenerateRandomToken()
+	if err == nil {
+		err = config.DB.Create(&models.LoginChallenge{
+			UserID:      user.ID,
+			TokenHash:   utils.HashToken(ticket),
+			Purpose:     models.LoginChallengeSSO,
+			LoginMethod: method,
+			ExpiresAt:   time.Now().Add(loginChallengeTTL),
+		}).Error
+	}
+	if err != nil {
+		q.Set("error", "Không thể tạo phiên đăng nhập")
+	} else {
+		q.Set("ticket", ticket)
+	}
+	target.RawQuery = q.Encode()
+	c.Redirect(http.StatusFound, target.String())
+}
+
+// Đổi vé SSO lấy phiên đăng nhập (qua bước 2FA nếu cần)
+// POST /auth/sso/exchange  {ticket}
+func ExchangeSSOTicket(c *gin.Context) {
+	var req struct {
+		Ticket string `json:"ticket" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Thiếu ticket"})
+		return
+	}
+
+	challenge, user, ok := loadLoginChallenge(c, req.Ticket, models.LoginChallengeSSO)
+	if !ok {
+		return
+	}
+	config.DB.Delete(challenge)
+
+	completeLogin(c, *user, challenge.LoginMethod)
+}
+
+// Các phương thức SSO đang bật (FE hiển thị nút đăng nhập)
+// GET /auth/sso/providers
+func GetSSOProviders(c *gin.Context) {
+	providers := []gin.H{}
+	if oidcEnabled() {
+		providers = append(providers, gin.H{
+			"type":      models.SSOProviderOIDC,
+			"name":      ssoDisplayName("OIDC", "Đăng nhập bằng tài khoản trường"),
+			"login_url": "/api/auth/sso/oidc/login",
+		})
+	}
+	if samlEnabled() {
+		providers = append(providers, gin.H{
+			"type":      models.SSOProviderSAML,
+			"name":      ssoDisplayName("SAML", "Đăng nhập bằng tài khoản trường (SAML)"),
+			"login_url": "/api/auth/sso/saml/login",
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"providers": providers})
+}
+
+func ssoDisplayName(prefix, fallback string) string {
+	if name := os.Getenv(prefix + "_DISPLAY_NAME"); name != "" {
+		return name
+	}
+	return fallback
+}
+
+// ===== OPENID CONNECT =====
+
+type oidcClient struct {
+	provider   *oidc.Provider
+	verifier   *oidc.IDTokenVerifier
+	oauth      oauth2.Config
+	roleClaim  string
+	nameClaim  string
+	trustEmail bool
+}
+
+var (
+	oidcMu     sync.Mutex
+	oidcCached *oidcClient
+)
+
+func oidcEnabled() bool {
+	return os.Getenv("OIDC_ISSUER") != "" && os.Getenv("OIDC_CLIENT_ID") != ""
+}
+
+// getOIDCClient đọc discovery document của IdP một lần rồi cache (lỗi thì lần sau thử lại)
+func getOIDCClient(ctx context.Context) (*oidcClient, error) {
+	if !oidcEnabled() {
+		return nil, errors.New("Chưa cấu hình đăng nhập OIDC")
+	}
+
+	oidcMu.Lock()
+	defer oidcMu.Unlock()
+	if oidcCached != nil {
+		return oidcCached, nil
+	}
+
+	provider, err := oidc.NewProvider(ctx, os.Getenv("OIDC_ISSUER"))
+	if err != nil {
+		return nil, fmt.Errorf("không thể đọc cấu hình OIDC: %w", err)
+	}
+
+	scopes := strings.Fields(strings.ReplaceAll(os.Getenv("OIDC_SCOPES"), ",", " "))
+	if len(scopes) == 0 {
+		scopes = []string{oidc.ScopeOpenID, "email", "profile"}
+	}
+	roleClaim := os.Getenv("OIDC_ROLE_CLAIM")
+	if roleClaim == "" {
+		roleClaim = "groups"
+	}
+	nameClaim := os.Getenv("OIDC_NAME_CLAIM")
+	if nameClaim == "" {
+		nameClaim = "name"
+	}
+
+	clientID := os.Getenv("OIDC_CLIENT_ID")
+	oidcCached = &oidcClient{
+		provider: provider,
+		verifier: provider.Verifier(&oidc.Config{ClientID: clientID}),
+		oauth: oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+			Endpoint:     provider.Endpoint(),
+			RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+			Scopes:       scopes,
+		},
+		roleClaim:  roleClaim,
+		nameClaim:  nameClaim,
+		trustEmail: os.Getenv("OIDC_TRUST_EMAIL") == "true",
+	}
+	return oidcCached, nil
+}
+
+// claimStrings đọc claim dạng chuỗi hoặc mảng chuỗi
+func claimStrings(v interface{}) []string {
+	switch val := v.(type) {
+	case string:
+		return strings.FieldsFunc(val, func(r rune) bool { return r == ',' || r == ' ' })
+	case []interface{}:
+		out := make([]string, 0, len(val))
+		for _, item := range val {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// Chuyển hướng sang IdP (authorization code + PKCE)
+// GET /auth/sso/oidc/login?redirect=/duong-dan-fe
+func OIDCLogin(c *gin.Context) {
+	client, err := getOIDCClient(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+
+	nonce, err := utils.GenerateRandomToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tạo phiên đăng nhập SSO"})
+		return
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	state, err := createSSOState(c, config.DB, models.SSOLoginState{
+		Provider:     models.SSOProviderOIDC,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		RedirectPath: safeRedirectPath(c.DefaultQuery("redirect", "/")),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tạo phiên đăng nhập SSO"})
+		return
+	}
+
+	c.Redirect(http.StatusFound, client.oauth.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier)))
+}
+
+// IdP gọi lại sau khi người dùng đăng nhập
+// GET /auth/sso/oidc/callback?code=...&state=...
+func OIDCCallback(c *gin.Context) {
+	method := "oidc"
+
+	state, err := consumeSSOState(c, config.DB, c.Query("state"), models.SSOProviderOIDC)
+	if err != nil {
+		redirectSSOResult(c, nil, method, "/", err)
+		return
+	}
+	if idpErr := c.Query("error"); idpErr != "" {
+		redirectSSOResult(c, nil, method, state.RedirectPath, fmt.Errorf("IdP từ chối đăng nhập: %s", idpErr))
+		return
+	}
+
+	ctx := c.Request.Context()
+	client, err := getOIDCClient(ctx)
+	if err != nil {
+		redirectSSOResult(c, nil, method, state.RedirectPath, err)
+		return
+	}
+
+	profile, err := exchangeOIDCCode(ctx, client, c.Query("code"), state)
+	if err != nil {
+		redirectSSOResult(c, nil, method, state.RedirectPath, err)
+		return
+	}
+
+	mapping, defaultRole := ssoRoleMapping("OIDC")
+	user, err := provisionSSOUser(config.DB, profile, mapSSORole(profile.Groups, mapping, defaultRole))
+	redirectSSOResult(c, user, method, state.RedirectPath, err)
+}
+
+// exchangeOIDCCode đổi code lấy id_token rồi kiểm tra chữ ký (JWKS), issuer, audience, hạn dùng và nonce
+func exchangeOIDCCode(ctx context.Context, client *oidcClient, code string, state *models.SSOLoginState) (ssoProfile, error) {
+	token, err := client.oauth.Exchange(ctx, code, oauth2.VerifierOption(state.CodeVerifier))
+	if err != nil {
+		return ssoProfile{}, errors.New("Không thể đổi mã xác thực với IdP")
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return ssoProfile{}, errors.New("IdP không trả về id_token")
+	}
+
+	idToken, err := client.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return ssoProfile{}, errors.New("id_token không hợp lệ")
+	}
+	if idToken.Nonce != state.Nonce {
+		return ssoProfile{}, errors.New("nonce không khớp")
+	}
+
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return ssoProfile{}, errors.New("Không đọc được thông tin người dùng")
+	}
+
+	email, _ := claims["email"].(string)
+	emailVerified, _ := claims["email_verified"].(bool)
+	fullName, _ := claims[client.nameClaim].(string)
+	return ssoProfile{
+		Provider:      models.SSOProviderOIDC,
+		Subject:       idToken.Subject,
+		Email:         email,
+		EmailVerified: emailVerified || client.trustEmail,
+		FullName:      fullName,
+		Groups:        claimStrings(claims[client.roleClaim]),
+	}, nil
+}
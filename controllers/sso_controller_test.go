@@ -0,0 +1,380 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crewjam/saml"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/vnkhanh/e-podcast-backend/models"
+	"github.com/vnkhanh/e-podcast-backend/utils"
+)
+
+// ===== Cookie gắn state với trình duyệt =====
+
+func TestSSOBindingCookie(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	setSSOBindingCookie(c, "binding", int(ssoStateTTL.Seconds()))
+
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("expected 1 cookie, got %d", len(cookies))
+	}
+	cookie := cookies[0]
+	if !cookie.HttpOnly || !cookie.Secure || cookie.SameSite != http.SameSiteNoneMode || cookie.Path != ssoBindingPath {
+		t.Errorf("unexpected cookie attributes: %+v", cookie)
+	}
+
+	state := &models.SSOLoginState{BrowserHash: utils.HashToken("binding")}
+	tests := []struct {
+		name    string
+		cookie  string
+		wantErr bool
+	}{
+		{"matching cookie", "binding", false},
+		{"missing cookie", "", true},
+		{"other browser", "attacker", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/auth/sso/oidc/callback", nil)
+			if tt.cookie != "" {
+				c.Request.AddCookie(&http.Cookie{Name: ssoBindingCookie, Value: tt.cookie})
+			}
+			if err := checkSSOBinding(c, state); (err != nil) != tt.wantErr {
+				t.Errorf("checkSSOBinding() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// State cũ chưa có BrowserHash không được chấp nhận
+	c, _ = gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/auth/sso/oidc/callback", nil)
+	c.Request.AddCookie(&http.Cookie{Name: ssoBindingCookie, Value: "binding"})
+	if err := checkSSOBinding(c, &models.SSOLoginState{}); err == nil {
+		t.Error("expected state without browser hash to be rejected")
+	}
+}
+
+// ===== OIDC =====
+
+// mockOIDCProvider dựng IdP giả: discovery, JWKS và token endpoint trả id_token ký RS256
+type mockOIDCProvider struct {
+	server   *httptest.Server
+	key      *rsa.PrivateKey
+	clientID string
+	verifier string // code_verifier nhận được ở token endpoint
+	claims   jwt.MapClaims
+	signKey  *rsa.PrivateKey // Khóa dùng để ký id_token (khác key khi giả lập chữ ký sai)
+}
+
+func newMockOIDCProvider(t *testing.T) *mockOIDCProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &mockOIDCProvider{key: key, signKey: key, clientID: "e-podcast"}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                p.server.URL,
+			"authorization_endpoint":                p.server.URL + "/authorize",
+			"token_endpoint":                        p.server.URL + "/token",
+			"jwks_uri":                              p.server.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "test-key",
+				"use": "sig",
+				"alg": "RS256",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.PostForm.Get("code") != "valid-code" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		p.verifier = r.PostForm.Get("code_verifier")
+
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, p.claims)
+		token.Header["kid"] = "test-key"
+		idToken, err := token.SignedString(p.signKey)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     idToken,
+		})
+	})
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+func (p *mockOIDCProvider) defaultClaims(nonce string) jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":            p.server.URL,
+		"aud":            p.clientID,
+		"sub":            "oidc-user-1",
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+		"nonce":          nonce,
+		"email":          "sv001@truong.edu.vn",
+		"email_verified": true,
+		"name":           "Nguyễn Văn A",
+		"groups":         []string{"student"},
+	}
+}
+
+func setupOIDCClient(t *testing.T, p *mockOIDCProvider) *oidcClient {
+	t.Helper()
+	t.Setenv("OIDC_ISSUER", p.server.URL)
+	t.Setenv("OIDC_CLIENT_ID", p.clientID)
+	t.Setenv("OIDC_CLIENT_SECRET", "secret")
+	t.Setenv("OIDC_REDIRECT_URL", "http://localhost/api/auth/sso/oidc/callback")
+	oidcMu.Lock()
+	oidcCached = nil
+	oidcMu.Unlock()
+	t.Cleanup(func() {
+		oidcMu.Lock()
+		oidcCached = nil
+		oidcMu.Unlock()
+	})
+
+	client, err := getOIDCClient(context.Background())
+	if err != nil {
+		t.Fatalf("getOIDCClient: %v", err)
+	}
+	return client
+}
+
+func TestExchangeOIDCCode(t *testing.T) {
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		code    string
+		modify  func(p *mockOIDCProvider)
+		wantErr bool
+	}{
+		{name: "valid id_token", code: "valid-code"},
+		{name: "invalid code", code: "bad-code", wantErr: true},
+		{name: "nonce mismatch", code: "valid-code", wantErr: true, modify: func(p *mockOIDCProvider) {
+			p.claims["nonce"] = "other-nonce"
+		}},
+		{name: "wrong audience", code: "valid-code", wantErr: true, modify: func(p *mockOIDCProvider) {
+			p.claims["aud"] = "other-client"
+		}},
+		{name: "expired", code: "valid-code", wantErr: true, modify: func(p *mockOIDCProvider) {
+			p.claims["exp"] = time.Now().Add(-time.Minute).Unix()
+		}},
+		{name: "signed by unknown key", code: "valid-code", wantErr: true, modify: func(p *mockOIDCProvider) {
+			p.signKey = otherKey
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newMockOIDCProvider(t)
+			client := setupOIDCClient(t, p)
+			state := &models.SSOLoginState{Nonce: "nonce-123", CodeVerifier: "verifier-123"}
+			p.claims = p.defaultClaims(state.Nonce)
+			if tt.modify != nil {
+				tt.modify(p)
+			}
+
+			profile, err := exchangeOIDCCode(context.Background(), client, tt.code, state)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("exchangeOIDCCode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if p.verifier != state.CodeVerifier {
+				t.Errorf("code_verifier = %q, want %q", p.verifier, state.CodeVerifier)
+			}
+			if profile.Subject != "oidc-user-1" || profile.Email != "sv001@truong.edu.vn" ||
+				!profile.EmailVerified || profile.FullName != "Nguyễn Văn A" ||
+				len(profile.Groups) != 1 || profile.Groups[0] != "student" {
+				t.Errorf("unexpected profile: %+v", profile)
+			}
+		})
+	}
+}
+
+// ===== SAML =====
+
+type staticSPProvider struct {
+	metadata *saml.EntityDescriptor
+}
+
+func (s staticSPProvider) GetServiceProvider(_ *http.Request, _ string) (*saml.EntityDescriptor, error) {
+	return s.metadata, nil
+}
+
+func mustURL(t *testing.T, raw string) url.URL {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return *u
+}
+
+func newTestKeyPair(t *testing.T, commonName string) (*rsa.PrivateKey, *x509.Certificate) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, cert
+}
+
+// samlLogin chạy một vòng SP -> IdP giả -> SP và trả về request POST tới ACS cùng ID của AuthnRequest
+func samlLogin(t *testing.T, sp *saml.ServiceProvider, idp *saml.IdentityProvider) (*http.Request, string) {
+	t.Helper()
+	authnRequest, err := sp.MakeAuthenticationRequest(idp.SSOURL.String(), saml.HTTPRedirectBinding, saml.HTTPPostBinding)
+	if err != nil {
+		t.Fatal(err)
+	}
+	redirectURL, err := authnRequest.Redirect("relay-state", sp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idpReq, err := saml.NewIdpAuthnRequest(idp, httptest.NewRequest(http.MethodGet, redirectURL.String(), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := idpReq.Validate(); err != nil {
+		t.Fatalf("IdP rejected AuthnRequest: %v", err)
+	}
+	session := &saml.Session{
+		ID:             "session-1",
+		CreateTime:     time.Now(),
+		ExpireTime:     time.Now().Add(time.Hour),
+		Index:          "1",
+		NameID:         "gv001",
+		UserEmail:      "gv001@truong.edu.vn",
+		UserCommonName: "Trần Thị B",
+		Groups:         []string{"faculty"},
+	}
+	if err := (saml.DefaultAssertionMaker{}).MakeAssertion(idpReq, session); err != nil {
+		t.Fatal(err)
+	}
+	form, err := idpReq.PostBinding()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := url.Values{"SAMLResponse": {form.SAMLResponse}, "RelayState": {form.RelayState}}
+	req := httptest.NewRequest(http.MethodPost, form.URL, strings.NewReader(values.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// SAMLACS đọc RelayState trước nên form đã được parse khi tới ParseResponse
+	if err := req.ParseForm(); err != nil {
+		t.Fatal(err)
+	}
+	return req, authnRequest.ID
+}
+
+func TestParseSAMLProfile(t *testing.T) {
+	t.Setenv("SAML_EMAIL_ATTRIBUTE", "mail")
+	t.Setenv("SAML_NAME_ATTRIBUTE", "cn")
+
+	idpKey, idpCert := newTestKeyPair(t, "idp")
+	spKey, spCert := newTestKeyPair(t, "sp")
+
+	idp := &saml.IdentityProvider{
+		Key:         idpKey,
+		Signer:      idpKey,
+		Certificate: idpCert,
+		MetadataURL: mustURL(t, "https://idp.truong.edu.vn/metadata"),
+		SSOURL:      mustURL(t, "https://idp.truong.edu.vn/sso"),
+	}
+	sp := &saml.ServiceProvider{
+		Key:         spKey,
+		Certificate: spCert,
+		MetadataURL: mustURL(t, "https://api.e-podcast.vn/api/auth/sso/saml/metadata"),
+		AcsURL:      mustURL(t, "https://api.e-podcast.vn/api/auth/sso/saml/acs"),
+		IDPMetadata: idp.Metadata(),
+	}
+	idp.ServiceProviderProvider = staticSPProvider{metadata: sp.Metadata()}
+
+	t.Run("valid response", func(t *testing.T) {
+		req, requestID := samlLogin(t, sp, idp)
+		profile, err := parseSAMLProfile(req, sp, &models.SSOLoginState{RequestID: requestID})
+		if err != nil {
+			t.Fatalf("parseSAMLProfile: %v", err)
+		}
+		if profile.Subject != "gv001" || profile.Email != "gv001@truong.edu.vn" ||
+			profile.FullName != "Trần Thị B" || len(profile.Groups) != 1 || profile.Groups[0] != "faculty" {
+			t.Errorf("unexpected profile: %+v", profile)
+		}
+	})
+
+	t.Run("response to another AuthnRequest", func(t *testing.T) {
+		req, _ := samlLogin(t, sp, idp)
+		if _, err := parseSAMLProfile(req, sp, &models.SSOLoginState{RequestID: "id-other"}); err == nil {
+			t.Error("expected InResponseTo mismatch to be rejected")
+		}
+	})
+
+	t.Run("signed by another IdP", func(t *testing.T) {
+		rogueKey, rogueCert := newTestKeyPair(t, "rogue")
+		rogue := *idp
+		rogue.Key, rogue.Signer, rogue.Certificate = rogueKey, rogueKey, rogueCert
+		req, requestID := samlLogin(t, sp, &rogue)
+		if _, err := parseSAMLProfile(req, sp, &models.SSOLoginState{RequestID: requestID}); err == nil {
+			t.Error("expected response signed by unknown key to be rejected")
+		}
+	})
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/vnkhanh/e-podcast-backend/models"
+	"github.com/vnkhanh/e-podcast-backend/utils"
 	"gorm.io/gorm"
 )
 
@@ -18,8 +19,15 @@ type (
 	}
 
 	MonthlyPoint struct {
-		Month string `json:"month"`
-		Count int64  `json:"count"`
+		Month       string `json:"month"`
+		Count       int64  `json:"count"`
+		UniqueUsers int64  `json:"unique_users"`
+	}
+
+	ListenPoint struct {
+		Date        string `json:"date"`
+		Count       int64  `json:"count"`
+		UniqueUsers int64  `json:"unique_users"`
 	}
 
 	TopPodcast struct {
@@ -36,33 +44,90 @@ type (
 	}
 )
 
+// reportLocation là múi giờ của báo cáo: ?tz= (tên IANA, vd Asia/Tokyo) hoặc múi giờ của trường.
+// Các bảng tổng hợp theo ngày luôn chia ngày theo múi giờ của trường; tz chỉ đổi cách hiểu from / to / "hôm nay"
+// và cách chia ngày với các báo cáo tính trực tiếp từ thời điểm gốc.
+func reportLocation(c *gin.Context) (*time.Location, bool) {
+	name := c.Query("tz")
+	if name == "" {
+		return utils.InstitutionLocation(), true
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Múi giờ không hợp lệ"})
+		return nil, false
+	}
+	return loc, true
+}
+
+// parseStatsRange đọc ?from=&to= (YYYY-MM-DD, tính cả hai đầu) theo múi giờ báo cáo, mặc định days ngày gần nhất
+func parseStatsRange(c *gin.Context, days int) (time.Time, time.Time, *time.Location, bool) {
+	loc, ok := reportLocation(c)
+	if !ok {
+		return time.Time{}, time.Time{}, nil, false
+	}
+	to := utils.DayIn(time.Now(), loc)
+	from := to.AddDate(0, 0, -days)
+	if t, err := time.ParseInLocation("2006-01-02", c.Query("from"), loc); err == nil {
+		from = t
+	}
+	if t, err := time.ParseInLocation("2006-01-02", c.Query("to"), loc); err == nil {
+		to = t
+	}
+	if to.Before(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Khoảng thời gian không hợp lệ"})
+		return from, to, loc, false
+	}
+	return from, to, loc, true
+}
+
 func GetDailyListens(c *gin.Context) {
 	db := c.MustGet("db").(*gorm.DB)
 
-	fromStr, toStr := c.Query("from"), c.Query("to")
-	to := time.Now()
-	from := to.AddDate(0, 0, -7)
+	from, to, _, ok := parseStatsRange(c, 7)
+	if !ok {
+		return
+	}
 
-	if fromStr != "" {
-		if t, err := time.Parse("2006-01-02", fromStr); err == nil {
-			from = t
+	// Dùng analytics thay vì listening_histories
+	var rows []models.ListeningAnalytics
+	db.Select("date", "total_listens", "unique_users", "listener_sketch").
+		Where("date BETWEEN ? AND ?", from, to).
+		Order("date").
+		Find(&rows)
+
+	// group=week: gộp theo tuần (bắt đầu thứ Hai), người nghe không trùng tính bằng gộp sketch
+	if c.Query("group") == "week" {
+		keyOf := func(t time.Time) string { return weekStart(t).Format("2006-01-02") }
+		sketches := make([]sketchRow, 0, len(rows))
+		res := []ListenPoint{}
+		index := map[string]int{}
+		for _, r := range rows {
+			key := keyOf(r.Date)
+			if i, ok := index[key]; ok {
+				res[i].Count += r.TotalListens
+			} else {
+				index[key] = len(res)
+				res = append(res, ListenPoint{Date: key, Count: r.TotalListens})
+			}
+			sketches = append(sketches, sketchRow{Date: r.Date, ListenerSketch: r.ListenerSketch})
 		}
-	}
-	if toStr != "" {
-		if t, err := time.Parse("2006-01-02", toStr); err == nil {
-			to = t
+		unique := mergeSketches(sketches, keyOf)
+		for i := range res {
+			res[i].UniqueUsers = unique[res[i].Date]
 		}
+		c.JSON(http.StatusOK, res)
+		return
 	}
 
-	var res []Point
-	// Dùng analytics thay vì listening_histories
-	db.Raw(`
-		SELECT TO_CHAR(date, 'YYYY-MM-DD') AS date,
-		       total_listens AS count
-		FROM listening_analytics
-		WHERE date BETWEEN ? AND ?
-		ORDER BY date
-	`, from, to).Scan(&res)
+	res := make([]ListenPoint, 0, len(rows))
+	for _, r := range rows {
+		res = append(res, ListenPoint{
+			Date:        r.Date.Format("2006-01-02"),
+			Count:       r.TotalListens,
+			UniqueUsers: r.UniqueUsers,
+		})
+	}
 
 	c.JSON(http.StatusOK, res)
 }
@@ -87,12 +152,24 @@ func GetMonthlyListens(c *gin.Context) {
 		ORDER BY month
 	`, year).Scan(&res)
 
+	// Người nghe không trùng mỗi tháng: gộp sketch các ngày trong tháng
+	from := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+	rows := loadSketchRows(db, &models.ListeningAnalytics{}, from, from.AddDate(1, 0, -1), "")
+	unique := mergeSketches(rows, func(t time.Time) string { return t.Format("2006-01") })
+	for i := range res {
+		res[i].UniqueUsers = unique[res[i].Month]
+	}
+
 	c.JSON(http.StatusOK, res)
 }
 
 func GetDashboardOverview(c *gin.Context) {
 	db := c.MustGet("db").(*gorm.DB)
-	now := time.Now()
+	loc, ok := reportLocation(c)
+	if !ok {
+		return
+	}
+	now := utils.DayIn(time.Now(), loc)
 
 	var totalUsers, totalPodcasts int64
 	db.Model(&models.User{}).Count(&totalUsers)
@@ -105,6 +182,16 @@ func GetDashboardOverview(c *gin.Context) {
 		Select("COALESCE(SUM(total_listens), 0)").
 		Scan(&totalListens30d)
 
+	// Tổng thời gian nghe thực 30 ngày (từ sự kiện phát)
+	var totalDuration30d int64
+	db.Model(&models.ListeningAnalytics{}).
+		Where("date >= ?", now.AddDate(0, 0, -30)).
+		Select("COALESCE(SUM(total_duration), 0)").
+		Scan(&totalDuration30d)
+
+	// Người nghe không trùng 30 ngày (gộp sketch từng ngày)
+	uniqueListeners30d := uniqueListenersOf(loadSketchRows(db, &models.ListeningAnalytics{}, now.AddDate(0, 0, -30), now, ""))
+
 	// Tỷ lệ hoàn thành từ analytics
 	var sumCompleted, sumTotal int64
 	db.Model(&models.ListeningAnalytics{}).
@@ -135,16 +222,22 @@ func GetDashboardOverview(c *gin.Context) {
 	`, now.AddDate(0, 0, -30)).Scan(&tops)
 
 	c.JSON(http.StatusOK, gin.H{
-		"total_users":       totalUsers,
-		"total_podcasts":    totalPodcasts,
-		"total_listens_30d": totalListens30d,
-		"completion_rate":   completionRate,
-		"top_podcasts":      tops,
+		"total_users":          totalUsers,
+		"total_podcasts":       totalPodcasts,
+		"total_listens_30d":    totalListens30d,
+		"total_hours_30d":      float64(totalDuration30d) / 3600,
+		"unique_listeners_30d": uniqueListeners30d,
+		"completion_rate":      completionRate,
+		"top_podcasts":         tops,
 	})
 }
 
 func GetSubjectBreakdown(c *gin.Context) {
 	db := c.MustGet("db").(*gorm.DB)
+	loc, ok := reportLocation(c)
+	if !ok {
+		return
+	}
 	var out []SubjectStat
 
 	// Dùng subject_analytics (30 ngày gần nhất)
@@ -156,7 +249,7 @@ func GetSubjectBreakdown(c *gin.Context) {
 		GROUP BY s.id, s.name
 		ORDER BY plays DESC
 		LIMIT 20
-	`, time.Now().AddDate(0, 0, -30)).Scan(&out)
+	`, utils.DayIn(time.Now(), loc).AddDate(0, 0, -30)).Scan(&out)
 
 	c.JSON(http.StatusOK, out)
 }
@@ -164,15 +257,19 @@ func GetSubjectBreakdown(c *gin.Context) {
 // ===================== Người dùng mới =====================
 func GetNewUsers(c *gin.Context) {
 	db := c.MustGet("db").(*gorm.DB)
+	loc, ok := reportLocation(c)
+	if !ok {
+		return
+	}
 	days, _ := strconv.Atoi(c.DefaultQuery("days", "30"))
-	from := time.Now().AddDate(0, 0, -days)
+	from := utils.DayIn(time.Now(), loc).AddDate(0, 0, -days)
 
 	var res []Point
 	db.Raw(`
 		WITH date_series AS (
 			SELECT generate_series(
 				?::date,
-				CURRENT_DATE,
+				(NOW() AT TIME ZONE ?)::date,
 				'1 day'::interval
 			)::date AS date
 		)
@@ -180,10 +277,10 @@ func GetNewUsers(c *gin.Context) {
 			TO_CHAR(ds.date, 'YYYY-MM-DD') AS date,
 			COALESCE(COUNT(u.id), 0) AS count
 		FROM date_series ds
-		LEFT JOIN users u ON u.created_at::date = ds.date
+		LEFT JOIN users u ON (u.created_at AT TIME ZONE ?)::date = ds.date
 		GROUP BY ds.date
 		ORDER BY ds.date
-	`, from).Scan(&res)
+	`, from, loc.String(), loc.String()).Scan(&res)
 
 	c.JSON(http.StatusOK, res)
 }
@@ -198,16 +295,39 @@ func GetPodcastAnalytics(c *gin.Context) {
 		return
 	}
 
+	var podcast models.Podcast
+	if err := db.First(&podcast, "id = ?", podcastID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy podcast"})
+		return
+	}
+	if !canManagePodcast(c, db, podcast, models.PermPodcastEdit) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Bạn không có quyền xem thống kê podcast này"})
+		return
+	}
+
+	loc, ok := reportLocation(c)
+	if !ok {
+		return
+	}
 	days := 30 // mặc định 30 ngày
-	from := time.Now().AddDate(0, 0, -days)
+	from := utils.DayIn(time.Now(), loc).AddDate(0, 0, -days)
 
 	var analytics []models.PodcastAnalytics
 	db.Where("podcast_id = ? AND date >= ?", podcastID, from).
 		Order("date DESC").
 		Find(&analytics)
 
+	var totalDuration int64
+	sketches := make([]sketchRow, 0, len(analytics))
+	for _, a := range analytics {
+		totalDuration += a.TotalDuration
+		sketches = append(sketches, sketchRow{Date: a.Date, ListenerSketch: a.ListenerSketch})
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"data": analytics,
+		"data":                   analytics,
+		"total_duration_seconds": totalDuration,
+		"unique_listeners":       uniqueListenersOf(sketches), // Không trùng trong cả 30 ngày
 	})
 }
 
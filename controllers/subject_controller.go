@@ -93,9 +93,9 @@ func GetSubjects(c *gin.Context) {
 			return db.Select("id, full_name, email")
 		})
 
-	// Nếu là giảng viên, chỉ thấy môn của mình
+	// Nếu là giảng viên, chỉ thấy môn của mình hoặc được cấp quyền
 	if role == string(models.RoleLecturer) {
-		query = query.Where("subjects.created_by = ?", userIDStr)
+		query = query.Where("subjects.id IN (?)", memberSubjectIDs(db, userIDStr))
 	}
 
 	// Nếu là admin, có thể lọc theo giảng viên
@@ -225,6 +225,7 @@ type UpdateSubjectInput struct {
 	Name       string         `json:"name"`
 	CourseCode string         `json:"course_code"`
 	Status     *bool          `json:"status"`
+	PublicFeed *bool          `json:"public_feed"` // Bật / tắt feed RSS công khai
 	Chapters   []ChapterInput `json:"chapters"`
 }
 
@@ -249,6 +250,9 @@ func UpdateSubject(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Môn học không tồn tại"})
 		return
 	}
+	if !requireSubjectPermission(c, config.DB, subjectID, models.PermSubjectEdit) {
+		return
+	}
 
 	// === 1. Cập nhật thông tin cơ bản ===
 	// Lấy userID từ context (nếu có)
@@ -299,6 +303,9 @@ func UpdateSubject(c *gin.Context) {
 	if input.Status != nil {
 		subject.Status = *input.Status
 	}
+	if input.PublicFeed != nil {
+		subject.PublicFeed = *input.PublicFeed
+	}
 
 	if err := config.DB.Save(&subject).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cập nhật môn học thất bại"})
@@ -428,6 +435,9 @@ func DeleteSubject(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy môn học"})
 		return
 	}
+	if !requireSubjectPermission(c, config.DB, subjectID, models.PermSubjectDelete) {
+		return
+	}
 
 	// 1. Lấy danh sách chapter IDs
 	chapterIDs := make([]uuid.UUID, 0)
@@ -481,6 +491,9 @@ func ToggleSubjectStatus(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy môn học"})
 		return
 	}
+	if !requireSubjectPermission(c, config.DB, subjectID, models.PermSubjectEdit) {
+		return
+	}
 
 	// đảo trạng thái
 	subject.Status = !subject.Status
@@ -557,6 +570,9 @@ func CreateChapter(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Môn học không tồn tại"})
 		return
 	}
+	if !requireSubjectPermission(c, db, subjectUUID, models.PermSubjectEdit) {
+		return
+	}
 
 	// Kiểm tra trùng tên
 	var existing models.Chapter
@@ -661,8 +677,9 @@ func GetSubjectDetailUser(c *gin.Context) {
 
 	// Mặc định dữ liệu trả về
 	response := gin.H{
-		"message": "Lấy chi tiết môn học thành công",
-		"data":    subject,
+		"message":            "Lấy chi tiết môn học thành công",
+		"data":               subject,
+		"revision_playlists": revisionPlaylists(db, subject.ID), // Danh sách ôn thi giảng viên tuyển chọn
 	}
 
 	// Nếu có user đăng nhập → tính tiến độ
@@ -0,0 +1,530 @@
+package controllers
+
+import (
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/vnkhanh/e-podcast-backend/config"
+	"github.com/vnkhanh/e-podcast-backend/models"
+	"github.com/vnkhanh/e-podcast-backend/utils"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// ==================== XÁC THỰC 2 LỚP (TOTP) ====================
+
+const (
+	loginChallengeTTL         = 5 * time.Minute
+	maxLoginChallengeAttempts = 5
+	recoveryCodeCount         = 10
+)
+
+func twoFactorIssuer() string {
+	if issuer := os.Getenv("TOTP_ISSUER"); issuer != "" {
+		return issuer
+	}
+	return "E-Podcast"
+}
+
+// twoFactorRequired: admin và giảng viên có quyền xóa nội dung nên bắt buộc bật 2FA
+func twoFactorRequired(user models.User) bool {
+	return user.Role == models.RoleAdmin || user.Role == models.RoleLecturer
+}
+
+// respondSession tạo phiên đăng nhập và trả cặp token cho client
+func respondSession(c *gin.Context, user models.User, method string, extra gin.H) {
+	tokens, err := utils.CreateSession(config.DB, user, sessionDevice(c, method))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tạo token"})
+		return
+	}
+
+	res := gin.H{
+		"message":       "Đăng nhập thành công",
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
+		"session_id":    tokens.SessionID,
+		"user": gin.H{
+			"id":                 user.ID,
+			"email":              user.Email,
+			"full_name":          user.FullName,
+			"role":               user.Role,
+			"two_factor_enabled": user.TwoFactorEnabled,
+		},
+	}
+	for k, v := range extra {
+		res[k] = v
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// completeLogin được gọi sau khi đã xác thực bước 1 (mật khẩu / Google):
+// bật 2FA -> trả thử thách nhập mã; admin / giảng viên chưa bật -> bắt buộc thiết lập; còn lại cấp phiên luôn
+func completeLogin(c *gin.Context, user models.User, method string) {
+	purpose := ""
+	switch {
+	case user.TwoFactorEnabled:
+		purpose = models.LoginChallengeVerify
+	case twoFactorRequired(user):
+		purpose = models.LoginChallengeSetup
+	default:
+		respondSession(c, user, method, nil)
+		return
+	}
+
+	token, err := utils.GenerateRandomToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tạo token"})
+		return
+	}
+
+	db := config.DB
+	db.Where("user_id = ?", user.ID).Delete(&models.LoginChallenge{})
+	if err := db.Create(&models.LoginChallenge{
+		UserID:      user.ID,
+		TokenHash:   utils.HashToken(token),
+		Purpose:     purpose,
+		LoginMethod: method,
+		ExpiresAt:   time.Now().Add(loginChallengeTTL),
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tạo token"})
+		return
+	}
+
+	res := gin.H{
+		"challenge_token": token,
+		"expires_in":      int(loginChallengeTTL.Seconds()),
+	}
+	if purpose == models.LoginChallengeVerify {
+		res["requires_2fa"] = true
+		res["message"] = "Vui lòng nhập mã xác thực 2 lớp"
+	} else {
+		res["requires_2fa_setup"] = true
+		res["message"] = "Tài khoản quản trị / giảng viên bắt buộc bật xác thực 2 lớp trước khi đăng nhập"
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// loadLoginChallenge tìm thử thách còn hiệu lực theo token và mục đích, kèm user
+func loadLoginChallenge(c *gin.Context, token string, purpose string) (*models.LoginChallenge, *models.User, bool) {
+	db := config.DB
+
+	var challenge models.LoginChallenge
+	if err := db.Where("token_hash = ? AND purpose = ?", utils.HashToken(token), purpose).
+		First(&challenge).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Phiên xác thực không hợp lệ, vui lòng đăng nhập lại"})
+		return nil, nil, false
+	}
+	if time.Now().After(challenge.ExpiresAt) || challenge.Attempts >= maxLoginChallengeAttempts {
+		db.Delete(&challenge)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Phiên xác thực đã hết hạn, vui lòng đăng nhập lại"})
+		return nil, nil, false
+	}
+
+	var user models.User
+	if err := db.First(&user, "id = ?", challenge.UserID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Không tìm thấy người dùng"})
+		return nil, nil, false
+	}
+	if user.Status != nil && !*user.Status {
+		db.Delete(&challenge)
+		c.JSON(http.StatusForbidden, gin.H{"error": "Tài khoản của bạn đã bị tạm khóa"})
+		return nil, nil, false
+	}
+	return &challenge, &user, true
+}
+
+// failLoginChallenge tăng số lần nhập sai, hết lượt thì hủy thử thách
+func failLoginChallenge(c *gin.Context, challenge *models.LoginChallenge) {
+	db := config.DB
+	challenge.Attempts++
+	if challenge.Attempts >= maxLoginChallengeAttempts {
+		db.Delete(challenge)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Nhập sai quá nhiều lần, vui lòng đăng nhập lại"})
+		return
+	}
+	db.Model(challenge).Update("attempts", challenge.Attempts)
+	c.JSON(http.StatusUnauthorized, gin.H{
+		"error":              "Mã xác thực không đúng",
+		"remaining_attempts": maxLoginChallengeAttempts - challenge.Attempts,
+	})
+}
+
+// consumeTOTP kiểm tra mã TOTP và ghi nhận bước đã dùng (điều kiện trên last_step để hai request cùng mã không cùng qua)
+func consumeTOTP(db *gorm.DB, user *models.User, code string) bool {
+	step, ok := utils.ValidateTOTP(user.TwoFactorSecret, code, user.TwoFactorLastStep)
+	if !ok {
+		return false
+	}
+	res := db.Model(&models.User{}).
+		Where("id = ? AND two_factor_last_step < ?", user.ID, step).
+		Update("two_factor_last_step", step)
+	if res.Error != nil || res.RowsAffected == 0 {
+		return false
+	}
+	user.TwoFactorLastStep = step
+	return true
+}
+
+// consumeRecoveryCode đánh dấu đã dùng một mã khôi phục còn hiệu lực
+func consumeRecoveryCode(db *gorm.DB, userID uuid.UUID, code string) bool {
+	res := db.Model(&models.TwoFactorRecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, utils.HashToken(utils.NormalizeRecoveryCode(code))).
+		Update("used_at", time.Now())
+	return res.Error == nil && res.RowsAffected > 0
+}
+
+// verifySecondFactor chấp nhận mã TOTP hoặc mã khôi phục
+func verifySecondFactor(db *gorm.DB, user *models.User, code, recoveryCode string) (bool, string) {
+	if code != "" && consumeTOTP(db, user, code) {
+		return true, "totp"
+	}
+	if recoveryCode != "" && consumeRecoveryCode(db, user.ID, recoveryCode) {
+		return true, "recovery_code"
+	}
+	return false, ""
+}
+
+// replaceRecoveryCodes xóa mã cũ và sinh bộ mã khôi phục mới (chỉ trả mã gốc một lần)
+func replaceRecoveryCodes(tx *gorm.DB, user models.User) ([]string, error) {
+	codes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.TwoFactorRecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	rows := make([]models.TwoFactorRecoveryCode, 0, len(codes))
+	for _, code := range codes {
+		rows = append(rows, models.TwoFactorRecoveryCode{UserID: user.ID, CodeHash: utils.HashToken(code)})
+	}
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// beginTwoFactorSetup sinh secret mới (chưa bật) và trả thông tin để quét QR
+func beginTwoFactorSetup(c *gin.Context, user models.User) {
+	if user.TwoFactorEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Xác thực 2 lớp đã được bật"})
+		return
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tạo mã bí mật"})
+		return
+	}
+	if err := config.DB.Model(&models.User{}).Where("id = ?", user.ID).
+		Updates(map[string]interface{}{"two_factor_secret": secret, "two_factor_last_step": 0}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể lưu mã bí mật"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":      secret,
+		"otpauth_url": utils.TOTPProvisioningURI(secret, user.Email, twoFactorIssuer()),
+		"message":     "Quét mã QR bằng ứng dụng xác thực rồi nhập mã 6 số để hoàn tất",
+	})
+}
+
+// enableTwoFactor xác nhận mã đầu tiên, bật 2FA và sinh mã khôi phục.
+// Khi thiết lập trong lúc đăng nhập (challenge khác nil), mã sai bị tính vào số lần thử của thử thách
+func enableTwoFactor(c *gin.Context, user *models.User, code string, challenge *models.LoginChallenge) ([]string, bool) {
+	if user.TwoFactorEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Xác thực 2 lớp đã được bật"})
+		return nil, false
+	}
+	if user.TwoFactorSecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Vui lòng bắt đầu thiết lập trước"})
+		return nil, false
+	}
+	step, ok := utils.ValidateTOTP(user.TwoFactorSecret, code, user.TwoFactorLastStep)
+	if !ok {
+		if challenge != nil {
+			failLoginChallenge(c, challenge)
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Mã xác thực không đúng"})
+		}
+		return nil, false
+	}
+
+	var codes []string
+	now := time.Now()
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+			"two_factor_enabled":    true,
+			"two_factor_enabled_at": now,
+			"two_factor_last_step":  step,
+		}).Error; err != nil {
+			return err
+		}
+		var err error
+		codes, err = replaceRecoveryCodes(tx, *user)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể bật xác thực 2 lớp"})
+		return nil, false
+	}
+
+	user.TwoFactorEnabled = true
+	user.TwoFactorEnabledAt = &now
+	user.TwoFactorLastStep = step
+	return codes, true
+}
+
+// ===== BƯỚC 2 CỦA ĐĂNG NHẬP =====
+
+// Nhập mã 2FA để hoàn tất đăng nhập
+// POST /auth/login/2fa  {challenge_token, code | recovery_code}
+func VerifyLoginTwoFactor(c *gin.Context) {
+	var req struct {
+		ChallengeToken string `json:"challenge_token" binding:"required"`
+		Code           string `json:"code"`
+		RecoveryCode   string `json:"recovery_code"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Thiếu challenge_token"})
+		return
+	}
+	if req.Code == "" && req.RecoveryCode == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Vui lòng nhập mã xác thực hoặc mã khôi phục"})
+		return
+	}
+
+	challenge, user, ok := loadLoginChallenge(c, req.ChallengeToken, models.LoginChallengeVerify)
+	if !ok {
+		return
+	}
+
+	verified, factor := verifySecondFactor(config.DB, user, req.Code, req.RecoveryCode)
+	if !verified {
+		failLoginChallenge(c, challenge)
+		return
+	}
+	config.DB.Delete(challenge)
+
+	extra := gin.H{}
+	if factor == "recovery_code" {
+		var remaining int64
+		config.DB.Model(&models.TwoFactorRecoveryCode{}).
+			Where("user_id = ? AND used_at IS NULL", user.ID).Count(&remaining)
+		extra["recovery_codes_remaining"] = remaining
+	}
+	respondSession(c, *user, challenge.LoginMethod, extra)
+}
+
+// Bắt đầu thiết lập 2FA trong lúc đăng nhập (admin / giảng viên chưa bật)
+// POST /auth/login/2fa/setup  {challenge_token}
+func SetupLoginTwoFactor(c *gin.Context) {
+	var req struct {
+		ChallengeToken string `json:"challenge_token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Thiếu challenge_token"})
+		return
+	}
+
+	_, user, ok := loadLoginChallenge(c, req.ChallengeToken, models.LoginChallengeSetup)
+	if !ok {
+		return
+	}
+	beginTwoFactorSetup(c, *user)
+}
+
+// Xác nhận mã đầu tiên, bật 2FA rồi cấp phiên đăng nhập
+// POST /auth/login/2fa/enable  {challenge_token, code}
+func EnableLoginTwoFactor(c *gin.Context) {
+	var req struct {
+		ChallengeToken string `json:"challenge_token" binding:"required"`
+		Code           string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Thiếu challenge_token hoặc mã xác thực"})
+		return
+	}
+
+	challenge, user, ok := loadLoginChallenge(c, req.ChallengeToken, models.LoginChallengeSetup)
+	if !ok {
+		return
+	}
+
+	codes, ok := enableTwoFactor(c, user, req.Code, challenge)
+	if !ok {
+		return
+	}
+	config.DB.Delete(challenge)
+
+	respondSession(c, *user, challenge.LoginMethod, gin.H{"recovery_codes": codes})
+}
+
+// ===== QUẢN LÝ 2FA TRONG TÀI KHOẢN =====
+
+// currentUser lấy user đang đăng nhập đầy đủ các trường 2FA
+func currentUser(c *gin.Context) (*models.User, bool) {
+	var user models.User
+	if err := config.DB.First(&user, "id = ?", c.GetString("user_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy người dùng"})
+		return nil, false
+	}
+	return &user, true
+}
+
+// Trạng thái 2FA của tôi
+// GET /user/account/2fa
+func GetTwoFactorStatus(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var remaining int64
+	config.DB.Model(&models.TwoFactorRecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", user.ID).Count(&remaining)
+
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":                  user.TwoFactorEnabled,
+		"enabled_at":               user.TwoFactorEnabledAt,
+		"required":                 twoFactorRequired(*user),
+		"recovery_codes_remaining": remaining,
+	})
+}
+
+// Bắt đầu thiết lập 2FA
+// POST /user/account/2fa/setup
+func SetupTwoFactor(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	beginTwoFactorSetup(c, *user)
+}
+
+// Xác nhận mã và bật 2FA
+// POST /user/account/2fa/enable  {code}
+func EnableTwoFactor(c *gin.Context) {
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Vui lòng nhập mã xác thực"})
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	codes, ok := enableTwoFactor(c, user, req.Code, nil)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Đã bật xác thực 2 lớp. Hãy lưu các mã khôi phục ở nơi an toàn",
+		"recovery_codes": codes,
+	})
+}
+
+// Tắt 2FA (không áp dụng cho admin / giảng viên)
+// POST /user/account/2fa/disable  {password, code | recovery_code}
+func DisableTwoFactor(c *gin.Context) {
+	var req struct {
+		Password     string `json:"password"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if !user.TwoFactorEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Xác thực 2 lớp chưa được bật"})
+		return
+	}
+	if twoFactorRequired(*user) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Tài khoản quản trị / giảng viên bắt buộc dùng xác thực 2 lớp"})
+		return
+	}
+	// Tài khoản Google không có mật khẩu thì chỉ cần mã 2FA
+	if user.Password != "" {
+		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Mật khẩu không đúng"})
+			return
+		}
+	}
+	if verified, _ := verifySecondFactor(config.DB, user, req.Code, req.RecoveryCode); !verified {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Mã xác thực không đúng"})
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+			"two_factor_enabled":    false,
+			"two_factor_secret":     "",
+			"two_factor_enabled_at": nil,
+			"two_factor_last_step":  0,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.ID).Delete(&models.TwoFactorRecoveryCode{}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tắt xác thực 2 lớp"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Đã tắt xác thực 2 lớp"})
+}
+
+// Sinh lại bộ mã khôi phục (mã cũ hết hiệu lực)
+// POST /user/account/2fa/recovery-codes  {code}
+func RegenerateRecoveryCodes(c *gin.Context) {
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Vui lòng nhập mã xác thực"})
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if !user.TwoFactorEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Xác thực 2 lớp chưa được bật"})
+		return
+	}
+	if !consumeTOTP(config.DB, user, req.Code) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Mã xác thực không đúng"})
+		return
+	}
+
+	var codes []string
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = replaceRecoveryCodes(tx, *user)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Không thể tạo mã khôi phục"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "Đã tạo bộ mã khôi phục mới",
+		"recovery_codes": codes,
+	})
+}
@@ -28,15 +28,33 @@ require (
 	google.golang.org/api v0.247.0
 )
 
-require cloud.google.com/go/texttospeech v1.15.1
+require (
+	cloud.google.com/go/texttospeech v1.15.1
+	github.com/coreos/go-oidc/v3 v3.14.1
+	github.com/crewjam/saml v0.5.1
+	github.com/xuri/excelize/v2 v2.10.0
+	golang.org/x/image v0.25.0
+	modernc.org/sqlite v1.34.5
+)
 
 require (
+	github.com/beevik/etree v1.5.0 // indirect
+	github.com/dustin/go-humanize v1.0.1 // indirect
+	github.com/go-jose/go-jose/v4 v4.0.5 // indirect
+	github.com/golang-jwt/jwt/v4 v4.5.2 // indirect
+	github.com/jonboulle/clockwork v0.2.2 // indirect
+	github.com/mattermost/xml-roundtrip-validator v0.1.0 // indirect
+	github.com/ncruces/go-strftime v0.1.9 // indirect
+	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
 	github.com/richardlehane/mscfb v1.0.4 // indirect
 	github.com/richardlehane/msoleps v1.0.4 // indirect
+	github.com/russellhaering/goxmldsig v1.4.0 // indirect
 	github.com/tiendc/go-deepcopy v1.7.1 // indirect
 	github.com/xuri/efp v0.0.1 // indirect
-	github.com/xuri/excelize/v2 v2.10.0 // indirect
 	github.com/xuri/nfp v0.0.2-0.20250530014748-2ddeb826f9a9 // indirect
+	modernc.org/libc v1.55.3 // indirect
+	modernc.org/mathutil v1.6.0 // indirect
+	modernc.org/memory v1.8.0 // indirect
 )
 
 require (
@@ -46,14 +64,14 @@ require (
 	cloud.google.com/go/longrunning v0.6.7 // indirect
 	github.com/tcolgate/mp3 v0.0.0-20170426193717-e79c5a46d300
 	go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc v0.61.0 // indirect
-	golang.org/x/oauth2 v0.30.0 // indirect
+	golang.org/x/oauth2 v0.30.0
 	golang.org/x/time v0.12.0 // indirect
 	google.golang.org/genproto/googleapis/api v0.0.0-20250818200422-3122310a409c // indirect
 )
 
 require (
 	github.com/gosimple/slug v1.15.0
-	github.com/gosimple/unidecode v1.0.1 // indirect
+	github.com/gosimple/unidecode v1.0.1
 )
 
 require (
@@ -42,22 +42,17 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// Kiểm tra trạng thái user trong DB
-		var user models.User
-		if err := config.DB.Select("status").First(&user, "id = ?", claims.UserID).Error; err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Không tìm thấy người dùng"})
+		// Kiểm tra trạng thái user và phiên đăng nhập trong DB
+		if status, msg := checkTokenUser(claims); status != 0 {
+			c.JSON(status, gin.H{"error": msg})
 			c.Abort()
 			return
 		}
 
-		if user.Status != nil && !*user.Status {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Tài khoản đã bị tạm khóa"})
-			c.Abort()
-			return
-		}
 		// Lưu thông tin vào context để controller dùng
 		c.Set("user_id", claims.UserID)
 		c.Set("role", claims.Role)
+		c.Set("session_id", claims.SessionID)
 		c.Next()
 	}
 }
@@ -93,9 +88,47 @@ func OptionalAuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// Tài khoản bị khóa / phiên đã bị thu hồi -> coi như anonymous
+		if status, _ := checkTokenUser(claims); status != 0 {
+			c.Next()
+			return
+		}
+
 		// Token hợp lệ -> lưu thông tin user
 		c.Set("user_id", claims.UserID)
 		c.Set("role", claims.Role)
+		c.Set("session_id", claims.SessionID)
 		c.Next()
 	}
 }
+
+// checkTokenUser kiểm tra người dùng của token còn tồn tại, không bị khóa và phiên còn hiệu lực.
+// Trả về 0 nếu hợp lệ, ngược lại là HTTP status và thông báo lỗi
+func checkTokenUser(claims *utils.JWTClaims) (int, string) {
+	var user models.User
+	if err := config.DB.Select("status", "sessions_revoked_at").First(&user, "id = ?", claims.UserID).Error; err != nil {
+		return http.StatusUnauthorized, "Không tìm thấy người dùng"
+	}
+
+	if user.Status != nil && !*user.Status {
+		return http.StatusForbidden, "Tài khoản đã bị tạm khóa"
+	}
+
+	// Phiên đăng nhập đã bị thu hồi (đăng xuất, đổi mật khẩu, bị khóa...)
+	if !sessionActive(claims, user) {
+		return http.StatusUnauthorized, "Phiên đăng nhập đã hết hiệu lực, vui lòng đăng nhập lại"
+	}
+	return 0, ""
+}
+
+// sessionActive: token có sid thì phiên phải còn hiệu lực; token cũ không có sid
+// bị từ chối nếu được cấp trước lần thu hồi toàn bộ phiên gần nhất
+func sessionActive(claims *utils.JWTClaims, user models.User) bool {
+	if claims.SessionID != "" {
+		return utils.ValidateSession(config.DB, claims.SessionID, claims.UserID) == nil
+	}
+	if user.SessionsRevokedAt != nil && claims.IssuedAt != nil {
+		return claims.IssuedAt.Time.After(*user.SessionsRevokedAt)
+	}
+	return true
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vnkhanh/e-podcast-backend/utils"
+)
+
+// RateLimitByIP giới hạn theo địa chỉ IP. name dùng làm khóa bucket và tên biến môi trường
+// RATE_LIMIT_<NAME> (vd RATE_LIMIT_LOGIN=10/m), fallback là giới hạn mặc định.
+func RateLimitByIP(name string, fallback string) gin.HandlerFunc {
+	return rateLimit(name, utils.RateLimitFromEnv(name, fallback), func(c *gin.Context) string {
+		return "ip:" + c.ClientIP()
+	})
+}
+
+// RateLimitByUser giới hạn theo user đăng nhập (dùng sau AuthMiddleware), chưa đăng nhập thì theo IP
+func RateLimitByUser(name string, fallback string) gin.HandlerFunc {
+	return rateLimit(name, utils.RateLimitFromEnv(name, fallback), func(c *gin.Context) string {
+		if userID := c.GetString("user_id"); userID != "" {
+			return "user:" + userID
+		}
+		return "ip:" + c.ClientIP()
+	})
+}
+
+func rateLimit(name string, limit utils.RateLimit, keyFunc func(c *gin.Context) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limit.Disabled() {
+			c.Next()
+			return
+		}
+
+		result := utils.GetRateLimitStore().Take(name+":"+keyFunc(c), limit, time.Now())
+		c.Header("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("X-RateLimit-Reset", strconv.Itoa(utils.RetryAfterSeconds(result.ResetAfter)))
+
+		if !result.Allowed {
+			AbortTooManyRequests(c, result.RetryAfter,
+				"Bạn thao tác quá nhanh, vui lòng thử lại sau %d giây")
+			return
+		}
+		c.Next()
+	}
+}
+
+// AbortTooManyRequests trả 429 kèm Retry-After; message có một %d cho số giây chờ
+func AbortTooManyRequests(c *gin.Context, retryAfter time.Duration, message string) {
+	secs := utils.RetryAfterSeconds(retryAfter)
+	c.Header("Retry-After", strconv.Itoa(secs))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+		"error":       fmt.Sprintf(message, secs),
+		"retry_after": secs,
+	})
+}
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vnkhanh/e-podcast-backend/models"
+)
+
+// RequirePermission yêu cầu vai trò của user có đủ các quyền (dùng sau AuthMiddleware / RequireRoles).
+// Phạm vi nội dung (môn học của ai) do controller kiểm tra tiếp.
+func RequirePermission(perms ...models.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := models.UserRole(c.GetString("role"))
+		for _, perm := range perms {
+			if !role.Can(perm) {
+				c.JSON(http.StatusForbidden, gin.H{
+					"error":      "Bạn không có quyền thực hiện thao tác này",
+					"permission": perm,
+				})
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
@@ -14,6 +14,10 @@ type ListeningAnalytics struct {
 	TotalListens     int64 `gorm:"default:0" json:"total_listens"`
 	UniqueUsers      int64 `gorm:"default:0" json:"unique_users"`
 	CompletedListens int64 `gorm:"default:0" json:"completed_listens"`
+	TotalDuration    int64 `gorm:"default:0" json:"total_duration"` // Tổng thời gian nghe (giây)
+
+	// HyperLogLog các user đã nghe trong ngày; gộp nhiều ngày để đếm người nghe theo tuần / tháng
+	ListenerSketch []byte `gorm:"type:bytea" json:"-"`
 
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
@@ -25,10 +29,11 @@ type PodcastAnalytics struct {
 	Date      time.Time `gorm:"type:date;not null;uniqueIndex:idx_podcast_analytics" json:"date"`
 	PodcastID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_podcast_analytics;index" json:"podcast_id"`
 
-	TotalPlays      int64 `gorm:"default:0" json:"total_plays"`
-	UniqueListeners int64 `gorm:"default:0" json:"unique_listeners"`
-	CompletedPlays  int64 `gorm:"default:0" json:"completed_plays"`
-	TotalDuration   int64 `gorm:"default:0" json:"total_duration"` // Tổng thời gian nghe (giây)
+	TotalPlays      int64  `gorm:"default:0" json:"total_plays"`
+	UniqueListeners int64  `gorm:"default:0" json:"unique_listeners"`
+	CompletedPlays  int64  `gorm:"default:0" json:"completed_plays"`
+	TotalDuration   int64  `gorm:"default:0" json:"total_duration"` // Tổng thời gian nghe (giây)
+	ListenerSketch  []byte `gorm:"type:bytea" json:"-"`
 
 	Podcast   Podcast   `gorm:"foreignKey:PodcastID;constraint:OnDelete:CASCADE" json:"podcast,omitempty"`
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
@@ -41,19 +46,63 @@ type SubjectAnalytics struct {
 	Date      time.Time `gorm:"type:date;not null;uniqueIndex:idx_subject_analytics" json:"date"`
 	SubjectID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_subject_analytics;index" json:"subject_id"`
 
-	TotalPlays int64 `gorm:"default:0" json:"total_plays"`
+	TotalPlays      int64  `gorm:"default:0" json:"total_plays"`
+	UniqueListeners int64  `gorm:"default:0" json:"unique_listeners"`
+	TotalDuration   int64  `gorm:"default:0" json:"total_duration"` // Tổng thời gian nghe (giây)
+	ListenerSketch  []byte `gorm:"type:bytea" json:"-"`
 
 	Subject   Subject   `gorm:"foreignKey:SubjectID;constraint:OnDelete:CASCADE" json:"subject,omitempty"`
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
 }
 
+// Loại sự kiện phát do player gửi lên
+const (
+	ListeningEventPlay      = "play"
+	ListeningEventPause     = "pause"
+	ListeningEventSeek      = "seek"
+	ListeningEventRate      = "rate" // Đổi tốc độ phát
+	ListeningEventHeartbeat = "heartbeat"
+	ListeningEventEnded     = "ended"
+)
+
+// Sự kiện phát thô, gửi theo lô từ player. (session_id, seq) duy nhất để client gửi lại lô không bị đếm trùng.
 type ListeningEvent struct {
-	ID         uuid.UUID `gorm:"type:uuid;primaryKey"`
-	UserID     uuid.UUID `gorm:"type:uuid;not null;index"`
-	PodcastID  uuid.UUID `gorm:"type:uuid;not null;index"`
-	ListenedAt time.Time `gorm:"not null;index"`
-	Duration   int       // Thời gian nghe trong session này
-	Position   int       // Vị trí bắt đầu
-	Completed  bool      // Hoàn thành trong session này không
+	ID              uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	UserID          uuid.UUID `gorm:"type:uuid;not null;index:idx_event_user_time" json:"user_id"`
+	PodcastID       uuid.UUID `gorm:"type:uuid;not null;index" json:"podcast_id"`
+	SessionID       string    `gorm:"type:varchar(64);not null;uniqueIndex:idx_event_session_seq" json:"session_id"` // Một lần mở player
+	Seq             int       `gorm:"not null;uniqueIndex:idx_event_session_seq" json:"seq"`
+	Type            string    `gorm:"type:varchar(20);not null" json:"type"`
+	Position        int       `gorm:"not null" json:"position"`          // Vị trí (giây) tại thời điểm sự kiện; với seek là vị trí mới
+	FromPosition    *int      `json:"from_position,omitempty"`           // seek: vị trí trước khi tua
+	PlaybackRate    float64   `gorm:"default:1" json:"playback_rate"`    // Tốc độ phát sau sự kiện
+	Playing         bool      `json:"playing"`                           // Player đang phát sau sự kiện (server suy ra)
+	ListenedSeconds int       `gorm:"default:0" json:"listened_seconds"` // Thời gian nghe thực kể từ sự kiện trước (server tính)
+	OccurredAt      time.Time `gorm:"not null;index:idx_event_user_time" json:"occurred_at"`
+	CreatedAt       time.Time `gorm:"autoCreateTime" json:"created_at"`
+
+	User    User    `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+	Podcast Podcast `gorm:"foreignKey:PodcastID;constraint:OnDelete:CASCADE" json:"-"`
+}
+
+// Những giây của podcast mà user đã nghe (bitset, bit i = giây thứ i), dùng để đếm retention không trùng người nghe
+type ListenerCoverage struct {
+	UserID    uuid.UUID `gorm:"type:uuid;primaryKey"`
+	PodcastID uuid.UUID `gorm:"type:uuid;primaryKey;index"`
+	Bitmap    []byte    `gorm:"type:bytea"`
+	Seconds   int       `gorm:"default:0"` // Số giây khác nhau đã nghe
+	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+
+	User    User    `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE"`
+	Podcast Podcast `gorm:"foreignKey:PodcastID;constraint:OnDelete:CASCADE"`
+}
+
+// Số người nghe (không trùng) đã nghe tới từng giây của podcast
+type PodcastRetention struct {
+	PodcastID uuid.UUID `gorm:"type:uuid;primaryKey" json:"podcast_id"`
+	Second    int       `gorm:"primaryKey;autoIncrement:false" json:"second"`
+	Listeners int64     `gorm:"default:0" json:"listeners"`
+
+	Podcast Podcast `gorm:"foreignKey:PodcastID;constraint:OnDelete:CASCADE" json:"-"`
 }
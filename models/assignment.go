@@ -6,6 +6,26 @@ import (
 	"github.com/google/uuid"
 )
 
+// Loại câu hỏi trong bài tập
+const (
+	QuestionTypeSingleChoice   = "single_choice"   // Một đáp án đúng
+	QuestionTypeMultipleChoice = "multiple_choice" // Nhiều đáp án đúng, chấm điểm từng phần
+	QuestionTypeTrueFalse      = "true_false"      // Đúng / Sai
+	QuestionTypeFillBlank      = "fill_blank"      // Điền vào chỗ trống, options là các đáp án được chấp nhận
+	QuestionTypeOrdering       = "ordering"        // Sắp xếp thứ tự, sort_order của option là thứ tự đúng
+	QuestionTypeMatching       = "matching"        // Ghép nối option_text với match_text
+	QuestionTypeShortAnswer    = "short_answer"    // Trả lời ngắn, chấm tay theo rubric
+	QuestionTypeEssay          = "essay"           // Tự luận, chấm tay theo rubric
+)
+
+// Trạng thái chấm điểm của câu trả lời / bài làm
+const (
+	GradingStatusAuto        = "auto"         // Chấm tự động
+	GradingStatusPending     = "pending"      // Chờ giảng viên chấm
+	GradingStatusAISuggested = "ai_suggested" // AI đã gợi ý điểm, chờ giảng viên xác nhận
+	GradingStatusGraded      = "graded"       // Đã chấm xong
+)
+
 // ASSIGNMENT (BÀI TẬP)
 type Assignment struct {
 	ID          uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
@@ -20,9 +40,31 @@ type Assignment struct {
 	IsPublished bool       `gorm:"default:false" json:"is_published"`               // Đã công bố chưa
 
 	HasPassword bool   `gorm:"default:false" json:"has_password"`
-	Password    string `gorm:"type:varchar(255)" json:"password,omitempty"`
+	Password    string `gorm:"type:varchar(255)" json:"-"`       // bcrypt hash, không bao giờ trả về client
 	AllowReview bool   `gorm:"default:true" json:"allow_review"` // Cho phép sinh viên xem đáp án
 
+	RequireAccessCode bool `gorm:"default:false" json:"require_access_code"` // Mỗi lượt làm cần một mã dự thi dùng một lần
+
+	// Nộp muộn: mặc định không cho nộp sau hạn. Nếu cho phép, mỗi ngày trễ trừ LatePenaltyPerDay % điểm
+	// (tối đa LatePenaltyMax %), không nhận bài sau LateUntil
+	AllowLate         bool       `gorm:"default:false" json:"allow_late"`
+	LatePenaltyPerDay float64    `gorm:"type:numeric(5,2);default:0" json:"late_penalty_per_day"`
+	LatePenaltyMax    float64    `gorm:"type:numeric(5,2);default:100" json:"late_penalty_max"`
+	LateUntil         *time.Time `json:"late_until,omitempty"`
+
+	ShuffleQuestions bool             `gorm:"default:false" json:"shuffle_questions"` // Xáo thứ tự câu hỏi cho từng sinh viên
+	ShuffleOptions   bool             `gorm:"default:false" json:"shuffle_options"`   // Xáo thứ tự đáp án cho từng sinh viên
+	Rules            []AssignmentRule `gorm:"foreignKey:AssignmentID;constraint:OnDelete:CASCADE;" json:"rules,omitempty"`
+
+	Classes []AssignmentClass `gorm:"foreignKey:AssignmentID;constraint:OnDelete:CASCADE;" json:"classes,omitempty"` // Lớp được giao, rỗng = mọi sinh viên
+
+	// Sổ điểm
+	CategoryID  *uuid.UUID     `gorm:"type:uuid;index" json:"category_id,omitempty"`
+	Category    *GradeCategory `gorm:"foreignKey:CategoryID;references:ID;constraint:OnDelete:SET NULL;" json:"category,omitempty"`
+	ScorePolicy string         `gorm:"type:varchar(10);default:'best'" json:"score_policy"` // best | last | average
+
+	Override *AssignmentOverride `gorm:"-" json:"override,omitempty"` // Ngoại lệ của sinh viên đang xem, đã áp vào các trường trên
+
 	CreatedBy uuid.UUID            `gorm:"type:uuid;not null" json:"created_by"`
 	Creator   User                 `gorm:"foreignKey:CreatedBy;references:ID;constraint:OnDelete:CASCADE;" json:"creator"`
 	CreatedAt time.Time            `gorm:"autoCreateTime" json:"created_at"`
@@ -36,12 +78,27 @@ type AssignmentQuestion struct {
 	AssignmentID uuid.UUID          `gorm:"type:uuid;not null" json:"assignment_id"`
 	Assignment   Assignment         `gorm:"foreignKey:AssignmentID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
 	Question     string             `gorm:"type:text;not null" json:"question"`
+	Type         string             `gorm:"type:varchar(30);default:'single_choice'" json:"type"`
 	Difficulty   string             `gorm:"type:varchar(50);default:'medium'" json:"difficulty"` // "easy", "medium", "hard"
 	Explanation  string             `gorm:"type:text" json:"explanation"`                        // Giải thích đáp án
+	Rubric       string             `gorm:"type:text" json:"rubric,omitempty"`                   // Tiêu chí chấm cho câu tự luận
 	Points       float64            `gorm:"type:numeric(5,2);default:1.0" json:"points"`         // Điểm của câu hỏi
 	SortOrder    int                `gorm:"default:0" json:"sort_order"`                         // Thứ tự hiển thị
 	CreatedAt    time.Time          `gorm:"autoCreateTime" json:"created_at"`
 	Options      []AssignmentOption `gorm:"foreignKey:QuestionID;constraint:OnDelete:CASCADE;" json:"options"`
+
+	BankQuestionID *uuid.UUID `gorm:"type:uuid;index" json:"bank_question_id,omitempty"` // Câu gốc trong ngân hàng
+	Pooled         bool       `gorm:"default:false" json:"pooled"`                       // Câu rút từ ngân hàng, chỉ xuất hiện trong đề của từng sinh viên
+
+	MatchChoices []MatchChoice     `gorm:"-" json:"match_choices,omitempty"` // Cột vế phải (đã xáo trộn) cho câu ghép nối
+	MatchKeys    map[string]string `gorm:"-" json:"match_keys,omitempty"`    // id lựa chọn vế phải -> option_id trong đề của lần làm bài
+}
+
+// MatchChoice là một lựa chọn vế phải của câu ghép nối, ID ngẫu nhiên riêng cho từng đề
+// (không trùng option_id để không lộ cặp đúng)
+type MatchChoice struct {
+	ID   uuid.UUID `json:"id"`
+	Text string    `json:"text"`
 }
 
 // ASSIGNMENT OPTION
@@ -50,6 +107,7 @@ type AssignmentOption struct {
 	QuestionID uuid.UUID          `gorm:"type:uuid;not null" json:"question_id"`
 	Question   AssignmentQuestion `gorm:"foreignKey:QuestionID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
 	OptionText string             `gorm:"type:text;not null" json:"option_text"`
+	MatchText  string             `gorm:"type:text" json:"match_text,omitempty"` // Vế phải của câu ghép nối
 	IsCorrect  bool               `gorm:"default:false" json:"is_correct"`
 	SortOrder  int                `gorm:"default:0" json:"sort_order"`
 }
@@ -69,6 +127,19 @@ type AssignmentSubmission struct {
 	StartedAt    time.Time  `gorm:"autoCreateTime" json:"started_at"`
 	SubmittedAt  *time.Time `json:"submitted_at,omitempty"` // Nullable - chỉ set khi nộp
 
+	GradingStatus string `gorm:"type:varchar(20);default:'graded'" json:"grading_status"` // "pending" khi còn câu tự luận chưa chấm
+
+	// Thời hạn do server tính lúc bắt đầu: StartedAt + TimeLimit, không vượt quá hạn nộp
+	ExpiresAt      *time.Time `gorm:"index" json:"expires_at,omitempty"`
+	AutoSubmitted  bool       `gorm:"default:false" json:"auto_submitted"`             // Hệ thống tự nộp khi hết giờ
+	IsLate         bool       `gorm:"default:false" json:"is_late"`                    // Nộp sau hạn
+	LatePenalty    float64    `gorm:"type:numeric(5,2);default:0" json:"late_penalty"` // % điểm bị trừ do nộp muộn
+	IntegrityFlags int        `gorm:"default:0" json:"integrity_flags"`                // Số lần rời tab / mất focus được ghi nhận
+
+	// Đã qua kiểm tra mật khẩu / mã dự thi khi bắt đầu lượt làm
+	AccessVerified bool       `gorm:"default:false" json:"access_verified"`
+	AccessCodeID   *uuid.UUID `gorm:"type:uuid" json:"access_code_id,omitempty"`
+
 	Answers []AssignmentAnswer `gorm:"foreignKey:SubmissionID;constraint:OnDelete:CASCADE;" json:"answers"`
 }
 
@@ -81,7 +152,79 @@ type AssignmentAnswer struct {
 	Question       AssignmentQuestion   `gorm:"foreignKey:QuestionID;references:ID;constraint:OnDelete:CASCADE;" json:"question"`
 	SelectedID     uuid.UUID            `gorm:"type:uuid" json:"selected_id"` // uuid.Nil nếu bỏ trống
 	SelectedOption AssignmentOption     `gorm:"foreignKey:SelectedID;references:ID;" json:"selected_option,omitempty"`
+	SelectedIDs    UUIDList             `gorm:"type:jsonb" json:"selected_ids"` // Nhiều lựa chọn / thứ tự sắp xếp
+	TextAnswer     string               `gorm:"type:text" json:"text_answer"`   // Câu điền vào chỗ trống
+	Pairs          StringMap            `gorm:"type:jsonb" json:"pairs"`        // Ghép nối: option_id -> id lựa chọn vế phải
 	IsCorrect      bool                 `gorm:"default:false" json:"is_correct"`
 	PointsEarned   float64              `gorm:"type:numeric(5,2)" json:"points_earned"`
+	TimeSpentSec   int                  `gorm:"default:0" json:"time_spent_sec"` // Thời gian làm câu này (giây), client cộng dồn
 	AnsweredAt     time.Time            `gorm:"autoCreateTime" json:"answered_at"`
+
+	// Chấm tay câu tự luận
+	GradingStatus     string     `gorm:"type:varchar(20);default:'auto'" json:"grading_status"`
+	AISuggestedPoints *float64   `gorm:"type:numeric(5,2)" json:"ai_suggested_points,omitempty"`
+	AIJustification   string     `gorm:"type:text" json:"ai_justification,omitempty"`
+	TeacherFeedback   string     `gorm:"type:text" json:"teacher_feedback,omitempty"`
+	GradedBy          *uuid.UUID `gorm:"type:uuid" json:"graded_by,omitempty"`
+	GradedAt          *time.Time `json:"graded_at,omitempty"`
+}
+
+// Loại sự kiện bất thường trong lúc làm bài do client báo về
+const (
+	AttemptEventFocusLost      = "focus_lost"      // Cửa sổ mất focus
+	AttemptEventTabHidden      = "tab_hidden"      // Chuyển sang tab khác
+	AttemptEventFullscreenExit = "fullscreen_exit" // Thoát toàn màn hình
+	AttemptEventCopy           = "copy"
+	AttemptEventPaste          = "paste"
+)
+
+// ASSIGNMENT ATTEMPT EVENT (NHẬT KÝ LÀM BÀI)
+type AssignmentAttemptEvent struct {
+	ID           uuid.UUID            `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	SubmissionID uuid.UUID            `gorm:"type:uuid;not null;index" json:"submission_id"`
+	Submission   AssignmentSubmission `gorm:"foreignKey:SubmissionID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	UserID       uuid.UUID            `gorm:"type:uuid;not null" json:"user_id"`
+	Type         string               `gorm:"type:varchar(30);not null" json:"type"`
+	DurationMs   int                  `gorm:"default:0" json:"duration_ms"` // Thời gian rời khỏi bài (nếu có)
+	Detail       string               `gorm:"type:text" json:"detail,omitempty"`
+	ClientTime   *time.Time           `json:"client_time,omitempty"` // Thời điểm theo đồng hồ client
+	CreatedAt    time.Time            `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// ASSIGNMENT ACCESS CODE (MÃ DỰ THI DÙNG MỘT LẦN)
+// Chỉ lưu hash SHA-256 của mã; mã gốc chỉ hiển thị một lần lúc tạo.
+type AssignmentAccessCode struct {
+	ID           uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	AssignmentID uuid.UUID  `gorm:"type:uuid;not null;index" json:"assignment_id"`
+	Assignment   Assignment `gorm:"foreignKey:AssignmentID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	BatchID      uuid.UUID  `gorm:"type:uuid;not null;index" json:"batch_id"` // Các mã tạo cùng một lần
+	CodeHash     string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	Hint         string     `gorm:"type:varchar(8)" json:"hint"`              // Vài ký tự cuối để đối chiếu
+	Label        string     `gorm:"type:varchar(255)" json:"label"`           // Tên / email sinh viên được phát mã
+	UserID       *uuid.UUID `gorm:"type:uuid;index" json:"user_id,omitempty"` // nil = ai có mã cũng dùng được
+	ValidFrom    *time.Time `json:"valid_from,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	UsedAt       *time.Time `json:"used_at,omitempty"`
+	UsedBy       *uuid.UUID `gorm:"type:uuid" json:"used_by,omitempty"`
+	SubmissionID *uuid.UUID `gorm:"type:uuid" json:"submission_id,omitempty"`
+	CreatedBy    uuid.UUID  `gorm:"type:uuid;not null" json:"created_by"`
+	CreatedAt    time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// ASSIGNMENT OVERRIDE (NGOẠI LỆ CHO TỪNG SINH VIÊN)
+// Thêm thời gian, gia hạn, thêm lượt làm cho sinh viên có chế độ đặc biệt / giấy nghỉ ốm.
+type AssignmentOverride struct {
+	ID            uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	AssignmentID  uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_assignment_override" json:"assignment_id"`
+	Assignment    Assignment `gorm:"foreignKey:AssignmentID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	UserID        uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_assignment_override" json:"user_id"`
+	User          User       `gorm:"foreignKey:UserID;references:ID;constraint:OnDelete:CASCADE;" json:"user"`
+	ExtraMinutes  int        `gorm:"default:0" json:"extra_minutes"`  // Cộng thêm vào TimeLimit
+	DueDate       *time.Time `json:"due_date,omitempty"`              // Hạn nộp riêng, nil = theo bài tập / lớp
+	ExtraAttempts int        `gorm:"default:0" json:"extra_attempts"` // Cộng thêm vào MaxAttempts
+	Reason        string     `gorm:"type:text;not null" json:"reason"`
+	GrantedBy     uuid.UUID  `gorm:"type:uuid;not null" json:"granted_by"`
+	Granter       User       `gorm:"foreignKey:GrantedBy;references:ID" json:"granter"`
+	CreatedAt     time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
 }
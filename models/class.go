@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Cách sinh viên được thêm vào lớp
+const (
+	EnrollmentViaInvite = "invite" // Tự tham gia bằng mã mời
+	EnrollmentViaRoster = "roster" // Giảng viên import danh sách
+	EnrollmentViaManual = "manual" // Giảng viên thêm từng người
+)
+
+// CLASS (LỚP HỌC PHẦN)
+type Class struct {
+	ID         uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	SubjectID  uuid.UUID `gorm:"type:uuid;not null;index" json:"subject_id"`
+	Subject    Subject   `gorm:"foreignKey:SubjectID;references:ID;constraint:OnDelete:CASCADE;" json:"subject"`
+	LecturerID uuid.UUID `gorm:"type:uuid;not null;index" json:"lecturer_id"`
+	Lecturer   User      `gorm:"foreignKey:LecturerID;references:ID;constraint:OnDelete:CASCADE;" json:"lecturer"`
+	Name       string    `gorm:"type:varchar(255);not null" json:"name"`
+	Semester   string    `gorm:"type:varchar(50)" json:"semester"`                         // Ví dụ: "HK1 2025-2026"
+	InviteCode string    `gorm:"type:varchar(16);uniqueIndex;not null" json:"invite_code"` // Mã mời để sinh viên tự tham gia
+	AllowJoin  bool      `gorm:"default:true" json:"allow_join"`                           // Tắt để khóa mã mời
+	IsArchived bool      `gorm:"default:false" json:"is_archived"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+
+	Enrollments []ClassEnrollment `gorm:"foreignKey:ClassID;constraint:OnDelete:CASCADE;" json:"enrollments,omitempty"`
+}
+
+// CLASS ENROLLMENT (SINH VIÊN TRONG LỚP)
+type ClassEnrollment struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	ClassID   uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_class_user" json:"class_id"`
+	Class     Class     `gorm:"foreignKey:ClassID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_class_user;index" json:"user_id"`
+	User      User      `gorm:"foreignKey:UserID;references:ID;constraint:OnDelete:CASCADE;" json:"user"`
+	JoinedVia string    `gorm:"type:varchar(20);default:'invite'" json:"joined_via"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// ASSIGNMENT CLASS (BÀI TẬP GIAO CHO LỚP)
+// Bài tập không gắn lớp nào vẫn hiển thị cho mọi sinh viên như trước.
+type AssignmentClass struct {
+	ID           uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	AssignmentID uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_assignment_class" json:"assignment_id"`
+	Assignment   Assignment `gorm:"foreignKey:AssignmentID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	ClassID      uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_assignment_class;index" json:"class_id"`
+	Class        Class      `gorm:"foreignKey:ClassID;references:ID;constraint:OnDelete:CASCADE;" json:"class"`
+	DueDate      *time.Time `json:"due_date,omitempty"` // Hạn nộp riêng của lớp, nil = dùng hạn chung của bài tập
+	CreatedAt    time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
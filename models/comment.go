@@ -6,14 +6,58 @@ import (
 	"github.com/google/uuid"
 )
 
+// Loại phản hồi bình luận
+const (
+	ReactionLike       = "like"
+	ReactionLove       = "love"
+	ReactionHaha       = "haha"
+	ReactionWow        = "wow"
+	ReactionSad        = "sad"
+	ReactionInsightful = "insightful"
+)
+
+// CommentReactions là các phản hồi hợp lệ kèm emoji hiển thị
+var CommentReactions = map[string]string{
+	ReactionLike:       "👍",
+	ReactionLove:       "❤️",
+	ReactionHaha:       "😂",
+	ReactionWow:        "😮",
+	ReactionSad:        "😢",
+	ReactionInsightful: "💡",
+}
+
 type Comment struct {
-	ID        uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
-	PodcastID uuid.UUID  `gorm:"type:uuid;not null;index" json:"podcast_id"`
-	UserID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
-	ParentID  *uuid.UUID `gorm:"type:uuid;index" json:"parent_id,omitempty"`
-	Content   string     `gorm:"type:text;not null" json:"content"`
-	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	ID          uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	PodcastID   uuid.UUID  `gorm:"type:uuid;not null;index" json:"podcast_id"`
+	UserID      uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	ParentID    *uuid.UUID `gorm:"type:uuid;index" json:"parent_id,omitempty"`
+	Content     string     `gorm:"type:text;not null" json:"content"`
+	PositionSec *int       `json:"position_sec,omitempty"` // Mốc thời gian (giây) trong podcast mà bình luận gắn vào
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	EditedAt    *time.Time `json:"edited_at,omitempty"` // Lần sửa gần nhất, nil = chưa sửa
 
 	User    User      `gorm:"foreignKey:UserID" json:"user"`
 	Replies []Comment `gorm:"foreignKey:ParentID;constraint:OnDelete:CASCADE;" json:"replies,omitempty"`
 }
+
+// COMMENT EDIT (LỊCH SỬ SỬA BÌNH LUẬN, LƯU NỘI DUNG TRƯỚC MỖI LẦN SỬA)
+type CommentEdit struct {
+	ID          uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	CommentID   uuid.UUID `gorm:"type:uuid;not null;index" json:"comment_id"`
+	Comment     Comment   `gorm:"foreignKey:CommentID;constraint:OnDelete:CASCADE;" json:"-"`
+	Content     string    `gorm:"type:text;not null" json:"content"`
+	PositionSec *int      `json:"position_sec,omitempty"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"` // Thời điểm sửa
+}
+
+// COMMENT REACTION (THÍCH / EMOJI CHO BÌNH LUẬN)
+// Một user có thể thả nhiều loại phản hồi, mỗi loại một lần
+type CommentReaction struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	CommentID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_comment_user_reaction" json:"comment_id"`
+	Comment   Comment   `gorm:"foreignKey:CommentID;constraint:OnDelete:CASCADE;" json:"-"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_comment_user_reaction;index" json:"user_id"`
+	User      User      `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE;" json:"-"`
+	Reaction  string    `gorm:"type:varchar(20);not null;uniqueIndex:idx_comment_user_reaction" json:"reaction"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
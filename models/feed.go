@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FEED TOKEN (LINK FEED RSS RIÊNG CỦA USER)
+// Mỗi user một token, gắn vào URL feed để ứng dụng podcast mở được môn học không công khai.
+// Chỉ lưu hash: token gốc hiện cho user một lần lúc tạo, tạo lại token sẽ vô hiệu hóa mọi link cũ.
+type FeedToken struct {
+	ID         uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	UserID     uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex" json:"user_id"`
+	User       User       `gorm:"foreignKey:UserID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	TokenHash  string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"` // Lần gần nhất ứng dụng podcast tải feed
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
@@ -18,6 +18,7 @@ type Flashcard struct {
 	SourceText    string `gorm:"type:text" json:"source_text"`
 	ReferenceText string `gorm:"type:text" json:"reference_text"` // đoạn tài liệu gốc (để hiển thị trích dẫn)
 	ChunkIndex    int    `json:"chunk_index"`
+	IsCurated     bool   `gorm:"default:false" json:"is_curated"` // bộ thẻ do giảng viên biên soạn, hiển thị cho mọi sinh viên
 
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Cách lấy điểm khi sinh viên làm bài nhiều lần
+const (
+	ScorePolicyBest    = "best"    // Lần cao nhất
+	ScorePolicyLast    = "last"    // Lần nộp cuối
+	ScorePolicyAverage = "average" // Trung bình các lần
+)
+
+// GRADE CATEGORY (NHÓM ĐIỂM: chuyên cần, giữa kỳ, cuối kỳ...)
+type GradeCategory struct {
+	ID        uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	SubjectID uuid.UUID  `gorm:"type:uuid;not null;index" json:"subject_id"`
+	Subject   Subject    `gorm:"foreignKey:SubjectID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	ClassID   *uuid.UUID `gorm:"type:uuid;index" json:"class_id,omitempty"` // nil = áp dụng cho cả môn
+	Name      string     `gorm:"type:varchar(100);not null" json:"name"`
+	Weight    float64    `gorm:"type:numeric(5,2);default:0" json:"weight"` // Trọng số (%)
+	SortOrder int        `gorm:"default:0" json:"sort_order"`
+	CreatedBy uuid.UUID  `gorm:"type:uuid;not null" json:"created_by"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// GRADE ADJUSTMENT (ĐIỀU CHỈNH ĐIỂM THỦ CÔNG)
+// Mỗi lần điều chỉnh là một bản ghi mới để lưu vết; bản ghi mới nhất của (bài tập, sinh viên) có hiệu lực.
+type GradeAdjustment struct {
+	ID            uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	AssignmentID  uuid.UUID  `gorm:"type:uuid;not null;index:idx_grade_adjustment" json:"assignment_id"`
+	Assignment    Assignment `gorm:"foreignKey:AssignmentID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	UserID        uuid.UUID  `gorm:"type:uuid;not null;index:idx_grade_adjustment" json:"user_id"`
+	User          User       `gorm:"foreignKey:UserID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	Score         *float64   `gorm:"type:numeric(5,2)" json:"score"`          // nil = hủy điều chỉnh, dùng lại điểm bài làm
+	PreviousScore *float64   `gorm:"type:numeric(5,2)" json:"previous_score"` // Điểm hiệu lực trước khi điều chỉnh
+	Reason        string     `gorm:"type:text;not null" json:"reason"`
+	AdjustedBy    uuid.UUID  `gorm:"type:uuid;not null" json:"adjusted_by"`
+	Adjuster      User       `gorm:"foreignKey:AdjustedBy;references:ID" json:"adjuster"`
+	CreatedAt     time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
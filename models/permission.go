@@ -0,0 +1,130 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Permission là quyền thao tác chi tiết, kiểm tra theo vai trò hệ thống và theo từng môn học
+type Permission string
+
+const (
+	PermSubjectCreate Permission = "subject.create"
+	PermSubjectEdit   Permission = "subject.edit"   // Sửa thông tin, chương, trạng thái môn học
+	PermSubjectDelete Permission = "subject.delete" // Xóa môn học
+	PermSubjectGrant  Permission = "subject.grant"  // Cấp / thu hồi quyền đồng giảng dạy
+
+	PermPodcastCreate  Permission = "podcast.create"
+	PermPodcastEdit    Permission = "podcast.edit"
+	PermPodcastPublish Permission = "podcast.publish" // Đổi trạng thái sang published / archived
+	PermPodcastDelete  Permission = "podcast.delete"
+
+	PermDocumentUpload Permission = "document.upload"
+	PermDocumentDelete Permission = "document.delete"
+
+	PermAssignmentManage Permission = "assignment.manage" // Tạo / sửa / xóa / công bố bài tập
+	PermAssignmentGrade  Permission = "assignment.grade"  // Xem bài nộp, chấm điểm, sổ điểm
+
+	PermCategoryManage Permission = "category.manage"
+	PermUserManage     Permission = "user.manage" // Tạo giảng viên, khóa / xóa tài khoản
+)
+
+// Quyền theo vai trò hệ thống. Admin có mọi quyền trên mọi nội dung;
+// giảng viên có quyền nhưng chỉ trên nội dung mình sở hữu hoặc được cấp.
+var rolePermissions = map[UserRole][]Permission{
+	RoleLecturer: {
+		PermSubjectCreate, PermSubjectEdit, PermSubjectDelete, PermSubjectGrant,
+		PermPodcastCreate, PermPodcastEdit, PermPodcastPublish, PermPodcastDelete,
+		PermDocumentUpload, PermDocumentDelete,
+		PermAssignmentManage, PermAssignmentGrade,
+		PermCategoryManage,
+	},
+}
+
+// Can kiểm tra vai trò có quyền p hay không (chưa xét phạm vi nội dung)
+func (r UserRole) Can(p Permission) bool {
+	if r == RoleAdmin {
+		return true
+	}
+	for _, perm := range rolePermissions[r] {
+		if perm == p {
+			return true
+		}
+	}
+	return false
+}
+
+// Permissions trả về danh sách quyền của vai trò
+func (r UserRole) Permissions() []Permission {
+	if r == RoleAdmin {
+		return []Permission{
+			PermSubjectCreate, PermSubjectEdit, PermSubjectDelete, PermSubjectGrant,
+			PermPodcastCreate, PermPodcastEdit, PermPodcastPublish, PermPodcastDelete,
+			PermDocumentUpload, PermDocumentDelete,
+			PermAssignmentManage, PermAssignmentGrade,
+			PermCategoryManage, PermUserManage,
+		}
+	}
+	return rolePermissions[r]
+}
+
+// Vai trò của giảng viên được cấp quyền trên môn học (người tạo môn học là chủ sở hữu, không lưu ở đây)
+const (
+	SubjectRoleCoTeacher = "co_teacher" // Đồng giảng dạy: quản lý nội dung như chủ, trừ xóa môn và cấp quyền
+	SubjectRoleAssistant = "assistant"  // Trợ giảng: soạn podcast, chấm bài; không công bố / xóa
+)
+
+var subjectRolePermissions = map[string][]Permission{
+	SubjectRoleCoTeacher: {
+		PermSubjectEdit,
+		PermPodcastCreate, PermPodcastEdit, PermPodcastPublish, PermPodcastDelete,
+		PermDocumentUpload,
+		PermAssignmentManage, PermAssignmentGrade,
+	},
+	SubjectRoleAssistant: {
+		PermPodcastCreate, PermPodcastEdit,
+		PermDocumentUpload,
+		PermAssignmentGrade,
+	},
+}
+
+// ValidSubjectRole kiểm tra vai trò môn học hợp lệ
+func ValidSubjectRole(role string) bool {
+	_, ok := subjectRolePermissions[role]
+	return ok
+}
+
+// SubjectRoleCan kiểm tra vai trò môn học có quyền p hay không
+func SubjectRoleCan(role string, p Permission) bool {
+	for _, perm := range subjectRolePermissions[role] {
+		if perm == p {
+			return true
+		}
+	}
+	return false
+}
+
+// SubjectRolesWith trả về các vai trò môn học có quyền p (dùng để lọc trong câu truy vấn)
+func SubjectRolesWith(p Permission) []string {
+	roles := make([]string, 0, len(subjectRolePermissions))
+	for role := range subjectRolePermissions {
+		if SubjectRoleCan(role, p) {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// SUBJECT MEMBER (GIẢNG VIÊN ĐƯỢC CẤP QUYỀN TRÊN MÔN HỌC)
+type SubjectMember struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	SubjectID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_subject_member" json:"subject_id"`
+	Subject   Subject   `gorm:"foreignKey:SubjectID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_subject_member;index" json:"user_id"`
+	User      User      `gorm:"foreignKey:UserID;references:ID;constraint:OnDelete:CASCADE;" json:"user"`
+	Role      string    `gorm:"type:varchar(20);not null" json:"role"` // co_teacher | assistant
+	GrantedBy uuid.UUID `gorm:"type:uuid;not null" json:"granted_by"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
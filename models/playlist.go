@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Loại danh sách phát
+const (
+	PlaylistKindPersonal = "personal" // Danh sách do sinh viên tự tạo
+	PlaylistKindQueue    = "queue"    // Hàng chờ "phát tiếp theo", mỗi user một danh sách, tạo khi cần
+	PlaylistKindRevision = "revision" // Danh sách ôn thi do giảng viên tuyển chọn, hiển thị trên trang môn học
+)
+
+// Phạm vi chia sẻ
+const (
+	PlaylistVisibilityPrivate = "private" // Chỉ chủ sở hữu
+	PlaylistVisibilityLink    = "link"    // Ai có link (share_token) đều xem được
+	PlaylistVisibilityClass   = "class"   // Sinh viên các lớp được chia sẻ
+)
+
+// PLAYLIST (DANH SÁCH PHÁT)
+type Playlist struct {
+	ID          uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	UserID      uuid.UUID  `gorm:"type:uuid;not null;index;uniqueIndex:idx_user_queue,where:kind = 'queue'" json:"user_id"` // Mỗi user một hàng chờ
+	User        User       `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE;" json:"-"`
+	Kind        string     `gorm:"type:varchar(20);not null;default:'personal';index" json:"kind"`
+	SubjectID   *uuid.UUID `gorm:"type:uuid;index" json:"subject_id,omitempty"` // Bắt buộc với revision
+	Subject     *Subject   `gorm:"foreignKey:SubjectID;constraint:OnDelete:CASCADE;" json:"subject,omitempty"`
+	Title       string     `gorm:"type:varchar(255);not null" json:"title"`
+	Description string     `gorm:"type:text" json:"description"`
+	Visibility  string     `gorm:"type:varchar(20);not null;default:'private'" json:"visibility"`
+	ShareToken  *string    `gorm:"type:varchar(64);uniqueIndex" json:"share_token,omitempty"` // Chỉ trả về cho chủ sở hữu
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+
+	Items   []PlaylistItem  `gorm:"foreignKey:PlaylistID;constraint:OnDelete:CASCADE;" json:"items,omitempty"`
+	Classes []PlaylistClass `gorm:"foreignKey:PlaylistID;constraint:OnDelete:CASCADE;" json:"classes,omitempty"`
+}
+
+// PLAYLIST ITEM (PODCAST TRONG DANH SÁCH, THEO THỨ TỰ)
+type PlaylistItem struct {
+	ID         uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	PlaylistID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_playlist_podcast;index:idx_playlist_position" json:"playlist_id"`
+	PodcastID  uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_playlist_podcast" json:"podcast_id"`
+	Podcast    Podcast   `gorm:"foreignKey:PodcastID;constraint:OnDelete:CASCADE;" json:"podcast"`
+	Position   int       `gorm:"not null;default:0;index:idx_playlist_position" json:"position"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// PLAYLIST CLASS (LỚP ĐƯỢC CHIA SẺ DANH SÁCH)
+type PlaylistClass struct {
+	PlaylistID uuid.UUID `gorm:"type:uuid;primaryKey" json:"playlist_id"`
+	ClassID    uuid.UUID `gorm:"type:uuid;primaryKey;index" json:"class_id"`
+	Class      Class     `gorm:"foreignKey:ClassID;constraint:OnDelete:CASCADE;" json:"class"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// QUESTION BANK (NGÂN HÀNG CÂU HỎI) - dùng lại cho nhiều bài tập của một môn / chương
+type QuestionBank struct {
+	ID          uuid.UUID      `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	SubjectID   uuid.UUID      `gorm:"type:uuid;not null;index" json:"subject_id"`
+	Subject     Subject        `gorm:"constraint:OnDelete:CASCADE;" json:"subject"`
+	ChapterID   *uuid.UUID     `gorm:"type:uuid;index" json:"chapter_id,omitempty"` // null = dùng chung cả môn
+	Chapter     *Chapter       `gorm:"constraint:OnDelete:SET NULL;" json:"chapter,omitempty"`
+	Title       string         `gorm:"type:varchar(255);not null" json:"title"`
+	Description string         `gorm:"type:text" json:"description"`
+	CreatedBy   uuid.UUID      `gorm:"type:uuid;not null" json:"created_by"`
+	Creator     User           `gorm:"foreignKey:CreatedBy;references:ID;constraint:OnDelete:CASCADE;" json:"creator"`
+	CreatedAt   time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
+	Questions   []BankQuestion `gorm:"foreignKey:BankID;constraint:OnDelete:CASCADE;" json:"questions,omitempty"`
+}
+
+// BANK QUESTION - cùng cấu trúc với AssignmentQuestion
+type BankQuestion struct {
+	ID          uuid.UUID    `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	BankID      uuid.UUID    `gorm:"type:uuid;not null;index" json:"bank_id"`
+	Bank        QuestionBank `gorm:"foreignKey:BankID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	ChapterID   *uuid.UUID   `gorm:"type:uuid;index" json:"chapter_id,omitempty"` // Chương của câu hỏi (mặc định theo ngân hàng)
+	Question    string       `gorm:"type:text;not null" json:"question"`
+	Type        string       `gorm:"type:varchar(30);default:'single_choice'" json:"type"`
+	Difficulty  string       `gorm:"type:varchar(50);default:'medium'" json:"difficulty"` // "easy", "medium", "hard"
+	Explanation string       `gorm:"type:text" json:"explanation"`
+	Rubric      string       `gorm:"type:text" json:"rubric,omitempty"`
+	Points      float64      `gorm:"type:numeric(5,2);default:1.0" json:"points"`
+	CreatedAt   time.Time    `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time    `gorm:"autoUpdateTime" json:"updated_at"`
+	Options     []BankOption `gorm:"foreignKey:QuestionID;constraint:OnDelete:CASCADE;" json:"options"`
+}
+
+// BANK OPTION
+type BankOption struct {
+	ID         uuid.UUID    `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	QuestionID uuid.UUID    `gorm:"type:uuid;not null" json:"question_id"`
+	Question   BankQuestion `gorm:"foreignKey:QuestionID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	OptionText string       `gorm:"type:text;not null" json:"option_text"`
+	MatchText  string       `gorm:"type:text" json:"match_text,omitempty"`
+	IsCorrect  bool         `gorm:"default:false" json:"is_correct"`
+	SortOrder  int          `gorm:"default:0" json:"sort_order"`
+}
+
+// ASSIGNMENT RULE - quy tắc rút câu hỏi ngẫu nhiên, vd: "5 câu dễ chương 2 từ ngân hàng X"
+type AssignmentRule struct {
+	ID           uuid.UUID    `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	AssignmentID uuid.UUID    `gorm:"type:uuid;not null;index" json:"assignment_id"`
+	Assignment   Assignment   `gorm:"foreignKey:AssignmentID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	BankID       uuid.UUID    `gorm:"type:uuid;not null" json:"bank_id"`
+	Bank         QuestionBank `gorm:"foreignKey:BankID;references:ID;constraint:OnDelete:CASCADE;" json:"bank"`
+	ChapterID    *uuid.UUID   `gorm:"type:uuid" json:"chapter_id,omitempty"`        // Lọc theo chương (tùy chọn)
+	Difficulty   string       `gorm:"type:varchar(50)" json:"difficulty,omitempty"` // Lọc theo độ khó, rỗng = mọi độ khó
+	Count        int          `gorm:"not null" json:"count"`                        // Số câu cần rút
+	Points       float64      `gorm:"type:numeric(5,2);default:0" json:"points"`    // Điểm mỗi câu, 0 = giữ điểm của câu trong ngân hàng
+	SortOrder    int          `gorm:"default:0" json:"sort_order"`
+}
+
+// SUBMISSION QUESTION - đề riêng của từng lần làm bài (câu hỏi + thứ tự đáp án đã cố định)
+type SubmissionQuestion struct {
+	ID           uuid.UUID            `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	SubmissionID uuid.UUID            `gorm:"type:uuid;not null;index" json:"submission_id"`
+	Submission   AssignmentSubmission `gorm:"foreignKey:SubmissionID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	QuestionID   uuid.UUID            `gorm:"type:uuid;not null" json:"question_id"`
+	Question     AssignmentQuestion   `gorm:"foreignKey:QuestionID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	SortOrder    int                  `gorm:"not null" json:"sort_order"`
+	OptionOrder  UUIDList             `gorm:"type:jsonb" json:"option_order"` // Thứ tự hiển thị đáp án
+	MatchKeys    StringMap            `gorm:"type:jsonb" json:"-"`            // Câu ghép nối: id lựa chọn vế phải -> option_id
+}
@@ -78,6 +78,7 @@ type QuizAttemptHistory struct {
 	SelectedID     uuid.UUID    `gorm:"type:uuid;not null" json:"selected_id"`
 	SelectedOption QuizOption   `gorm:"foreignKey:SelectedID;references:ID;constraint:OnDelete:CASCADE;" json:"selected_option"`
 	IsCorrect      bool         `gorm:"default:false" json:"is_correct"`
+	TimeSpentSec   int          `gorm:"default:0" json:"time_spent_sec"` // Thời gian làm câu này (giây)
 	AnsweredAt     time.Time    `gorm:"autoCreateTime" json:"answered_at"`
 }
 
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Lý do phiên đăng nhập bị thu hồi
+const (
+	SessionRevokedLogout         = "logout"          // Người dùng đăng xuất
+	SessionRevokedByUser         = "revoked_by_user" // Đăng xuất từ xa trong danh sách phiên
+	SessionRevokedPasswordChange = "password_change" // Đổi / đặt lại mật khẩu
+	SessionRevokedAccountLocked  = "account_locked"  // Admin khóa tài khoản
+	SessionRevokedTokenReuse     = "token_reuse"     // Refresh token cũ bị dùng lại, nghi lộ token
+)
+
+// USER SESSION (PHIÊN ĐĂNG NHẬP)
+// Mỗi lần đăng nhập tạo một phiên giữ refresh token (chỉ lưu hash). Access token mang sid của phiên.
+type UserSession struct {
+	ID                uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	UserID            uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	User              User       `gorm:"foreignKey:UserID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	RefreshTokenHash  string     `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	PreviousTokenHash string     `gorm:"type:varchar(64);index" json:"-"` // Token trước lần xoay gần nhất, dùng để phát hiện dùng lại
+	DeviceName        string     `gorm:"type:varchar(100)" json:"device_name"`
+	UserAgent         string     `gorm:"type:text" json:"user_agent"`
+	IPAddress         string     `gorm:"type:varchar(64)" json:"ip_address"`
+	LoginMethod       string     `gorm:"type:varchar(20)" json:"login_method"` // password | google
+	ExpiresAt         time.Time  `gorm:"not null;index" json:"expires_at"`
+	LastUsedAt        time.Time  `json:"last_used_at"`
+	RevokedAt         *time.Time `gorm:"index" json:"revoked_at,omitempty"`
+	RevokeReason      string     `gorm:"type:varchar(30)" json:"revoke_reason,omitempty"`
+	CreatedAt         time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
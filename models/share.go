@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Kênh chia sẻ (cũng là utm_source của link chia sẻ)
+const (
+	ShareChannelFacebook  = "facebook"
+	ShareChannelMessenger = "messenger"
+	ShareChannelZalo      = "zalo"
+	ShareChannelTwitter   = "twitter"
+	ShareChannelLinkedIn  = "linkedin"
+	ShareChannelEmail     = "email"
+	ShareChannelCopy      = "copy" // Sao chép link
+	ShareChannelOther     = "other"
+)
+
+// ShareChannels là các kênh hợp lệ, theo thứ tự hiển thị
+var ShareChannels = []string{
+	ShareChannelFacebook, ShareChannelMessenger, ShareChannelZalo, ShareChannelTwitter,
+	ShareChannelLinkedIn, ShareChannelEmail, ShareChannelCopy,
+}
+
+// Loại sự kiện chia sẻ
+const (
+	ShareActionShare = "share" // Người dùng bấm chia sẻ
+	ShareActionOpen  = "open"  // Có người mở link đã chia sẻ (không tính bot đọc thẻ Open Graph)
+)
+
+// PODCAST SHARE (LƯỢT CHIA SẺ / LƯỢT MỞ LINK CHIA SẺ THEO KÊNH)
+type PodcastShare struct {
+	ID        uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	PodcastID uuid.UUID  `gorm:"type:uuid;not null;index:idx_podcast_share_channel" json:"podcast_id"`
+	Podcast   Podcast    `gorm:"foreignKey:PodcastID;constraint:OnDelete:CASCADE;" json:"-"`
+	UserID    *uuid.UUID `gorm:"type:uuid;index" json:"user_id,omitempty"` // nil khi khách chia sẻ / mở link
+	Channel   string     `gorm:"type:varchar(20);not null;index:idx_podcast_share_channel" json:"channel"`
+	Action    string     `gorm:"type:varchar(10);not null;default:'share'" json:"action"`
+	// Hash của (podcast, kênh, loại, ngày, user hoặc IP + trình duyệt): mỗi người chỉ được tính một lần mỗi ngày
+	DedupKey  *string   `gorm:"type:varchar(64);uniqueIndex" json:"-"`
+	CreatedAt time.Time `gorm:"autoCreateTime;index" json:"created_at"`
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Nhà cung cấp đăng nhập một lần của trường
+const (
+	SSOProviderOIDC = "oidc"
+	SSOProviderSAML = "saml"
+)
+
+// USER IDENTITY (TÀI KHOẢN SSO LIÊN KẾT)
+// Một user có thể liên kết với định danh ở IdP; tra theo (provider, subject) thay vì email vì email có thể đổi
+type UserIdentity struct {
+	ID          uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	UserID      uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	User        User       `gorm:"foreignKey:UserID;references:ID;constraint:OnDelete:CASCADE;" json:"-"`
+	Provider    string     `gorm:"type:varchar(20);not null;uniqueIndex:idx_identity_subject" json:"provider"` // oidc | saml
+	Subject     string     `gorm:"type:varchar(255);not null;uniqueIndex:idx_identity_subject" json:"subject"` // sub (OIDC) / NameID (SAML)
+	Email       string     `gorm:"type:varchar(150)" json:"email"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// SSO LOGIN STATE (TRẠNG THÁI CHUYỂN HƯỚNG SANG IDP)
+// Lưu state / nonce / PKCE (OIDC) hoặc ID của AuthnRequest (SAML) để kiểm tra khi IdP gọi lại
+type SSOLoginState struct {
+	ID           uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	StateHash    string    `gorm:"type:varchar(64);uniqueIndex;not null"`
+	Provider     string    `gorm:"type:varchar(20);not null"`
+	Nonce        string    `gorm:"type:varchar(100)"`
+	CodeVerifier string    `gorm:"type:varchar(100)"`
+	RequestID    string    `gorm:"type:varchar(100);index"`
+	BrowserHash  string    `gorm:"type:varchar(64)"` // Hash của cookie gắn state với trình duyệt đã bắt đầu đăng nhập
+	RedirectPath string    `gorm:"type:text"`        // Trang FE quay lại sau khi đăng nhập
+	ExpiresAt    time.Time `gorm:"not null"`
+	CreatedAt    time.Time `gorm:"autoCreateTime"`
+}
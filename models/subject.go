@@ -12,6 +12,7 @@ type Subject struct {
 	CourseCode string     `gorm:"type:varchar(50);unique;" json:"course_code"`
 	Status     bool       `gorm:"default:true;not null" json:"status"`      // trạng thái (true: active, false: inactive)
 	Slug       string     `gorm:"size:255;uniqueIndex" json:"slug"`         // slug cho URL thân thiện
+	PublicFeed bool       `gorm:"default:true;not null" json:"public_feed"` // false: feed RSS chỉ mở bằng link riêng của từng user
 	CreatedBy  *uuid.UUID `gorm:"type:uuid;default:null" json:"created_by"` // có thể null
 	UpdatedBy  *uuid.UUID `gorm:"type:uuid;default:null" json:"updated_by"` // có thể null
 	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EMAIL VERIFICATION (XÁC MINH EMAIL KHI ĐĂNG KÝ)
+// Chỉ lưu hash của token gửi qua email
+type EmailVerification struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index"`
+	User      User      `gorm:"foreignKey:UserID;references:ID;constraint:OnDelete:CASCADE;"`
+	TokenHash string    `gorm:"type:varchar(64);uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
+
+// TWO FACTOR RECOVERY CODE (MÃ KHÔI PHỤC 2FA)
+// Mỗi mã dùng được một lần khi mất thiết bị sinh mã
+type TwoFactorRecoveryCode struct {
+	ID        uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null;index"`
+	User      User       `gorm:"foreignKey:UserID;references:ID;constraint:OnDelete:CASCADE;"`
+	CodeHash  string     `gorm:"type:varchar(64);not null"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `gorm:"autoCreateTime"`
+}
+
+// Mục đích của thử thách đăng nhập
+const (
+	LoginChallengeVerify = "verify" // Đã bật 2FA: nhập mã TOTP / mã khôi phục
+	LoginChallengeSetup  = "setup"  // Admin / giảng viên chưa bật 2FA: bắt buộc thiết lập trước khi vào hệ thống
+	LoginChallengeSSO    = "sso"    // Đăng nhập SSO xong, FE đổi vé lấy phiên (không đưa token lên URL)
+)
+
+// LOGIN CHALLENGE (BƯỚC 2 CỦA ĐĂNG NHẬP)
+// Mật khẩu / Google đúng nhưng cần thêm mã 2FA; token thử thách chỉ lưu hash và bị giới hạn số lần thử
+type LoginChallenge struct {
+	ID          uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	UserID      uuid.UUID `gorm:"type:uuid;not null;index"`
+	User        User      `gorm:"foreignKey:UserID;references:ID;constraint:OnDelete:CASCADE;"`
+	TokenHash   string    `gorm:"type:varchar(64);uniqueIndex;not null"`
+	Purpose     string    `gorm:"type:varchar(10);not null"`
+	LoginMethod string    `gorm:"type:varchar(20)"`
+	Attempts    int       `gorm:"default:0"`
+	ExpiresAt   time.Time `gorm:"not null"`
+	CreatedAt   time.Time `gorm:"autoCreateTime"`
+}
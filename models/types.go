@@ -0,0 +1,99 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+// UUIDList lưu danh sách UUID dưới dạng jsonb (vd: các đáp án được chọn)
+type UUIDList []uuid.UUID
+
+func (l UUIDList) Value() (driver.Value, error) {
+	if l == nil {
+		return "[]", nil
+	}
+	b, err := json.Marshal(l)
+	return string(b), err
+}
+
+func (l *UUIDList) Scan(value interface{}) error {
+	if value == nil {
+		*l = nil
+		return nil
+	}
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return errors.New("UUIDList: kiểu dữ liệu không hỗ trợ")
+	}
+	return json.Unmarshal(data, l)
+}
+
+// StringMap lưu map string -> string dưới dạng jsonb (vd: các cặp ghép nối)
+type StringMap map[string]string
+
+func (m StringMap) Value() (driver.Value, error) {
+	if m == nil {
+		return "{}", nil
+	}
+	b, err := json.Marshal(m)
+	return string(b), err
+}
+
+func (m *StringMap) Scan(value interface{}) error {
+	if value == nil {
+		*m = nil
+		return nil
+	}
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return errors.New("StringMap: kiểu dữ liệu không hỗ trợ")
+	}
+	return json.Unmarshal(data, m)
+}
+
+// ChapterMarker là một mốc chương trong audio podcast (Podcasting 2.0 chapters)
+type ChapterMarker struct {
+	StartSec int    `json:"start_sec"`
+	Title    string `json:"title"`
+}
+
+// ChapterMarkerList lưu các mốc chương dưới dạng jsonb, sắp theo StartSec
+type ChapterMarkerList []ChapterMarker
+
+func (l ChapterMarkerList) Value() (driver.Value, error) {
+	if l == nil {
+		return "[]", nil
+	}
+	b, err := json.Marshal(l)
+	return string(b), err
+}
+
+func (l *ChapterMarkerList) Scan(value interface{}) error {
+	if value == nil {
+		*l = nil
+		return nil
+	}
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return errors.New("ChapterMarkerList: kiểu dữ liệu không hỗ trợ")
+	}
+	return json.Unmarshal(data, l)
+}
@@ -24,6 +24,18 @@ type User struct {
 	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
 	Status    *bool     `gorm:"type:boolean" json:"status"`
 
+	// Xác minh email khi đăng ký; tài khoản cũ / Google / do admin tạo mặc định đã xác minh
+	EmailVerified *bool `gorm:"type:boolean;default:true" json:"email_verified"`
+
+	// Xác thực 2 lớp (TOTP). Secret được sinh khi bắt đầu thiết lập, chỉ có hiệu lực khi TwoFactorEnabled
+	TwoFactorEnabled   bool       `gorm:"default:false" json:"two_factor_enabled"`
+	TwoFactorSecret    string     `gorm:"type:varchar(64)" json:"-"`
+	TwoFactorLastStep  int64      `gorm:"default:0" json:"-"` // Bước thời gian của mã TOTP dùng gần nhất, chống dùng lại mã
+	TwoFactorEnabledAt *time.Time `json:"two_factor_enabled_at,omitempty"`
+
+	// Token cũ không gắn phiên (phát hành trước khi có UserSession) cấp trước thời điểm này bị từ chối
+	SessionsRevokedAt *time.Time `json:"-"`
+
 	// Quan hệ
 	Documents          []Document         `json:"documents"`
 	Favorites          []Favorite         `json:"favorites"`
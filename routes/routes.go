@@ -4,6 +4,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/vnkhanh/e-podcast-backend/controllers"
 	"github.com/vnkhanh/e-podcast-backend/middleware"
+	"github.com/vnkhanh/e-podcast-backend/models"
 	"github.com/vnkhanh/e-podcast-backend/ws"
 	"gorm.io/gorm"
 )
@@ -14,23 +15,67 @@ func SetupRouter(r *gin.Engine, db *gorm.DB) *gin.Engine {
 	})
 	r.GET("/health", controllers.HealthCheck)
 
+	// Trang chia sẻ có thẻ Open Graph và ảnh chia sẻ, dành cho bot mạng xã hội
+	r.GET("/share/podcasts/:podcast_id", controllers.PodcastSharePageHandler(db))
+	r.GET("/share/podcasts/:podcast_id/image", controllers.PodcastShareImageHandler(db))
+
 	api := r.Group("/api")
 	api.GET("/search", controllers.SearchAutocomplete(db))
 	api.GET("/search/full", controllers.SearchFullHandler(db))
 	api.GET("/podcasts/:podcast_id/share-social", controllers.SharePodcastSocialHandler(db))
 
+	// Giới hạn tần suất theo nhóm route, ghi đè bằng RATE_LIMIT_<TÊN> (vd RATE_LIMIT_LOGIN=10/m, "off" để tắt)
+	authLimit := middleware.RateLimitByIP("auth", "60/m")
+	loginLimit := middleware.RateLimitByIP("login", "10/m")
+	emailLimit := middleware.RateLimitByIP("email", "5/15m")
+	aiLimit := middleware.RateLimitByUser("ai", "20/h")
+	accessLimit := middleware.RateLimitByUser("assignment_access", "10/m")
+	eventsLimit := middleware.RateLimitByUser("listening_events", "120/m")
+	shareLimit := middleware.RateLimitByIP("share", "30/m")
+
+	api.POST("/podcasts/:podcast_id/shares", shareLimit, middleware.OptionalAuthMiddleware(), controllers.RecordPodcastShareHandler(db))
+
 	auth := api.Group("/auth")
 	{
+		auth.Use(authLimit)
 		auth.POST("/register", controllers.Register)
-		auth.POST("/login", controllers.Login)
-		auth.POST("/logingoogle", controllers.GoogleLogin)
+		auth.POST("/login", loginLimit, controllers.Login)
+		auth.POST("/logingoogle", loginLimit, controllers.GoogleLogin)
 		auth.PUT("/change-password", middleware.AuthMiddleware(), controllers.ChangePassword)
-		auth.POST("/forgot-password", controllers.ForgotPassword)
+		auth.POST("/forgot-password", emailLimit, controllers.ForgotPassword)
 		auth.POST("/reset-password", controllers.ResetPassword)
 		auth.GET("/verify-reset-token", controllers.VerifyResetToken)
+		auth.POST("/refresh", controllers.RefreshToken)
+		auth.POST("/logout", middleware.AuthMiddleware(), controllers.Logout)
+		auth.POST("/verify-email", controllers.VerifyEmail)
+		auth.POST("/resend-verification", emailLimit, controllers.ResendVerificationEmail)
+		auth.POST("/login/2fa", loginLimit, controllers.VerifyLoginTwoFactor)
+		auth.POST("/login/2fa/setup", loginLimit, controllers.SetupLoginTwoFactor)
+		auth.POST("/login/2fa/enable", loginLimit, controllers.EnableLoginTwoFactor)
+
+		// Đăng nhập một lần (SSO) của trường
+		sso := auth.Group("/sso")
+		{
+			sso.GET("/providers", controllers.GetSSOProviders)
+			sso.POST("/exchange", loginLimit, controllers.ExchangeSSOTicket)
+			sso.GET("/oidc/login", controllers.OIDCLogin)
+			sso.GET("/oidc/callback", controllers.OIDCCallback)
+			sso.GET("/saml/metadata", controllers.SAMLMetadata)
+			sso.GET("/saml/login", controllers.SAMLLogin)
+			sso.POST("/saml/acs", controllers.SAMLACS)
+		}
 		// auth.POST("/loginfacebook", controllers.FacebookLogin)
 	}
 
+	// Feed RSS / iTunes công khai, môn không công khai mở bằng ?token= của user
+	feeds := api.Group("/feeds")
+	{
+		feeds.Use(middleware.DBMiddleware(db))
+		feeds.GET("/subjects/:slug", controllers.GetSubjectFeed)
+		feeds.GET("/categories/:slug", controllers.GetCategoryFeed)
+		feeds.GET("/podcasts/:id/chapters", controllers.GetPodcastChapters)
+	}
+
 	user := api.Group("/user")
 	{
 		user.Use(middleware.DBMiddleware(db))
@@ -43,6 +88,7 @@ func SetupRouter(r *gin.Engine, db *gorm.DB) *gin.Engine {
 			account.GET("/listening-history/:podcast_id", controllers.GetPodcastHistory)
 			account.DELETE("/listening-history/:podcast_id", controllers.DeletePodcastHistory)
 			account.DELETE("/listening-history", controllers.ClearAllHistory)
+			account.POST("/listening-events", eventsLimit, controllers.IngestListeningEvents)
 
 			// favorite
 			account.GET("/favorites", controllers.GetFavorites)
@@ -50,17 +96,56 @@ func SetupRouter(r *gin.Engine, db *gorm.DB) *gin.Engine {
 			account.DELETE("/favorites/:podcast_id", controllers.RemoveFavorite)
 			account.GET("/favorite/:podcast_id", controllers.CheckFavorite)
 
+			// phiên đăng nhập
+			account.GET("/sessions", controllers.GetMySessions)
+			account.POST("/sessions/revoke-others", controllers.RevokeOtherSessions)
+			account.DELETE("/sessions/:id", controllers.RevokeMySession)
+
+			// xác thực 2 lớp
+			account.GET("/2fa", controllers.GetTwoFactorStatus)
+			account.POST("/2fa/setup", controllers.SetupTwoFactor)
+			account.POST("/2fa/enable", controllers.EnableTwoFactor)
+			account.POST("/2fa/disable", controllers.DisableTwoFactor)
+			account.POST("/2fa/recovery-codes", controllers.RegenerateRecoveryCodes)
+
+			// danh sách phát
+			account.GET("/playlists", controllers.GetMyPlaylists)
+			account.POST("/playlists", controllers.CreatePlaylist)
+			account.PUT("/playlists/:id", controllers.UpdatePlaylist)
+			account.DELETE("/playlists/:id", controllers.DeletePlaylist)
+			account.POST("/playlists/:id/items", controllers.AddPlaylistItem)
+			account.DELETE("/playlists/:id/items/:podcast_id", controllers.RemovePlaylistItem)
+			account.PUT("/playlists/:id/items/order", controllers.ReorderPlaylistItems)
+			account.POST("/playlists/:id/share-link", controllers.RegeneratePlaylistShareLink)
+
+			// hàng chờ phát tiếp theo
+			account.GET("/queue", controllers.GetQueue)
+			account.POST("/queue", controllers.AddToQueue)
+			account.PUT("/queue/order", controllers.ReorderQueue)
+			account.DELETE("/queue/:podcast_id", controllers.RemoveFromQueue)
+			account.DELETE("/queue", controllers.ClearQueue)
+
+			// link feed RSS cho ứng dụng podcast
+			account.GET("/feeds", controllers.GetMyFeeds)
+			account.POST("/feeds/token", controllers.RegenerateFeedToken)
+
 		}
 		user.GET("/categories/featured", controllers.GetCategoriesUserPopular)
 		user.GET("/categories", controllers.GetCategoriesUser)
 		user.GET("/categories/:slug/podcasts", controllers.GetPodcastsByCategory)
 		user.GET("/podcasts/featured", controllers.GetFeaturedPodcasts)
 		user.GET("/podcasts/latest", controllers.GetLatestPodcasts)
+		user.GET("/home", middleware.OptionalAuthMiddleware(), controllers.GetHomeFeed) // trang chủ cá nhân hóa
+		user.GET("/podcasts/:id/similar", middleware.OptionalAuthMiddleware(), controllers.GetSimilarPodcasts)
+		user.GET("/playlists/shared/:token", middleware.OptionalAuthMiddleware(), controllers.GetSharedPlaylist)
+		user.GET("/playlists/:id", middleware.OptionalAuthMiddleware(), controllers.GetPlaylist)
 
 		user.GET("/podcasts/:id", controllers.GetPodcastByID)
-		user.POST("/documents/:id/flashcards", middleware.AuthMiddleware(), controllers.GenerateFlashcardsFromDocument)
+		user.POST("/documents/:id/flashcards", middleware.AuthMiddleware(), aiLimit, controllers.GenerateFlashcardsFromDocument)
 		user.GET("/podcasts/:id/flashcards", middleware.AuthMiddleware(), controllers.GetFlashcardsByPodcast)
-		user.GET("/documents/:id", controllers.GetDocumentDetail)
+		user.GET("/podcasts/:id/flashcards/export", middleware.AuthMiddleware(), controllers.ExportFlashcards)
+		user.POST("/podcasts/:id/flashcards/import", middleware.AuthMiddleware(), controllers.ImportFlashcards)
+		user.GET("/documents/:id", middleware.OptionalAuthMiddleware(), controllers.GetPublishedDocumentDetail)
 		user.GET("/podcasts", controllers.GetAllPublishedPodcasts)
 		user.GET("/tagsget", controllers.GetTags)
 		user.GET("/categoriesget", controllers.GetCategoriesGet)
@@ -72,13 +157,13 @@ func SetupRouter(r *gin.Engine, db *gorm.DB) *gin.Engine {
 		user.GET("/subjects", middleware.OptionalAuthMiddleware(), controllers.GetAllSubjectsUser)         // ds môn học với tiến độ
 
 		// Quiz routes
-		user.POST("/documents/:id/quizzes", middleware.AuthMiddleware(), controllers.GenerateQuizzesFromDocument) // tạo quiz
-		user.GET("/podcasts/:id/quiz-sets", middleware.AuthMiddleware(), controllers.GetQuizSetsByPodcast)        // lấy ds quiz theo podcast id
-		user.GET("/quiz-sets/:id/questions", middleware.AuthMiddleware(), controllers.GetQuizQuestions)           // lấy ds câu hỏi của quiz
-		user.POST("/quiz-sets/:id/submit", middleware.AuthMiddleware(), controllers.SubmitQuizAttempt)            // gửi câu hỏi
-		user.GET("/quiz-attempts", middleware.AuthMiddleware(), controllers.GetUserQuizAttempts)                  // lấy
-		user.GET("/quiz-attempts/:attemptID", middleware.AuthMiddleware(), controllers.GetQuizAttemptDetail)      // gửi câu hỏi
-		user.GET("/quiz-sets/:id/attempts", middleware.AuthMiddleware(), controllers.GetQuizAttemptsBySet)        // lấy lịch sử làm quiz
+		user.POST("/documents/:id/quizzes", middleware.AuthMiddleware(), aiLimit, controllers.GenerateQuizzesFromDocument) // tạo quiz
+		user.GET("/podcasts/:id/quiz-sets", middleware.AuthMiddleware(), controllers.GetQuizSetsByPodcast)                 // lấy ds quiz theo podcast id
+		user.GET("/quiz-sets/:id/questions", middleware.AuthMiddleware(), controllers.GetQuizQuestions)                    // lấy ds câu hỏi của quiz
+		user.POST("/quiz-sets/:id/submit", middleware.AuthMiddleware(), controllers.SubmitQuizAttempt)                     // gửi câu hỏi
+		user.GET("/quiz-attempts", middleware.AuthMiddleware(), controllers.GetUserQuizAttempts)                           // lấy
+		user.GET("/quiz-attempts/:attemptID", middleware.AuthMiddleware(), controllers.GetQuizAttemptDetail)               // gửi câu hỏi
+		user.GET("/quiz-sets/:id/attempts", middleware.AuthMiddleware(), controllers.GetQuizAttemptsBySet)                 // lấy lịch sử làm quiz
 		user.DELETE("/quiz-sets/:quizset_id", middleware.AuthMiddleware(), controllers.DeleteQuizSetByCurrentUser)
 		user.DELETE("/quiz-sets", middleware.AuthMiddleware(), controllers.DeleteAllQuizSetsByCurrentUser)
 
@@ -98,12 +183,19 @@ func SetupRouter(r *gin.Engine, db *gorm.DB) *gin.Engine {
 
 		user.GET("/assignments/:id/submissions/:submissionId", middleware.AuthMiddleware(), controllers.GetSubmissionDetail)
 
-		user.POST("/assignments/:id/verify-password", middleware.AuthMiddleware(), controllers.VerifyAssignmentPassword)
+		user.POST("/assignments/:id/verify-password", middleware.AuthMiddleware(), accessLimit, controllers.VerifyAssignmentPassword)
 
-		user.POST("/assignments/:id/start", middleware.AuthMiddleware(), controllers.StartAssignment)
+		user.POST("/assignments/:id/start", middleware.AuthMiddleware(), accessLimit, controllers.StartAssignment)
 		user.POST("/assignments/submissions/:submissionId/save", middleware.AuthMiddleware(), controllers.SaveAssignmentProgress)
+		user.POST("/assignments/submissions/:submissionId/events", middleware.AuthMiddleware(), controllers.LogAttemptEvents)
 
 		user.GET("/assignments/:id/check-draft", middleware.AuthMiddleware(), controllers.CheckDraftSubmission)
+
+		// Lớp học phần
+		user.GET("/classes", middleware.AuthMiddleware(), controllers.GetMyClasses)
+		user.POST("/classes/join", middleware.AuthMiddleware(), controllers.JoinClass)
+		user.GET("/classes/assignments", middleware.AuthMiddleware(), controllers.GetMyClassAssignments)
+		user.DELETE("/classes/:id", middleware.AuthMiddleware(), controllers.LeaveClass)
 	}
 	admin := api.Group("/admin")
 	{
@@ -113,13 +205,14 @@ func SetupRouter(r *gin.Engine, db *gorm.DB) *gin.Engine {
 			middleware.RequireRoles("admin", "teacher"),
 		)
 		admin.GET("/me", controllers.GetProfileUser)
+		admin.GET("/me/permissions", controllers.GetMyPermissions)
 
 	}
 
 	// ==================== Quản lý môn học ====================
 	subjects := admin.Group("/subjects")
 	{
-		subjects.POST("", controllers.CreateSubject)
+		subjects.POST("", middleware.RequirePermission(models.PermSubjectCreate), controllers.CreateSubject)
 		subjects.GET("", controllers.GetSubjects)
 		subjects.GET("/get", controllers.GetSubjectsGet)
 		subjects.GET("/:id", controllers.GetSubjectDetail)
@@ -127,6 +220,11 @@ func SetupRouter(r *gin.Engine, db *gorm.DB) *gin.Engine {
 		subjects.DELETE("/:id", controllers.DeleteSubject)
 		subjects.PATCH("/:id/toggle-status", controllers.ToggleSubjectStatus)
 
+		// Đồng giảng dạy
+		subjects.GET("/:id/members", controllers.GetSubjectMembers)
+		subjects.POST("/:id/members", controllers.UpsertSubjectMember)
+		subjects.DELETE("/:id/members/:userId", controllers.DeleteSubjectMember)
+
 		// Chương
 		subjects.GET("/:id/chapters", controllers.ListChaptersBySubject)
 		subjects.POST("/:id/chapters", controllers.CreateChapter)
@@ -136,22 +234,22 @@ func SetupRouter(r *gin.Engine, db *gorm.DB) *gin.Engine {
 	// ==================== Quản lý danh mục ====================
 	categories := admin.Group("/categories")
 	{
-		categories.POST("", controllers.CreateCategory)
+		categories.POST("", middleware.RequirePermission(models.PermCategoryManage), controllers.CreateCategory)
 		categories.GET("", controllers.GetCategories)
 		categories.GET("/get", controllers.GetCategoriesGet)
 		categories.GET("/:id", controllers.GetCategoryDetail)
-		categories.PUT("/:id", controllers.UpdateCategory)
-		categories.DELETE("/:id", controllers.DeleteCategory)
-		categories.PATCH("/:id/toggle-status", controllers.ToggleCategoryStatus)
+		categories.PUT("/:id", middleware.RequirePermission(models.PermCategoryManage), controllers.UpdateCategory)
+		categories.DELETE("/:id", middleware.RequirePermission(models.PermCategoryManage), controllers.DeleteCategory)
+		categories.PATCH("/:id/toggle-status", middleware.RequirePermission(models.PermCategoryManage), controllers.ToggleCategoryStatus)
 	}
 
 	// ==================== Quản lý tài liệu ====================
 	documents := admin.Group("/documents")
 	{
-		documents.POST("", controllers.UploadDocument)
+		documents.POST("", middleware.RequirePermission(models.PermDocumentUpload), aiLimit, controllers.UploadDocument)
 		documents.GET("", controllers.GetDocuments)
 		documents.GET("/:id", controllers.GetDocumentDetail)
-		documents.DELETE("/:id", controllers.DeleteDocument)
+		documents.DELETE("/:id", middleware.RequirePermission(models.PermDocumentDelete), controllers.DeleteDocument)
 		// documents.PUT("/:id", controllers.UpdateDocument)
 		// documents.PATCH("/:id/toggle-status", controllers.ToggleDocumentStatus)
 	}
@@ -159,18 +257,20 @@ func SetupRouter(r *gin.Engine, db *gorm.DB) *gin.Engine {
 	// ==================== Quản lý podcast ====================
 	podcasts := admin.Group("/podcasts")
 	{
-		podcasts.POST("", controllers.CreatePodcastWithUpload)
+		podcasts.POST("", middleware.RequirePermission(models.PermPodcastCreate), aiLimit, controllers.CreatePodcastWithUpload)
 		podcasts.GET("", controllers.GetPodcasts)
 		podcasts.GET("/:id", controllers.GetPodcastDetail)
 		podcasts.DELETE("/:id", controllers.DeletePodcast)
 		podcasts.PUT("/:id", controllers.UpdatePodcast)
+		podcasts.POST("/:id/flashcards/import", controllers.ImportCuratedFlashcards)
 	}
 	// ==================== Quản lý bài tập ====================
 
 	assignments := admin.Group("/assignments")
 	{
-		assignments.POST("/from-gemini", controllers.CreateAssignmentFromGemini)
+		assignments.POST("/from-gemini", aiLimit, controllers.CreateAssignmentFromGemini)
 		assignments.POST("/from-file", controllers.CreateAssignmentFromFile)
+		assignments.GET("/import-template", controllers.DownloadQuestionTemplate)
 		assignments.GET("", controllers.GetTeacherAssignments)
 		assignments.PUT("/:id", controllers.UpdateAssignment)
 		assignments.DELETE("/:id", controllers.DeleteAssignment)
@@ -179,18 +279,93 @@ func SetupRouter(r *gin.Engine, db *gorm.DB) *gin.Engine {
 		assignments.GET("/podcasts/by-chapter/:chapterID", controllers.GetPodcastsByChapter)
 		assignments.GET("/:id/submissions", controllers.GetAssignmentSubmissions)
 		assignments.GET("/submissions/:id", controllers.GetAssignmentSubmissionDetailTeacher)
+		assignments.GET("/submissions/:id/events", controllers.GetSubmissionEvents)
 
 		// Câu hỏi
 		assignments.GET("/:id/questions", controllers.GetAssignmentQuestionsForTeacher)
 		assignments.POST("/:id/questions", controllers.CreateAssignmentQuestion)
+		assignments.POST("/:id/questions/import", controllers.ImportAssignmentQuestions)
+		assignments.GET("/:id/questions/export", controllers.ExportAssignmentQuestions)
 		assignments.PUT("/questions/:questionId", controllers.UpdateAssignmentQuestion)
 		assignments.DELETE("/questions/:questionId", controllers.DeleteAssignmentQuestion)
 		assignments.GET("/:id", controllers.GetAssignmentDetailTeacher)
 
+		// Rule rút câu hỏi từ ngân hàng
+		assignments.GET("/:id/rules", controllers.GetAssignmentRules)
+		assignments.PUT("/:id/rules", controllers.UpdateAssignmentRules)
+
+		// Giao bài cho lớp
+		assignments.GET("/:id/classes", controllers.GetAssignmentClasses)
+		assignments.PUT("/:id/classes", controllers.UpdateAssignmentClasses)
+
+		// Chấm câu tự luận
+		assignments.GET("/grading-queue", controllers.GetGradingQueue)
+		assignments.PUT("/answers/:answerId/grade", controllers.GradeAssignmentAnswer)
+		assignments.POST("/answers/:answerId/ai-suggest", aiLimit, controllers.SuggestAssignmentAnswerGrade)
+
 		// Xuất file
 		assignments.GET("/:id/export", controllers.ExportAssignmentSubmissions)
+		assignments.GET("/:id/item-analysis", controllers.GetAssignmentItemAnalysis)
+		assignments.GET("/:id/access-codes", controllers.GetAssignmentAccessCodes)
+		assignments.POST("/:id/access-codes", controllers.GenerateAssignmentAccessCodes)
+		assignments.DELETE("/:id/access-codes/:codeId", controllers.RevokeAssignmentAccessCode)
+		assignments.GET("/:id/overrides", controllers.GetAssignmentOverrides)
+		assignments.PUT("/:id/overrides/:userId", controllers.UpsertAssignmentOverride)
+		assignments.DELETE("/:id/overrides/:userId", controllers.DeleteAssignmentOverride)
 
 	}
+	// ==================== Lớp học phần ====================
+	classes := admin.Group("/classes")
+	{
+		classes.GET("", controllers.GetClasses)
+		classes.POST("", controllers.CreateClass)
+		classes.GET("/:id", controllers.GetClassDetail)
+		classes.PUT("/:id", controllers.UpdateClass)
+		classes.DELETE("/:id", controllers.DeleteClass)
+		classes.POST("/:id/invite-code", controllers.RegenerateClassInviteCode)
+		classes.POST("/:id/students", controllers.AddClassStudents)
+		classes.POST("/:id/roster", controllers.ImportClassRoster)
+		classes.DELETE("/:id/students/:userId", controllers.RemoveClassStudent)
+	}
+	// ==================== Danh sách ôn thi ====================
+	revision := admin.Group("/playlists")
+	{
+		revision.GET("", controllers.GetRevisionPlaylists)
+		revision.POST("", controllers.CreateRevisionPlaylist)
+		revision.GET("/:id", controllers.GetPlaylist)
+		revision.PUT("/:id", controllers.UpdatePlaylist)
+		revision.DELETE("/:id", controllers.DeletePlaylist)
+		revision.POST("/:id/items", controllers.AddPlaylistItem)
+		revision.DELETE("/:id/items/:podcast_id", controllers.RemovePlaylistItem)
+		revision.PUT("/:id/items/order", controllers.ReorderPlaylistItems)
+	}
+	// ==================== Phân tích quiz ====================
+	admin.GET("/quiz-sets/:id/item-analysis", controllers.GetQuizSetItemAnalysis)
+	// ==================== Sổ điểm ====================
+	gradebook := admin.Group("/gradebook")
+	{
+		gradebook.GET("", controllers.GetGradebook)
+		gradebook.GET("/export", controllers.ExportGradebook)
+		gradebook.GET("/categories", controllers.GetGradeCategories)
+		gradebook.POST("/categories", controllers.CreateGradeCategory)
+		gradebook.PUT("/categories/:id", controllers.UpdateGradeCategory)
+		gradebook.DELETE("/categories/:id", controllers.DeleteGradeCategory)
+		gradebook.GET("/adjustments", controllers.GetGradeAdjustments)
+		gradebook.POST("/adjustments", controllers.CreateGradeAdjustment)
+	}
+	// ==================== Ngân hàng câu hỏi ====================
+	banks := admin.Group("/question-banks")
+	{
+		banks.GET("", controllers.GetQuestionBanks)
+		banks.POST("", controllers.CreateQuestionBank)
+		banks.GET("/:id", controllers.GetQuestionBankDetail)
+		banks.PUT("/:id", controllers.UpdateQuestionBank)
+		banks.DELETE("/:id", controllers.DeleteQuestionBank)
+		banks.POST("/:id/questions", controllers.CreateBankQuestion)
+		banks.PUT("/questions/:questionId", controllers.UpdateBankQuestion)
+		banks.DELETE("/questions/:questionId", controllers.DeleteBankQuestion)
+		banks.POST("/:id/import-assignment/:assignmentId", controllers.ImportAssignmentQuestionsToBank)
+	}
 	// ==================== Quản lý tag ====================
 	tags := admin.Group("/tags")
 	{
@@ -198,6 +373,7 @@ func SetupRouter(r *gin.Engine, db *gorm.DB) *gin.Engine {
 	}
 	users := admin.Group("/users")
 	{
+		users.Use(middleware.RequirePermission(models.PermUserManage))
 		users.POST("", controllers.AdminCreateLecturer)
 		users.GET("", controllers.AdminGetUsers)
 		users.GET("/:id", controllers.AdminGetUserDetail)
@@ -214,14 +390,31 @@ func SetupRouter(r *gin.Engine, db *gorm.DB) *gin.Engine {
 		stats.GET("/new-users", controllers.GetNewUsers)
 		// stats.GET("/top-podcasts", controllers.GetTopPodcasts)
 		stats.GET("/subject-breakdown", controllers.GetSubjectBreakdown)
+		stats.GET("/unique-listeners", controllers.GetUniqueListeners)
+		stats.GET("/podcasts/:podcast_id", controllers.GetPodcastAnalytics)
+		stats.GET("/podcasts/:podcast_id/retention", controllers.GetPodcastRetention)
+		stats.GET("/podcasts/:podcast_id/shares", controllers.GetPodcastShareStats)
+
+		// Dashboard giảng viên: chỉ các môn mình dạy, mọi biểu đồ hỗ trợ ?format=csv
+		stats.GET("/subjects", controllers.GetTaughtSubjects)
+		stats.GET("/subjects/:subject_id/chapters", controllers.GetSubjectChapterStats)
+		stats.GET("/subjects/:subject_id/chapters/:chapter_id/not-started", controllers.GetChapterNotStarted)
+		stats.GET("/subjects/:subject_id/podcasts", controllers.GetSubjectPodcastStats)
+		stats.GET("/subjects/:subject_id/score-distribution", controllers.GetSubjectScoreDistribution)
+		stats.GET("/subjects/:subject_id/trends", controllers.GetSubjectTrends)
 	}
 
 	// ==================== Bình luận ====================
 	comments := api.Group("/comments")
 	{
 		comments.POST("", middleware.AuthMiddleware(), controllers.CreateComment)
-		comments.GET("/podcasts/:id", controllers.GetComments)
+		comments.GET("/podcasts/:id", middleware.OptionalAuthMiddleware(), controllers.GetComments)
+		comments.GET("/:id/replies", middleware.OptionalAuthMiddleware(), controllers.GetCommentReplies)
+		comments.GET("/:id/history", middleware.OptionalAuthMiddleware(), controllers.GetCommentHistory)
+		comments.PUT("/:id", middleware.AuthMiddleware(), controllers.UpdateComment)
 		comments.DELETE("/:id", middleware.AuthMiddleware(), controllers.DeleteComment)
+		comments.PUT("/:id/reactions/:reaction", middleware.AuthMiddleware(), controllers.AddCommentReaction)
+		comments.DELETE("/:id/reactions/:reaction", middleware.AuthMiddleware(), controllers.RemoveCommentReaction)
 	}
 
 	// ==================== Thông báo ====================
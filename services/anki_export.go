@@ -0,0 +1,264 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// AnkiCard là một thẻ cần đóng gói vào file .apkg
+type AnkiCard struct {
+	Front     string
+	Back      string
+	Source    string
+	Reference string
+}
+
+// Schema collection Anki 2.1 (schema 11) - đủ để Anki import được
+const ankiSchema = `
+CREATE TABLE col (
+	id integer primary key, crt integer not null, mod integer not null, scm integer not null,
+	ver integer not null, dty integer not null, usn integer not null, ls integer not null,
+	conf text not null, models text not null, decks text not null, dconf text not null, tags text not null
+);
+CREATE TABLE notes (
+	id integer primary key, guid text not null, mid integer not null, mod integer not null,
+	usn integer not null, tags text not null, flds text not null, sfld integer not null,
+	csum integer not null, flags integer not null, data text not null
+);
+CREATE TABLE cards (
+	id integer primary key, nid integer not null, did integer not null, ord integer not null,
+	mod integer not null, usn integer not null, type integer not null, queue integer not null,
+	due integer not null, ivl integer not null, factor integer not null, reps integer not null,
+	lapses integer not null, left integer not null, odue integer not null, odid integer not null,
+	flags integer not null, data text not null
+);
+CREATE TABLE revlog (
+	id integer primary key, cid integer not null, usn integer not null, ease integer not null,
+	ivl integer not null, lastIvl integer not null, factor integer not null, time integer not null,
+	type integer not null
+);
+CREATE TABLE graves (usn integer not null, oid integer not null, type integer not null);
+CREATE INDEX ix_notes_usn on notes (usn);
+CREATE INDEX ix_cards_usn on cards (usn);
+CREATE INDEX ix_revlog_usn on revlog (usn);
+CREATE INDEX ix_cards_nid on cards (nid);
+CREATE INDEX ix_cards_sched on cards (did, queue, due);
+CREATE INDEX ix_revlog_cid on revlog (cid);
+CREATE INDEX ix_notes_csum on notes (csum);
+`
+
+// BuildAnkiPackage tạo file .apkg (zip chứa collection.anki2 + media) từ danh sách thẻ
+func BuildAnkiPackage(deckName string, cards []AnkiCard) ([]byte, error) {
+	tmpDir, err := os.MkdirTemp("", "anki-*")
+	if err != nil {
+		return nil, fmt.Errorf("không thể tạo thư mục tạm: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	dbPath := filepath.Join(tmpDir, "collection.anki2")
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("không thể tạo collection: %v", err)
+	}
+
+	if err := writeAnkiCollection(db, deckName, cards); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := db.Close(); err != nil {
+		return nil, fmt.Errorf("không thể đóng collection: %v", err)
+	}
+
+	collection, err := os.ReadFile(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("không thể đọc collection: %v", err)
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.Create("collection.anki2")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(collection); err != nil {
+		return nil, err
+	}
+	media, err := zw.Create("media")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := media.Write([]byte("{}")); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func writeAnkiCollection(db *sql.DB, deckName string, cards []AnkiCard) error {
+	if _, err := db.Exec(ankiSchema); err != nil {
+		return fmt.Errorf("không thể tạo schema Anki: %v", err)
+	}
+
+	now := time.Now()
+	nowSec := now.Unix()
+	nowMs := now.UnixMilli()
+
+	// ID deck/model dựa trên tên deck để import lại không bị nhân bản
+	deckID := ankiStableID("deck:" + deckName)
+	modelID := ankiStableID("model:e-podcast-flashcard")
+
+	models, decks, dconf, conf, err := ankiCollectionJSON(deckName, deckID, modelID, nowSec)
+	if err != nil {
+		return err
+	}
+
+	if _, err := db.Exec(`INSERT INTO col VALUES (1, ?, ?, ?, 11, 0, 0, 0, ?, ?, ?, ?, '{}')`,
+		nowSec, nowMs, nowMs, conf, models, decks, dconf); err != nil {
+		return fmt.Errorf("không thể ghi collection: %v", err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	for i, card := range cards {
+		noteID := nowMs + int64(i)
+		fields := []string{card.Front, card.Back, card.Source, card.Reference}
+		guid := ankiGUID(deckName, card.Front, card.Back)
+
+		if _, err := tx.Exec(`INSERT INTO notes VALUES (?, ?, ?, ?, -1, '', ?, ?, ?, 0, '')`,
+			noteID, guid, modelID, nowSec, strings.Join(fields, "\x1f"), card.Front, ankiChecksum(card.Front)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("không thể ghi note: %v", err)
+		}
+		if _, err := tx.Exec(`INSERT INTO cards VALUES (?, ?, ?, 0, ?, -1, 0, 0, ?, 0, 0, 0, 0, 0, 0, 0, 0, '')`,
+			noteID, noteID, deckID, nowSec, i+1); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("không thể ghi card: %v", err)
+		}
+	}
+	return tx.Commit()
+}
+
+func ankiCollectionJSON(deckName string, deckID, modelID, now int64) (models, decks, dconf, conf string, err error) {
+	field := func(name string, ord int) map[string]interface{} {
+		return map[string]interface{}{
+			"name": name, "ord": ord, "font": "Arial", "size": 20,
+			"media": []string{}, "rtl": false, "sticky": false,
+		}
+	}
+
+	modelMap := map[string]interface{}{
+		strconv.FormatInt(modelID, 10): map[string]interface{}{
+			"id":    modelID,
+			"name":  "E-Podcast Flashcard",
+			"type":  0,
+			"mod":   now,
+			"usn":   -1,
+			"sortf": 0,
+			"did":   deckID,
+			"tags":  []string{},
+			"vers":  []int{},
+			"flds": []interface{}{
+				field("Front", 0), field("Back", 1), field("Source", 2), field("Reference", 3),
+			},
+			"tmpls": []interface{}{
+				map[string]interface{}{
+					"name":  "Card 1",
+					"ord":   0,
+					"qfmt":  "{{Front}}",
+					"afmt":  "{{FrontSide}}<hr id=answer>{{Back}}{{#Source}}<br><br><small>{{Source}}</small>{{/Source}}",
+					"bqfmt": "",
+					"bafmt": "",
+					"did":   nil,
+				},
+			},
+			"req":       []interface{}{[]interface{}{0, "any", []int{0}}},
+			"css":       ".card { font-family: arial; font-size: 20px; text-align: center; color: black; background-color: white; }",
+			"latexPre":  "\\documentclass[12pt]{article}\n\\special{papersize=3in,5in}\n\\usepackage[utf8]{inputenc}\n\\usepackage{amssymb,amsmath}\n\\pagestyle{empty}\n\\setlength{\\parindent}{0in}\n\\begin{document}\n",
+			"latexPost": "\\end{document}",
+		},
+	}
+
+	deck := func(id int64, name string) map[string]interface{} {
+		return map[string]interface{}{
+			"id": id, "name": name, "desc": "", "conf": 1, "dyn": 0, "collapsed": false,
+			"extendNew": 10, "extendRev": 50, "mod": now, "usn": -1,
+			"newToday": []int{0, 0}, "revToday": []int{0, 0}, "lrnToday": []int{0, 0}, "timeToday": []int{0, 0},
+		}
+	}
+	deckMap := map[string]interface{}{
+		"1":                           deck(1, "Default"),
+		strconv.FormatInt(deckID, 10): deck(deckID, deckName),
+	}
+
+	dconfMap := map[string]interface{}{
+		"1": map[string]interface{}{
+			"id": 1, "name": "Default", "mod": 0, "usn": 0, "maxTaken": 60, "autoplay": true,
+			"timer": 0, "replayq": true, "dyn": false,
+			"new": map[string]interface{}{
+				"delays": []int{1, 10}, "ints": []int{1, 4, 7}, "initialFactor": 2500,
+				"order": 1, "perDay": 20, "separate": true, "bury": true,
+			},
+			"rev": map[string]interface{}{
+				"perDay": 100, "ease4": 1.3, "fuzz": 0.05, "maxIvl": 36500,
+				"minSpace": 1, "ivlFct": 1, "bury": true,
+			},
+			"lapse": map[string]interface{}{
+				"delays": []int{10}, "mult": 0, "minInt": 1, "leechFails": 8, "leechAction": 0,
+			},
+		},
+	}
+
+	confMap := map[string]interface{}{
+		"activeDecks": []int64{deckID}, "curDeck": deckID, "curModel": strconv.FormatInt(modelID, 10),
+		"nextPos": 1, "newSpread": 0, "collapseTime": 1200, "timeLim": 0,
+		"estTimes": true, "dueCounts": true, "sortType": "noteFld", "sortBackwards": false, "addToCur": true,
+	}
+
+	encode := func(v interface{}) string {
+		if err != nil {
+			return ""
+		}
+		var b []byte
+		b, err = json.Marshal(v)
+		return string(b)
+	}
+
+	return encode(modelMap), encode(deckMap), encode(dconfMap), encode(confMap), err
+}
+
+// ankiStableID sinh ID dương (giống timestamp ms) cố định theo chuỗi đầu vào
+func ankiStableID(key string) int64 {
+	sum := sha1.Sum([]byte(key))
+	v, _ := strconv.ParseInt(hex.EncodeToString(sum[:6]), 16, 64)
+	return 1_000_000_000_000 + v%1_000_000_000_000
+}
+
+func ankiGUID(parts ...string) string {
+	sum := sha1.Sum([]byte(strings.Join(parts, "\x1f")))
+	return hex.EncodeToString(sum[:5])
+}
+
+// ankiChecksum = 8 ký tự hex đầu của SHA1 trường đầu tiên (đã bỏ HTML)
+func ankiChecksum(first string) int64 {
+	stripped := HTMLTagRe.ReplaceAllString(first, "")
+	sum := sha1.Sum([]byte(stripped))
+	v, _ := strconv.ParseInt(hex.EncodeToString(sum[:4]), 16, 64)
+	return v
+}
@@ -1,24 +1,45 @@
 package services
 
 import (
+	"fmt"
 	"io"
 	"net/http"
+	"time"
 
 	tcmp3 "github.com/tcolgate/mp3"
 )
 
+// countingReader đếm số byte đã đọc qua
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
 // Tính thời lượng file MP3 bằng URL, trả về số giây
 func GetMP3DurationFromURL(url string) (float64, error) {
+	dur, _, err := GetMP3InfoFromURL(url)
+	return dur, err
+}
+
+// GetMP3InfoFromURL trả về thời lượng (giây) và kích thước file (bytes) trong một lần tải
+func GetMP3InfoFromURL(url string) (float64, int64, error) {
 
 	resp, err := http.Get(url)
 	if err != nil {
-		return 0, err
+		return 0, 0, err
 	}
 	defer resp.Body.Close()
 
 	var (
 		dur     float64
-		dec     = tcmp3.NewDecoder(resp.Body)
+		body    = &countingReader{r: resp.Body}
+		dec     = tcmp3.NewDecoder(body)
 		frame   tcmp3.Frame
 		skipped int
 	)
@@ -28,10 +49,29 @@ func GetMP3DurationFromURL(url string) (float64, error) {
 			if err == io.EOF {
 				break
 			}
-			return 0, err
+			return 0, 0, err
 		}
 		dur += frame.Duration().Seconds()
 	}
 
-	return dur, nil
+	return dur, body.n, nil
+}
+
+var headClient = &http.Client{Timeout: 5 * time.Second}
+
+// GetAudioSizeFromURL lấy kích thước file qua Content-Length của request HEAD (không tải cả file)
+func GetAudioSizeFromURL(url string) (int64, error) {
+	resp, err := headClient.Head(url)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("HEAD %s trả về %d", url, resp.StatusCode)
+	}
+	if resp.ContentLength <= 0 {
+		return 0, fmt.Errorf("HEAD %s không có Content-Length", url)
+	}
+	return resp.ContentLength, nil
 }
@@ -0,0 +1,492 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/vnkhanh/e-podcast-backend/models"
+	"github.com/xuri/excelize/v2"
+)
+
+// QuizQuestion là câu hỏi trung gian dùng chung cho import / export các định dạng đề
+type QuizQuestion struct {
+	Row         int // Vị trí trong file nguồn (dòng hoặc số thứ tự câu), dùng cho báo cáo lỗi
+	Type        string
+	Question    string
+	Difficulty  string
+	Explanation string
+	Rubric      string
+	Points      float64
+	Options     []QuizOption
+}
+
+type QuizOption struct {
+	Text      string
+	MatchText string
+	IsCorrect bool
+}
+
+// ImportIssue là một dòng / câu bị từ chối khi import
+type ImportIssue struct {
+	Row      int    `json:"row"`
+	Question string `json:"question,omitempty"`
+	Reason   string `json:"reason"`
+}
+
+// Cột của file mẫu Excel / CSV mở rộng
+var QuizTemplateHeaders = []string{
+	"type", "question", "option_a", "option_b", "option_c", "option_d", "option_e", "option_f",
+	"correct", "points", "difficulty", "explanation", "rubric",
+}
+
+var quizTypeAliases = map[string]string{
+	"":                "single_choice",
+	"single":          "single_choice",
+	"multichoice":     "single_choice",
+	"multiple":        "multiple_choice",
+	"multi":           "multiple_choice",
+	"truefalse":       "true_false",
+	"tf":              "true_false",
+	"shortanswer":     "fill_blank",
+	"fill":            "fill_blank",
+	"short":           "short_answer",
+	"order":           "ordering",
+	"match":           "matching",
+	"single_choice":   "single_choice",
+	"multiple_choice": "multiple_choice",
+	"true_false":      "true_false",
+	"fill_blank":      "fill_blank",
+	"ordering":        "ordering",
+	"matching":        "matching",
+	"short_answer":    "short_answer",
+	"essay":           "essay",
+}
+
+// ParseQuestionCSV đọc CSV: định dạng cũ (question,A,B,C,D,correct,points,explanation)
+// hoặc định dạng mở rộng có header giống file mẫu Excel
+func ParseQuestionCSV(data []byte) ([]QuizQuestion, []ImportIssue, error) {
+	data = bytes.TrimPrefix(data, []byte("\uFEFF"))
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil, fmt.Errorf("file rỗng")
+	}
+
+	if columns := templateColumns(records[0]); columns != nil {
+		questions, issues := parseTemplateRows(records[1:], columns, 2)
+		return questions, issues, nil
+	}
+
+	// Định dạng cũ - bỏ header
+	var questions []QuizQuestion
+	var issues []ImportIssue
+	for i := 1; i < len(records); i++ {
+		row := records[i]
+		if isBlankRow(row) {
+			continue
+		}
+		if len(row) < 8 {
+			issues = append(issues, ImportIssue{Row: i + 1, Question: cell(row, 0),
+				Reason: fmt.Sprintf("Thiếu cột: cần 8 cột, có %d", len(row))})
+			continue
+		}
+
+		points, err := strconv.ParseFloat(strings.TrimSpace(row[6]), 64)
+		if err != nil {
+			issues = append(issues, ImportIssue{Row: i + 1, Question: row[0], Reason: "Điểm không hợp lệ: " + row[6]})
+			continue
+		}
+
+		correct := strings.ToUpper(strings.TrimSpace(row[5]))
+		if correct == "" || !strings.Contains("ABCD", correct) || len(correct) != 1 {
+			issues = append(issues, ImportIssue{Row: i + 1, Question: row[0], Reason: "Đáp án đúng phải là A, B, C hoặc D"})
+			continue
+		}
+
+		questions = append(questions, QuizQuestion{
+			Row:         i + 1,
+			Type:        models.QuestionTypeSingleChoice,
+			Question:    strings.TrimSpace(row[0]),
+			Explanation: strings.TrimSpace(row[7]),
+			Points:      points,
+			Options: []QuizOption{
+				{Text: strings.TrimSpace(row[1]), IsCorrect: correct == "A"},
+				{Text: strings.TrimSpace(row[2]), IsCorrect: correct == "B"},
+				{Text: strings.TrimSpace(row[3]), IsCorrect: correct == "C"},
+				{Text: strings.TrimSpace(row[4]), IsCorrect: correct == "D"},
+			},
+		})
+	}
+	return questions, issues, nil
+}
+
+// ParseQuestionXLSX đọc sheet đầu tiên của file mẫu Excel
+func ParseQuestionXLSX(data []byte) ([]QuizQuestion, []ImportIssue, error) {
+	f, err := excelize.OpenReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	sheets := f.GetSheetList()
+	if len(sheets) == 0 {
+		return nil, nil, fmt.Errorf("file không có sheet nào")
+	}
+	rows, err := f.GetRows(sheets[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil, fmt.Errorf("sheet rỗng")
+	}
+
+	columns := templateColumns(rows[0])
+	if columns == nil {
+		return nil, nil, fmt.Errorf("dòng đầu phải là header theo file mẫu (type, question, option_a, ...)")
+	}
+
+	questions, issues := parseTemplateRows(rows[1:], columns, 2)
+	return questions, issues, nil
+}
+
+// BuildQuestionTemplateXLSX tạo file mẫu Excel để giảng viên điền câu hỏi
+func BuildQuestionTemplateXLSX() ([]byte, error) {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	sheet := "Questions"
+	f.SetSheetName("Sheet1", sheet)
+
+	for i, h := range QuizTemplateHeaders {
+		col, _ := excelize.ColumnNumberToName(i + 1)
+		f.SetCellValue(sheet, col+"1", h)
+	}
+
+	examples := [][]interface{}{
+		{"single_choice", "Thủ đô của Việt Nam là?", "Hà Nội", "Huế", "Đà Nẵng", "TP.HCM", "", "", "A", 1, "easy", "Hà Nội là thủ đô", ""},
+		{"multiple_choice", "Chọn các số nguyên tố", "2", "4", "5", "9", "", "", "A,C", 1, "medium", "", ""},
+		{"true_false", "Trái Đất quay quanh Mặt Trời", "", "", "", "", "", "", "TRUE", 1, "easy", "", ""},
+		{"fill_blank", "Ngôn ngữ lập trình của Google có tên là ___", "Go", "Golang", "", "", "", "", "", 1, "easy", "", ""},
+		{"ordering", "Sắp xếp các bước theo đúng thứ tự", "Bước 1", "Bước 2", "Bước 3", "", "", "", "", 1, "medium", "", ""},
+		{"matching", "Ghép quốc gia với thủ đô", "Việt Nam => Hà Nội", "Nhật Bản => Tokyo", "Pháp => Paris", "", "", "", "", 1, "medium", "", ""},
+		{"essay", "Trình bày ý chính của podcast", "", "", "", "", "", "", "", 2, "hard", "", "Nêu đủ 3 ý chính (1đ), lập luận rõ ràng (1đ)"},
+	}
+	for r, row := range examples {
+		for cIdx, v := range row {
+			col, _ := excelize.ColumnNumberToName(cIdx + 1)
+			f.SetCellValue(sheet, fmt.Sprintf("%s%d", col, r+2), v)
+		}
+	}
+
+	headerStyle, _ := f.NewStyle(&excelize.Style{
+		Font: &excelize.Font{Bold: true, Color: "FFFFFF"},
+		Fill: excelize.Fill{Type: "pattern", Color: []string{"4472C4"}, Pattern: 1},
+	})
+	f.SetCellStyle(sheet, "A1", "M1", headerStyle)
+	f.SetColWidth(sheet, "A", "A", 18)
+	f.SetColWidth(sheet, "B", "B", 45)
+	f.SetColWidth(sheet, "C", "H", 22)
+	f.SetColWidth(sheet, "L", "M", 40)
+
+	guide := "Hướng dẫn"
+	f.NewSheet(guide)
+	lines := []string{
+		"type: single_choice | multiple_choice | true_false | fill_blank | ordering | matching | short_answer | essay",
+		"correct: chữ cái đáp án đúng (A), nhiều đáp án cách nhau bởi dấu phẩy (A,C); true_false ghi TRUE/FALSE",
+		"fill_blank: option_a..option_f là các đáp án được chấp nhận",
+		"ordering: điền các mục theo đúng thứ tự từ option_a",
+		"matching: mỗi option là một cặp \"vế trái => vế phải\"",
+		"short_answer / essay: không cần option, ghi tiêu chí chấm ở cột rubric",
+		"difficulty: easy | medium | hard (mặc định medium); points mặc định 1",
+	}
+	for i, line := range lines {
+		f.SetCellValue(guide, fmt.Sprintf("A%d", i+1), line)
+	}
+	f.SetColWidth(guide, "A", "A", 110)
+
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// templateColumns trả về vị trí các cột nếu dòng là header của file mẫu, nil nếu không phải
+func templateColumns(header []string) map[string]int {
+	columns := map[string]int{}
+	for i, h := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\uFEFF")))] = i
+	}
+	if _, ok := columns["type"]; !ok {
+		return nil
+	}
+	if _, ok := columns["question"]; !ok {
+		return nil
+	}
+	return columns
+}
+
+func parseTemplateRows(rows [][]string, columns map[string]int, firstRow int) ([]QuizQuestion, []ImportIssue) {
+	get := func(row []string, name string) string {
+		idx, ok := columns[name]
+		if !ok {
+			return ""
+		}
+		return cell(row, idx)
+	}
+
+	var questions []QuizQuestion
+	var issues []ImportIssue
+	for i, row := range rows {
+		rowNum := firstRow + i
+		if isBlankRow(row) {
+			continue
+		}
+
+		text := get(row, "question")
+		fail := func(reason string) {
+			issues = append(issues, ImportIssue{Row: rowNum, Question: text, Reason: reason})
+		}
+
+		qType, ok := quizTypeAliases[strings.ToLower(get(row, "type"))]
+		if !ok {
+			fail("Loại câu hỏi không hỗ trợ: " + get(row, "type"))
+			continue
+		}
+		if text == "" {
+			fail("Thiếu nội dung câu hỏi")
+			continue
+		}
+
+		points := 1.0
+		if p := get(row, "points"); p != "" {
+			v, err := strconv.ParseFloat(strings.ReplaceAll(p, ",", "."), 64)
+			if err != nil || v <= 0 {
+				fail("Điểm không hợp lệ: " + p)
+				continue
+			}
+			points = v
+		}
+
+		difficulty := strings.ToLower(get(row, "difficulty"))
+		if difficulty == "" {
+			difficulty = "medium"
+		}
+		if difficulty != "easy" && difficulty != "medium" && difficulty != "hard" {
+			fail("Độ khó phải là easy, medium hoặc hard")
+			continue
+		}
+
+		var rawOptions []string
+		for _, letter := range []string{"a", "b", "c", "d", "e", "f"} {
+			if v := get(row, "option_"+letter); v != "" {
+				rawOptions = append(rawOptions, v)
+			}
+		}
+
+		q := QuizQuestion{
+			Row:         rowNum,
+			Type:        qType,
+			Question:    text,
+			Difficulty:  difficulty,
+			Explanation: get(row, "explanation"),
+			Rubric:      get(row, "rubric"),
+			Points:      points,
+		}
+
+		correct := strings.ToUpper(strings.ReplaceAll(get(row, "correct"), " ", ""))
+		var reason string
+		q.Options, reason = buildTemplateOptions(qType, rawOptions, correct)
+		if reason != "" {
+			fail(reason)
+			continue
+		}
+
+		questions = append(questions, q)
+	}
+	return questions, issues
+}
+
+func buildTemplateOptions(qType string, raw []string, correct string) ([]QuizOption, string) {
+	var options []QuizOption
+	switch qType {
+	case models.QuestionTypeTrueFalse:
+		var answer bool
+		switch correct {
+		case "TRUE", "T", "ĐÚNG", "DUNG", "1":
+			answer = true
+		case "FALSE", "F", "SAI", "0":
+			answer = false
+		default:
+			return nil, "Câu Đúng/Sai cần cột correct là TRUE hoặc FALSE"
+		}
+		return []QuizOption{{Text: "Đúng", IsCorrect: answer}, {Text: "Sai", IsCorrect: !answer}}, ""
+
+	case models.QuestionTypeFillBlank, models.QuestionTypeOrdering:
+		for _, r := range raw {
+			options = append(options, QuizOption{Text: r, IsCorrect: true})
+		}
+
+	case models.QuestionTypeMatching:
+		for _, r := range raw {
+			left, right, ok := strings.Cut(r, "=>")
+			if !ok {
+				return nil, fmt.Sprintf("Cặp ghép nối \"%s\" phải có dạng \"vế trái => vế phải\"", r)
+			}
+			options = append(options, QuizOption{Text: strings.TrimSpace(left), MatchText: strings.TrimSpace(right), IsCorrect: true})
+		}
+
+	case models.QuestionTypeShortAnswer, models.QuestionTypeEssay:
+		return nil, ""
+
+	default:
+		if correct == "" {
+			return nil, "Thiếu cột correct (chữ cái đáp án đúng)"
+		}
+		marked := map[int]bool{}
+		for _, letter := range strings.Split(correct, ",") {
+			if len(letter) != 1 || letter[0] < 'A' || letter[0] > 'F' {
+				return nil, "Đáp án đúng không hợp lệ: " + letter
+			}
+			idx := int(letter[0] - 'A')
+			if idx >= len(raw) {
+				return nil, fmt.Sprintf("Đáp án đúng %s không có nội dung", letter)
+			}
+			marked[idx] = true
+		}
+		for i, r := range raw {
+			options = append(options, QuizOption{Text: r, IsCorrect: marked[i]})
+		}
+	}
+	return options, ""
+}
+
+func cell(row []string, idx int) string {
+	if idx < 0 || idx >= len(row) {
+		return ""
+	}
+	return strings.TrimSpace(row[idx])
+}
+
+func isBlankRow(row []string) bool {
+	for _, v := range row {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// ==================== HELPER XML ====================
+
+// xmlNode là cây XML tổng quát dùng để đọc Moodle XML / QTI
+type xmlNode struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Content string     `xml:",chardata"`
+	Nodes   []xmlNode  `xml:",any"`
+}
+
+func parseXMLNode(data []byte) (*xmlNode, error) {
+	var root xmlNode
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) { return input, nil }
+	if err := decoder.Decode(&root); err != nil {
+		return nil, err
+	}
+	return &root, nil
+}
+
+func (n *xmlNode) attr(name string) string {
+	for _, a := range n.Attrs {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// child trả về node con trực tiếp đầu tiên theo tên
+func (n *xmlNode) child(name string) *xmlNode {
+	for i := range n.Nodes {
+		if n.Nodes[i].XMLName.Local == name {
+			return &n.Nodes[i]
+		}
+	}
+	return nil
+}
+
+func (n *xmlNode) children(name string) []*xmlNode {
+	var out []*xmlNode
+	for i := range n.Nodes {
+		if n.Nodes[i].XMLName.Local == name {
+			out = append(out, &n.Nodes[i])
+		}
+	}
+	return out
+}
+
+// find tìm node con cháu đầu tiên theo tên
+func (n *xmlNode) find(name string) *xmlNode {
+	for i := range n.Nodes {
+		if n.Nodes[i].XMLName.Local == name {
+			return &n.Nodes[i]
+		}
+		if found := n.Nodes[i].find(name); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// text ghép toàn bộ chữ của node và con cháu
+func (n *xmlNode) text() string {
+	if n == nil {
+		return ""
+	}
+	var sb strings.Builder
+	n.writeText(&sb)
+	return strings.TrimSpace(strings.Join(strings.Fields(sb.String()), " "))
+}
+
+func (n *xmlNode) writeText(sb *strings.Builder) {
+	sb.WriteString(n.Content)
+	for i := range n.Nodes {
+		sb.WriteString(" ")
+		n.Nodes[i].writeText(sb)
+	}
+}
+
+// HTMLTagRe khớp một thẻ HTML, dùng chung khi bỏ HTML khỏi câu hỏi và flashcard
+var HTMLTagRe = regexp.MustCompile(`<[^>]*>`)
+
+// plainText bỏ thẻ HTML và giải mã entity
+func plainText(s string) string {
+	s = HTMLTagRe.ReplaceAllString(s, " ")
+	s = html.UnescapeString(s)
+	return strings.TrimSpace(strings.Join(strings.Fields(s), " "))
+}
+
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+func formatPoints(p float64) string {
+	return strconv.FormatFloat(p, 'f', -1, 64)
+}
@@ -0,0 +1,419 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/vnkhanh/e-podcast-backend/models"
+)
+
+// ==================== GIFT ====================
+
+// ParseGIFT đọc định dạng GIFT của Moodle. Các câu cách nhau bởi dòng trống.
+func ParseGIFT(data []byte) ([]QuizQuestion, []ImportIssue, error) {
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	text = strings.TrimPrefix(text, "\uFEFF")
+
+	// Mỗi khối kèm dòng chú thích ngay trước đó ("// easy | 2 điểm" do ExportGIFT sinh ra)
+	type giftBlock struct {
+		text    string
+		comment string
+	}
+	var blocks []giftBlock
+	var current []string
+	comment := ""
+	flush := func() {
+		if len(current) > 0 {
+			blocks = append(blocks, giftBlock{text: strings.Join(current, "\n"), comment: comment})
+			current = nil
+			comment = ""
+		}
+	}
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "//") {
+			if len(current) == 0 {
+				comment = strings.TrimSpace(trimmed[2:])
+			}
+			continue
+		}
+		if trimmed == "" {
+			flush()
+			continue
+		}
+		current = append(current, line)
+	}
+	flush()
+
+	var questions []QuizQuestion
+	var issues []ImportIssue
+	index := 0
+	for _, block := range blocks {
+		if strings.HasPrefix(strings.TrimSpace(block.text), "$CATEGORY:") {
+			continue
+		}
+		index++
+
+		q, reason := parseGIFTQuestion(block.text)
+		q.Row = index
+		if reason != "" {
+			issues = append(issues, ImportIssue{Row: index, Question: q.Question, Reason: reason})
+			continue
+		}
+		applyGIFTComment(&q, block.comment)
+		questions = append(questions, q)
+	}
+	return questions, issues, nil
+}
+
+func parseGIFTQuestion(block string) (QuizQuestion, string) {
+	q := QuizQuestion{Points: 1, Difficulty: "medium"}
+	block = strings.TrimSpace(block)
+
+	// ::tiêu đề::
+	if strings.HasPrefix(block, "::") {
+		if end := indexUnescaped(block[2:], "::"); end >= 0 {
+			block = strings.TrimSpace(block[2+end+2:])
+		}
+	}
+
+	open := indexUnescaped(block, "{")
+	if open < 0 {
+		return q, "Thiếu khối đáp án {...}"
+	}
+	closeIdx := indexUnescaped(block[open:], "}")
+	if closeIdx < 0 {
+		q.Question = giftUnescape(stripGIFTFormat(block[:open]))
+		return q, "Thiếu dấu } đóng khối đáp án"
+	}
+	closeIdx += open
+
+	before := strings.TrimSpace(stripGIFTFormat(block[:open]))
+	after := strings.TrimSpace(block[closeIdx+1:])
+	body := strings.TrimSpace(block[open+1 : closeIdx])
+
+	q.Question = giftUnescape(before)
+	if after != "" {
+		// Câu điền khuyết giữa câu: "Thủ đô {=Hà Nội} của Việt Nam"
+		q.Question = giftUnescape(before + " ___ " + after)
+	}
+	if q.Question == "" {
+		return q, "Thiếu nội dung câu hỏi"
+	}
+
+	// Phản hồi chung: ####...
+	if idx := indexUnescaped(body, "####"); idx >= 0 {
+		q.Explanation = giftUnescape(strings.TrimSpace(body[idx+4:]))
+		body = strings.TrimSpace(body[:idx])
+	}
+
+	switch {
+	case body == "":
+		q.Type = models.QuestionTypeEssay
+		return q, ""
+
+	case strings.HasPrefix(body, "#"):
+		return q, "Câu hỏi dạng số (numerical) không được hỗ trợ"
+	}
+
+	if tf := strings.ToUpper(strings.TrimSpace(stripFeedback(body))); tf == "T" || tf == "TRUE" || tf == "F" || tf == "FALSE" {
+		answer := tf == "T" || tf == "TRUE"
+		q.Type = models.QuestionTypeTrueFalse
+		q.Options = []QuizOption{{Text: "Đúng", IsCorrect: answer}, {Text: "Sai", IsCorrect: !answer}}
+		return q, ""
+	}
+
+	answers := splitGIFTAnswers(body)
+	if len(answers) == 0 {
+		return q, "Khối đáp án không hợp lệ"
+	}
+
+	hasWrong, hasMatch, hasWeight := false, false, false
+	numCorrect := 0
+	for _, a := range answers {
+		if a.prefix == '~' {
+			hasWrong = true
+		}
+		if strings.Contains(a.text, "->") {
+			hasMatch = true
+		}
+		if a.weight != nil {
+			hasWeight = true
+		}
+		if a.prefix == '=' || (a.weight != nil && *a.weight > 0) {
+			numCorrect++
+		}
+	}
+
+	switch {
+	case hasMatch:
+		q.Type = models.QuestionTypeMatching
+		for _, a := range answers {
+			left, right, _ := strings.Cut(a.text, "->")
+			q.Options = append(q.Options, QuizOption{
+				Text:      giftUnescape(strings.TrimSpace(left)),
+				MatchText: giftUnescape(strings.TrimSpace(right)),
+				IsCorrect: true,
+			})
+		}
+
+	case !hasWrong:
+		// Chỉ có "=" → trả lời ngắn với các đáp án được chấp nhận
+		q.Type = models.QuestionTypeFillBlank
+		for _, a := range answers {
+			q.Options = append(q.Options, QuizOption{Text: giftUnescape(a.text), IsCorrect: true})
+		}
+
+	default:
+		q.Type = models.QuestionTypeSingleChoice
+		if hasWeight || numCorrect > 1 {
+			q.Type = models.QuestionTypeMultipleChoice
+		}
+		for _, a := range answers {
+			correct := a.prefix == '='
+			if a.weight != nil {
+				correct = *a.weight > 0
+			}
+			q.Options = append(q.Options, QuizOption{Text: giftUnescape(a.text), IsCorrect: correct})
+		}
+	}
+
+	return q, ""
+}
+
+// applyGIFTComment đọc độ khó / số điểm từ chú thích dạng "easy | 2 điểm"
+func applyGIFTComment(q *QuizQuestion, comment string) {
+	for _, part := range strings.Split(comment, "|") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		switch part {
+		case "easy", "medium", "hard":
+			q.Difficulty = part
+			continue
+		}
+		if fields := strings.Fields(part); len(fields) == 2 && (fields[1] == "điểm" || fields[1] == "points") {
+			if v, err := strconv.ParseFloat(fields[0], 64); err == nil && v > 0 {
+				q.Points = v
+			}
+		}
+	}
+}
+
+type giftAnswer struct {
+	prefix byte
+	weight *float64
+	text   string
+}
+
+// splitGIFTAnswers tách các đáp án bắt đầu bằng = hoặc ~ (bỏ qua ký tự đã escape)
+func splitGIFTAnswers(body string) []giftAnswer {
+	var answers []giftAnswer
+	start := -1
+	var prefix byte
+	push := func(end int) {
+		if start < 0 {
+			return
+		}
+		raw := strings.TrimSpace(stripFeedback(body[start:end]))
+		a := giftAnswer{prefix: prefix}
+		// Trọng số: %50%
+		if strings.HasPrefix(raw, "%") {
+			if endW := strings.Index(raw[1:], "%"); endW >= 0 {
+				if w, err := strconv.ParseFloat(raw[1:1+endW], 64); err == nil {
+					a.weight = &w
+				}
+				raw = strings.TrimSpace(raw[endW+2:])
+			}
+		}
+		a.text = raw
+		answers = append(answers, a)
+	}
+
+	for i := 0; i < len(body); i++ {
+		ch := body[i]
+		if ch == '\\' {
+			i++
+			continue
+		}
+		if ch == '=' || ch == '~' {
+			push(i)
+			start = i + 1
+			prefix = ch
+		}
+	}
+	push(len(body))
+	return answers
+}
+
+// stripFeedback bỏ phần phản hồi "#..." sau đáp án
+func stripFeedback(s string) string {
+	if idx := indexUnescaped(s, "#"); idx >= 0 {
+		return s[:idx]
+	}
+	return s
+}
+
+// stripGIFTFormat bỏ tiền tố định dạng [html] / [markdown] / [plain] / [moodle]
+func stripGIFTFormat(s string) string {
+	s = strings.TrimSpace(s)
+	for _, f := range []string{"[html]", "[markdown]", "[plain]", "[moodle]"} {
+		if strings.HasPrefix(strings.ToLower(s), f) {
+			return plainText(s[len(f):])
+		}
+	}
+	return s
+}
+
+func indexUnescaped(s, sub string) int {
+	for i := 0; i+len(sub) <= len(s); i++ {
+		if s[i] == '\\' {
+			i++
+			continue
+		}
+		if s[i:i+len(sub)] == sub {
+			return i
+		}
+	}
+	return -1
+}
+
+const giftSpecialChars = "~=#{}:"
+
+// giftUnescape giải mã trong một lượt từ trái sang phải để "\\" được xử lý trước các ký tự đặc biệt
+// (vd: `\\=` là dấu "\" theo sau bởi "=" chứ không phải "\=")
+func giftUnescape(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			sb.WriteByte(s[i])
+			continue
+		}
+		next := s[i+1]
+		switch {
+		case next == '\\':
+			sb.WriteByte('\\')
+		case next == 'n':
+			sb.WriteByte('\n')
+		case strings.IndexByte(giftSpecialChars, next) >= 0:
+			sb.WriteByte(next)
+		default:
+			sb.WriteByte('\\')
+			sb.WriteByte(next)
+		}
+		i++
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+func giftEscape(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	for _, ch := range giftSpecialChars {
+		s = strings.ReplaceAll(s, string(ch), `\`+string(ch))
+	}
+	return strings.ReplaceAll(s, "\n", `\n`)
+}
+
+// ExportGIFT xuất câu hỏi ra GIFT. Câu sắp xếp và câu trả lời ngắn chưa có đáp án mẫu không biểu diễn được sẽ bị bỏ qua.
+// Câu trả lời ngắn xuất thành {=a =b} với mỗi dòng rubric là một đáp án được chấp nhận
+func ExportGIFT(category string, questions []QuizQuestion) ([]byte, []ImportIssue) {
+	var sb strings.Builder
+	var skipped []ImportIssue
+
+	if category != "" {
+		fmt.Fprintf(&sb, "$CATEGORY: $course$/%s\n\n", category)
+	}
+
+	for i, q := range questions {
+		if q.Type == models.QuestionTypeOrdering {
+			skipped = append(skipped, ImportIssue{Row: i + 1, Question: q.Question, Reason: "GIFT không hỗ trợ câu sắp xếp"})
+			continue
+		}
+		var shortAnswers []string
+		if q.Type == models.QuestionTypeShortAnswer {
+			for _, line := range strings.Split(q.Rubric, "\n") {
+				if line = strings.TrimSpace(line); line != "" {
+					shortAnswers = append(shortAnswers, line)
+				}
+			}
+			if len(shortAnswers) == 0 {
+				skipped = append(skipped, ImportIssue{Row: i + 1, Question: q.Question, Reason: "Câu trả lời ngắn chưa có đáp án mẫu (rubric) để xuất sang GIFT"})
+				continue
+			}
+		}
+
+		difficulty := q.Difficulty
+		if difficulty == "" {
+			difficulty = "medium"
+		}
+		fmt.Fprintf(&sb, "// %s | %s điểm\n", difficulty, formatPoints(q.Points))
+		fmt.Fprintf(&sb, "::Q%d:: %s {", i+1, giftEscape(q.Question))
+
+		switch q.Type {
+		case models.QuestionTypeTrueFalse:
+			answer := true
+			for _, o := range q.Options {
+				if o.IsCorrect {
+					answer = isTrueLabel(o.Text)
+				}
+			}
+			if answer {
+				sb.WriteString("TRUE")
+			} else {
+				sb.WriteString("FALSE")
+			}
+
+		case models.QuestionTypeMultipleChoice:
+			numCorrect := 0
+			for _, o := range q.Options {
+				if o.IsCorrect {
+					numCorrect++
+				}
+			}
+			for _, o := range q.Options {
+				weight := -100.0
+				if o.IsCorrect {
+					weight = 100 / float64(numCorrect)
+				}
+				fmt.Fprintf(&sb, "\n\t~%%%s%%%s", strconv.FormatFloat(weight, 'f', 5, 64), giftEscape(o.Text))
+			}
+
+		case models.QuestionTypeFillBlank:
+			for _, o := range q.Options {
+				fmt.Fprintf(&sb, "\n\t=%s", giftEscape(o.Text))
+			}
+
+		case models.QuestionTypeMatching:
+			for _, o := range q.Options {
+				fmt.Fprintf(&sb, "\n\t=%s -> %s", giftEscape(o.Text), giftEscape(o.MatchText))
+			}
+
+		case models.QuestionTypeShortAnswer:
+			for _, a := range shortAnswers {
+				fmt.Fprintf(&sb, "\n\t=%s", giftEscape(a))
+			}
+
+		case models.QuestionTypeEssay:
+			// Khối rỗng = tự luận
+
+		default:
+			for _, o := range q.Options {
+				prefix := "~"
+				if o.IsCorrect {
+					prefix = "="
+				}
+				fmt.Fprintf(&sb, "\n\t%s%s", prefix, giftEscape(o.Text))
+			}
+		}
+
+		if q.Explanation != "" {
+			fmt.Fprintf(&sb, "\n\t####%s", giftEscape(q.Explanation))
+		}
+		if q.Type == models.QuestionTypeTrueFalse || q.Type == models.QuestionTypeEssay {
+			sb.WriteString("}\n\n")
+		} else {
+			sb.WriteString("\n}\n\n")
+		}
+	}
+
+	return []byte(sb.String()), skipped
+}
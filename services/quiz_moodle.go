@@ -0,0 +1,257 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/vnkhanh/e-podcast-backend/models"
+)
+
+// ==================== MOODLE XML ====================
+
+// ParseMoodleXML đọc file Moodle XML (<quiz><question type="...">...)
+func ParseMoodleXML(data []byte) ([]QuizQuestion, []ImportIssue, error) {
+	root, err := parseXMLNode(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("XML không hợp lệ: %v", err)
+	}
+	if root.XMLName.Local != "quiz" {
+		return nil, nil, fmt.Errorf("không phải file Moodle XML (thiếu thẻ <quiz>)")
+	}
+
+	var questions []QuizQuestion
+	var issues []ImportIssue
+	index := 0
+	for _, node := range root.children("question") {
+		mType := node.attr("type")
+		if mType == "category" {
+			continue
+		}
+		index++
+
+		q := QuizQuestion{
+			Row:         index,
+			Question:    moodleText(node.child("questiontext")),
+			Explanation: moodleText(node.child("generalfeedback")),
+			Points:      1,
+			Difficulty:  "medium",
+		}
+		if q.Question == "" {
+			q.Question = moodleText(node.child("name"))
+		}
+		if grade := node.child("defaultgrade"); grade != nil {
+			if v, err := strconv.ParseFloat(strings.TrimSpace(grade.text()), 64); err == nil && v > 0 {
+				q.Points = v
+			}
+		}
+		if tags := node.child("tags"); tags != nil {
+			for _, tag := range tags.children("tag") {
+				switch t := strings.ToLower(moodleText(tag)); t {
+				case "easy", "medium", "hard":
+					q.Difficulty = t
+				}
+			}
+		}
+
+		fail := func(reason string) {
+			issues = append(issues, ImportIssue{Row: index, Question: q.Question, Reason: reason})
+		}
+
+		switch mType {
+		case "multichoice":
+			q.Type = models.QuestionTypeSingleChoice
+			if strings.TrimSpace(node.child("single").text()) == "false" {
+				q.Type = models.QuestionTypeMultipleChoice
+			}
+			for _, ans := range node.children("answer") {
+				q.Options = append(q.Options, QuizOption{Text: moodleText(ans), IsCorrect: moodleFraction(ans) > 0})
+			}
+
+		case "truefalse":
+			q.Type = models.QuestionTypeTrueFalse
+			for _, ans := range node.children("answer") {
+				label := "Sai"
+				if strings.EqualFold(moodleText(ans), "true") {
+					label = "Đúng"
+				}
+				q.Options = append(q.Options, QuizOption{Text: label, IsCorrect: moodleFraction(ans) >= 100})
+			}
+
+		case "shortanswer":
+			q.Type = models.QuestionTypeFillBlank
+			for _, ans := range node.children("answer") {
+				if moodleFraction(ans) >= 100 {
+					q.Options = append(q.Options, QuizOption{Text: moodleText(ans), IsCorrect: true})
+				}
+			}
+
+		case "matching":
+			q.Type = models.QuestionTypeMatching
+			for _, sub := range node.children("subquestion") {
+				left := moodleText(sub)
+				right := moodleText(sub.child("answer"))
+				if left == "" {
+					// Moodle cho phép vế phải "gây nhiễu" không có vế trái - không hỗ trợ
+					continue
+				}
+				q.Options = append(q.Options, QuizOption{Text: left, MatchText: right, IsCorrect: true})
+			}
+
+		case "ordering":
+			q.Type = models.QuestionTypeOrdering
+			for _, ans := range node.children("answer") {
+				q.Options = append(q.Options, QuizOption{Text: moodleText(ans), IsCorrect: true})
+			}
+
+		case "essay":
+			q.Type = models.QuestionTypeEssay
+			q.Rubric = moodleText(node.child("graderinfo"))
+
+		default:
+			fail(fmt.Sprintf("Loại câu hỏi Moodle \"%s\" không được hỗ trợ", mType))
+			continue
+		}
+
+		questions = append(questions, q)
+	}
+
+	return questions, issues, nil
+}
+
+// moodleText đọc <text> của một node Moodle (questiontext, answer, feedback...)
+func moodleText(n *xmlNode) string {
+	if n == nil {
+		return ""
+	}
+	if t := n.child("text"); t != nil {
+		return plainText(t.Content)
+	}
+	return plainText(n.text())
+}
+
+func moodleFraction(n *xmlNode) float64 {
+	v, _ := strconv.ParseFloat(n.attr("fraction"), 64)
+	return v
+}
+
+// ExportMoodleXML xuất câu hỏi ra Moodle XML, trả về nội dung file và các câu không xuất được
+func ExportMoodleXML(category string, questions []QuizQuestion) ([]byte, []ImportIssue) {
+	var sb strings.Builder
+	var skipped []ImportIssue
+
+	sb.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n<quiz>\n")
+	if category != "" {
+		sb.WriteString("  <question type=\"category\">\n    <category><text>$course$/" + xmlEscape(category) + "</text></category>\n  </question>\n")
+	}
+
+	for i, q := range questions {
+		mType := ""
+		switch q.Type {
+		case models.QuestionTypeSingleChoice, models.QuestionTypeMultipleChoice, "":
+			mType = "multichoice"
+		case models.QuestionTypeTrueFalse:
+			mType = "truefalse"
+		case models.QuestionTypeFillBlank:
+			mType = "shortanswer"
+		case models.QuestionTypeMatching:
+			mType = "matching"
+		case models.QuestionTypeOrdering:
+			mType = "ordering"
+		case models.QuestionTypeEssay, models.QuestionTypeShortAnswer:
+			mType = "essay"
+		default:
+			skipped = append(skipped, ImportIssue{Row: i + 1, Question: q.Question, Reason: "Loại câu hỏi không xuất được sang Moodle"})
+			continue
+		}
+
+		name := q.Question
+		if r := []rune(name); len(r) > 60 {
+			name = string(r[:60]) + "..."
+		}
+
+		fmt.Fprintf(&sb, "  <question type=\"%s\">\n", mType)
+		fmt.Fprintf(&sb, "    <name><text>%s</text></name>\n", xmlEscape(name))
+		fmt.Fprintf(&sb, "    <questiontext format=\"plain_text\"><text>%s</text></questiontext>\n", xmlEscape(q.Question))
+		fmt.Fprintf(&sb, "    <generalfeedback format=\"plain_text\"><text>%s</text></generalfeedback>\n", xmlEscape(q.Explanation))
+		fmt.Fprintf(&sb, "    <defaultgrade>%s</defaultgrade>\n", formatPoints(q.Points))
+
+		switch mType {
+		case "multichoice":
+			numCorrect := 0
+			for _, o := range q.Options {
+				if o.IsCorrect {
+					numCorrect++
+				}
+			}
+			single := q.Type != models.QuestionTypeMultipleChoice
+			fmt.Fprintf(&sb, "    <single>%t</single>\n    <shuffleanswers>true</shuffleanswers>\n    <answernumbering>abc</answernumbering>\n", single)
+			for _, o := range q.Options {
+				fraction := "0"
+				if o.IsCorrect {
+					fraction = "100"
+					if !single && numCorrect > 0 {
+						fraction = strconv.FormatFloat(100/float64(numCorrect), 'f', 5, 64)
+					}
+				} else if !single {
+					// Chọn sai bị trừ điểm giống quy tắc chấm nhiều đáp án của hệ thống
+					fraction = "-100"
+				}
+				fmt.Fprintf(&sb, "    <answer fraction=\"%s\" format=\"plain_text\"><text>%s</text></answer>\n", fraction, xmlEscape(o.Text))
+			}
+
+		case "truefalse":
+			answer := true
+			for _, o := range q.Options {
+				if o.IsCorrect {
+					answer = isTrueLabel(o.Text)
+				}
+			}
+			trueFraction, falseFraction := "100", "0"
+			if !answer {
+				trueFraction, falseFraction = "0", "100"
+			}
+			fmt.Fprintf(&sb, "    <answer fraction=\"%s\"><text>true</text></answer>\n", trueFraction)
+			fmt.Fprintf(&sb, "    <answer fraction=\"%s\"><text>false</text></answer>\n", falseFraction)
+
+		case "shortanswer":
+			sb.WriteString("    <usecase>0</usecase>\n")
+			for _, o := range q.Options {
+				fmt.Fprintf(&sb, "    <answer fraction=\"100\"><text>%s</text></answer>\n", xmlEscape(o.Text))
+			}
+
+		case "matching":
+			sb.WriteString("    <shuffleanswers>true</shuffleanswers>\n")
+			for _, o := range q.Options {
+				fmt.Fprintf(&sb, "    <subquestion format=\"plain_text\"><text>%s</text><answer><text>%s</text></answer></subquestion>\n",
+					xmlEscape(o.Text), xmlEscape(o.MatchText))
+			}
+
+		case "ordering":
+			sb.WriteString("    <layouttype>VERTICAL</layouttype>\n    <selecttype>ALL</selecttype>\n    <gradingtype>ABSOLUTE_POSITION</gradingtype>\n")
+			for i, o := range q.Options {
+				fmt.Fprintf(&sb, "    <answer fraction=\"%d\" format=\"plain_text\"><text>%s</text></answer>\n", i+1, xmlEscape(o.Text))
+			}
+
+		case "essay":
+			sb.WriteString("    <responseformat>plain</responseformat>\n    <responsefieldlines>10</responsefieldlines>\n")
+			fmt.Fprintf(&sb, "    <graderinfo format=\"plain_text\"><text>%s</text></graderinfo>\n", xmlEscape(q.Rubric))
+		}
+
+		if q.Difficulty != "" {
+			fmt.Fprintf(&sb, "    <tags><tag><text>%s</text></tag></tags>\n", xmlEscape(q.Difficulty))
+		}
+		sb.WriteString("  </question>\n")
+	}
+
+	sb.WriteString("</quiz>\n")
+	return []byte(sb.String()), skipped
+}
+
+func isTrueLabel(s string) bool {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "đúng", "true", "t", "đ", "dung":
+		return true
+	}
+	return false
+}
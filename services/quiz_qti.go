@@ -0,0 +1,497 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vnkhanh/e-podcast-backend/models"
+)
+
+// ==================== IMS QTI 2.1 ====================
+
+// ParseQTI đọc một assessmentItem XML hoặc gói content package (.zip có imsmanifest.xml)
+func ParseQTI(data []byte) ([]QuizQuestion, []ImportIssue, error) {
+	if bytes.HasPrefix(data, []byte("PK")) {
+		return parseQTIPackage(data)
+	}
+
+	root, err := parseXMLNode(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("XML không hợp lệ: %v", err)
+	}
+	if root.XMLName.Local != "assessmentItem" {
+		return nil, nil, fmt.Errorf("không phải QTI assessmentItem")
+	}
+
+	q, reason := parseQTIItem(root)
+	q.Row = 1
+	if reason != "" {
+		return nil, []ImportIssue{{Row: 1, Question: q.Question, Reason: reason}}, nil
+	}
+	return []QuizQuestion{q}, nil, nil
+}
+
+func parseQTIPackage(data []byte) ([]QuizQuestion, []ImportIssue, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("file zip không hợp lệ: %v", err)
+	}
+
+	files := map[string]*zip.File{}
+	var names []string
+	for _, f := range zr.File {
+		files[f.Name] = f
+		names = append(names, f.Name)
+	}
+
+	// Thứ tự item theo manifest, nếu không có thì theo tên file
+	var itemPaths []string
+	if mf, ok := files["imsmanifest.xml"]; ok {
+		if content, err := readZipFile(mf); err == nil {
+			if manifest, err := parseXMLNode(content); err == nil {
+				collectQTIResources(manifest, &itemPaths)
+			}
+		}
+	}
+	if len(itemPaths) == 0 {
+		sort.Strings(names)
+		for _, name := range names {
+			if strings.HasSuffix(strings.ToLower(name), ".xml") && path.Base(name) != "imsmanifest.xml" {
+				itemPaths = append(itemPaths, name)
+			}
+		}
+	}
+
+	var questions []QuizQuestion
+	var issues []ImportIssue
+	for i, p := range itemPaths {
+		row := i + 1
+		f, ok := files[p]
+		if !ok {
+			issues = append(issues, ImportIssue{Row: row, Reason: "Không tìm thấy file " + p + " trong gói"})
+			continue
+		}
+		content, err := readZipFile(f)
+		if err != nil {
+			issues = append(issues, ImportIssue{Row: row, Reason: "Không đọc được " + p})
+			continue
+		}
+		root, err := parseXMLNode(content)
+		if err != nil {
+			issues = append(issues, ImportIssue{Row: row, Reason: fmt.Sprintf("%s: XML không hợp lệ", p)})
+			continue
+		}
+		if root.XMLName.Local != "assessmentItem" {
+			continue
+		}
+
+		q, reason := parseQTIItem(root)
+		q.Row = row
+		if reason != "" {
+			issues = append(issues, ImportIssue{Row: row, Question: q.Question, Reason: reason})
+			continue
+		}
+		questions = append(questions, q)
+	}
+
+	if len(questions) == 0 && len(issues) == 0 {
+		return nil, nil, fmt.Errorf("gói QTI không có assessmentItem nào")
+	}
+	return questions, issues, nil
+}
+
+func collectQTIResources(n *xmlNode, out *[]string) {
+	if n.XMLName.Local == "resource" && strings.HasPrefix(n.attr("type"), "imsqti_item") {
+		if href := n.attr("href"); href != "" {
+			*out = append(*out, href)
+		}
+	}
+	for i := range n.Nodes {
+		collectQTIResources(&n.Nodes[i], out)
+	}
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	var buf bytes.Buffer
+	_, err = buf.ReadFrom(rc)
+	return buf.Bytes(), err
+}
+
+func parseQTIItem(item *xmlNode) (QuizQuestion, string) {
+	q := QuizQuestion{Points: 1, Difficulty: "medium"}
+
+	body := item.child("itemBody")
+	if body == nil {
+		return q, "Thiếu itemBody"
+	}
+
+	// Điểm tối đa
+	for _, od := range item.children("outcomeDeclaration") {
+		if id := od.attr("identifier"); id == "MAXSCORE" || id == "SCORE" {
+			if v, err := strconv.ParseFloat(od.find("value").text(), 64); err == nil && v > 0 {
+				q.Points = v
+				if id == "MAXSCORE" {
+					break
+				}
+			}
+		}
+	}
+
+	for _, fb := range item.children("modalFeedback") {
+		if q.Explanation == "" {
+			q.Explanation = plainText(fb.text())
+		}
+	}
+
+	// Đáp án đúng
+	var correct []string
+	mapped := map[string]bool{}
+	var mappedKeys []string
+	if rd := item.child("responseDeclaration"); rd != nil {
+		if cr := rd.child("correctResponse"); cr != nil {
+			for _, v := range cr.children("value") {
+				correct = append(correct, strings.TrimSpace(v.Content))
+			}
+		}
+		if mapping := rd.child("mapping"); mapping != nil {
+			for _, me := range mapping.children("mapEntry") {
+				if v, _ := strconv.ParseFloat(me.attr("mappedValue"), 64); v > 0 {
+					mapped[me.attr("mapKey")] = true
+					mappedKeys = append(mappedKeys, me.attr("mapKey"))
+				}
+			}
+		}
+	}
+	isCorrect := func(id string) bool {
+		for _, c := range correct {
+			if c == id {
+				return true
+			}
+		}
+		return mapped[id]
+	}
+
+	// Nội dung câu hỏi: prompt của interaction hoặc phần chữ ngoài interaction
+	interaction, kind := findQTIInteraction(body)
+	if interaction == nil {
+		q.Question = plainText(body.text())
+		return q, "Không có interaction được hỗ trợ (choice, textEntry, extendedText, order, match)"
+	}
+	if p := interaction.child("prompt"); p != nil {
+		q.Question = plainText(p.text())
+	}
+	if q.Question == "" {
+		q.Question = qtiBodyText(body)
+	}
+	if q.Question == "" {
+		q.Question = item.attr("title")
+	}
+
+	switch kind {
+	case "choiceInteraction":
+		maxChoices, _ := strconv.Atoi(interaction.attr("maxChoices"))
+		q.Type = models.QuestionTypeSingleChoice
+		if maxChoices != 1 {
+			q.Type = models.QuestionTypeMultipleChoice
+		}
+		for _, ch := range interaction.children("simpleChoice") {
+			q.Options = append(q.Options, QuizOption{Text: plainText(ch.text()), IsCorrect: isCorrect(ch.attr("identifier"))})
+		}
+		if q.Type == models.QuestionTypeSingleChoice && len(q.Options) == 2 &&
+			isTrueFalseLabel(q.Options[0].Text) && isTrueFalseLabel(q.Options[1].Text) {
+			q.Type = models.QuestionTypeTrueFalse
+		}
+
+	case "textEntryInteraction":
+		q.Type = models.QuestionTypeFillBlank
+		seen := map[string]bool{}
+		for _, c := range correct {
+			if !seen[c] {
+				seen[c] = true
+				q.Options = append(q.Options, QuizOption{Text: c, IsCorrect: true})
+			}
+		}
+		for _, key := range mappedKeys {
+			if !seen[key] {
+				seen[key] = true
+				q.Options = append(q.Options, QuizOption{Text: key, IsCorrect: true})
+			}
+		}
+
+	case "extendedTextInteraction":
+		q.Type = models.QuestionTypeEssay
+		for _, rb := range body.children("rubricBlock") {
+			q.Rubric = plainText(rb.text())
+		}
+
+	case "orderInteraction":
+		q.Type = models.QuestionTypeOrdering
+		texts := map[string]string{}
+		var ids []string
+		for _, ch := range interaction.children("simpleChoice") {
+			texts[ch.attr("identifier")] = plainText(ch.text())
+			ids = append(ids, ch.attr("identifier"))
+		}
+		if len(correct) > 0 {
+			ids = correct
+		}
+		for _, id := range ids {
+			text, ok := texts[id]
+			if !ok {
+				return q, "correctResponse tham chiếu lựa chọn không tồn tại: " + id
+			}
+			q.Options = append(q.Options, QuizOption{Text: text, IsCorrect: true})
+		}
+
+	case "matchInteraction":
+		q.Type = models.QuestionTypeMatching
+		sets := interaction.children("simpleMatchSet")
+		if len(sets) != 2 {
+			return q, "matchInteraction cần đúng 2 simpleMatchSet"
+		}
+		left := map[string]string{}
+		var leftIDs []string
+		for _, ch := range sets[0].children("simpleAssociableChoice") {
+			left[ch.attr("identifier")] = plainText(ch.text())
+			leftIDs = append(leftIDs, ch.attr("identifier"))
+		}
+		right := map[string]string{}
+		for _, ch := range sets[1].children("simpleAssociableChoice") {
+			right[ch.attr("identifier")] = plainText(ch.text())
+		}
+		pairs := map[string]string{}
+		for _, c := range correct {
+			parts := strings.Fields(c)
+			if len(parts) == 2 {
+				pairs[parts[0]] = parts[1]
+			}
+		}
+		for _, id := range leftIDs {
+			rid, ok := pairs[id]
+			if !ok {
+				return q, "Thiếu cặp ghép đúng cho " + left[id]
+			}
+			q.Options = append(q.Options, QuizOption{Text: left[id], MatchText: right[rid], IsCorrect: true})
+		}
+	}
+
+	return q, ""
+}
+
+var qtiInteractions = []string{"choiceInteraction", "textEntryInteraction", "extendedTextInteraction", "orderInteraction", "matchInteraction"}
+
+func findQTIInteraction(body *xmlNode) (*xmlNode, string) {
+	for _, name := range qtiInteractions {
+		if n := body.find(name); n != nil {
+			return n, name
+		}
+	}
+	return nil, ""
+}
+
+// qtiBodyText lấy chữ của itemBody, bỏ qua các interaction / rubricBlock
+func qtiBodyText(n *xmlNode) string {
+	var sb strings.Builder
+	var walk func(*xmlNode)
+	walk = func(node *xmlNode) {
+		sb.WriteString(node.Content)
+		for i := range node.Nodes {
+			child := &node.Nodes[i]
+			switch child.XMLName.Local {
+			case "choiceInteraction", "extendedTextInteraction", "orderInteraction", "matchInteraction", "rubricBlock":
+				continue
+			case "textEntryInteraction":
+				sb.WriteString(" ___ ")
+				continue
+			}
+			sb.WriteString(" ")
+			walk(child)
+		}
+	}
+	walk(n)
+	return plainText(sb.String())
+}
+
+func isTrueFalseLabel(s string) bool {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "đúng", "sai", "true", "false":
+		return true
+	}
+	return false
+}
+
+// ExportQTI xuất câu hỏi thành gói QTI 2.1 (zip gồm imsmanifest.xml + mỗi câu một file item)
+func ExportQTI(questions []QuizQuestion) ([]byte, []ImportIssue, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	var resources strings.Builder
+	for i, q := range questions {
+		id := fmt.Sprintf("item%03d", i+1)
+		href := id + ".xml"
+
+		w, err := zw.Create(href)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, err := w.Write([]byte(buildQTIItem(id, q))); err != nil {
+			return nil, nil, err
+		}
+
+		fmt.Fprintf(&resources, "    <resource identifier=\"RES-%s\" type=\"imsqti_item_xmlv2p1\" href=\"%s\">\n      <file href=\"%s\"/>\n    </resource>\n", id, href, href)
+	}
+
+	manifest := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<manifest xmlns="http://www.imsglobal.org/xsd/imscp_v1p1" identifier="MANIFEST-1">
+  <metadata>
+    <schema>IMS Content</schema>
+    <schemaversion>1.1</schemaversion>
+  </metadata>
+  <organizations/>
+  <resources>
+%s  </resources>
+</manifest>
+`, resources.String())
+
+	w, err := zw.Create("imsmanifest.xml")
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := w.Write([]byte(manifest)); err != nil {
+		return nil, nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, nil, err
+	}
+
+	return buf.Bytes(), nil, nil
+}
+
+func buildQTIItem(id string, q QuizQuestion) string {
+	var decl, body strings.Builder
+	template := "match_correct"
+	choiceID := func(i int) string { return fmt.Sprintf("C%d", i+1) }
+
+	switch q.Type {
+	case models.QuestionTypeMultipleChoice:
+		decl.WriteString(`  <responseDeclaration identifier="RESPONSE" cardinality="multiple" baseType="identifier">` + "\n    <correctResponse>\n")
+		for i, o := range q.Options {
+			if o.IsCorrect {
+				fmt.Fprintf(&decl, "      <value>%s</value>\n", choiceID(i))
+			}
+		}
+		decl.WriteString("    </correctResponse>\n  </responseDeclaration>\n")
+		fmt.Fprintf(&body, "    <choiceInteraction responseIdentifier=\"RESPONSE\" shuffle=\"true\" maxChoices=\"0\">\n      <prompt>%s</prompt>\n", xmlEscape(q.Question))
+		for i, o := range q.Options {
+			fmt.Fprintf(&body, "      <simpleChoice identifier=\"%s\">%s</simpleChoice>\n", choiceID(i), xmlEscape(o.Text))
+		}
+		body.WriteString("    </choiceInteraction>\n")
+
+	case models.QuestionTypeFillBlank:
+		template = "map_response"
+		decl.WriteString(`  <responseDeclaration identifier="RESPONSE" cardinality="single" baseType="string">` + "\n")
+		if len(q.Options) > 0 {
+			fmt.Fprintf(&decl, "    <correctResponse>\n      <value>%s</value>\n    </correctResponse>\n", xmlEscape(q.Options[0].Text))
+		}
+		fmt.Fprintf(&decl, "    <mapping defaultValue=\"0\">\n")
+		for _, o := range q.Options {
+			fmt.Fprintf(&decl, "      <mapEntry mapKey=\"%s\" mappedValue=\"%s\" caseSensitive=\"false\"/>\n", xmlEscape(o.Text), formatPoints(q.Points))
+		}
+		decl.WriteString("    </mapping>\n  </responseDeclaration>\n")
+		fmt.Fprintf(&body, "    <p>%s</p>\n    <p><textEntryInteraction responseIdentifier=\"RESPONSE\" expectedLength=\"30\"/></p>\n", xmlEscape(q.Question))
+
+	case models.QuestionTypeOrdering:
+		decl.WriteString(`  <responseDeclaration identifier="RESPONSE" cardinality="ordered" baseType="identifier">` + "\n    <correctResponse>\n")
+		for i := range q.Options {
+			fmt.Fprintf(&decl, "      <value>%s</value>\n", choiceID(i))
+		}
+		decl.WriteString("    </correctResponse>\n  </responseDeclaration>\n")
+		fmt.Fprintf(&body, "    <orderInteraction responseIdentifier=\"RESPONSE\" shuffle=\"true\">\n      <prompt>%s</prompt>\n", xmlEscape(q.Question))
+		for i, o := range q.Options {
+			fmt.Fprintf(&body, "      <simpleChoice identifier=\"%s\">%s</simpleChoice>\n", choiceID(i), xmlEscape(o.Text))
+		}
+		body.WriteString("    </orderInteraction>\n")
+
+	case models.QuestionTypeMatching:
+		decl.WriteString(`  <responseDeclaration identifier="RESPONSE" cardinality="multiple" baseType="directedPair">` + "\n    <correctResponse>\n")
+		for i := range q.Options {
+			fmt.Fprintf(&decl, "      <value>L%d R%d</value>\n", i+1, i+1)
+		}
+		decl.WriteString("    </correctResponse>\n  </responseDeclaration>\n")
+		fmt.Fprintf(&body, "    <matchInteraction responseIdentifier=\"RESPONSE\" shuffle=\"true\" maxAssociations=\"%d\">\n      <prompt>%s</prompt>\n      <simpleMatchSet>\n", len(q.Options), xmlEscape(q.Question))
+		for i, o := range q.Options {
+			fmt.Fprintf(&body, "        <simpleAssociableChoice identifier=\"L%d\" matchMax=\"1\">%s</simpleAssociableChoice>\n", i+1, xmlEscape(o.Text))
+		}
+		body.WriteString("      </simpleMatchSet>\n      <simpleMatchSet>\n")
+		for i, o := range q.Options {
+			fmt.Fprintf(&body, "        <simpleAssociableChoice identifier=\"R%d\" matchMax=\"1\">%s</simpleAssociableChoice>\n", i+1, xmlEscape(o.MatchText))
+		}
+		body.WriteString("      </simpleMatchSet>\n    </matchInteraction>\n")
+
+	case models.QuestionTypeEssay, models.QuestionTypeShortAnswer:
+		template = ""
+		decl.WriteString(`  <responseDeclaration identifier="RESPONSE" cardinality="single" baseType="string"/>` + "\n")
+		if q.Rubric != "" {
+			fmt.Fprintf(&body, "    <rubricBlock view=\"scorer\"><p>%s</p></rubricBlock>\n", xmlEscape(q.Rubric))
+		}
+		expectedLines := 10
+		if q.Type == models.QuestionTypeShortAnswer {
+			expectedLines = 3
+		}
+		fmt.Fprintf(&body, "    <extendedTextInteraction responseIdentifier=\"RESPONSE\" expectedLines=\"%d\">\n      <prompt>%s</prompt>\n    </extendedTextInteraction>\n", expectedLines, xmlEscape(q.Question))
+
+	default:
+		// single_choice, true_false
+		decl.WriteString(`  <responseDeclaration identifier="RESPONSE" cardinality="single" baseType="identifier">` + "\n    <correctResponse>\n")
+		for i, o := range q.Options {
+			if o.IsCorrect {
+				fmt.Fprintf(&decl, "      <value>%s</value>\n", choiceID(i))
+				break
+			}
+		}
+		decl.WriteString("    </correctResponse>\n  </responseDeclaration>\n")
+		shuffle := q.Type != models.QuestionTypeTrueFalse
+		fmt.Fprintf(&body, "    <choiceInteraction responseIdentifier=\"RESPONSE\" shuffle=\"%t\" maxChoices=\"1\">\n      <prompt>%s</prompt>\n", shuffle, xmlEscape(q.Question))
+		for i, o := range q.Options {
+			fmt.Fprintf(&body, "      <simpleChoice identifier=\"%s\">%s</simpleChoice>\n", choiceID(i), xmlEscape(o.Text))
+		}
+		body.WriteString("    </choiceInteraction>\n")
+	}
+
+	title := q.Question
+	if r := []rune(title); len(r) > 60 {
+		title = string(r[:60]) + "..."
+	}
+
+	var sb strings.Builder
+	sb.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	fmt.Fprintf(&sb, `<assessmentItem xmlns="http://www.imsglobal.org/xsd/imsqti_v2p1" identifier="%s" title="%s" adaptive="false" timeDependent="false">`+"\n",
+		id, xmlEscape(title))
+	sb.WriteString(decl.String())
+	sb.WriteString(`  <outcomeDeclaration identifier="SCORE" cardinality="single" baseType="float">` + "\n    <defaultValue><value>0</value></defaultValue>\n  </outcomeDeclaration>\n")
+	fmt.Fprintf(&sb, `  <outcomeDeclaration identifier="MAXSCORE" cardinality="single" baseType="float">`+"\n    <defaultValue><value>%s</value></defaultValue>\n  </outcomeDeclaration>\n", formatPoints(q.Points))
+	if q.Explanation != "" {
+		sb.WriteString(`  <outcomeDeclaration identifier="FEEDBACK" cardinality="single" baseType="identifier"/>` + "\n")
+	}
+	sb.WriteString("  <itemBody>\n")
+	sb.WriteString(body.String())
+	sb.WriteString("  </itemBody>\n")
+	if template != "" {
+		fmt.Fprintf(&sb, "  <responseProcessing template=\"http://www.imsglobal.org/question/qti_v2p1/rptemplates/%s\"/>\n", template)
+	}
+	if q.Explanation != "" {
+		fmt.Fprintf(&sb, "  <modalFeedback outcomeIdentifier=\"FEEDBACK\" showHide=\"hide\" identifier=\"GENERAL\">%s</modalFeedback>\n", xmlEscape(q.Explanation))
+	}
+	sb.WriteString("</assessmentItem>\n")
+	return sb.String()
+}
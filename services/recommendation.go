@@ -0,0 +1,365 @@
+package services
+
+import (
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ==================== GỢI Ý CÁ NHÂN HÓA (TRANG CHỦ) ====================
+
+const (
+	reviewScoreThreshold = 5.0 // Điểm quiz cao nhất dưới mức này thì gợi ý nghe lại
+	similarSeedLimit     = 3   // Số podcast gần đây dùng làm gốc cho "vì bạn đã nghe"
+)
+
+// Thứ tự chương / podcast giống trang chi tiết môn học: sort_order rồi số trong tiêu đề
+const chapterPodcastOrder = `ch.sort_order,
+	CAST(substring(ch.title from '[0-9]+') AS INTEGER) NULLS LAST, ch.title,
+	CAST(substring(p.title from '[0-9]+') AS INTEGER) NULLS LAST, p.title`
+
+// RecommendedPodcast là một thẻ podcast trong các kệ gợi ý
+type RecommendedPodcast struct {
+	ID           uuid.UUID `json:"id"`
+	Title        string    `json:"title"`
+	CoverImage   string    `json:"cover_image"`
+	AudioURL     string    `json:"audio_url"`
+	DurationSec  int       `json:"duration_sec"`
+	ViewCount    int       `json:"view_count"`
+	LikeCount    int       `json:"like_count"`
+	ChapterID    uuid.UUID `json:"chapter_id"`
+	ChapterTitle string    `json:"chapter_title"`
+	SubjectID    uuid.UUID `json:"subject_id"`
+	SubjectName  string    `json:"subject_name"`
+	SubjectSlug  string    `json:"subject_slug"`
+	LastPosition int       `json:"last_position"` // Vị trí nghe cuối của user (giây), 0 = chưa nghe
+	Completed    bool      `json:"completed"`
+	Reason       string    `json:"reason,omitempty"`
+	Score        float64   `json:"score,omitempty"` // Độ tương đồng (kệ "vì bạn đã nghe") hoặc điểm quiz (kệ ôn tập)
+}
+
+// SimilarShelf là kệ "vì bạn đã nghe <seed>"
+type SimilarShelf struct {
+	Seed     RecommendedPodcast   `json:"seed"`
+	Podcasts []RecommendedPodcast `json:"podcasts"`
+}
+
+type scoredPodcast struct {
+	PodcastID uuid.UUID
+	Score     float64
+}
+
+func roundScore(s float64) float64 {
+	return math.Round(s*100) / 100
+}
+
+// LoadPodcastCards lấy thẻ podcast (đã xuất bản) theo danh sách id, giữ nguyên thứ tự
+func LoadPodcastCards(db *gorm.DB, userID uuid.UUID, ids []uuid.UUID) ([]RecommendedPodcast, error) {
+	if len(ids) == 0 {
+		return []RecommendedPodcast{}, nil
+	}
+	var rows []RecommendedPodcast
+	if err := db.Raw(`
+		SELECT p.id, p.title, p.cover_image, p.audio_url, p.duration_sec, p.view_count, p.like_count,
+			ch.id AS chapter_id, ch.title AS chapter_title,
+			s.id AS subject_id, s.name AS subject_name, s.slug AS subject_slug,
+			COALESCE(lh.last_position, 0) AS last_position, COALESCE(lh.completed, FALSE) AS completed
+		FROM podcasts p
+		JOIN chapters ch ON ch.id = p.chapter_id
+		JOIN subjects s ON s.id = ch.subject_id
+		LEFT JOIN listening_histories lh ON lh.podcast_id = p.id AND lh.user_id = ?
+		WHERE p.id IN ? AND p.status = 'published'
+	`, userID, ids).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uuid.UUID]RecommendedPodcast, len(rows))
+	for _, r := range rows {
+		byID[r.ID] = r
+	}
+	out := make([]RecommendedPodcast, 0, len(rows))
+	for _, id := range ids {
+		if r, ok := byID[id]; ok {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+// loadScoredCards lấy thẻ của danh sách podcast đã chấm điểm, gắn điểm đã làm tròn
+func loadScoredCards(db *gorm.DB, userID uuid.UUID, scored []scoredPodcast) ([]RecommendedPodcast, error) {
+	ids := make([]uuid.UUID, 0, len(scored))
+	scores := make(map[uuid.UUID]float64, len(scored))
+	for _, s := range scored {
+		ids = append(ids, s.PodcastID)
+		scores[s.PodcastID] = s.Score
+	}
+	cards, err := LoadPodcastCards(db, userID, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range cards {
+		cards[i].Score = roundScore(scores[cards[i].ID])
+	}
+	return cards, nil
+}
+
+// ContinueListening: podcast đang nghe dở, mới nhất trước
+func ContinueListening(db *gorm.DB, userID uuid.UUID, limit int) ([]RecommendedPodcast, error) {
+	var ids []uuid.UUID
+	if err := db.Raw(`
+		SELECT lh.podcast_id FROM listening_histories lh
+		JOIN podcasts p ON p.id = lh.podcast_id
+		WHERE lh.user_id = ? AND NOT lh.completed AND lh.last_position > 0 AND p.status = 'published'
+		ORDER BY lh.last_listened_at DESC
+		LIMIT ?
+	`, userID, limit).Scan(&ids).Error; err != nil {
+		return nil, err
+	}
+
+	cards, err := LoadPodcastCards(db, userID, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range cards {
+		cards[i].Reason = "Nghe tiếp"
+	}
+	return cards, nil
+}
+
+// activeSubjectIDs: môn user ghi danh (lớp chưa lưu trữ) hoặc đã nghe, môn có hoạt động gần nhất trước
+func activeSubjectIDs(db *gorm.DB, userID uuid.UUID, limit int) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := db.Raw(`
+		SELECT a.subject_id FROM (
+			SELECT cl.subject_id, MAX(ce.created_at) AS active_at
+			FROM class_enrollments ce
+			JOIN classes cl ON cl.id = ce.class_id
+			WHERE ce.user_id = ? AND NOT cl.is_archived
+			GROUP BY cl.subject_id
+			UNION ALL
+			SELECT ch.subject_id, MAX(lh.last_listened_at)
+			FROM listening_histories lh
+			JOIN podcasts p ON p.id = lh.podcast_id
+			JOIN chapters ch ON ch.id = p.chapter_id
+			WHERE lh.user_id = ?
+			GROUP BY ch.subject_id
+		) a
+		JOIN subjects s ON s.id = a.subject_id AND s.status = TRUE
+		GROUP BY a.subject_id
+		ORDER BY MAX(a.active_at) DESC
+		LIMIT ?
+	`, userID, userID, limit).Scan(&ids).Error
+	return ids, err
+}
+
+// NextInChapter: với mỗi môn, podcast đầu tiên (theo thứ tự chương) user chưa nghe xong
+func NextInChapter(db *gorm.DB, userID uuid.UUID, limit int) ([]RecommendedPodcast, error) {
+	subjectIDs, err := activeSubjectIDs(db, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	if len(subjectIDs) == 0 {
+		return []RecommendedPodcast{}, nil
+	}
+
+	var rows []struct {
+		SubjectID uuid.UUID
+		PodcastID uuid.UUID
+	}
+	if err := db.Raw(`
+		SELECT DISTINCT ON (ch.subject_id) ch.subject_id, p.id AS podcast_id
+		FROM podcasts p
+		JOIN chapters ch ON ch.id = p.chapter_id
+		LEFT JOIN listening_histories lh ON lh.podcast_id = p.id AND lh.user_id = ?
+		WHERE ch.subject_id IN ? AND p.status = 'published' AND NOT COALESCE(lh.completed, FALSE)
+		ORDER BY ch.subject_id, `+chapterPodcastOrder, userID, subjectIDs).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	next := make(map[uuid.UUID]uuid.UUID, len(rows))
+	for _, r := range rows {
+		next[r.SubjectID] = r.PodcastID
+	}
+	ids := make([]uuid.UUID, 0, len(rows))
+	for _, subjectID := range subjectIDs {
+		if podcastID, ok := next[subjectID]; ok {
+			ids = append(ids, podcastID)
+		}
+	}
+
+	cards, err := LoadPodcastCards(db, userID, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range cards {
+		cards[i].Reason = "Bài tiếp theo trong " + cards[i].SubjectName
+	}
+	return cards, nil
+}
+
+// ReviewByQuiz: podcast có điểm quiz cao nhất còn dưới ngưỡng, nên nghe lại
+func ReviewByQuiz(db *gorm.DB, userID uuid.UUID, limit int) ([]RecommendedPodcast, error) {
+	var rows []scoredPodcast
+	if err := db.Raw(`
+		SELECT qa.podcast_id, MAX(qa.score) AS score
+		FROM quiz_attempts qa
+		JOIN podcasts p ON p.id = qa.podcast_id
+		WHERE qa.user_id = ? AND p.status = 'published' AND qa.taken_at >= ?
+		GROUP BY qa.podcast_id
+		HAVING MAX(qa.score) < ?
+		ORDER BY MAX(qa.taken_at) DESC
+		LIMIT ?
+	`, userID, time.Now().AddDate(0, 0, -60), reviewScoreThreshold, limit).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	cards, err := loadScoredCards(db, userID, rows)
+	if err != nil {
+		return nil, err
+	}
+	for i := range cards {
+		cards[i].Reason = "Ôn lại: điểm quiz chưa đạt"
+	}
+	return cards, nil
+}
+
+// similarPodcasts: item-to-item theo nghe chung — người đã nghe seed còn nghe gì.
+// Điểm = số người nghe chung / sqrt(người nghe seed * người nghe podcast đó) (cosine), bỏ podcast trong exclude.
+func similarPodcasts(db *gorm.DB, seedID uuid.UUID, exclude []uuid.UUID, limit int) ([]scoredPodcast, error) {
+	if len(exclude) == 0 {
+		exclude = []uuid.UUID{seedID}
+	}
+	// Số người nghe của mỗi podcast ứng viên đếm một lần bằng GROUP BY thay vì subquery theo từng dòng
+	var rows []scoredPodcast
+	if err := db.Raw(`
+		WITH seed AS (
+			SELECT user_id FROM listening_histories WHERE podcast_id = ?
+		), co AS (
+			SELECT lh.podcast_id, COUNT(*) AS shared
+			FROM listening_histories lh
+			JOIN seed ON seed.user_id = lh.user_id
+			WHERE lh.podcast_id <> ?
+			GROUP BY lh.podcast_id
+		), listeners AS (
+			SELECT x.podcast_id, COUNT(*) AS total
+			FROM listening_histories x
+			JOIN co ON co.podcast_id = x.podcast_id
+			GROUP BY x.podcast_id
+		)
+		SELECT co.podcast_id, co.shared / SQRT(sc.total::float * l.total) AS score
+		FROM co
+		JOIN listeners l ON l.podcast_id = co.podcast_id
+		CROSS JOIN (SELECT COUNT(*) AS total FROM seed) sc
+		JOIN podcasts p ON p.id = co.podcast_id AND p.status = 'published'
+		WHERE co.podcast_id NOT IN ?
+		ORDER BY score DESC, co.shared DESC
+		LIMIT ?
+	`, seedID, seedID, exclude, limit).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	if len(rows) > 0 {
+		return rows, nil
+	}
+
+	// Chưa đủ dữ liệu nghe chung: podcast cùng môn được nghe nhiều
+	err := db.Raw(`
+		SELECT p.id AS podcast_id, 0 AS score
+		FROM podcasts p
+		JOIN chapters ch ON ch.id = p.chapter_id
+		WHERE ch.subject_id = (
+			SELECT c2.subject_id FROM chapters c2 JOIN podcasts p2 ON p2.chapter_id = c2.id WHERE p2.id = ?
+		) AND p.status = 'published' AND p.id <> ? AND p.id NOT IN ?
+		ORDER BY p.view_count DESC, p.like_count DESC
+		LIMIT ?
+	`, seedID, seedID, exclude, limit).Scan(&rows).Error
+	return rows, err
+}
+
+// SimilarPodcasts: thẻ podcast tương tự seed (người nghe seed còn nghe gì)
+func SimilarPodcasts(db *gorm.DB, userID, seedID uuid.UUID, limit int) ([]RecommendedPodcast, error) {
+	scored, err := similarPodcasts(db, seedID, nil, limit)
+	if err != nil {
+		return nil, err
+	}
+	return loadScoredCards(db, userID, scored)
+}
+
+// BecauseYouListened: kệ gợi ý cho mỗi podcast gốc (yêu thích / nghe gần đây), bỏ podcast user đã nghe
+func BecauseYouListened(db *gorm.DB, userID uuid.UUID, limit int) ([]SimilarShelf, error) {
+	var seeds []uuid.UUID
+	if err := db.Raw(`
+		SELECT podcast_id FROM (
+			SELECT podcast_id, created_at AS active_at FROM favorites WHERE user_id = ?
+			UNION ALL
+			SELECT podcast_id, last_listened_at FROM listening_histories WHERE user_id = ?
+		) s
+		GROUP BY podcast_id
+		ORDER BY MAX(active_at) DESC
+		LIMIT ?
+	`, userID, userID, similarSeedLimit).Scan(&seeds).Error; err != nil {
+		return nil, err
+	}
+	if len(seeds) == 0 {
+		return []SimilarShelf{}, nil
+	}
+
+	var exclude []uuid.UUID
+	if err := db.Raw("SELECT podcast_id FROM listening_histories WHERE user_id = ?", userID).Scan(&exclude).Error; err != nil {
+		return nil, err
+	}
+	exclude = append(exclude, seeds...)
+
+	seedCards, err := LoadPodcastCards(db, userID, seeds)
+	if err != nil {
+		return nil, err
+	}
+	shelves := make([]SimilarShelf, 0, len(seedCards))
+	for _, seed := range seedCards {
+		scored, err := similarPodcasts(db, seed.ID, exclude, limit)
+		if err != nil {
+			return nil, err
+		}
+		cards, err := loadScoredCards(db, userID, scored)
+		if err != nil {
+			return nil, err
+		}
+		if len(cards) == 0 {
+			continue
+		}
+		for i := range cards {
+			cards[i].Reason = "Vì bạn đã nghe " + seed.Title
+			exclude = append(exclude, cards[i].ID) // Không lặp lại podcast giữa các kệ
+		}
+		shelves = append(shelves, SimilarShelf{Seed: seed, Podcasts: cards})
+	}
+	return shelves, nil
+}
+
+// TrendingPodcasts: nghe nhiều nhất từ ngày thống kê since (podcast_analytics), bỏ podcast user đã nghe
+func TrendingPodcasts(db *gorm.DB, userID uuid.UUID, since time.Time, limit int) ([]RecommendedPodcast, error) {
+	var ids []uuid.UUID
+	if err := db.Raw(`
+		SELECT p.id FROM podcasts p
+		LEFT JOIN podcast_analytics pa ON pa.podcast_id = p.id AND pa.date >= ?
+		WHERE p.status = 'published'
+			AND NOT EXISTS (SELECT 1 FROM listening_histories lh WHERE lh.podcast_id = p.id AND lh.user_id = ?)
+		GROUP BY p.id, p.like_count, p.view_count
+		ORDER BY COALESCE(SUM(pa.total_plays), 0) DESC, p.like_count DESC, p.view_count DESC
+		LIMIT ?
+	`, since, userID, limit).Scan(&ids).Error; err != nil {
+		return nil, err
+	}
+
+	cards, err := LoadPodcastCards(db, userID, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range cards {
+		cards[i].Reason = "Đang được nghe nhiều"
+	}
+	return cards, nil
+}
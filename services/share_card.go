@@ -0,0 +1,317 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gosimple/unidecode"
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+	_ "golang.org/x/image/webp"
+)
+
+// ==================== ẢNH CHIA SẺ (OPEN GRAPH 1200x630) ====================
+
+const (
+	ShareCardWidth  = 1200
+	ShareCardHeight = 630
+
+	maxCoverBytes = 5 << 20
+)
+
+// ShareCard là nội dung vẽ lên ảnh chia sẻ
+type ShareCard struct {
+	Title    string      // Tên podcast
+	Subtitle string      // Tên môn học
+	Footer   string      // Tên trang + thời lượng
+	Cover    image.Image // Ảnh bìa, nil thì vẽ khối màu
+}
+
+var (
+	cardBgTop    = color.RGBA{15, 23, 42, 255}
+	cardBgBottom = color.RGBA{30, 41, 59, 255}
+	cardAccent   = color.RGBA{129, 140, 248, 255}
+	cardText     = color.RGBA{248, 250, 252, 255}
+	cardMuted    = color.RGBA{148, 163, 184, 255}
+)
+
+// Font tiếng Việt: SHARE_CARD_FONT / SHARE_CARD_FONT_BOLD, sau đó font hệ thống phổ biến,
+// cuối cùng là Go font (không có dấu tiếng Việt, chữ thiếu glyph được bỏ dấu)
+var (
+	cardFontRegular *sfnt.Font
+	cardFontBold    *sfnt.Font
+	cardFontOnce    sync.Once
+)
+
+var (
+	regularFontPaths = []string{
+		"/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf",
+		"/usr/share/fonts/truetype/DejaVuSans.ttf",
+		"/usr/share/fonts/dejavu/DejaVuSans.ttf",
+		"/usr/share/fonts/truetype/noto/NotoSans-Regular.ttf",
+	}
+	boldFontPaths = []string{
+		"/usr/share/fonts/truetype/dejavu/DejaVuSans-Bold.ttf",
+		"/usr/share/fonts/truetype/DejaVuSans-Bold.ttf",
+		"/usr/share/fonts/dejavu/DejaVuSans-Bold.ttf",
+		"/usr/share/fonts/truetype/noto/NotoSans-Bold.ttf",
+	}
+)
+
+func loadCardFont(envKey string, paths []string, fallback []byte) *sfnt.Font {
+	candidates := paths
+	if p := os.Getenv(envKey); p != "" {
+		candidates = append([]string{p}, paths...)
+	}
+	for _, p := range candidates {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			continue
+		}
+		f, err := opentype.Parse(data)
+		if err != nil {
+			log.Printf("Không đọc được font %s: %v\n", p, err)
+			continue
+		}
+		return f
+	}
+	f, _ := opentype.Parse(fallback)
+	return f
+}
+
+func cardFonts() (*sfnt.Font, *sfnt.Font) {
+	cardFontOnce.Do(func() {
+		cardFontRegular = loadCardFont("SHARE_CARD_FONT", regularFontPaths, goregular.TTF)
+		cardFontBold = loadCardFont("SHARE_CARD_FONT_BOLD", boldFontPaths, gobold.TTF)
+	})
+	return cardFontRegular, cardFontBold
+}
+
+// drawableText thay ký tự font không có bằng dạng không dấu để không hiện ô vuông
+func drawableText(f *sfnt.Font, s string) string {
+	var buf sfnt.Buffer
+	var b strings.Builder
+	for _, r := range s {
+		if idx, err := f.GlyphIndex(&buf, r); err == nil && idx != 0 {
+			b.WriteRune(r)
+			continue
+		}
+		b.WriteString(unidecode.Unidecode(string(r)))
+	}
+	return b.String()
+}
+
+func newFace(f *sfnt.Font, size float64) (font.Face, error) {
+	return opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+}
+
+// wrapText ngắt dòng theo từ trong maxWidth, tối đa maxLines dòng (dòng cuối thêm "…" nếu bị cắt)
+func wrapText(face font.Face, text string, maxWidth int, maxLines int) []string {
+	limit := fixed.I(maxWidth)
+	var lines []string
+	current := ""
+	truncated := false
+	for _, w := range strings.Fields(text) {
+		candidate := w
+		if current != "" {
+			candidate = current + " " + w
+		}
+		if current == "" || font.MeasureString(face, candidate) <= limit {
+			current = candidate
+			continue
+		}
+		if len(lines) == maxLines-1 {
+			truncated = true
+			break
+		}
+		lines = append(lines, current)
+		current = w
+	}
+	if current == "" {
+		return lines
+	}
+	if truncated || font.MeasureString(face, current) > limit {
+		// Bỏ cả từ cuối trước, chỉ cắt giữa từ khi dòng chỉ còn một từ
+		for current != "" && font.MeasureString(face, current+"…") > limit {
+			if i := strings.LastIndex(current, " "); i > 0 {
+				current = current[:i]
+				continue
+			}
+			runes := []rune(current)
+			current = string(runes[:len(runes)-1])
+		}
+		current += "…"
+	}
+	return append(lines, current)
+}
+
+func drawString(dst draw.Image, face font.Face, c color.Color, x, y int, s string) {
+	d := &font.Drawer{Dst: dst, Src: image.NewUniform(c), Face: face, Dot: fixed.P(x, y)}
+	d.DrawString(s)
+}
+
+// coverSquare cắt ảnh bìa thành hình vuông ở giữa rồi co về size x size
+func coverSquare(src image.Image, size int) image.Image {
+	b := src.Bounds()
+	side := b.Dx()
+	if b.Dy() < side {
+		side = b.Dy()
+	}
+	crop := image.Rect(b.Min.X+(b.Dx()-side)/2, b.Min.Y+(b.Dy()-side)/2, 0, 0)
+	crop.Max = crop.Min.Add(image.Pt(side, side))
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), src, crop, xdraw.Src, nil)
+	return dst
+}
+
+// RenderShareCard vẽ ảnh chia sẻ PNG 1200x630: ảnh bìa bên trái, môn học + tên podcast bên phải
+func RenderShareCard(card ShareCard) ([]byte, error) {
+	canvas := image.NewRGBA(image.Rect(0, 0, ShareCardWidth, ShareCardHeight))
+
+	// Nền chuyển màu theo chiều dọc
+	for y := 0; y < ShareCardHeight; y++ {
+		t := float64(y) / float64(ShareCardHeight-1)
+		row := color.RGBA{
+			R: uint8(float64(cardBgTop.R) + t*(float64(cardBgBottom.R)-float64(cardBgTop.R))),
+			G: uint8(float64(cardBgTop.G) + t*(float64(cardBgBottom.G)-float64(cardBgTop.G))),
+			B: uint8(float64(cardBgTop.B) + t*(float64(cardBgBottom.B)-float64(cardBgTop.B))),
+			A: 255,
+		}
+		draw.Draw(canvas, image.Rect(0, y, ShareCardWidth, y+1), image.NewUniform(row), image.Point{}, draw.Src)
+	}
+
+	// Ảnh bìa
+	const coverSize, coverX, coverY = 430, 60, 100
+	coverRect := image.Rect(coverX, coverY, coverX+coverSize, coverY+coverSize)
+	if card.Cover != nil {
+		draw.Draw(canvas, coverRect, coverSquare(card.Cover, coverSize), image.Point{}, draw.Src)
+	} else {
+		draw.Draw(canvas, coverRect, image.NewUniform(cardAccent), image.Point{}, draw.Src)
+	}
+
+	regular, bold := cardFonts()
+	subtitleFace, err := newFace(regular, 32)
+	if err != nil {
+		return nil, err
+	}
+	defer subtitleFace.Close()
+	titleFace, err := newFace(bold, 56)
+	if err != nil {
+		return nil, err
+	}
+	defer titleFace.Close()
+	footerFace, err := newFace(regular, 28)
+	if err != nil {
+		return nil, err
+	}
+	defer footerFace.Close()
+
+	const textX, textWidth = 540, 600
+	if card.Subtitle != "" {
+		for _, line := range wrapText(subtitleFace, drawableText(regular, card.Subtitle), textWidth, 1) {
+			drawString(canvas, subtitleFace, cardAccent, textX, 160, line)
+		}
+	}
+	for i, line := range wrapText(titleFace, drawableText(bold, card.Title), textWidth, 4) {
+		drawString(canvas, titleFace, cardText, textX, 250+i*70, line)
+	}
+	if card.Footer != "" {
+		drawString(canvas, footerFace, cardMuted, textX, 530, drawableText(regular, card.Footer))
+	}
+
+	var out bytes.Buffer
+	if err := png.Encode(&out, canvas); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// imageClient chỉ kết nối tới địa chỉ công khai (chặn SSRF vào mạng nội bộ kể cả khi DNS trỏ lại)
+// và chỉ theo redirect trong các host được phép
+var imageClient = &http.Client{
+	Timeout: 5 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: func(_, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				ip := net.ParseIP(host)
+				if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+					ip.IsLinkLocalMulticast() || ip.IsUnspecified() || ip.IsMulticast() {
+					return fmt.Errorf("không cho phép tải ảnh từ địa chỉ %s", host)
+				}
+				return nil
+			},
+		}).DialContext,
+		TLSHandshakeTimeout: 5 * time.Second,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 3 {
+			return errors.New("quá nhiều lần chuyển hướng")
+		}
+		return checkImageURL(req.URL)
+	},
+}
+
+// checkImageURL chỉ cho tải ảnh qua http(s) từ host của SUPABASE_URL (nơi lưu ảnh bìa)
+func checkImageURL(u *url.URL) error {
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return fmt.Errorf("scheme %q không được hỗ trợ", u.Scheme)
+	}
+	storage, err := url.Parse(os.Getenv("SUPABASE_URL"))
+	if err != nil || storage.Hostname() == "" {
+		return errors.New("chưa cấu hình SUPABASE_URL")
+	}
+	if !strings.EqualFold(u.Hostname(), storage.Hostname()) {
+		return fmt.Errorf("host %s không nằm trong danh sách được phép", u.Hostname())
+	}
+	return nil
+}
+
+// FetchImage tải và giải mã ảnh (jpeg / png / gif / webp) từ kho ảnh của hệ thống, giới hạn 5MB
+func FetchImage(rawURL string) (image.Image, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkImageURL(u); err != nil {
+		return nil, err
+	}
+	resp, err := imageClient.Get(u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s trả về %d", rawURL, resp.StatusCode)
+	}
+	img, _, err := image.Decode(io.LimitReader(resp.Body, maxCoverBytes))
+	return img, err
+}
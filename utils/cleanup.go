@@ -2,6 +2,8 @@ package utils
 
 import (
 	"log"
+	"os"
+	"strconv"
 	"time"
 
 	"github.com/vnkhanh/e-podcast-backend/config"
@@ -24,6 +26,37 @@ func CleanupExpiredTokens() {
 	if result.RowsAffected > 0 {
 		log.Printf("Đã xóa %d password reset tokens hết hạn/đã dùng", result.RowsAffected)
 	}
+
+	// Xóa phiên đăng nhập đã hết hạn hoặc bị thu hồi quá 30 ngày
+	cutoff := time.Now().Add(-RefreshTokenTTL)
+	sessions := db.Where("expires_at < ? OR revoked_at < ?", time.Now(), cutoff).
+		Delete(&models.UserSession{})
+	if sessions.Error != nil {
+		log.Printf("Lỗi khi xóa phiên đăng nhập: %v", sessions.Error)
+		return
+	}
+	if sessions.RowsAffected > 0 {
+		log.Printf("Đã xóa %d phiên đăng nhập hết hạn/đã thu hồi", sessions.RowsAffected)
+	}
+
+	// Xóa link xác minh email, thử thách 2FA và state SSO đã hết hạn
+	db.Where("expires_at < ?", time.Now()).Delete(&models.EmailVerification{})
+	db.Where("expires_at < ?", time.Now()).Delete(&models.LoginChallenge{})
+	db.Where("expires_at < ?", time.Now()).Delete(&models.SSOLoginState{})
+
+	// Sự kiện nghe thô đã được cộng dồn vào bảng thống kê, chỉ giữ lại một thời gian
+	events := db.Where("occurred_at < ?", time.Now().AddDate(0, 0, -ListeningEventRetentionDays())).Delete(&models.ListeningEvent{})
+	if events.Error == nil && events.RowsAffected > 0 {
+		log.Printf("Đã xóa %d sự kiện nghe cũ", events.RowsAffected)
+	}
+}
+
+// ListeningEventRetentionDays là số ngày giữ sự kiện nghe thô, đọc từ env LISTENING_EVENT_RETENTION_DAYS (mặc định 180)
+func ListeningEventRetentionDays() int {
+	if v, err := strconv.Atoi(os.Getenv("LISTENING_EVENT_RETENTION_DAYS")); err == nil && v > 0 {
+		return v
+	}
+	return 180
 }
 
 // StartCleanupJob chạy cleanup job định kỳ
@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/gosimple/unidecode"
+)
+
+// SanitizeFileName bỏ ký tự điều khiển, dấu phân cách đường dẫn và dấu ngoặc kép khỏi tên file,
+// khoảng trắng được thay bằng "_"
+func SanitizeFileName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.TrimSpace(name) {
+		switch {
+		case unicode.IsControl(r), strings.ContainsRune(`/\"<>:|?*`, r):
+			continue
+		case unicode.IsSpace(r):
+			b.WriteRune('_')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	if b.Len() == 0 {
+		return "download"
+	}
+	return b.String()
+}
+
+// AttachmentDisposition tạo header Content-Disposition cho file tải về:
+// filename= là bản ASCII (bỏ dấu) cho trình duyệt cũ, filename*= là tên UTF-8 theo RFC 5987
+func AttachmentDisposition(filename string) string {
+	name := SanitizeFileName(filename)
+
+	var ascii strings.Builder
+	for _, r := range unidecode.Unidecode(name) {
+		if r < 0x20 || r > 0x7e || r == '"' || r == '\\' || r == ';' || r == '%' {
+			ascii.WriteRune('_')
+			continue
+		}
+		ascii.WriteRune(r)
+	}
+	return fmt.Sprintf(`attachment; filename="%s"; filename*=UTF-8''%s`, ascii.String(), rfc5987Escape(name))
+}
+
+// rfc5987Escape mã hóa phần trăm mọi byte không thuộc attr-char của RFC 5987
+func rfc5987Escape(s string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9' ||
+			strings.IndexByte("!#$&+-.^_`|~", ch) >= 0 {
+			b.WriteByte(ch)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[ch>>4])
+		b.WriteByte(hex[ch&0x0f])
+	}
+	return b.String()
+}
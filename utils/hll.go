@@ -0,0 +1,137 @@
+package utils
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+// ==================== HYPERLOGLOG (ĐẾM SỐ PHẦN TỬ KHÁC NHAU) ====================
+
+const (
+	hllPrecision = 12 // 4096 thanh ghi, sai số chuẩn ~1.6%
+	hllRegisters = 1 << hllPrecision
+	hllDense     = byte(1) // Định dạng lưu: đủ 4096 thanh ghi
+	hllSparse    = byte(2) // Định dạng lưu: chỉ các thanh ghi khác 0 (chỉ số 2 byte + giá trị 1 byte)
+)
+
+// HLLSketch ước lượng số phần tử khác nhau; gộp được (hợp của nhiều ngày = gộp các sketch)
+type HLLSketch struct {
+	registers []uint8
+}
+
+func NewHLLSketch() *HLLSketch {
+	return &HLLSketch{registers: make([]uint8, hllRegisters)}
+}
+
+// ParseHLLSketch đọc sketch đã lưu; dữ liệu rỗng là sketch rỗng
+func ParseHLLSketch(data []byte) (*HLLSketch, error) {
+	s := NewHLLSketch()
+	if len(data) == 0 {
+		return s, nil
+	}
+	switch data[0] {
+	case hllDense:
+		if len(data) != 1+hllRegisters {
+			return nil, errors.New("sketch không hợp lệ")
+		}
+		copy(s.registers, data[1:])
+	case hllSparse:
+		body := data[1:]
+		if len(body)%3 != 0 {
+			return nil, errors.New("sketch không hợp lệ")
+		}
+		for i := 0; i < len(body); i += 3 {
+			idx := binary.BigEndian.Uint16(body[i:])
+			if int(idx) >= hllRegisters {
+				return nil, errors.New("sketch không hợp lệ")
+			}
+			s.registers[idx] = body[i+2]
+		}
+	default:
+		return nil, errors.New("định dạng sketch không hỗ trợ")
+	}
+	return s, nil
+}
+
+// hllHash: FNV-1a rồi trộn bit (splitmix64) để các bit đầu phân bố đều
+func hllHash(data []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(data)
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// Add thêm phần tử, trả về true nếu sketch thay đổi (phần tử có thể là mới)
+func (s *HLLSketch) Add(data []byte) bool {
+	x := hllHash(data)
+	idx := x >> (64 - hllPrecision)
+	rho := uint8(bits.LeadingZeros64(x<<hllPrecision|1<<(hllPrecision-1)) + 1)
+	if rho > s.registers[idx] {
+		s.registers[idx] = rho
+		return true
+	}
+	return false
+}
+
+// Merge gộp sketch khác vào (hợp hai tập)
+func (s *HLLSketch) Merge(other *HLLSketch) {
+	for i, v := range other.registers {
+		if v > s.registers[i] {
+			s.registers[i] = v
+		}
+	}
+}
+
+// Estimate ước lượng số phần tử khác nhau
+func (s *HLLSketch) Estimate() int64 {
+	m := float64(hllRegisters)
+	sum := 0.0
+	zeros := 0
+	for _, v := range s.registers {
+		sum += math.Ldexp(1, -int(v))
+		if v == 0 {
+			zeros++
+		}
+	}
+	alpha := 0.7213 / (1 + 1.079/m)
+	estimate := alpha * m * m / sum
+
+	// Tập nhỏ: dùng linear counting cho chính xác hơn
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return int64(math.Round(estimate))
+}
+
+// Bytes mã hóa sketch để lưu (bytea), chọn dạng thưa khi ít thanh ghi khác 0
+func (s *HLLSketch) Bytes() []byte {
+	nonZero := 0
+	for _, v := range s.registers {
+		if v != 0 {
+			nonZero++
+		}
+	}
+	if 3*nonZero < hllRegisters {
+		out := make([]byte, 1, 1+3*nonZero)
+		out[0] = hllSparse
+		for i, v := range s.registers {
+			if v != 0 {
+				out = binary.BigEndian.AppendUint16(out, uint16(i))
+				out = append(out, v)
+			}
+		}
+		return out
+	}
+	out := make([]byte, 1+hllRegisters)
+	out[0] = hllDense
+	copy(out[1:], s.registers)
+	return out
+}
@@ -8,24 +8,29 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// Access token ngắn hạn, hết hạn thì client dùng refresh token để lấy token mới
+const AccessTokenTTL = 15 * time.Minute
+
 type JWTClaims struct {
-	UserID string `json:"user_id"`
-	Role   string `json:"role"`
+	UserID    string `json:"user_id"`
+	Role      string `json:"role"`
+	SessionID string `json:"sid,omitempty"` // Phiên đăng nhập, rỗng với token cũ
 	jwt.RegisteredClaims
 }
 
-// GenerateToken tạo JWT token từ userID và role
-func GenerateToken(userID string, role string) (string, error) {
+// GenerateToken tạo access token từ userID, role và phiên đăng nhập
+func GenerateToken(userID string, role string, sessionID string) (string, error) {
 	jwtKey := []byte(os.Getenv("JWT_SECRET")) // Đọc tại thời điểm gọi
 	if len(jwtKey) == 0 {
 		return "", errors.New("JWT_SECRET không được thiết lập")
 	}
 	now := time.Now().UTC()
 	claims := JWTClaims{
-		UserID: userID,
-		Role:   role,
+		UserID:    userID,
+		Role:      role,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(now.Add(7 * 24 * time.Hour)), // 7 ngày từ thời điểm UTC hiện tại
+			ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
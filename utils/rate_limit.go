@@ -0,0 +1,331 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ==================== GIỚI HẠN TẦN SUẤT (TOKEN BUCKET) ====================
+
+// RateLimit: tối đa Requests yêu cầu trong Period (bucket đầy Requests token, hồi dần đều)
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// Disabled: cấu hình "off" hoặc không hợp lệ thì không giới hạn
+func (l RateLimit) Disabled() bool {
+	return l.Requests <= 0 || l.Period <= 0
+}
+
+func (l RateLimit) ratePerSecond() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// ParseRateLimit đọc chuỗi dạng "10/m", "5/15m", "100/h", "off"
+func ParseRateLimit(s string) (RateLimit, error) {
+	s = strings.TrimSpace(strings.ToLower(s))
+	if s == "off" || s == "0" {
+		return RateLimit{}, nil
+	}
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) != 2 {
+		return RateLimit{}, fmt.Errorf("giới hạn không hợp lệ: %q", s)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || n < 0 {
+		return RateLimit{}, fmt.Errorf("số yêu cầu không hợp lệ: %q", s)
+	}
+
+	var period time.Duration
+	switch p := strings.TrimSpace(parts[1]); p {
+	case "s":
+		period = time.Second
+	case "m":
+		period = time.Minute
+	case "h":
+		period = time.Hour
+	default:
+		if period, err = time.ParseDuration(p); err != nil || period <= 0 {
+			return RateLimit{}, fmt.Errorf("khoảng thời gian không hợp lệ: %q", s)
+		}
+	}
+	return RateLimit{Requests: n, Period: period}, nil
+}
+
+// RateLimitFromEnv lấy giới hạn từ biến môi trường RATE_LIMIT_<NAME>, sai định dạng thì dùng mặc định
+func RateLimitFromEnv(name string, fallback string) RateLimit {
+	key := "RATE_LIMIT_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+	if v := os.Getenv(key); v != "" {
+		if limit, err := ParseRateLimit(v); err == nil {
+			return limit
+		}
+		log.Printf("Cấu hình %s không hợp lệ, dùng mặc định %s", key, fallback)
+	}
+	limit, _ := ParseRateLimit(fallback)
+	return limit
+}
+
+// RateLimitResult là kết quả lấy token, dùng để trả header X-RateLimit-*
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	ResetAfter time.Duration // Thời gian đến khi bucket đầy lại
+	RetryAfter time.Duration // Khi bị từ chối: thời gian đến khi có token tiếp theo
+}
+
+// RateLimitStore lưu trạng thái bucket và bộ đếm đăng nhập sai.
+// Mặc định lưu trong bộ nhớ; chạy nhiều instance thì thay bằng store dùng chung (Redis...) qua SetRateLimitStore.
+type RateLimitStore interface {
+	// Take lấy một token từ bucket key
+	Take(key string, limit RateLimit, now time.Time) RateLimitResult
+	// AddFailure tăng số lần thất bại của key (tự quên sau window kể từ lần sai cuối), trả về tổng hiện tại
+	AddFailure(key string, window time.Duration, now time.Time) int
+	// ResetFailures xóa bộ đếm và khóa của key
+	ResetFailures(key string)
+	// Lock khóa key đến thời điểm until
+	Lock(key string, until time.Time)
+	// LockedUntil trả về thời điểm hết khóa (zero nếu không bị khóa)
+	LockedUntil(key string, now time.Time) time.Time
+}
+
+type memoryBucket struct {
+	tokens float64
+	last   time.Time
+	idle   time.Duration // Sau khoảng này bucket chắc chắn đầy lại, có thể bỏ
+}
+
+type memoryFailure struct {
+	count       int
+	lastFailure time.Time
+	window      time.Duration
+	lockedUntil time.Time
+}
+
+// MemoryRateLimitStore là store trong bộ nhớ của một instance
+type MemoryRateLimitStore struct {
+	mu       sync.Mutex
+	buckets  map[string]*memoryBucket
+	failures map[string]*memoryFailure
+}
+
+// NewMemoryRateLimitStore tạo store và chạy dọn dẹp định kỳ các key không còn dùng
+func NewMemoryRateLimitStore(cleanupInterval time.Duration) *MemoryRateLimitStore {
+	s := &MemoryRateLimitStore{
+		buckets:  map[string]*memoryBucket{},
+		failures: map[string]*memoryFailure{},
+	}
+	if cleanupInterval > 0 {
+		go func() {
+			ticker := time.NewTicker(cleanupInterval)
+			defer ticker.Stop()
+			for now := range ticker.C {
+				s.prune(now)
+			}
+		}()
+	}
+	return s
+}
+
+func (s *MemoryRateLimitStore) Take(key string, limit RateLimit, now time.Time) RateLimitResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	capacity := float64(limit.Requests)
+	rate := limit.ratePerSecond()
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &memoryBucket{tokens: capacity, last: now}
+		s.buckets[key] = b
+	}
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(capacity, b.tokens+elapsed*rate)
+	}
+	b.last = now
+	b.idle = limit.Period
+
+	result := RateLimitResult{Limit: limit.Requests}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	}
+	result.Remaining = int(b.tokens)
+	result.ResetAfter = time.Duration((capacity - b.tokens) / rate * float64(time.Second))
+	return result
+}
+
+func (s *MemoryRateLimitStore) AddFailure(key string, window time.Duration, now time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, ok := s.failures[key]
+	if !ok || now.Sub(f.lastFailure) > f.window {
+		f = &memoryFailure{lockedUntil: lockedUntilOf(f)}
+		s.failures[key] = f
+	}
+	f.count++
+	f.lastFailure = now
+	f.window = window
+	return f.count
+}
+
+func lockedUntilOf(f *memoryFailure) time.Time {
+	if f == nil {
+		return time.Time{}
+	}
+	return f.lockedUntil
+}
+
+func (s *MemoryRateLimitStore) ResetFailures(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.failures, key)
+}
+
+func (s *MemoryRateLimitStore) Lock(key string, until time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, ok := s.failures[key]
+	if !ok {
+		f = &memoryFailure{lastFailure: time.Now(), window: time.Until(until)}
+		s.failures[key] = f
+	}
+	f.lockedUntil = until
+}
+
+func (s *MemoryRateLimitStore) LockedUntil(key string, now time.Time) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f, ok := s.failures[key]; ok && now.Before(f.lockedUntil) {
+		return f.lockedUntil
+	}
+	return time.Time{}
+}
+
+func (s *MemoryRateLimitStore) prune(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, b := range s.buckets {
+		if now.Sub(b.last) > b.idle {
+			delete(s.buckets, key)
+		}
+	}
+	for key, f := range s.failures {
+		if now.Sub(f.lastFailure) > f.window && now.After(f.lockedUntil) {
+			delete(s.failures, key)
+		}
+	}
+}
+
+var (
+	rateLimitStoreMu sync.RWMutex
+	rateLimitStore   RateLimitStore
+)
+
+// SetRateLimitStore thay store mặc định (gọi khi khởi động, trước khi nhận request)
+func SetRateLimitStore(store RateLimitStore) {
+	rateLimitStoreMu.Lock()
+	defer rateLimitStoreMu.Unlock()
+	rateLimitStore = store
+}
+
+// GetRateLimitStore trả về store đang dùng, tạo store trong bộ nhớ nếu chưa cấu hình
+func GetRateLimitStore() RateLimitStore {
+	rateLimitStoreMu.RLock()
+	store := rateLimitStore
+	rateLimitStoreMu.RUnlock()
+	if store != nil {
+		return store
+	}
+
+	rateLimitStoreMu.Lock()
+	defer rateLimitStoreMu.Unlock()
+	if rateLimitStore == nil {
+		rateLimitStore = NewMemoryRateLimitStore(5 * time.Minute)
+	}
+	return rateLimitStore
+}
+
+// ===== KHÓA TẠM THỜI KHI NHẬP SAI NHIỀU LẦN =====
+
+// LockoutPolicy: sai từ Threshold lần trở lên thì khóa BaseDelay, mỗi lần sai tiếp theo gấp đôi, tối đa MaxDelay
+type LockoutPolicy struct {
+	Threshold int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	Window    time.Duration // Quên các lần sai sau khoảng này
+}
+
+// LoginLockoutPolicy đọc cấu hình LOGIN_LOCKOUT_THRESHOLD / LOGIN_LOCKOUT_BASE / LOGIN_LOCKOUT_MAX
+func LoginLockoutPolicy() LockoutPolicy {
+	policy := LockoutPolicy{
+		Threshold: 5,
+		BaseDelay: 30 * time.Second,
+		MaxDelay:  30 * time.Minute,
+		Window:    time.Hour,
+	}
+	if v, err := strconv.Atoi(os.Getenv("LOGIN_LOCKOUT_THRESHOLD")); err == nil && v > 0 {
+		policy.Threshold = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("LOGIN_LOCKOUT_BASE")); err == nil && v > 0 {
+		policy.BaseDelay = v
+	}
+	if v, err := time.ParseDuration(os.Getenv("LOGIN_LOCKOUT_MAX")); err == nil && v >= policy.BaseDelay {
+		policy.MaxDelay = v
+	}
+	return policy
+}
+
+// LockoutRemaining trả về thời gian còn bị khóa của key (0 nếu không bị khóa)
+func LockoutRemaining(key string) time.Duration {
+	now := time.Now()
+	until := GetRateLimitStore().LockedUntil(key, now)
+	if until.IsZero() {
+		return 0
+	}
+	return until.Sub(now)
+}
+
+// RegisterFailure ghi nhận một lần sai, trả về thời gian bị khóa nếu vượt ngưỡng
+func RegisterFailure(key string, policy LockoutPolicy) time.Duration {
+	store := GetRateLimitStore()
+	now := time.Now()
+	failures := store.AddFailure(key, policy.Window, now)
+	if failures < policy.Threshold {
+		return 0
+	}
+
+	delay := policy.BaseDelay
+	for i := policy.Threshold; i < failures && delay < policy.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > policy.MaxDelay {
+		delay = policy.MaxDelay
+	}
+	store.Lock(key, now.Add(delay))
+	return delay
+}
+
+// ClearFailures xóa bộ đếm sai sau khi xác thực thành công
+func ClearFailures(key string) {
+	GetRateLimitStore().ResetFailures(key)
+}
+
+// RetryAfterSeconds làm tròn lên số giây cho header Retry-After
+func RetryAfterSeconds(d time.Duration) int {
+	secs := int(math.Ceil(d.Seconds()))
+	if secs < 1 {
+		secs = 1
+	}
+	return secs
+}
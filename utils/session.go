@@ -0,0 +1,225 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vnkhanh/e-podcast-backend/models"
+	"gorm.io/gorm"
+)
+
+// Refresh token sống 30 ngày, mỗi lần làm mới được xoay vòng và gia hạn lại
+const RefreshTokenTTL = 30 * 24 * time.Hour
+
+var (
+	ErrSessionNotFound = errors.New("Phiên đăng nhập không tồn tại")
+	ErrSessionRevoked  = errors.New("Phiên đăng nhập đã bị thu hồi")
+	ErrSessionExpired  = errors.New("Phiên đăng nhập đã hết hạn")
+	ErrTokenReused     = errors.New("Refresh token đã được sử dụng, phiên đăng nhập bị thu hồi để bảo vệ tài khoản")
+)
+
+// SessionTokens là cặp token trả về cho client
+type SessionTokens struct {
+	AccessToken  string    `json:"token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresIn    int       `json:"expires_in"` // Số giây access token còn hiệu lực
+	SessionID    uuid.UUID `json:"session_id"`
+}
+
+// SessionDevice là thông tin thiết bị lấy từ request
+type SessionDevice struct {
+	UserAgent   string
+	IPAddress   string
+	LoginMethod string
+}
+
+// HashToken băm refresh token để lưu DB
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateRandomToken sinh token ngẫu nhiên 256 bit an toàn để đặt vào link / gửi cho client
+func GenerateRandomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// DescribeDevice rút gọn User-Agent thành tên dễ đọc, ví dụ "Chrome trên Windows"
+func DescribeDevice(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+
+	browser := "Trình duyệt khác"
+	switch {
+	case strings.Contains(ua, "edg/"):
+		browser = "Edge"
+	case strings.Contains(ua, "opr/") || strings.Contains(ua, "opera"):
+		browser = "Opera"
+	case strings.Contains(ua, "chrome/") || strings.Contains(ua, "crios/"):
+		browser = "Chrome"
+	case strings.Contains(ua, "firefox/") || strings.Contains(ua, "fxios/"):
+		browser = "Firefox"
+	case strings.Contains(ua, "safari/"):
+		browser = "Safari"
+	case strings.Contains(ua, "okhttp") || strings.Contains(ua, "dart"):
+		browser = "Ứng dụng"
+	}
+
+	platform := "thiết bị không xác định"
+	switch {
+	case strings.Contains(ua, "iphone") || strings.Contains(ua, "ipad") || strings.Contains(ua, "ios"):
+		platform = "iOS"
+	case strings.Contains(ua, "android"):
+		platform = "Android"
+	case strings.Contains(ua, "windows"):
+		platform = "Windows"
+	case strings.Contains(ua, "mac os"):
+		platform = "macOS"
+	case strings.Contains(ua, "linux"):
+		platform = "Linux"
+	}
+
+	return browser + " trên " + platform
+}
+
+func issueTokens(user models.User, session models.UserSession, refreshToken string) (*SessionTokens, error) {
+	accessToken, err := GenerateToken(user.ID.String(), string(user.Role), session.ID.String())
+	if err != nil {
+		return nil, err
+	}
+	return &SessionTokens{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(AccessTokenTTL.Seconds()),
+		SessionID:    session.ID,
+	}, nil
+}
+
+// CreateSession tạo phiên đăng nhập mới và cấp cặp token
+func CreateSession(db *gorm.DB, user models.User, device SessionDevice) (*SessionTokens, error) {
+	refreshToken, err := GenerateRandomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session := models.UserSession{
+		UserID:           user.ID,
+		RefreshTokenHash: HashToken(refreshToken),
+		DeviceName:       DescribeDevice(device.UserAgent),
+		UserAgent:        device.UserAgent,
+		IPAddress:        device.IPAddress,
+		LoginMethod:      device.LoginMethod,
+		ExpiresAt:        now.Add(RefreshTokenTTL),
+		LastUsedAt:       now,
+	}
+	if err := db.Create(&session).Error; err != nil {
+		return nil, err
+	}
+	return issueTokens(user, session, refreshToken)
+}
+
+// RotateSession đổi refresh token cũ lấy cặp token mới.
+// Token cũ bị dùng lại sau khi đã xoay nghĩa là có thể đã lộ: thu hồi luôn phiên đó.
+func RotateSession(db *gorm.DB, refreshToken string, ipAddress string) (*SessionTokens, *models.User, error) {
+	hash := HashToken(refreshToken)
+	now := time.Now()
+
+	var session models.UserSession
+	if err := db.Where("refresh_token_hash = ?", hash).First(&session).Error; err != nil {
+		if db.Where("previous_token_hash = ? AND revoked_at IS NULL", hash).First(&session).Error == nil {
+			RevokeSession(db, session.ID, models.SessionRevokedTokenReuse)
+			return nil, nil, ErrTokenReused
+		}
+		return nil, nil, ErrSessionNotFound
+	}
+	if session.RevokedAt != nil {
+		return nil, nil, ErrSessionRevoked
+	}
+	if now.After(session.ExpiresAt) {
+		return nil, nil, ErrSessionExpired
+	}
+
+	var user models.User
+	if err := db.First(&user, "id = ?", session.UserID).Error; err != nil {
+		return nil, nil, ErrSessionNotFound
+	}
+	if user.Status != nil && !*user.Status {
+		RevokeSession(db, session.ID, models.SessionRevokedAccountLocked)
+		return nil, &user, ErrSessionRevoked
+	}
+
+	newToken, err := GenerateRandomToken()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Điều kiện theo hash cũ để hai request làm mới đồng thời không cùng thành công
+	res := db.Model(&models.UserSession{}).
+		Where("id = ? AND refresh_token_hash = ? AND revoked_at IS NULL", session.ID, hash).
+		Updates(map[string]interface{}{
+			"refresh_token_hash":  HashToken(newToken),
+			"previous_token_hash": hash,
+			"expires_at":          now.Add(RefreshTokenTTL),
+			"last_used_at":        now,
+			"ip_address":          ipAddress,
+		})
+	if res.Error != nil {
+		return nil, nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, nil, ErrTokenReused
+	}
+
+	tokens, err := issueTokens(user, session, newToken)
+	return tokens, &user, err
+}
+
+// ValidateSession kiểm tra phiên của access token còn hiệu lực
+func ValidateSession(db *gorm.DB, sessionID string, userID string) error {
+	var session models.UserSession
+	if err := db.Select("id", "user_id", "expires_at", "revoked_at").
+		First(&session, "id = ?", sessionID).Error; err != nil {
+		return ErrSessionNotFound
+	}
+	if session.UserID.String() != userID {
+		return ErrSessionNotFound
+	}
+	if session.RevokedAt != nil {
+		return ErrSessionRevoked
+	}
+	if time.Now().After(session.ExpiresAt) {
+		return ErrSessionExpired
+	}
+	return nil
+}
+
+// RevokeSession thu hồi một phiên
+func RevokeSession(db *gorm.DB, sessionID uuid.UUID, reason string) error {
+	return db.Model(&models.UserSession{}).
+		Where("id = ? AND revoked_at IS NULL", sessionID).
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "revoke_reason": reason}).Error
+}
+
+// RevokeUserSessions thu hồi mọi phiên của user (trừ keepSessionID nếu có)
+// và chặn luôn các token cũ không gắn phiên.
+func RevokeUserSessions(db *gorm.DB, userID uuid.UUID, reason string, keepSessionID string) error {
+	now := time.Now()
+	query := db.Model(&models.UserSession{}).Where("user_id = ? AND revoked_at IS NULL", userID)
+	if keepSessionID != "" {
+		query = query.Where("id <> ?", keepSessionID)
+	}
+	if err := query.Updates(map[string]interface{}{"revoked_at": now, "revoke_reason": reason}).Error; err != nil {
+		return err
+	}
+	return db.Model(&models.User{}).Where("id = ?", userID).Update("sessions_revoked_at", now).Error
+}
@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"log"
+	"os"
+	"sync"
+	"time"
+	_ "time/tzdata" // Nhúng dữ liệu múi giờ, không phụ thuộc tzdata của máy chủ
+)
+
+// ==================== MÚI GIỜ CỦA TRƯỜNG (CHIA NGÀY THỐNG KÊ) ====================
+
+const defaultInstitutionTZ = "Asia/Ho_Chi_Minh"
+
+var (
+	institutionLoc  *time.Location
+	institutionOnce sync.Once
+)
+
+// InstitutionLocation là múi giờ dùng để chia ngày thống kê, đọc từ env INSTITUTION_TZ (mặc định Asia/Ho_Chi_Minh)
+func InstitutionLocation() *time.Location {
+	institutionOnce.Do(func() {
+		name := os.Getenv("INSTITUTION_TZ")
+		if name == "" {
+			name = defaultInstitutionTZ
+		}
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			log.Printf("Không thể load INSTITUTION_TZ %s: %v — dùng %s\n", name, err, defaultInstitutionTZ)
+			loc, _ = time.LoadLocation(defaultInstitutionTZ)
+		}
+		institutionLoc = loc
+	})
+	return institutionLoc
+}
+
+// SetInstitutionLocation đặt múi giờ thống kê (dùng cho lệnh backfill với --tz)
+func SetInstitutionLocation(loc *time.Location) {
+	institutionOnce.Do(func() {})
+	institutionLoc = loc
+}
+
+// DayIn là 00:00 của ngày chứa t theo múi giờ loc.
+// Giá trị ghi vào cột date là đúng ngày đó vì driver lấy năm / tháng / ngày theo múi giờ của time.
+func DayIn(t time.Time, loc *time.Location) time.Time {
+	y, m, d := t.In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, loc)
+}
+
+// AnalyticsDay là ngày thống kê của thời điểm t theo múi giờ của trường
+func AnalyticsDay(t time.Time) time.Time {
+	return DayIn(t, InstitutionLocation())
+}
@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAnalyticsDay(t *testing.T) {
+	hcm, err := time.LoadLocation("Asia/Ho_Chi_Minh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetInstitutionLocation(hcm)
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want string
+	}{
+		{"local 23:59", time.Date(2025, 3, 10, 23, 59, 59, 0, hcm), "2025-03-10"},
+		{"local 00:00", time.Date(2025, 3, 11, 0, 0, 0, 0, hcm), "2025-03-11"},
+		{"UTC 16:59 is local 23:59", time.Date(2025, 3, 10, 16, 59, 59, 0, time.UTC), "2025-03-10"},
+		{"UTC 17:00 is local 00:00 next day", time.Date(2025, 3, 10, 17, 0, 0, 0, time.UTC), "2025-03-11"},
+		{"UTC 23:59 is local morning next day", time.Date(2025, 3, 10, 23, 59, 0, 0, time.UTC), "2025-03-11"},
+		{"UTC 00:00 is local 07:00 same day", time.Date(2025, 3, 11, 0, 0, 0, 0, time.UTC), "2025-03-11"},
+		{"year boundary", time.Date(2024, 12, 31, 17, 30, 0, 0, time.UTC), "2025-01-01"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := AnalyticsDay(tt.at)
+			if got.Format("2006-01-02") != tt.want {
+				t.Errorf("AnalyticsDay(%s) = %s, want %s", tt.at, got.Format("2006-01-02"), tt.want)
+			}
+			if got.Location() != hcm || got.Hour() != 0 || got.Minute() != 0 || got.Second() != 0 {
+				t.Errorf("AnalyticsDay(%s) = %s, want local midnight", tt.at, got)
+			}
+		})
+	}
+}
+
+func TestDayInUTC(t *testing.T) {
+	tests := []struct {
+		at   time.Time
+		want string
+	}{
+		{time.Date(2025, 3, 10, 23, 59, 59, 0, time.UTC), "2025-03-10"},
+		{time.Date(2025, 3, 11, 0, 0, 0, 0, time.UTC), "2025-03-11"},
+		{time.Date(2025, 3, 11, 6, 59, 0, 0, time.FixedZone("ICT", 7*3600)), "2025-03-10"},
+	}
+	for _, tt := range tests {
+		if got := DayIn(tt.at, time.UTC).Format("2006-01-02"); got != tt.want {
+			t.Errorf("DayIn(%s, UTC) = %s, want %s", tt.at, got, tt.want)
+		}
+	}
+}